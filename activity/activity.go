@@ -0,0 +1,29 @@
+package activity
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Entry is a change made to a file, as shown in the activity feed of the
+// directories containing it.
+type Entry struct {
+	ID          int       `json:"id" storm:"id,increment"`
+	Type        string    `json:"type"`
+	Path        string    `json:"path"` // full path on the server
+	Destination string    `json:"destination,omitempty"`
+	User        string    `json:"user,omitempty"` // empty for the changes made out of File Browser
+	Time        time.Time `json:"time" storm:"index"`
+}
+
+// Within reports whether the entry concerns the directory or something in
+// it.
+func (e *Entry) Within(dir string) bool {
+	return within(e.Path, dir) || (e.Destination != "" && within(e.Destination, dir))
+}
+
+// within reports whether p is dir or is inside it.
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,97 @@
+package activity
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// Retention is how long the entries are kept.
+const Retention = 90 * 24 * time.Hour
+
+// pruneInterval is how often the old entries are removed.
+const pruneInterval = time.Hour
+
+// feedPage is the number of entries read at once to build a feed.
+const feedPage = 500
+
+// StorageBackend is the interface to implement for an activity storage.
+type StorageBackend interface {
+	// Latest returns the entries from the newest, skipping the first ones.
+	Latest(skip, limit int) ([]*Entry, error)
+	Save(e *Entry) error
+	DeleteBefore(t time.Time) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// NewStorage creates an activity storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Save saves the entry, removing the entries older than the retention from
+// time to time.
+func (s *Storage) Save(e *Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if err := s.back.Save(e); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	prune := time.Since(s.lastPruned) >= pruneInterval
+	if prune {
+		s.lastPruned = time.Now()
+	}
+	s.mu.Unlock()
+
+	if prune {
+		if err := s.back.DeleteBefore(time.Now().Add(-Retention)); err != nil {
+			log.Printf("WARNING: couldn't remove the old activity: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Feed returns, from the newest, at most limit entries concerning the
+// directory and accepted by keep, which can change them.
+func (s *Storage) Feed(dir string, limit int, keep func(e *Entry) bool) ([]*Entry, error) {
+	feed := []*Entry{}
+
+	for skip := 0; len(feed) < limit; skip += feedPage {
+		page, err := s.back.Latest(skip, feedPage)
+		if errors.Is(err, fberrors.ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, e := range page {
+			if e.Within(dir) && keep(e) {
+				feed = append(feed, e)
+				if len(feed) == limit {
+					break
+				}
+			}
+		}
+
+		if len(page) < feedPage {
+			break
+		}
+	}
+
+	return feed, nil
+}
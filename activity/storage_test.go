@@ -0,0 +1,65 @@
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryBackend struct {
+	entries []*Entry
+}
+
+func (b *memoryBackend) Latest(skip, limit int) ([]*Entry, error) {
+	list := []*Entry{}
+	for i := len(b.entries) - 1 - skip; i >= 0 && len(list) < limit; i-- {
+		e := *b.entries[i]
+		list = append(list, &e)
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Save(e *Entry) error {
+	e.ID = len(b.entries) + 1
+	b.entries = append(b.entries, e)
+	return nil
+}
+
+func (b *memoryBackend) DeleteBefore(t time.Time) error {
+	kept := []*Entry{}
+	for _, e := range b.entries {
+		if !e.Time.Before(t) {
+			kept = append(kept, e)
+		}
+	}
+	b.entries = kept
+	return nil
+}
+
+func TestFeed(t *testing.T) {
+	back := &memoryBackend{}
+	s := NewStorage(back)
+
+	require.NoError(t, s.Save(&Entry{Type: "uploaded", Path: "/srv/old.txt", Time: time.Now().Add(-2 * Retention)}))
+	require.NoError(t, s.Save(&Entry{Type: "uploaded", Path: "/srv/docs/a.txt"}))
+	require.NoError(t, s.Save(&Entry{Type: "uploaded", Path: "/srv/docs/secret.txt"}))
+	require.NoError(t, s.Save(&Entry{Type: "renamed", Path: "/srv/b.txt", Destination: "/srv/docs/b.txt"}))
+	require.NoError(t, s.Save(&Entry{Type: "deleted", Path: "/srv/docs2/c.txt"}))
+
+	// the first entry was too old
+	require.Len(t, back.entries, 4)
+
+	feed, err := s.Feed("/srv/docs", 10, func(e *Entry) bool {
+		return e.Path != "/srv/docs/secret.txt"
+	})
+	require.NoError(t, err)
+	require.Len(t, feed, 2)
+	require.Equal(t, "renamed", feed[0].Type)
+	require.Equal(t, "/srv/docs/a.txt", feed[1].Path)
+
+	feed, err = s.Feed("/srv", 1, func(*Entry) bool { return true })
+	require.NoError(t, err)
+	require.Len(t, feed, 1)
+	require.Equal(t, "deleted", feed[0].Type)
+}
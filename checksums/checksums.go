@@ -0,0 +1,30 @@
+package checksums
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Record is the checksum of a file computed when it was uploaded, to detect
+// its later corruption.
+type Record struct {
+	Path     string    `json:"path" storm:"id"` // full path on the server
+	Algo     string    `json:"algo"`
+	Sum      string    `json:"sum"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"` // of the file when the sum was computed
+	Created  time.Time `json:"created"`
+}
+
+// Current reports whether the file still has the size and the
+// modification time it had when the sum was computed, so that a different
+// content can only come from its corruption.
+func (r *Record) Current(size int64, modified time.Time) bool {
+	return r.Size == size && r.Modified.Equal(modified)
+}
+
+// within reports whether p is dir or is inside it.
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
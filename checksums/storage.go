@@ -0,0 +1,97 @@
+package checksums
+
+import (
+	"errors"
+	"sync"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// StorageBackend is the interface to implement for a checksums storage.
+type StorageBackend interface {
+	All() ([]*Record, error)
+	Get(path string) (*Record, error)
+	Save(r *Record) error
+	Delete(path string) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+	mu   sync.Mutex
+}
+
+// NewStorage creates a checksums storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(path string) (*Record, error) {
+	return s.back.Get(path)
+}
+
+// Save wraps a StorageBackend.Save.
+func (s *Storage) Save(r *Record) error {
+	return s.back.Save(r)
+}
+
+// Within returns the records of the path and of everything in it.
+func (s *Storage) Within(path string) ([]*Record, error) {
+	all, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return nil, err
+	}
+
+	list := []*Record{}
+	for _, r := range all {
+		if within(r.Path, path) {
+			list = append(list, r)
+		}
+	}
+	return list, nil
+}
+
+// Move moves the records of the path and of everything in it to their new
+// path, as when it is renamed.
+func (s *Storage) Move(src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.Within(src)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range list {
+		if err := s.back.Delete(r.Path); err != nil {
+			return err
+		}
+		r.Path = dst + r.Path[len(src):]
+		if err := s.back.Save(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteWithin removes the records of the path and of everything in it, as
+// when it is deleted.
+func (s *Storage) DeleteWithin(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.Within(path)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range list {
+		if err := s.back.Delete(r.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
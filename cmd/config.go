@@ -7,12 +7,14 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
 	"github.com/filebrowser/filebrowser/v2/auth"
 	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/settings"
 )
 
@@ -36,6 +38,9 @@ func addConfigFlags(flags *pflag.FlagSet) {
 	flags.Bool("createUserDir", false, "generate user's home directory automatically")
 	flags.Uint("minimumPasswordLength", settings.DefaultMinimumPasswordLength, "minimum password length for new users")
 	flags.String("shell", "", "shell command to which other commands should be appended")
+	flags.String("commandTimeout", settings.DefaultCommandTimeout, "time after which the blocking commands of the hooks, the scheduled commands and the commands of the command line are killed")
+	flags.Bool("stripGPSOnShare", false, "remove GPS data from images downloaded through public shares, refusing the images it can't be removed from")
+	flags.String("uploadChecksum", "", "checksum algorithm (md5, sha1, sha256, sha512 or blake3) of the checksums stored for the uploads, none if empty")
 
 	// NB: these are string so they can be presented as octal in the help text
 	// as that's the conventional representation for modes in Unix.
@@ -60,6 +65,21 @@ func addConfigFlags(flags *pflag.FlagSet) {
 
 	flags.Uint64("tus.chunkSize", settings.DefaultTusChunkSize, "the tus chunk size")
 	flags.Uint16("tus.retryCount", settings.DefaultTusRetryCount, "the tus retry count")
+	flags.String("tus.expiration", settings.DefaultTusExpiration, "inactivity after which an incomplete upload is removed")
+
+	flags.Uint64("extract.maxSize", settings.DefaultExtractMaxSize, "maximum total uncompressed size when extracting an archive")
+	flags.Uint64("extract.maxEntries", settings.DefaultExtractMaxEntries, "maximum number of entries when extracting an archive")
+	flags.Uint64("extract.maxRatio", settings.DefaultExtractMaxRatio, "maximum compression ratio when extracting an archive")
+
+	flags.Bool("sandbox.cleanEnv", false, "run the commands with PATH, LANG and the variables of sandbox.env only")
+	flags.StringSlice("sandbox.env", nil, "variables of the environment kept with sandbox.cleanEnv")
+	flags.Uint64("sandbox.cpuTime", 0, "seconds of CPU time the commands can use, unlimited if 0 (Linux only)")
+	flags.Uint64("sandbox.memory", 0, "bytes of memory the commands can use, unlimited if 0 (Linux only)")
+	flags.Uint64("sandbox.maxOutput", settings.DefaultSandboxMaxOutput, "bytes of output of a command of the command line after which it is stopped")
+	flags.String("sandbox.wrapper", "", "command the commands are run by, such as bwrap or nsjail")
+
+	flags.StringSlice("fetch.allowedHosts", nil, "hosts, IP addresses or CIDR ranges URLs can be fetched from, any public host if empty")
+	flags.StringSlice("fetch.deniedHosts", nil, "hosts, IP addresses or CIDR ranges URLs can't be fetched from")
 }
 
 func getAuthMethod(flags *pflag.FlagSet, defaults ...interface{}) (settings.AuthMethod, map[string]interface{}, error) {
@@ -206,6 +226,9 @@ func printSettings(ser *settings.Server, set *settings.Settings, auther auth.Aut
 	fmt.Fprintf(w, "Minimum Password Length:\t%d\n", set.MinimumPasswordLength)
 	fmt.Fprintf(w, "Auth Method:\t%s\n", set.AuthMethod)
 	fmt.Fprintf(w, "Shell:\t%s\t\n", strings.Join(set.Shell, " "))
+	fmt.Fprintf(w, "Command Timeout:\t%s\n", set.CommandTimeout)
+	fmt.Fprintf(w, "Strip GPS On Share:\t%t\n", set.StripGPSOnShare)
+	fmt.Fprintf(w, "Upload Checksum:\t%s\n", set.UploadChecksum)
 
 	fmt.Fprintln(w, "\nBranding:")
 	fmt.Fprintf(w, "\tName:\t%s\n", set.Branding.Name)
@@ -229,10 +252,39 @@ func printSettings(ser *settings.Server, set *settings.Settings, auther auth.Aut
 	fmt.Fprintf(w, "\tThumbnails Enabled:\t%t\n", ser.EnableThumbnails)
 	fmt.Fprintf(w, "\tResize Preview:\t%t\n", ser.ResizePreview)
 	fmt.Fprintf(w, "\tType Detection by Header:\t%t\n", ser.TypeDetectionByHeader)
+	fmt.Fprintf(w, "\tFFmpeg Path:\t%s\n", ser.FFmpegPath)
 
 	fmt.Fprintln(w, "\nTUS:")
 	fmt.Fprintf(w, "\tChunk size:\t%d\n", set.Tus.ChunkSize)
 	fmt.Fprintf(w, "\tRetry count:\t%d\n", set.Tus.RetryCount)
+	fmt.Fprintf(w, "\tExpiration:\t%s\n", set.Tus.Expiration)
+
+	fmt.Fprintln(w, "\nExtract:")
+	fmt.Fprintf(w, "\tMax size:\t%d\n", set.Extract.MaxSize)
+	fmt.Fprintf(w, "\tMax entries:\t%d\n", set.Extract.MaxEntries)
+	fmt.Fprintf(w, "\tMax ratio:\t%d\n", set.Extract.MaxRatio)
+
+	fmt.Fprintln(w, "\nSandbox:")
+	fmt.Fprintf(w, "\tClean environment:\t%t\n", set.Sandbox.CleanEnv)
+	fmt.Fprintf(w, "\tEnvironment:\t%s\n", strings.Join(set.Sandbox.Env, " "))
+	fmt.Fprintf(w, "\tCPU time:\t%d\n", set.Sandbox.CPUTime)
+	fmt.Fprintf(w, "\tMemory:\t%d\n", set.Sandbox.Memory)
+	fmt.Fprintf(w, "\tMax output:\t%d\n", set.Sandbox.MaxOutput)
+	fmt.Fprintf(w, "\tWrapper:\t%s\n", strings.Join(set.Sandbox.Wrapper, " "))
+
+	fmt.Fprintln(w, "\nFetch:")
+	fmt.Fprintf(w, "\tAllowed hosts:\t%s\n", strings.Join(set.Fetch.AllowedHosts, " "))
+	fmt.Fprintf(w, "\tDenied hosts:\t%s\n", strings.Join(set.Fetch.DeniedHosts, " "))
+
+	fmt.Fprintln(w, "\nSchedules:")
+	for _, sch := range set.Schedules {
+		fmt.Fprintf(w, "\t%s:\t%s as %s in %s: %s\n", sch.Name, sch.Cron, sch.Username, sch.Path, sch.Command)
+	}
+
+	fmt.Fprintln(w, "\nWebhooks:")
+	for _, hook := range set.Webhooks {
+		fmt.Fprintf(w, "\t%s:\t%s %s %s\n", hook.Name, hook.URL, strings.Join(hook.Events, ","), hook.Path)
+	}
 
 	fmt.Fprintln(w, "\nDefaults:")
 	fmt.Fprintf(w, "\tScope:\t%s\n", set.Defaults.Scope)
@@ -308,6 +360,8 @@ func getSettings(flags *pflag.FlagSet, set *settings.Settings, ser *settings.Ser
 		case "disableTypeDetectionByHeader":
 			ser.TypeDetectionByHeader, err = flags.GetBool(flag.Name)
 			ser.TypeDetectionByHeader = !ser.TypeDetectionByHeader
+		case "ffmpegPath":
+			ser.FFmpegPath, err = flags.GetString(flag.Name)
 
 		// Settings flags from [addConfigFlags]
 		case "signup":
@@ -324,6 +378,18 @@ func getSettings(flags *pflag.FlagSet, set *settings.Settings, ser *settings.Ser
 			if err == nil {
 				set.Shell = convertCmdStrToCmdArray(shell)
 			}
+		case "commandTimeout":
+			set.CommandTimeout, err = flags.GetString(flag.Name)
+			if err == nil {
+				_, err = time.ParseDuration(set.CommandTimeout)
+			}
+		case "stripGPSOnShare":
+			set.StripGPSOnShare, err = flags.GetBool(flag.Name)
+		case "uploadChecksum":
+			set.UploadChecksum, err = flags.GetString(flag.Name)
+			if err == nil && set.UploadChecksum != "" {
+				_, err = files.NewHash(set.UploadChecksum)
+			}
 		case "fileMode":
 			set.FileMode, err = getAndParseFileMode(flags, flag.Name)
 		case "dirMode":
@@ -348,6 +414,37 @@ func getSettings(flags *pflag.FlagSet, set *settings.Settings, ser *settings.Ser
 			set.Tus.ChunkSize, err = flags.GetUint64(flag.Name)
 		case "tus.retryCount":
 			set.Tus.RetryCount, err = flags.GetUint16(flag.Name)
+		case "tus.expiration":
+			set.Tus.Expiration, err = flags.GetString(flag.Name)
+			if err == nil {
+				_, err = time.ParseDuration(set.Tus.Expiration)
+			}
+		case "extract.maxSize":
+			set.Extract.MaxSize, err = flags.GetUint64(flag.Name)
+		case "extract.maxEntries":
+			set.Extract.MaxEntries, err = flags.GetUint64(flag.Name)
+		case "extract.maxRatio":
+			set.Extract.MaxRatio, err = flags.GetUint64(flag.Name)
+		case "sandbox.cleanEnv":
+			set.Sandbox.CleanEnv, err = flags.GetBool(flag.Name)
+		case "sandbox.env":
+			set.Sandbox.Env, err = flags.GetStringSlice(flag.Name)
+		case "sandbox.cpuTime":
+			set.Sandbox.CPUTime, err = flags.GetUint64(flag.Name)
+		case "sandbox.memory":
+			set.Sandbox.Memory, err = flags.GetUint64(flag.Name)
+		case "sandbox.maxOutput":
+			set.Sandbox.MaxOutput, err = flags.GetUint64(flag.Name)
+		case "sandbox.wrapper":
+			var wrapper string
+			wrapper, err = flags.GetString(flag.Name)
+			if err == nil {
+				set.Sandbox.Wrapper = convertCmdStrToCmdArray(wrapper)
+			}
+		case "fetch.allowedHosts":
+			set.Fetch.AllowedHosts, err = flags.GetStringSlice(flag.Name)
+		case "fetch.deniedHosts":
+			set.Fetch.DeniedHosts, err = flags.GetStringSlice(flag.Name)
 		}
 
 		if err != nil {
@@ -27,8 +27,10 @@ import (
 	"github.com/filebrowser/filebrowser/v2/frontend"
 	fbhttp "github.com/filebrowser/filebrowser/v2/http"
 	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/jobs"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/transcode"
 	"github.com/filebrowser/filebrowser/v2/users"
 )
 
@@ -89,6 +91,8 @@ func init() {
 	flags.Uint32("socketPerm", 0666, "unix socket file permissions")
 	flags.String("cacheDir", "", "file cache directory (disabled if empty)")
 	flags.Int("imageProcessors", 4, "image processors count")
+	flags.Int("transcodeProcessors", 2, "concurrent video transcoding processes")
+	flags.Int("jobWorkers", 2, "concurrent background jobs")
 	flags.String("defaults.locale", "zh-cn", "default locale for new users (e.g. en, zh-cn, zh-tw)")
 	addServerFlags(flags)
 }
@@ -109,6 +113,7 @@ func addServerFlags(flags *pflag.FlagSet) {
 	flags.Bool("disablePreviewResize", false, "disable resize of image previews")
 	flags.Bool("disableExec", true, "disables Command Runner feature")
 	flags.Bool("disableTypeDetectionByHeader", false, "disables type detection by reading file headers")
+	flags.String("ffmpegPath", "", "ffmpeg binary used to stream videos as HLS (disabled if empty)")
 }
 
 var rootCmd = &cobra.Command{
@@ -183,6 +188,21 @@ user created with the credentials from options "username" and "password".`,
 		}
 		server.Root = root
 
+		transcodeWorkersCount := v.GetInt("transcodeProcessors")
+		if transcodeWorkersCount < 1 {
+			return errors.New("transcode processors count could not be < 1")
+		}
+		transcoder := transcode.New(server.FFmpegPath, transcodeWorkersCount)
+
+		jobWorkersCount := v.GetInt("jobWorkers")
+		if jobWorkersCount < 1 {
+			return errors.New("job workers count could not be < 1")
+		}
+		jobManager, err := jobs.NewManager(st.Storage.Jobs, jobWorkersCount)
+		if err != nil {
+			return err
+		}
+
 		adr := server.Address + ":" + server.Port
 
 		var listener net.Listener
@@ -222,12 +242,16 @@ user created with the credentials from options "username" and "password".`,
 			panic(err)
 		}
 
-		handler, err := fbhttp.NewHandler(imageService, fileCache, st.Storage, server, assetsFs)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		handler, err := fbhttp.NewHandler(ctx, imageService, transcoder, jobManager, fileCache, st.Storage, server, assetsFs)
 		if err != nil {
 			return err
 		}
 
 		defer listener.Close()
+		go fbhttp.ExpireUploads(ctx, st.Storage)
 
 		log.Println("Listening on", listener.Addr().String())
 		srv := &http.Server{
@@ -332,6 +356,10 @@ func getServerSettings(v *viper.Viper, st *storage.Storage) (*settings.Server, e
 		server.TypeDetectionByHeader = !v.GetBool("disableTypeDetectionByHeader")
 	}
 
+	if v.IsSet("ffmpegPath") {
+		server.FFmpegPath = v.GetString("ffmpegPath")
+	}
+
 	if v.IsSet("disableExec") {
 		server.EnableExec = !v.GetBool("disableExec")
 	}
@@ -404,10 +432,18 @@ func quickSetup(v *viper.Viper, s *storage.Storage) error {
 		Tus: settings.Tus{
 			ChunkSize:  settings.DefaultTusChunkSize,
 			RetryCount: settings.DefaultTusRetryCount,
+			Expiration: settings.DefaultTusExpiration,
+		},
+		Extract: settings.Extract{
+			MaxSize:    settings.DefaultExtractMaxSize,
+			MaxEntries: settings.DefaultExtractMaxEntries,
+			MaxRatio:   settings.DefaultExtractMaxRatio,
 		},
-		Commands: nil,
-		Shell:    nil,
-		Rules:    nil,
+		Commands:       nil,
+		CommandTimeout: settings.DefaultCommandTimeout,
+		Sandbox:        settings.Sandbox{MaxOutput: settings.DefaultSandboxMaxOutput},
+		Shell:          nil,
+		Rules:          nil,
 	}
 
 	var err error
@@ -440,6 +476,7 @@ func quickSetup(v *viper.Viper, s *storage.Storage) error {
 		ResizePreview:         !v.GetBool("disablePreviewResize"),
 		EnableExec:            !v.GetBool("disableExec"),
 		TypeDetectionByHeader: !v.GetBool("disableTypeDetectionByHeader"),
+		FFmpegPath:            v.GetString("ffmpegPath"),
 	}
 
 	err = s.Settings.SaveServer(ser)
@@ -0,0 +1,626 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/filebrowser/filebrowser/v2/delta"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+)
+
+const (
+	// syncStateName is the file, in the local directory, keeping the state
+	// of the last sync.
+	syncStateName = ".filebrowser-sync.json"
+	// syncTempPrefix is the prefix of the files being downloaded.
+	syncTempPrefix = ".filebrowser-sync-"
+	// syncRounds is how many times the changes are listed and applied,
+	// until there are none left on either side.
+	syncRounds = 3
+	// syncPasswordEnv is the environment variable holding the password.
+	syncPasswordEnv = "FB_SYNC_PASSWORD"
+)
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	flags := syncCmd.Flags()
+	flags.String("server", "http://127.0.0.1:8080", "url of the server, with its base url")
+	flags.String("username", "admin", "username to log in with")
+	flags.String("password-file", "", "file holding the password to log in with")
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <local dir> <remote dir>",
+	Short: "Synchronize a local directory with a directory on a server",
+	Long: `Synchronize a local directory with a directory on a server in both
+directions, transferring the files created, changed or deleted on either
+side since the last sync.
+
+The state of the last sync is kept in ` + syncStateName + ` in the local
+directory. When a file was changed on both sides, the remote version is
+kept under its name and the local one is renamed to a conflict copy, such
+as "report(1).txt", which is then uploaded too. A file changed on one side
+is restored when it was deleted on the other.
+
+The password is read from the file given by "--password-file", else from
+the ` + syncPasswordEnv + ` environment variable, else it's asked for when
+the input is a terminal.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		server, err := flags.GetString("server")
+		if err != nil {
+			return err
+		}
+		username, err := flags.GetString("username")
+		if err != nil {
+			return err
+		}
+		password, err := syncPassword(cmd)
+		if err != nil {
+			return err
+		}
+
+		client, err := newSyncClient(server)
+		if err != nil {
+			return err
+		}
+		if err := client.login(username, password); err != nil {
+			return err
+		}
+
+		local, err := filepath.Abs(args[0])
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(local, 0755); err != nil {
+			return err
+		}
+
+		s := &syncer{
+			client: client,
+			fs:     afero.NewBasePathFs(afero.NewOsFs(), local),
+			remote: path.Clean("/" + args[1]),
+			out:    cmd.OutOrStdout(),
+		}
+		if err := s.load(server); err != nil {
+			return err
+		}
+		return s.run()
+	},
+}
+
+// syncPassword returns the password from the password file, from the
+// environment or from a prompt, so that it doesn't show in the arguments.
+func syncPassword(cmd *cobra.Command) (string, error) {
+	file, err := cmd.Flags().GetString("password-file")
+	if err != nil {
+		return "", err
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	if password, ok := os.LookupEnv(syncPasswordEnv); ok {
+		return password, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no password given: use --password-file or set %s", syncPasswordEnv)
+	}
+	fmt.Fprint(cmd.ErrOrStderr(), "Password: ")
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(cmd.ErrOrStderr())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// syncEntry is a file or a directory as it was on both sides after the
+// last sync.
+type syncEntry struct {
+	delta.Entry        // with the modification time of the local file
+	ETag        string `json:"etag,omitempty"` // of the remote file
+}
+
+type syncState struct {
+	Server string               `json:"server"`
+	Remote string               `json:"remote"`
+	Cursor string               `json:"cursor"`
+	Files  map[string]syncEntry `json:"files"` // by path relative to the directories
+}
+
+// syncer synchronizes a local directory with a remote one, by comparing
+// the changes made on each side since the last sync.
+type syncer struct {
+	client *syncClient
+	fs     afero.Fs // the local directory
+	remote string
+	state  *syncState
+	out    io.Writer
+}
+
+// load reads the state of the last sync, starting over when there isn't
+// one for the same remote directory.
+func (s *syncer) load(server string) error {
+	s.state = &syncState{Server: server, Remote: s.remote, Files: map[string]syncEntry{}}
+
+	b, err := afero.ReadFile(s.fs, "/"+syncStateName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state := &syncState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return fmt.Errorf("couldn't read %s: %w", syncStateName, err)
+	}
+	if state.Server == server && state.Remote == s.remote && state.Files != nil {
+		s.state = state
+	}
+	return nil
+}
+
+func (s *syncer) save() error {
+	b, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+
+	tmp := "/" + syncTempPrefix + "state"
+	if err := afero.WriteFile(s.fs, tmp, b, 0600); err != nil {
+		return err
+	}
+	return s.fs.Rename(tmp, "/"+syncStateName)
+}
+
+func (s *syncer) run() error {
+	for range syncRounds {
+		res, err := s.client.delta(s.remote, s.state.Cursor)
+		if err != nil {
+			return err
+		}
+
+		remote := s.remoteChanges(res)
+		local, err := s.localChanges()
+		if err != nil {
+			return err
+		}
+
+		if len(remote) == 0 && len(local) == 0 {
+			s.state.Cursor = res.Cursor
+			return s.save()
+		}
+
+		// The cursor is only moved once the remote changes are applied,
+		// while the state records the changes applied so far.
+		err = s.apply(local, remote)
+		if err == nil {
+			s.state.Cursor = res.Cursor
+		}
+		if saveErr := s.save(); err == nil {
+			err = saveErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remoteChanges returns the remote changes which differ from the state.
+func (s *syncer) remoteChanges(res *remoteDelta) map[string]remoteChange {
+	changes := map[string]remoteChange{}
+	listed := map[string]bool{}
+	for _, c := range res.Changes {
+		if isSyncFile(c.Path) {
+			continue
+		}
+		listed[c.Path] = true
+
+		known, ok := s.state.Files[c.Path]
+		switch {
+		case c.Deleted && !ok:
+		case !c.Deleted && ok && c.Dir && known.Dir:
+		case !c.Deleted && ok && !c.Dir && !known.Dir && c.Sha256 == known.Sha256:
+			// Uploaded by the last sync, or changed back.
+			known.ETag = c.ETag
+			s.state.Files[c.Path] = known
+		default:
+			changes[c.Path] = c
+		}
+	}
+
+	// Without the previous snapshot, the whole tree is listed, and what
+	// isn't there anymore was deleted.
+	if res.Reset {
+		for p, known := range s.state.Files {
+			if !listed[p] {
+				changes[p] = remoteChange{Change: delta.Change{Path: p, Deleted: true, Entry: delta.Entry{Dir: known.Dir}}}
+			}
+		}
+	}
+
+	return changes
+}
+
+// localChanges returns the local files and directories which differ from
+// the state, hashing the files whose size or modification time changed.
+func (s *syncer) localChanges() (map[string]delta.Change, error) {
+	changes := map[string]delta.Change{}
+	seen := map[string]bool{}
+
+	err := afero.Walk(s.fs, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		p = filepath.ToSlash(p)
+		if p == "/" {
+			return nil
+		}
+		if isSyncFile(p) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		known, ok := s.state.Files[p]
+		switch {
+		case info.IsDir():
+			seen[p] = true
+			if !ok || !known.Dir {
+				changes[p] = delta.Change{Path: p, Entry: delta.Entry{Dir: true}}
+			}
+			return nil
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		seen[p] = true
+		e := delta.Entry{Size: info.Size(), Modified: info.ModTime()}
+		if ok && known.Unchanged(e) {
+			return nil
+		}
+
+		if e.Sha256, err = s.localSha256(p); err != nil {
+			return err
+		}
+		if ok && !known.Dir && known.Sha256 == e.Sha256 {
+			// Only touched.
+			known.Modified = e.Modified
+			s.state.Files[p] = known
+			return nil
+		}
+
+		changes[p] = delta.Change{Path: p, Entry: e}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for p, known := range s.state.Files {
+		if !seen[p] {
+			changes[p] = delta.Change{Path: p, Deleted: true, Entry: delta.Entry{Dir: known.Dir}}
+		}
+	}
+
+	return changes, nil
+}
+
+// apply applies the local changes to the remote directory and the remote
+// ones to the local directory, resolving the conflicts, in the order of
+// the paths so that the directories are created before their content.
+func (s *syncer) apply(local map[string]delta.Change, remote map[string]remoteChange) error {
+	paths := make([]string, 0, len(local)+len(remote))
+	for p := range local {
+		paths = append(paths, p)
+	}
+	for p := range remote {
+		if _, ok := local[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var localChanged, remoteChanged, removed []string
+	for p, c := range local {
+		if !c.Deleted {
+			localChanged = append(localChanged, p)
+		}
+	}
+	for p, c := range remote {
+		if !c.Deleted {
+			remoteChanged = append(remoteChanged, p)
+		}
+	}
+
+	for _, p := range paths {
+		if withinAny(p, removed) {
+			continue
+		}
+
+		l, inLocal := local[p]
+		r, inRemote := remote[p]
+		known, synced := s.state.Files[p]
+
+		var err error
+		switch {
+		case !inRemote && l.Deleted:
+			// A directory with new remote content is left to be restored.
+			if l.Dir && changedWithin(p, remoteChanged) {
+				s.forget(p)
+				continue
+			}
+			fmt.Fprintf(s.out, "delete %s (remote)\n", p)
+			if err = s.client.remove(s.remotePath(p)); err == nil {
+				s.forget(p)
+				removed = append(removed, p)
+			}
+		case !inLocal && r.Deleted:
+			if r.Dir && changedWithin(p, localChanged) {
+				s.forget(p)
+				continue
+			}
+			fmt.Fprintf(s.out, "delete %s (local)\n", p)
+			if err = s.fs.RemoveAll(p); err == nil {
+				s.forget(p)
+				removed = append(removed, p)
+			}
+		case !inRemote && synced && known.Dir != l.Dir:
+			// Replaced by a file or by a directory.
+			if err = s.client.remove(s.remotePath(p)); err == nil {
+				s.forget(p)
+				err = s.push(p, l.Entry, false, "")
+			}
+			if known.Dir {
+				removed = append(removed, p)
+			}
+		case !inRemote:
+			err = s.push(p, l.Entry, synced, known.ETag)
+		case !inLocal && synced && known.Dir != r.Dir:
+			if known.Dir && changedWithin(p, localChanged) {
+				err = s.merge(p, delta.Change{Path: p, Entry: delta.Entry{Dir: true}}, r)
+				break
+			}
+			if err = s.fs.RemoveAll(p); err == nil {
+				s.forget(p)
+				err = s.pull(p, r)
+			}
+			if known.Dir {
+				removed = append(removed, p)
+			}
+		case l.Deleted && r.Deleted:
+			s.forget(p)
+		case !inLocal, l.Deleted:
+			err = s.pull(p, r)
+		case r.Deleted:
+			err = s.push(p, l.Entry, false, "")
+		default:
+			err = s.merge(p, l, r)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// merge resolves a path created or changed on both sides.
+func (s *syncer) merge(p string, l delta.Change, r remoteChange) error {
+	switch {
+	case l.Dir && r.Dir:
+		s.state.Files[p] = syncEntry{Entry: delta.Entry{Dir: true}}
+		return nil
+	case !l.Dir && !r.Dir && l.Sha256 == r.Sha256:
+		s.state.Files[p] = syncEntry{Entry: l.Entry, ETag: r.ETag}
+		return nil
+	}
+
+	conflict := fileutils.AddVersionSuffix(p, s.fs)
+
+	// The local directory keeps its name, for its content to be synced,
+	// and the remote file is renamed.
+	if l.Dir {
+		fmt.Fprintf(s.out, "conflict %s: the remote file is kept as %s\n", p, conflict)
+		if err := s.client.rename(s.remotePath(p), s.remotePath(conflict)); err != nil {
+			return err
+		}
+		if err := s.push(p, l.Entry, false, ""); err != nil {
+			return err
+		}
+		r.Path = conflict
+		return s.pull(conflict, r)
+	}
+
+	fmt.Fprintf(s.out, "conflict %s: the local file is kept as %s\n", p, conflict)
+	if err := s.fs.Rename(p, conflict); err != nil {
+		return err
+	}
+	if err := s.pull(p, r); err != nil {
+		return err
+	}
+	return s.push(conflict, l.Entry, false, "")
+}
+
+// pull downloads the remote file or creates the directory.
+func (s *syncer) pull(p string, r remoteChange) error {
+	fmt.Fprintf(s.out, "pull %s\n", p)
+
+	if r.Dir {
+		if err := s.fs.MkdirAll(p, 0755); err != nil {
+			return err
+		}
+		s.state.Files[p] = syncEntry{Entry: delta.Entry{Dir: true}}
+		return nil
+	}
+
+	if err := s.fs.MkdirAll(path.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := afero.TempFile(s.fs, path.Dir(p), syncTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.fs.Remove(tmp.Name()) }()
+
+	h := sha256.New()
+	err = s.client.download(s.remotePath(p), io.MultiWriter(tmp, h))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := s.fs.Stat(p); err == nil {
+		mode = info.Mode()
+	}
+	if err := s.fs.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := s.fs.Chtimes(tmp.Name(), r.Modified, r.Modified); err != nil {
+		return err
+	}
+	if err := s.fs.Rename(tmp.Name(), p); err != nil {
+		return err
+	}
+
+	info, err := s.fs.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	// The file may have changed since it was listed, in which case its
+	// ETag isn't known.
+	entry := syncEntry{Entry: delta.Entry{Size: info.Size(), Modified: info.ModTime(), Sha256: hex.EncodeToString(h.Sum(nil))}}
+	if entry.Sha256 == r.Sha256 {
+		entry.ETag = r.ETag
+	}
+	s.state.Files[p] = entry
+	return nil
+}
+
+// push uploads the local file or creates the directory. The remote file
+// is only replaced with override, if it has the given ETag when not empty.
+// A remote file changed meanwhile is left for the next round.
+func (s *syncer) push(p string, e delta.Entry, override bool, etag string) error {
+	fmt.Fprintf(s.out, "push %s\n", p)
+
+	if e.Dir {
+		if err := s.client.mkdir(s.remotePath(p)); err != nil {
+			return err
+		}
+		s.state.Files[p] = syncEntry{Entry: delta.Entry{Dir: true}}
+		return nil
+	}
+
+	f, err := s.fs.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	newETag, err := s.client.upload(s.remotePath(p), io.TeeReader(f, h), override, etag)
+	if errors.Is(err, errSyncConflict) {
+		fmt.Fprintf(s.out, "skip %s: %v\n", p, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.state.Files[p] = syncEntry{
+		Entry: delta.Entry{Size: info.Size(), Modified: info.ModTime(), Sha256: hex.EncodeToString(h.Sum(nil))},
+		ETag:  newETag,
+	}
+	return nil
+}
+
+// forget removes the path, and everything in it, from the state.
+func (s *syncer) forget(p string) {
+	for q := range s.state.Files {
+		if q == p || strings.HasPrefix(q, p+"/") {
+			delete(s.state.Files, q)
+		}
+	}
+}
+
+func (s *syncer) remotePath(p string) string {
+	return path.Join(s.remote, p)
+}
+
+func (s *syncer) localSha256(p string) (string, error) {
+	f, err := s.fs.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isSyncFile reports whether the path is the state or a download of the
+// sync, which are not synced.
+func isSyncFile(p string) bool {
+	name := path.Base(p)
+	return name == syncStateName || strings.HasPrefix(name, syncTempPrefix)
+}
+
+// withinAny reports whether p is inside one of the directories.
+func withinAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// changedWithin reports whether one of the changed paths is inside dir.
+func changedWithin(dir string, changed []string) bool {
+	for _, p := range changed {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/delta"
+)
+
+// errSyncConflict is returned when a remote file was created or changed
+// after the changes were listed.
+var errSyncConflict = errors.New("the remote file changed meanwhile")
+
+// remoteChange is a change listed by the delta API.
+type remoteChange struct {
+	delta.Change
+	ETag string `json:"etag,omitempty"`
+}
+
+type remoteDelta struct {
+	Cursor  string         `json:"cursor"`
+	Reset   bool           `json:"reset"`
+	Changes []remoteChange `json:"changes"`
+}
+
+// syncClient calls the API of a server on behalf of a user.
+type syncClient struct {
+	server *url.URL
+	token  string
+	client *http.Client
+}
+
+func newSyncClient(server string) (*syncClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(server, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server url %q", server)
+	}
+	return &syncClient{server: u, client: &http.Client{}}, nil
+}
+
+// do sends a request to the API at the given path, which is escaped. It
+// returns an error for the responses with an error status.
+func (c *syncClient) do(method, api, p string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	u := *c.server
+	u.Path = path.Join(u.Path, "/api", api, p)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.token != "" {
+		req.Header.Set("X-Auth", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return resp, fmt.Errorf("%s %s: %s", method, p, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *syncClient) login(username, password string) error {
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(http.MethodPost, "login", "", nil, bytes.NewReader(body), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	token, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	c.token = string(token)
+	return nil
+}
+
+func (c *syncClient) delta(dir, cursor string) (*remoteDelta, error) {
+	resp, err := c.do(http.MethodGet, "delta", dir, url.Values{"cursor": {cursor}}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res := &remoteDelta{}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *syncClient) download(p string, w io.Writer) error {
+	resp, err := c.do(http.MethodGet, "raw", p, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// upload writes the file and returns the ETag of the new file. Without
+// override, the remote file must not exist, and with an ETag it must have
+// this one.
+func (c *syncClient) upload(p string, r io.Reader, override bool, etag string) (string, error) {
+	query, header := url.Values{}, http.Header{}
+	if override {
+		query.Set("override", "true")
+	}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+
+	resp, err := c.do(http.MethodPost, "resources", p, query, r, header)
+	if resp != nil && (resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusPreconditionFailed) {
+		return "", errSyncConflict
+	}
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+func (c *syncClient) mkdir(p string) error {
+	resp, err := c.do(http.MethodPost, "resources", p+"/", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *syncClient) rename(src, dst string) error {
+	query := url.Values{"action": {"rename"}, "destination": {url.QueryEscape(dst)}}
+	resp, err := c.do(http.MethodPatch, "resources", src, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *syncClient) remove(p string) error {
+	resp, err := c.do(http.MethodDelete, "resources", p, nil, nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/diskcache"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/transcode"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestSync(t *testing.T) {
+	server, remote := newSyncServer(t)
+	local := t.TempDir()
+	t.Setenv(syncPasswordEnv, "password")
+
+	sync := func() string {
+		t.Helper()
+		out := &bytes.Buffer{}
+		syncCmd.SetOut(out)
+		require.NoError(t, syncCmd.Flags().Set("server", server.URL))
+		require.NoError(t, syncCmd.RunE(syncCmd, []string{local, "/"}))
+		return out.String()
+	}
+	write := func(dir, name, content string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+		// a change within the same second as the last sync is still seen
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(p, later, later))
+	}
+	read := func(dir, name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	write(local, "a.txt", "a")
+	write(local, "dir/b.txt", "b")
+	write(remote, "c.txt", "c")
+
+	out := sync()
+	require.Contains(t, out, "push /a.txt\n")
+	require.Contains(t, out, "push /dir/b.txt\n")
+	require.Contains(t, out, "pull /c.txt\n")
+	require.Equal(t, "a", read(remote, "a.txt"))
+	require.Equal(t, "b", read(remote, "dir/b.txt"))
+	require.Equal(t, "c", read(local, "c.txt"))
+
+	// the cursor is kept for the next sync, which has nothing to do
+	state := &syncState{}
+	require.NoError(t, json.Unmarshal([]byte(read(local, syncStateName)), state))
+	require.NotEmpty(t, state.Cursor)
+	require.Equal(t, server.URL, state.Server)
+	require.Empty(t, sync())
+
+	// changed on both sides, deleted on one side and created on the other
+	write(local, "a.txt", "local a")
+	write(remote, "a.txt", "remote a")
+	require.NoError(t, os.Remove(filepath.Join(local, "c.txt")))
+	write(remote, "dir/d.txt", "d")
+
+	out = sync()
+	require.Contains(t, out, "conflict /a.txt: the local file is kept as /a(1).txt\n")
+	require.Equal(t, "remote a", read(local, "a.txt"))
+	require.Equal(t, "local a", read(local, "a(1).txt"))
+	require.Equal(t, "remote a", read(remote, "a.txt"))
+	require.Equal(t, "local a", read(remote, "a(1).txt"))
+	require.NoFileExists(t, filepath.Join(remote, "c.txt"))
+	require.Equal(t, "d", read(local, "dir/d.txt"))
+	require.Empty(t, sync())
+}
+
+func TestSyncPassword(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("password-file", "", "")
+
+	t.Setenv(syncPasswordEnv, "from env")
+	password, err := syncPassword(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "from env", password)
+
+	file := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(file, []byte("from file\n"), 0600))
+	require.NoError(t, cmd.Flags().Set("password-file", file))
+	password, err = syncPassword(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "from file", password)
+
+	require.NoError(t, cmd.Flags().Set("password-file", file+".missing"))
+	_, err = syncPassword(cmd)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// newSyncServer serves the API on a temporary root directory, which it
+// returns, for an admin with the password "password".
+func newSyncServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	set := &settings.Settings{
+		Key:        []byte("key"),
+		AuthMethod: auth.MethodJSONAuth,
+		FileMode:   settings.DefaultFileMode,
+		DirMode:    settings.DefaultDirMode,
+	}
+	root := t.TempDir()
+	server := &settings.Server{Root: root}
+	require.NoError(t, store.Settings.Save(set))
+	require.NoError(t, store.Settings.SaveServer(server))
+	require.NoError(t, store.Auth.Save(&auth.JSONAuth{}))
+
+	pwd, err := users.HashPwd("password")
+	require.NoError(t, err)
+	require.NoError(t, store.Users.Save(&users.User{
+		Username: "admin",
+		Password: pwd,
+		Scope:    "/",
+		Perm: users.Permissions{
+			Admin: true, Create: true, Rename: true, Modify: true, Delete: true, Download: true,
+		},
+	}))
+
+	jobManager, err := jobs.NewManager(store.Jobs, 1)
+	require.NoError(t, err)
+	handler, err := fbhttp.NewHandler(t.Context(), img.New(1), transcode.New("", 1), jobManager, diskcache.NewNoOp(), store, server, fstest.MapFS{})
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return ts, root
+}
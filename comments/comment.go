@@ -0,0 +1,51 @@
+package comments
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// Comment is a comment left by a user on a file or a directory.
+type Comment struct {
+	ID       string    `json:"id" storm:"id"`
+	Path     string    `json:"path" storm:"index"` // full path on the server
+	UserID   uint      `json:"userID" storm:"index"`
+	Username string    `json:"username"`
+	Body     string    `json:"body"`
+	Mentions []string  `json:"mentions,omitempty"`
+	Created  time.Time `json:"created"`
+	Edited   time.Time `json:"edited"` // zero if the comment wasn't edited
+}
+
+// NewID returns a new random comment ID.
+func NewID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ParseMentions returns the names mentioned as @name in the body, once each.
+func ParseMentions(body string) []string {
+	mentions := []string{}
+	for _, m := range mentionRegexp.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(m[1], ".-")
+		if name != "" && !slices.Contains(mentions, name) {
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// within reports whether p is dir or is inside it.
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,102 @@
+package comments
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// StorageBackend is the interface to implement for a comments storage.
+type StorageBackend interface {
+	All() ([]*Comment, error)
+	FindByPath(path string) ([]*Comment, error)
+	Get(id string) (*Comment, error)
+	Save(c *Comment) error
+	Delete(id string) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+	mu   sync.Mutex
+}
+
+// NewStorage creates a comments storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// FindByPath returns the comments of the path, the oldest first.
+func (s *Storage) FindByPath(path string) ([]*Comment, error) {
+	list, err := s.back.FindByPath(path)
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(id string) (*Comment, error) {
+	return s.back.Get(id)
+}
+
+// Save wraps a StorageBackend.Save.
+func (s *Storage) Save(c *Comment) error {
+	return s.back.Save(c)
+}
+
+// Delete wraps a StorageBackend.Delete.
+func (s *Storage) Delete(id string) error {
+	return s.back.Delete(id)
+}
+
+// Move moves the comments of the path and of everything in it to their
+// new path, as when it is renamed.
+func (s *Storage) Move(src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return err
+	}
+
+	for _, c := range list {
+		if within(c.Path, src) {
+			c.Path = dst + c.Path[len(src):]
+			if err := s.back.Save(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeleteWithin removes the comments of the path and of everything in it,
+// as when it is deleted.
+func (s *Storage) DeleteWithin(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return err
+	}
+
+	for _, c := range list {
+		if within(c.Path, path) {
+			if err := s.back.Delete(c.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package comments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type memoryBackend struct {
+	comments map[string]Comment
+}
+
+func (b *memoryBackend) All() ([]*Comment, error) {
+	list := []*Comment{}
+	for _, c := range b.comments {
+		c := c
+		list = append(list, &c)
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) FindByPath(path string) ([]*Comment, error) {
+	all, _ := b.All()
+	list := []*Comment{}
+	for _, c := range all {
+		if c.Path == path {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Get(id string) (*Comment, error) {
+	c, ok := b.comments[id]
+	if !ok {
+		return nil, fberrors.ErrNotExist
+	}
+	return &c, nil
+}
+
+func (b *memoryBackend) Save(c *Comment) error {
+	b.comments[c.ID] = *c
+	return nil
+}
+
+func (b *memoryBackend) Delete(id string) error {
+	delete(b.comments, id)
+	return nil
+}
+
+func TestStorage(t *testing.T) {
+	back := &memoryBackend{comments: map[string]Comment{}}
+	s := NewStorage(back)
+
+	now := time.Now()
+	require.NoError(t, s.Save(&Comment{ID: "b", Path: "/srv/docs/a.txt", Created: now}))
+	require.NoError(t, s.Save(&Comment{ID: "a", Path: "/srv/docs/a.txt", Created: now.Add(-time.Minute)}))
+	require.NoError(t, s.Save(&Comment{ID: "c", Path: "/srv/docs2/a.txt", Created: now}))
+
+	list, err := s.FindByPath("/srv/docs/a.txt")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "a", list[0].ID)
+
+	require.NoError(t, s.Move("/srv/docs", "/srv/papers"))
+	list, err = s.FindByPath("/srv/papers/a.txt")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	c, err := s.Get("c")
+	require.NoError(t, err)
+	require.Equal(t, "/srv/docs2/a.txt", c.Path)
+
+	require.NoError(t, s.DeleteWithin("/srv/papers"))
+	require.Len(t, back.comments, 1)
+}
+
+func TestParseMentions(t *testing.T) {
+	require.Equal(t, []string{"alice", "bob.smith"},
+		ParseMentions("@alice, can you and @bob.smith check this? Thanks @alice. mail@example.com"))
+	require.Empty(t, ParseMentions("no mentions"))
+}
@@ -0,0 +1,70 @@
+package delta
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// Entry is the state of a file or a directory in a snapshot.
+type Entry struct {
+	Dir      bool      `json:"dir,omitempty"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Sha256   string    `json:"sha256,omitempty"`
+}
+
+// Unchanged reports whether the entry has the same metadata as e, in which
+// case its content is assumed to be the same too.
+func (e Entry) Unchanged(o Entry) bool {
+	return e.Dir == o.Dir && e.Size == o.Size && e.Modified.Equal(o.Modified)
+}
+
+// Snapshot is the state of a directory tree as reported to a sync client,
+// against which the changes reported next are computed. Its ID is the
+// cursor the client is given.
+type Snapshot struct {
+	ID      string           `json:"id" storm:"id"`
+	UserID  uint             `json:"userID" storm:"index"`
+	Path    string           `json:"path"`    // full path of the directory
+	Entries map[string]Entry `json:"entries"` // by path relative to the directory
+	Created time.Time        `json:"created"`
+}
+
+// Change is a change of the tree between two snapshots.
+type Change struct {
+	Path    string `json:"path"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Entry
+}
+
+// NewID returns a random identifier for a snapshot.
+func NewID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Diff returns the entries of cur which are new or changed since prev,
+// and those of prev which were deleted, sorted by path.
+func Diff(prev, cur map[string]Entry) []Change {
+	changes := []Change{}
+	for p, e := range cur {
+		if old, ok := prev[p]; !ok || !old.Unchanged(e) || old.Sha256 != e.Sha256 {
+			changes = append(changes, Change{Path: p, Entry: e})
+		}
+	}
+	for p, e := range prev {
+		if _, ok := cur[p]; !ok {
+			changes = append(changes, Change{Path: p, Deleted: true, Entry: Entry{Dir: e.Dir}})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
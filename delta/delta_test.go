@@ -0,0 +1,92 @@
+package delta
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type memoryBackend struct {
+	snapshots map[string]Snapshot
+}
+
+func (b *memoryBackend) FindByUserID(id uint) ([]*Snapshot, error) {
+	list := []*Snapshot{}
+	for _, s := range b.snapshots {
+		if s.UserID == id {
+			s := s
+			list = append(list, &s)
+		}
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Get(id string) (*Snapshot, error) {
+	s, ok := b.snapshots[id]
+	if !ok {
+		return nil, fberrors.ErrNotExist
+	}
+	return &s, nil
+}
+
+func (b *memoryBackend) Save(s *Snapshot) error {
+	b.snapshots[s.ID] = *s
+	return nil
+}
+
+func (b *memoryBackend) Delete(id string) error {
+	delete(b.snapshots, id)
+	return nil
+}
+
+func TestDiff(t *testing.T) {
+	mod := time.Now()
+	prev := map[string]Entry{
+		"/docs":       {Dir: true},
+		"/docs/a.txt": {Size: 1, Modified: mod, Sha256: "aa"},
+		"/docs/b.txt": {Size: 1, Modified: mod, Sha256: "bb"},
+		"/old":        {Dir: true},
+	}
+	cur := map[string]Entry{
+		"/docs":       {Dir: true},
+		"/docs/a.txt": {Size: 1, Modified: mod, Sha256: "aa"},
+		"/docs/b.txt": {Size: 2, Modified: mod.Add(time.Second), Sha256: "cc"},
+		"/new.txt":    {Size: 3, Modified: mod, Sha256: "dd"},
+	}
+
+	require.Equal(t, []Change{
+		{Path: "/docs/b.txt", Entry: cur["/docs/b.txt"]},
+		{Path: "/new.txt", Entry: cur["/new.txt"]},
+		{Path: "/old", Deleted: true, Entry: Entry{Dir: true}},
+	}, Diff(prev, cur))
+
+	require.Len(t, Diff(nil, cur), len(cur))
+	require.Empty(t, Diff(cur, cur))
+}
+
+func TestSavePrunes(t *testing.T) {
+	back := &memoryBackend{snapshots: map[string]Snapshot{}}
+	s := NewStorage(back)
+	now := time.Now()
+
+	require.NoError(t, s.Save(&Snapshot{ID: "expired", UserID: 1, Path: "/srv/other", Created: now.Add(-maxSnapshotAge - time.Hour)}))
+	require.NoError(t, s.Save(&Snapshot{ID: "other-user", UserID: 2, Path: "/srv/docs", Created: now.Add(-time.Hour)}))
+	for i := range keepSnapshots + 2 {
+		id := fmt.Sprintf("docs-%d", i)
+		require.NoError(t, s.Save(&Snapshot{ID: id, UserID: 1, Path: "/srv/docs", Created: now.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	_, err := s.Get("expired")
+	require.ErrorIs(t, err, fberrors.ErrNotExist)
+	_, err = s.Get("docs-1")
+	require.ErrorIs(t, err, fberrors.ErrNotExist)
+	_, err = s.Get("docs-2")
+	require.NoError(t, err)
+	_, err = s.Get("other-user")
+	require.NoError(t, err)
+	require.Len(t, back.snapshots, keepSnapshots+1)
+}
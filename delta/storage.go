@@ -0,0 +1,75 @@
+package delta
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// keepSnapshots is how many of the latest snapshots of a directory are
+// kept for each user, so that a client repeating a request or several
+// clients syncing the same directory don't lose their cursors at once.
+const keepSnapshots = 8
+
+// maxSnapshotAge is how long a snapshot is kept, after which its client
+// has to start over from the whole tree.
+const maxSnapshotAge = 90 * 24 * time.Hour
+
+// StorageBackend is the interface to implement for a snapshots storage.
+type StorageBackend interface {
+	FindByUserID(id uint) ([]*Snapshot, error)
+	Get(id string) (*Snapshot, error)
+	Save(s *Snapshot) error
+	Delete(id string) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a snapshots storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(id string) (*Snapshot, error) {
+	return s.back.Get(id)
+}
+
+// Save saves the snapshot, removing the old snapshots of the user.
+func (s *Storage) Save(snap *Snapshot) error {
+	if err := s.back.Save(snap); err != nil {
+		return err
+	}
+
+	list, err := s.back.FindByUserID(snap.UserID)
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.After(list[j].Created)
+	})
+
+	kept := 0
+	for _, old := range list {
+		switch {
+		case time.Since(old.Created) > maxSnapshotAge:
+		case old.Path != snap.Path:
+			continue
+		case kept < keepSnapshots:
+			kept++
+			continue
+		}
+
+		if err := s.back.Delete(old.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -22,6 +22,7 @@ var (
 	ErrInvalidRequestParams = errors.New("invalid request params")
 	ErrSourceIsParent       = errors.New("source is parent")
 	ErrRootUserDeletion     = errors.New("user with id 1 can't be deleted")
+	ErrLocked               = errors.New("the resource is locked")
 )
 
 type ErrShortPassword struct {
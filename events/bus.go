@@ -0,0 +1,215 @@
+package events
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+// Types of events.
+const (
+	Created   = "created"
+	Modified  = "modified"
+	Deleted   = "deleted"
+	Renamed   = "renamed"
+	Copied    = "copied"
+	Uploaded  = "uploaded"
+	Shared    = "shared"
+	Mentioned = "mentioned"
+	LoggedIn  = "login"
+	Job       = "job"
+)
+
+// hookTypes maps the hook events to the types of events.
+var hookTypes = map[string]string{
+	"upload":  Uploaded,
+	"save":    Modified,
+	"delete":  Deleted,
+	"rename":  Renamed,
+	"copy":    Copied,
+	"chmod":   Modified,
+	"archive": Created,
+}
+
+// subscriberBuffer is the number of events kept for a slow subscriber
+// before the new ones are dropped.
+const subscriberBuffer = 64
+
+// recentWindow is how long a change made through File Browser hides the
+// same change reported by the file system watcher.
+const recentWindow = 2 * time.Second
+
+// Event is a change pushed to the users. The paths are the full paths on
+// the server and must be made relative to the scope of each user.
+type Event struct {
+	Type        string    `json:"type"`
+	Path        string    `json:"path,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Job         *jobs.Job `json:"job,omitempty"`
+	Time        time.Time `json:"time"`
+
+	// UserID restricts the event to a single user when set.
+	UserID uint `json:"-"`
+}
+
+// Bus dispatches the events to the subscribers.
+type Bus struct {
+	mu        sync.Mutex
+	subs      map[chan Event]struct{}
+	listeners []func(Event)
+	busy      map[string]int
+	recent    map[string]time.Time
+}
+
+// NewBus creates an events bus.
+func NewBus() *Bus {
+	return &Bus{
+		subs:   map[chan Event]struct{}{},
+		busy:   map[string]int{},
+		recent: map[string]time.Time{},
+	}
+}
+
+// Subscribe returns a channel receiving all the events. The returned
+// function must be called to unsubscribe.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Listen calls fn with every event published from now on. Unlike the
+// subscribers, the listeners never miss an event, so fn must return quickly.
+func (b *Bus) Listen(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Publish sends the event to the subscribers and the listeners.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	if e.User != "" {
+		b.remember(e.Path, e.Time)
+		b.remember(e.Destination, e.Time)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	listeners := b.listeners
+	b.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(e)
+	}
+}
+
+// PublishHook publishes the event matching a successful hook event.
+func (b *Bus) PublishHook(evt, path, dst, username string) {
+	typ, ok := hookTypes[evt]
+	if !ok {
+		return
+	}
+
+	b.Publish(Event{
+		Type:        typ,
+		Path:        path,
+		Destination: dst,
+		User:        username,
+	})
+}
+
+// PublishJob publishes the progress of a job to its user.
+func (b *Bus) PublishJob(job jobs.Job) {
+	b.Publish(Event{
+		Type:   Job,
+		Job:    &job,
+		UserID: job.UserID,
+	})
+}
+
+// Begin marks the paths as being changed through File Browser until the
+// returned function is called, so the file system watcher ignores them.
+func (b *Bus) Begin(paths ...string) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, path := range paths {
+		if path != "" {
+			b.busy[path]++
+		}
+	}
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		now := time.Now()
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			if b.busy[path]--; b.busy[path] <= 0 {
+				delete(b.busy, path)
+			}
+			b.remember(path, now)
+		}
+	}
+}
+
+// Recent reports whether the path, or one of its parents, is being or
+// was changed through File Browser in the last moments.
+func (b *Bus) Recent(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		if b.busy[path] > 0 {
+			return true
+		}
+		if t, ok := b.recent[path]; ok && time.Since(t) < recentWindow {
+			return true
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return false
+		}
+		path = parent
+	}
+}
+
+func (b *Bus) remember(path string, t time.Time) {
+	if path == "" {
+		return
+	}
+
+	if len(b.recent) > 1024 {
+		for p, last := range b.recent {
+			if t.Sub(last) >= recentWindow {
+				delete(b.recent, p)
+			}
+		}
+	}
+	b.recent[path] = t
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	updates, unsubscribe := bus.Subscribe()
+
+	end := bus.Begin("/srv/dst")
+	require.True(t, bus.Recent("/srv/dst/a/b.txt"))
+	require.False(t, bus.Recent("/srv/src"))
+	end()
+	require.True(t, bus.Recent("/srv/dst"))
+
+	bus.PublishHook("rename", "/srv/a", "/srv/b", "admin")
+	bus.PublishHook("unknown", "/srv/a", "", "admin")
+
+	e := <-updates
+	require.Equal(t, Renamed, e.Type)
+	require.Equal(t, "/srv/a", e.Path)
+	require.Equal(t, "/srv/b", e.Destination)
+	require.Equal(t, "admin", e.User)
+	require.True(t, bus.Recent("/srv/a"))
+
+	unsubscribe()
+	_, ok := <-updates
+	require.False(t, ok)
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0755))
+
+	bus := NewBus()
+	watcher, err := NewWatcher(bus)
+	if err != nil {
+		t.Skipf("file system watching unavailable: %v", err)
+	}
+	defer watcher.Close()
+
+	updates, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	stop := watcher.Add(root)
+	defer stop()
+
+	next := func() Event {
+		select {
+		case e := <-updates:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return Event{}
+		}
+	}
+
+	// changes made through File Browser are not reported twice
+	end := bus.Begin(filepath.Join(root, "own.txt"))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "own.txt"), []byte("a"), 0644))
+	end()
+
+	file := filepath.Join(root, "sub", "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("a"), 0644))
+	e := next()
+	require.Equal(t, Created, e.Type)
+	require.Equal(t, file, e.Path)
+	require.Empty(t, e.User)
+
+	require.NoError(t, os.Remove(file))
+	for e = next(); e.Type == Modified; e = next() {
+	}
+	require.Equal(t, Deleted, e.Type)
+	require.Equal(t, file, e.Path)
+}
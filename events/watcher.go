@@ -0,0 +1,203 @@
+package events
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// maxWatchedDirs limits the number of directories watched at the same
+// time, inotify watches being a limited resource.
+const maxWatchedDirs = 8192
+
+// coalesceWindow is how long the same change of a path is reported once,
+// writing a file producing many events.
+const coalesceWindow = time.Second
+
+// Watcher publishes the changes made to the file system outside of File
+// Browser. The roots are watched recursively while someone listens to
+// them.
+type Watcher struct {
+	bus *Bus
+	fsw *fsnotify.Watcher
+
+	mu    sync.Mutex
+	roots map[string]int
+	dirs  map[string]int
+	last  map[string]time.Time
+}
+
+// NewWatcher creates a file system watcher publishing to the bus.
+func NewWatcher(bus *Bus) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		bus:   bus,
+		fsw:   fsw,
+		roots: map[string]int{},
+		dirs:  map[string]int{},
+		last:  map[string]time.Time{},
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Add starts watching the root directory recursively. The returned
+// function must be called to stop watching.
+func (w *Watcher) Add(root string) func() {
+	root = filepath.Clean(root)
+
+	w.mu.Lock()
+	w.roots[root]++
+	if w.roots[root] == 1 {
+		w.addTree(root, []string{root})
+	}
+	w.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if w.roots[root]--; w.roots[root] > 0 {
+				return
+			}
+			delete(w.roots, root)
+			for dir := range w.dirs {
+				if within(dir, root) {
+					w.release(dir)
+				}
+			}
+		})
+	}
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// addTree watches the directory and its subdirectories on behalf of the
+// given roots.
+func (w *Watcher) addTree(dir string, roots []string) {
+	if len(roots) == 0 {
+		return
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if len(w.dirs) >= maxWatchedDirs {
+			return filepath.SkipAll
+		}
+
+		if w.dirs[path] == 0 {
+			if err := w.fsw.Add(path); err != nil {
+				return filepath.SkipDir
+			}
+		}
+		w.dirs[path] += len(roots)
+		return nil
+	})
+	if err != nil {
+		log.Printf("events: failed to watch %s: %v", dir, err)
+	}
+}
+
+func (w *Watcher) release(dir string) {
+	if w.dirs[dir]--; w.dirs[dir] > 0 {
+		return
+	}
+	delete(w.dirs, dir)
+	_ = w.fsw.Remove(dir)
+}
+
+func (w *Watcher) loop() {
+	for {
+		select {
+		case e, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(e)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				log.Printf("events: watcher error: %v", err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) handle(e fsnotify.Event) {
+	var typ string
+	switch {
+	case e.Has(fsnotify.Create):
+		typ = Created
+	case e.Has(fsnotify.Remove), e.Has(fsnotify.Rename):
+		// the new name of a renamed file comes as a creation
+		typ = Deleted
+	case e.Has(fsnotify.Write):
+		typ = Modified
+	default:
+		return
+	}
+
+	w.mu.Lock()
+	switch typ {
+	case Created:
+		var roots []string
+		for root := range w.roots {
+			if within(e.Name, root) {
+				roots = append(roots, root)
+			}
+		}
+		w.addTree(e.Name, roots)
+	case Deleted:
+		for dir := range w.dirs {
+			if within(dir, e.Name) {
+				delete(w.dirs, dir)
+				_ = w.fsw.Remove(dir)
+			}
+		}
+	}
+
+	key := typ + ":" + e.Name
+	now := time.Now()
+	if last, ok := w.last[key]; ok && now.Sub(last) < coalesceWindow {
+		w.mu.Unlock()
+		return
+	}
+	if len(w.last) > 1024 {
+		for k, last := range w.last {
+			if now.Sub(last) >= coalesceWindow {
+				delete(w.last, k)
+			}
+		}
+	}
+	w.last[key] = now
+	w.mu.Unlock()
+
+	if w.bus.Recent(e.Name) {
+		return
+	}
+
+	w.bus.Publish(Event{Type: typ, Path: e.Name, Time: now})
+}
+
+// within reports whether the path is the root or is inside it.
+func within(path, root string) bool {
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
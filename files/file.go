@@ -22,9 +22,12 @@ import (
 	"time"
 
 	"github.com/spf13/afero"
+	"lukechampine.com/blake3"
 
 	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/locks"
 	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/tags"
 )
 
 var (
@@ -35,6 +38,7 @@ var (
 // FileInfo describes a file.
 type FileInfo struct {
 	*Listing
+	*tags.Summary
 	Fs         afero.Fs          `json:"-"`
 	Path       string            `json:"path"`
 	Name       string            `json:"name"`
@@ -51,6 +55,10 @@ type FileInfo struct {
 	Token      string            `json:"token,omitempty"`
 	currentDir []os.FileInfo     `json:"-"`
 	Resolution *ImageResolution  `json:"resolution,omitempty"`
+	// ArchiveEntry is the path inside the archive of the virtual files
+	// listed from an archive.
+	ArchiveEntry string      `json:"archiveEntry,omitempty"`
+	Lock         *locks.Lock `json:"lock,omitempty"`
 }
 
 // FileOptions are the options when getting a file info.
@@ -175,34 +183,43 @@ func (i *FileInfo) Checksum(algo string) error {
 		i.Checksums = map[string]string{}
 	}
 
+	h, err := NewHash(algo)
+	if err != nil {
+		return err
+	}
+
 	reader, err := i.Fs.Open(i.Path)
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	var h hash.Hash
+	_, err = io.Copy(h, reader)
+	if err != nil {
+		return err
+	}
+
+	i.Checksums[algo] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
 
+// NewHash returns the hash of the checksum algorithm: md5, sha1, sha256,
+// sha512 or blake3.
+func NewHash(algo string) (hash.Hash, error) {
 	switch algo {
 	case "md5":
-		h = md5.New()
+		return md5.New(), nil
 	case "sha1":
-		h = sha1.New()
+		return sha1.New(), nil
 	case "sha256":
-		h = sha256.New()
+		return sha256.New(), nil
 	case "sha512":
-		h = sha512.New()
+		return sha512.New(), nil
+	case "blake3":
+		return blake3.New(32, nil), nil
 	default:
-		return fberrors.ErrInvalidOption
-	}
-
-	_, err = io.Copy(h, reader)
-	if err != nil {
-		return err
+		return nil, fberrors.ErrInvalidOption
 	}
-
-	i.Checksums[algo] = hex.EncodeToString(h.Sum(nil))
-	return nil
 }
 
 func (i *FileInfo) RealPath() string {
@@ -358,6 +375,12 @@ func (i *FileInfo) detectSubtitles() {
 			i.addSubtitle(path.Join(parentDir, f.Name()))
 		}
 	}
+
+	// embedded tracks are only looked up when a single video is
+	// requested, not for every video of a listing
+	if len(i.currentDir) == 0 {
+		i.detectEmbeddedSubtitles()
+	}
 }
 
 func (i *FileInfo) loadSubtitles(subsPath, baseName string, recursive bool) {
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+func TestNewHash(t *testing.T) {
+	// the inputs of the official test vectors repeat the bytes 0 to 250
+	input := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i % 251)
+		}
+		return b
+	}
+
+	testCases := map[string]struct {
+		input []byte
+		want  string
+	}{
+		"empty":       {nil, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		"one byte":    {input(1), "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		"abc":         {[]byte("abc"), "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85"},
+		"one chunk":   {input(1024), "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		"many chunks": {input(102400), "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			h, err := NewHash("blake3")
+			require.NoError(t, err)
+			_, _ = h.Write(tc.input)
+			require.Equal(t, tc.want, hex.EncodeToString(h.Sum(nil)))
+
+			// the same in small writes
+			h.Reset()
+			for p := tc.input; len(p) > 0; p = p[min(len(p), 100):] {
+				_, _ = h.Write(p[:min(len(p), 100)])
+			}
+			require.Equal(t, tc.want, hex.EncodeToString(h.Sum(nil)))
+		})
+	}
+
+	_, err := NewHash("crc32")
+	require.ErrorIs(t, err, fberrors.ErrInvalidOption)
+}
@@ -3,6 +3,7 @@ package files
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/maruel/natural"
 )
@@ -26,6 +27,8 @@ func (l Listing) ApplySort() {
 			sort.Sort(sort.Reverse(bySize(l)))
 		case "modified":
 			sort.Sort(sort.Reverse(byModified(l)))
+		case "taken":
+			sort.Sort(sort.Reverse(newByTaken(l)))
 		default:
 			// If not one of the above, do nothing
 			return
@@ -38,6 +41,8 @@ func (l Listing) ApplySort() {
 			sort.Sort(bySize(l))
 		case "modified":
 			sort.Sort(byModified(l))
+		case "taken":
+			sort.Sort(newByTaken(l))
 		default:
 			sort.Sort(byName(l))
 			return
@@ -106,3 +111,30 @@ func (l byModified) Less(i, j int) bool {
 	iModified, jModified := l.Items[i].ModTime, l.Items[j].ModTime
 	return iModified.Sub(jModified) < 0
 }
+
+// By Taken (EXIF capture date, or modified time as a fallback)
+type byTaken struct {
+	Listing
+	taken []time.Time
+}
+
+func newByTaken(l Listing) byTaken {
+	taken := make([]time.Time, len(l.Items))
+	for i, item := range l.Items {
+		taken[i] = item.DateTaken()
+	}
+	return byTaken{Listing: l, taken: taken}
+}
+
+func (l byTaken) Len() int {
+	return len(l.Items)
+}
+
+func (l byTaken) Swap(i, j int) {
+	l.Items[i], l.Items[j] = l.Items[j], l.Items[i]
+	l.taken[i], l.taken[j] = l.taken[j], l.taken[i]
+}
+
+func (l byTaken) Less(i, j int) bool {
+	return l.taken[i].Before(l.taken[j])
+}
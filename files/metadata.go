@@ -0,0 +1,113 @@
+package files
+
+import (
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// Metadata holds the structured metadata extracted from a media file.
+// Only the section matching the file type is filled.
+type Metadata struct {
+	Type  string         `json:"type"`
+	Image *ImageMetadata `json:"image,omitempty"`
+	Audio *AudioMetadata `json:"audio,omitempty"`
+	Video *VideoMetadata `json:"video,omitempty"`
+}
+
+// ImageMetadata is the EXIF information of an image.
+type ImageMetadata struct {
+	Make         string           `json:"make,omitempty"`
+	Model        string           `json:"model,omitempty"`
+	LensModel    string           `json:"lensModel,omitempty"`
+	ExposureTime string           `json:"exposureTime,omitempty"`
+	FNumber      float64          `json:"fNumber,omitempty"`
+	ISO          int              `json:"iso,omitempty"`
+	FocalLength  float64          `json:"focalLength,omitempty"`
+	Orientation  int              `json:"orientation,omitempty"`
+	DateTaken    *time.Time       `json:"dateTaken,omitempty"`
+	GPS          *GPSCoordinates  `json:"gps,omitempty"`
+	Resolution   *ImageResolution `json:"resolution,omitempty"`
+}
+
+// GPSCoordinates is a position in decimal degrees.
+type GPSCoordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude,omitempty"`
+}
+
+// AudioMetadata contains the tags (ID3 or Vorbis comments) and
+// stream information of an audio file.
+type AudioMetadata struct {
+	Format      string            `json:"format"`
+	Title       string            `json:"title,omitempty"`
+	Artist      string            `json:"artist,omitempty"`
+	Album       string            `json:"album,omitempty"`
+	AlbumArtist string            `json:"albumArtist,omitempty"`
+	Genre       string            `json:"genre,omitempty"`
+	Year        string            `json:"year,omitempty"`
+	Track       string            `json:"track,omitempty"`
+	Disc        string            `json:"disc,omitempty"`
+	Duration    float64           `json:"duration,omitempty"`
+	SampleRate  int               `json:"sampleRate,omitempty"`
+	Channels    int               `json:"channels,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// VideoMetadata describes the container of a video file and
+// the tracks stored inside it.
+type VideoMetadata struct {
+	Container string       `json:"container"`
+	Duration  float64      `json:"duration,omitempty"`
+	Tracks    []MediaTrack `json:"tracks"`
+}
+
+// MediaTrack is a single track of a media container.
+type MediaTrack struct {
+	Number   int    `json:"number"`
+	Type     string `json:"type"`
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+// Metadata reads the metadata of the file. The file type must have
+// been detected before, which is the case when the FileInfo was
+// created with the Expand option.
+func (i *FileInfo) Metadata() (*Metadata, error) {
+	if i.IsDir {
+		return nil, fberrors.ErrIsDirectory
+	}
+
+	fd, err := i.Fs.Open(i.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	meta := &Metadata{Type: i.Type}
+
+	switch i.Type {
+	case "image":
+		meta.Image, err = readImageMetadata(fd)
+		if err != nil {
+			return nil, err
+		}
+		meta.Image.Resolution = i.Resolution
+	case "audio":
+		meta.Audio, err = readAudioMetadata(fd, i.Size)
+	case "video":
+		meta.Video, err = readVideoMetadata(fd, i.Size)
+	default:
+		return nil, fberrors.ErrInvalidOption
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
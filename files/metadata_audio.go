@@ -0,0 +1,350 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// maxTagSize limits how much of a file is read to parse its tags.
+const maxTagSize = 8 * 1024 * 1024 // 8 MB
+
+var errNoTags = errors.New("no supported tags found")
+
+// id3Frames maps ID3v2.3/2.4 and ID3v2.2 frame identifiers to
+// the common Vorbis comment field names.
+var id3Frames = map[string]string{
+	"TIT2": "TITLE", "TT2": "TITLE",
+	"TPE1": "ARTIST", "TP1": "ARTIST",
+	"TPE2": "ALBUMARTIST", "TP2": "ALBUMARTIST",
+	"TALB": "ALBUM", "TAL": "ALBUM",
+	"TCON": "GENRE", "TCO": "GENRE",
+	"TYER": "DATE", "TYE": "DATE", "TDRC": "DATE",
+	"TRCK": "TRACKNUMBER", "TRK": "TRACKNUMBER",
+	"TPOS": "DISCNUMBER", "TPA": "DISCNUMBER",
+	"TCOM": "COMPOSER", "TCM": "COMPOSER",
+}
+
+// readAudioMetadata reads ID3 tags from MP3 files and Vorbis comments
+// from FLAC and Ogg (Vorbis/Opus) files.
+func readAudioMetadata(r io.ReadSeeker, size int64) (*AudioMetadata, error) {
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	meta := &AudioMetadata{Tags: map[string]string{}}
+
+	var err error
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		meta.Format = "mp3"
+		err = readID3v2(r, meta)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		meta.Format = "flac"
+		err = readFlac(r, meta)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		meta.Format = "ogg"
+		err = readOgg(r, meta)
+	default:
+		meta.Format = "unknown"
+		err = readID3v1(r, size, meta)
+		if err == nil {
+			meta.Format = "mp3"
+		}
+	}
+
+	if err != nil && !errors.Is(err, errNoTags) {
+		return nil, err
+	}
+
+	meta.fillFromTags()
+	return meta, nil
+}
+
+func (m *AudioMetadata) fillFromTags() {
+	m.Title = m.Tags["TITLE"]
+	m.Artist = m.Tags["ARTIST"]
+	m.Album = m.Tags["ALBUM"]
+	m.AlbumArtist = m.Tags["ALBUMARTIST"]
+	m.Genre = m.Tags["GENRE"]
+	m.Year = m.Tags["DATE"]
+	m.Track = m.Tags["TRACKNUMBER"]
+	m.Disc = m.Tags["DISCNUMBER"]
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+func readID3v2(r io.Reader, meta *AudioMetadata) error {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+
+	version := header[3]
+	size := syncsafe(header[6:10])
+	if size > maxTagSize {
+		return errNoTags
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return err
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for pos := 0; pos+headerLen <= len(body); {
+		id := string(body[pos : pos+idLen])
+		if id[0] == 0 {
+			break // padding
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(body[pos+3])<<16 | int(body[pos+4])<<8 | int(body[pos+5])
+		case 4:
+			frameSize = syncsafe(body[pos+4 : pos+8])
+		default:
+			frameSize = int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+		}
+
+		pos += headerLen
+		if frameSize <= 0 || pos+frameSize > len(body) {
+			break
+		}
+
+		if name, ok := id3Frames[id]; ok {
+			if value := decodeID3Text(body[pos : pos+frameSize]); value != "" {
+				meta.Tags[name] = value
+			}
+		}
+
+		pos += frameSize
+	}
+
+	return nil
+}
+
+func decodeID3Text(frame []byte) string {
+	if len(frame) < 2 {
+		return ""
+	}
+
+	var text string
+	data := frame[1:]
+	switch frame[0] {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+			order = binary.LittleEndian
+			data = data[2:]
+		} else if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+			data = data[2:]
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, order.Uint16(data[i:]))
+		}
+		text = string(utf16.Decode(units))
+	case 3: // UTF-8
+		text = string(data)
+	default: // ISO-8859-1
+		decoded, err := charmap.ISO8859_1.NewDecoder().Bytes(data)
+		if err != nil {
+			return ""
+		}
+		text = string(decoded)
+	}
+
+	// Multiple values are separated by NUL, only keep the first one.
+	if idx := strings.IndexRune(text, 0); idx >= 0 {
+		text = text[:idx]
+	}
+
+	return strings.TrimSpace(text)
+}
+
+func readID3v1(r io.ReadSeeker, size int64, meta *AudioMetadata) error {
+	if size < 128 {
+		return errNoTags
+	}
+	if _, err := r.Seek(size-128, io.SeekStart); err != nil {
+		return err
+	}
+
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(tag, []byte("TAG")) {
+		return errNoTags
+	}
+
+	field := func(b []byte) string {
+		decoded, _ := charmap.ISO8859_1.NewDecoder().Bytes(bytes.TrimRight(b, "\x00 "))
+		return strings.TrimSpace(string(decoded))
+	}
+
+	for name, value := range map[string]string{
+		"TITLE":  field(tag[3:33]),
+		"ARTIST": field(tag[33:63]),
+		"ALBUM":  field(tag[63:93]),
+		"DATE":   field(tag[93:97]),
+	} {
+		if value != "" {
+			meta.Tags[name] = value
+		}
+	}
+
+	// ID3v1.1 stores the track number in the last byte of the comment.
+	if tag[125] == 0 && tag[126] != 0 {
+		meta.Tags["TRACKNUMBER"] = strconv.Itoa(int(tag[126]))
+	}
+
+	return nil
+}
+
+func readFlac(r io.Reader, meta *AudioMetadata) error {
+	if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		switch blockType {
+		case 0, 4: // STREAMINFO, VORBIS_COMMENT
+			if length > maxTagSize {
+				return errNoTags
+			}
+			block := make([]byte, length)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return err
+			}
+			if blockType == 0 {
+				parseFlacStreamInfo(block, meta)
+			} else {
+				parseVorbisComment(block, meta)
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+				return err
+			}
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+func parseFlacStreamInfo(block []byte, meta *AudioMetadata) {
+	if len(block) < 18 {
+		return
+	}
+
+	// 20 bits sample rate, 3 bits channels-1, 5 bits bps-1, 36 bits total samples.
+	bits := binary.BigEndian.Uint64(block[10:18])
+	sampleRate := int(bits >> 44)
+	channels := int((bits>>41)&0x7) + 1
+	totalSamples := bits & 0xFFFFFFFFF
+
+	meta.SampleRate = sampleRate
+	meta.Channels = channels
+	if sampleRate > 0 {
+		meta.Duration = float64(totalSamples) / float64(sampleRate)
+	}
+}
+
+func readOgg(r io.Reader, meta *AudioMetadata) error {
+	head := make([]byte, 64*1024)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	head = head[:n]
+
+	if idx := bytes.Index(head, []byte("\x01vorbis")); idx >= 0 && len(head) >= idx+16 {
+		meta.Format = "vorbis"
+		meta.Channels = int(head[idx+11])
+		meta.SampleRate = int(binary.LittleEndian.Uint32(head[idx+12:]))
+	} else if idx := bytes.Index(head, []byte("OpusHead")); idx >= 0 && len(head) >= idx+16 {
+		meta.Format = "opus"
+		meta.Channels = int(head[idx+9])
+		meta.SampleRate = int(binary.LittleEndian.Uint32(head[idx+12:]))
+	}
+
+	if idx := bytes.Index(head, []byte("\x03vorbis")); idx >= 0 {
+		parseVorbisComment(head[idx+7:], meta)
+		return nil
+	}
+	if idx := bytes.Index(head, []byte("OpusTags")); idx >= 0 {
+		parseVorbisComment(head[idx+8:], meta)
+		return nil
+	}
+
+	return errNoTags
+}
+
+// parseVorbisComment parses a Vorbis comment block, as used by FLAC,
+// Ogg Vorbis and Opus. Truncated blocks are parsed as far as possible.
+func parseVorbisComment(block []byte, meta *AudioMetadata) {
+	readString := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+		length := int(binary.LittleEndian.Uint32(block))
+		block = block[4:]
+		if length > len(block) {
+			return "", false
+		}
+		value := string(block[:length])
+		block = block[length:]
+		return value, true
+	}
+
+	// vendor string
+	if _, ok := readString(); !ok || len(block) < 4 {
+		return
+	}
+
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+	for i := 0; i < count; i++ {
+		comment, ok := readString()
+		if !ok {
+			return
+		}
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+		key = strings.ToUpper(key)
+		if _, exists := meta.Tags[key]; !exists {
+			meta.Tags[key] = strings.TrimSpace(value)
+		}
+	}
+}
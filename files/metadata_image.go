@@ -0,0 +1,173 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// maxExifScan is how many bytes are scanned looking for the EXIF block.
+const maxExifScan = 2 * 1024 * 1024 // 2 MB
+
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// readImageMetadata reads the EXIF information of an image. Images
+// without EXIF data return an empty ImageMetadata.
+func readImageMetadata(r io.Reader) (*ImageMetadata, error) {
+	meta := &ImageMetadata{}
+
+	tags, err := readExifTags(r)
+	if errors.Is(err, exif.ErrNoExif) {
+		return meta, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var gpsLat, gpsLon []exifcommon.Rational
+	var gpsLatRef, gpsLonRef string
+	var gpsAlt *float64
+	var gpsAltBelow bool
+	var offset string
+
+	for _, tag := range tags {
+		switch tag.TagName {
+		case "Make":
+			meta.Make = exifString(tag.Value)
+		case "Model":
+			meta.Model = exifString(tag.Value)
+		case "LensModel":
+			meta.LensModel = exifString(tag.Value)
+		case "ExposureTime":
+			if r, ok := firstRational(tag.Value); ok && r.Denominator != 0 {
+				meta.ExposureTime = formatExposure(r)
+			}
+		case "FNumber":
+			meta.FNumber, _ = rationalFloat(tag.Value)
+		case "FocalLength":
+			meta.FocalLength, _ = rationalFloat(tag.Value)
+		case "ISOSpeedRatings", "PhotographicSensitivity":
+			if v, ok := tag.Value.([]uint16); ok && len(v) > 0 {
+				meta.ISO = int(v[0])
+			}
+		case "Orientation":
+			if v, ok := tag.Value.([]uint16); ok && len(v) > 0 {
+				meta.Orientation = int(v[0])
+			}
+		case "DateTimeOriginal":
+			if t, err := parseExifDate(exifString(tag.Value), offset); err == nil {
+				meta.DateTaken = &t
+			}
+		case "OffsetTimeOriginal":
+			offset = exifString(tag.Value)
+		case "GPSLatitude":
+			gpsLat, _ = tag.Value.([]exifcommon.Rational)
+		case "GPSLatitudeRef":
+			gpsLatRef = exifString(tag.Value)
+		case "GPSLongitude":
+			gpsLon, _ = tag.Value.([]exifcommon.Rational)
+		case "GPSLongitudeRef":
+			gpsLonRef = exifString(tag.Value)
+		case "GPSAltitude":
+			if v, ok := rationalFloat(tag.Value); ok {
+				gpsAlt = &v
+			}
+		case "GPSAltitudeRef":
+			if v, ok := tag.Value.([]byte); ok && len(v) > 0 {
+				gpsAltBelow = v[0] == 1
+			}
+		}
+	}
+
+	// The offset tag may come after the date.
+	if meta.DateTaken != nil && offset != "" {
+		if t, err := parseExifDate(meta.DateTaken.Format(exifDateLayout), offset); err == nil {
+			meta.DateTaken = &t
+		}
+	}
+
+	if len(gpsLat) == 3 && len(gpsLon) == 3 {
+		meta.GPS = &GPSCoordinates{
+			Latitude:  gpsDecimal(gpsLat, gpsLatRef == "S"),
+			Longitude: gpsDecimal(gpsLon, gpsLonRef == "W"),
+		}
+		if gpsAlt != nil {
+			meta.GPS.Altitude = *gpsAlt
+			if gpsAltBelow {
+				meta.GPS.Altitude = -meta.GPS.Altitude
+			}
+		}
+	}
+
+	return meta, nil
+}
+
+func readExifTags(r io.Reader) ([]exif.ExifTag, error) {
+	rawExif, err := exif.SearchAndExtractExifWithReader(io.LimitReader(r, maxExifScan))
+	if err != nil {
+		return nil, err
+	}
+
+	tags, _, err := exif.GetFlatExifData(rawExif, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func exifString(v interface{}) string {
+	s, _ := v.(string)
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+func firstRational(v interface{}) (exifcommon.Rational, bool) {
+	r, ok := v.([]exifcommon.Rational)
+	if !ok || len(r) == 0 {
+		return exifcommon.Rational{}, false
+	}
+	return r[0], true
+}
+
+func rationalFloat(v interface{}) (float64, bool) {
+	r, ok := firstRational(v)
+	if !ok || r.Denominator == 0 {
+		return 0, false
+	}
+	return float64(r.Numerator) / float64(r.Denominator), true
+}
+
+func formatExposure(r exifcommon.Rational) string {
+	if r.Numerator == 0 {
+		return "0"
+	}
+	if r.Numerator >= r.Denominator {
+		return fmt.Sprintf("%g", float64(r.Numerator)/float64(r.Denominator))
+	}
+	return fmt.Sprintf("1/%d", (r.Denominator+r.Numerator/2)/r.Numerator)
+}
+
+func gpsDecimal(parts []exifcommon.Rational, negative bool) float64 {
+	var value float64
+	for i, div := range []float64{1, 60, 3600} {
+		if parts[i].Denominator == 0 {
+			continue
+		}
+		value += float64(parts[i].Numerator) / float64(parts[i].Denominator) / div
+	}
+	if negative {
+		value = -value
+	}
+	return value
+}
+
+func parseExifDate(value, offset string) (time.Time, error) {
+	if offset != "" {
+		return time.Parse(exifDateLayout+"-07:00", value+offset)
+	}
+	return time.ParseInLocation(exifDateLayout, value, time.Local)
+}
@@ -0,0 +1,256 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadImageMetadata(t *testing.T) {
+	testCases := map[string]*ImageMetadata{
+		"IMG_2578.JPG": {
+			Make:         "Apple",
+			Model:        "iPhone 6 Plus",
+			LensModel:    "iPhone 6 Plus back camera 4.15mm f/2.2",
+			ExposureTime: "1/2198",
+			FNumber:      2.2,
+			ISO:          32,
+			FocalLength:  4.15,
+			DateTaken:    timePtr(time.Date(2015, 10, 2, 14, 32, 29, 0, time.Local)),
+			GPS: &GPSCoordinates{
+				Latitude:  13.751577777777777,
+				Longitude: 100.49263888888889,
+				Altitude:  10.593506493506494,
+			},
+		},
+		"20130612_142406.jpg": {
+			Make:         "SAMSUNG",
+			Model:        "GT-N7000",
+			ExposureTime: "1/714",
+			FNumber:      2.65,
+			ISO:          32,
+			FocalLength:  3.97,
+			Orientation:  1,
+			DateTaken:    timePtr(time.Date(2013, 6, 12, 14, 24, 6, 0, time.Local)),
+		},
+		"gray-sample.jpg": {Orientation: 1},
+	}
+
+	for name, want := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fd, err := os.Open("../img/testdata/" + name)
+			require.NoError(t, err)
+			defer fd.Close()
+
+			meta, err := readImageMetadata(fd)
+			require.NoError(t, err)
+			require.Equal(t, want, meta)
+		})
+	}
+
+	// no EXIF block
+	meta, err := readImageMetadata(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n")))
+	require.NoError(t, err)
+	require.Equal(t, &ImageMetadata{}, meta)
+}
+
+func TestFormatExposure(t *testing.T) {
+	require.Equal(t, "0", formatExposure(rational(0, 1)))
+	require.Equal(t, "1/250", formatExposure(rational(1, 250)))
+	require.Equal(t, "1/3", formatExposure(rational(10, 32)))
+	require.Equal(t, "2.5", formatExposure(rational(5, 2)))
+}
+
+func TestReadAudioMetadata(t *testing.T) {
+	id3v1 := make([]byte, 128)
+	copy(id3v1, "TAG")
+	copy(id3v1[3:], "Title")
+	copy(id3v1[33:], "Artist")
+	copy(id3v1[63:], "Album")
+	copy(id3v1[93:], "1999")
+	id3v1[126] = 7
+
+	testCases := map[string]struct {
+		data []byte
+		want *AudioMetadata
+	}{
+		"id3v2.3": {
+			id3v2(3,
+				id3Frame(3, "TIT2", "\x00Caf\xe9"),
+				id3Frame(3, "TPE1", "\x01\xff\xfeA\x00r\x00t\x00"),
+				id3Frame(3, "TALB", "\x03Album\x00Other"),
+				id3Frame(3, "TXXX", "\x03ignored"),
+			),
+			&AudioMetadata{
+				Format: "mp3", Title: "Café", Artist: "Art", Album: "Album",
+				Tags: map[string]string{"TITLE": "Café", "ARTIST": "Art", "ALBUM": "Album"},
+			},
+		},
+		"id3v2.4": {
+			id3v2(4, id3Frame(4, "TRCK", "\x032/10"), id3Frame(4, "TDRC", "\x032020")),
+			&AudioMetadata{
+				Format: "mp3", Track: "2/10", Year: "2020",
+				Tags: map[string]string{"TRACKNUMBER": "2/10", "DATE": "2020"},
+			},
+		},
+		"id3v2.2": {
+			id3v2(2, id3Frame(2, "TT2", "\x00Old")),
+			&AudioMetadata{Format: "mp3", Title: "Old", Tags: map[string]string{"TITLE": "Old"}},
+		},
+		"id3v1": {
+			append(make([]byte, 100), id3v1...),
+			&AudioMetadata{
+				Format: "mp3", Title: "Title", Artist: "Artist", Album: "Album", Year: "1999", Track: "7",
+				Tags: map[string]string{"TITLE": "Title", "ARTIST": "Artist", "ALBUM": "Album", "DATE": "1999", "TRACKNUMBER": "7"},
+			},
+		},
+		"flac": {
+			flac(),
+			&AudioMetadata{
+				Format: "flac", Title: "Song", Genre: "Jazz", SampleRate: 44100, Channels: 2, Duration: 10,
+				Tags: map[string]string{"TITLE": "Song", "GENRE": "Jazz"},
+			},
+		},
+		"unknown": {
+			make([]byte, 200),
+			&AudioMetadata{Format: "unknown", Tags: map[string]string{}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			meta, err := readAudioMetadata(bytes.NewReader(tc.data), int64(len(tc.data)))
+			require.NoError(t, err)
+			require.Equal(t, tc.want, meta)
+		})
+	}
+}
+
+func TestReadVideoMetadata(t *testing.T) {
+	video := isoBox("trak",
+		isoBox("tkhd", append(make([]byte, 76), 0x07, 0x80, 0, 0, 0x04, 0x38, 0, 0)),
+		isoBox("mdia",
+			isoBox("mdhd", append(make([]byte, 20), 0x15, 0xC7)), // "eng"
+			isoBox("hdlr", []byte("\x00\x00\x00\x00\x00\x00\x00\x00vide")),
+			isoBox("minf", isoBox("stbl", isoBox("stsd", []byte("\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10avc1")))),
+		),
+	)
+	audio := isoBox("trak",
+		isoBox("tkhd", make([]byte, 84)),
+		isoBox("mdia", isoBox("hdlr", []byte("\x00\x00\x00\x00\x00\x00\x00\x00soun"))),
+	)
+	mvhd := binary.BigEndian.AppendUint32(make([]byte, 12), 1000)
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 90500)
+
+	testCases := map[string]struct {
+		brand string
+		want  string
+	}{
+		"mp4": {"isom", "mp4"},
+		"mov": {"qt  ", "mov"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			data := bytes.Join([][]byte{
+				isoBox("ftyp", []byte(tc.brand+"\x00\x00\x00\x00")),
+				isoBox("moov", isoBox("mvhd", mvhd), video, audio),
+				isoBox("mdat", make([]byte, 16)),
+			}, nil)
+
+			meta, err := readVideoMetadata(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			require.Equal(t, &VideoMetadata{
+				Container: tc.want,
+				Duration:  90.5,
+				Tracks: []MediaTrack{
+					{Number: 1, Type: "video", Codec: "avc1", Language: "eng", Width: 1920, Height: 1080},
+					{Number: 2, Type: "audio"},
+				},
+			}, meta)
+		})
+	}
+
+	_, err := readVideoMetadata(bytes.NewReader(make([]byte, 16)), 16)
+	require.ErrorIs(t, err, errUnknownContainer)
+
+	// a box larger than the file
+	data := append(isoBox("ftyp", []byte("isom")), 0, 0, 1, 0, 'm', 'o', 'o', 'v')
+	_, err = readVideoMetadata(bytes.NewReader(data), int64(len(data)))
+	require.Error(t, err)
+
+	// an element header running past the end of its parent, found by fuzzing
+	data = []byte("\x1aE\xdf\xa3\x86A0\x8200A0\x80")
+	meta, err := readVideoMetadata(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Equal(t, "matroska", meta.Container)
+	cues, err := readMatroskaCues(bytes.NewReader(data), int64(len(data)), 1, "S_TEXT/UTF8")
+	require.NoError(t, err)
+	require.Empty(t, cues)
+}
+
+func rational(numerator, denominator uint32) exifcommon.Rational {
+	return exifcommon.Rational{Numerator: numerator, Denominator: denominator}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func id3v2(version byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 16)...) // padding
+	size := len(body)
+	header := []byte{'I', 'D', '3', version, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	return append(header, body...)
+}
+
+func id3Frame(version byte, id, content string) []byte {
+	size := len(content)
+	frame := []byte(id)
+	switch version {
+	case 2:
+		frame = append(frame, byte(size>>16), byte(size>>8), byte(size))
+	case 4:
+		frame = append(frame, byte(size>>21&0x7F), byte(size>>14&0x7F), byte(size>>7&0x7F), byte(size&0x7F), 0, 0)
+	default:
+		frame = binary.BigEndian.AppendUint32(frame, uint32(size))
+		frame = append(frame, 0, 0)
+	}
+	return append(frame, content...)
+}
+
+func flac() []byte {
+	// 44.1 kHz, 2 channels, 16 bits, 441000 samples
+	streamInfo := make([]byte, 34)
+	bits := uint64(44100)<<44 | uint64(1)<<41 | uint64(15)<<36 | 441000
+	binary.BigEndian.PutUint64(streamInfo[10:], bits)
+
+	comment := binary.LittleEndian.AppendUint32(nil, 6)
+	comment = append(comment, "vendor"...)
+	comment = binary.LittleEndian.AppendUint32(comment, 3)
+	for _, field := range []string{"title=Song", "GENRE=Jazz", "invalid"} {
+		comment = binary.LittleEndian.AppendUint32(comment, uint32(len(field)))
+		comment = append(comment, field...)
+	}
+
+	data := []byte("fLaC")
+	data = append(data, 0, 0, 0, byte(len(streamInfo)))
+	data = append(data, streamInfo...)
+	data = append(data, 1, 0, 0, 4, 0, 0, 0, 0) // padding
+	data = append(data, 0x84, 0, byte(len(comment)>>8), byte(len(comment)))
+	return append(data, comment...)
+}
+
+func isoBox(typ string, children ...[]byte) []byte {
+	content := bytes.Join(children, nil)
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+	box = append(box, typ...)
+	return append(box, content...)
+}
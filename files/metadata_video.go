@@ -0,0 +1,480 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+var errUnknownContainer = errors.New("unknown media container")
+
+// readVideoMetadata detects the container (ISO BMFF or Matroska) and
+// reads its duration and track list.
+func readVideoMetadata(r io.ReadSeeker, size int64) (*VideoMetadata, error) {
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(head[4:8], []byte("ftyp")):
+		return readMP4(r, size)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return readMatroska(r, size)
+	default:
+		return nil, errUnknownContainer
+	}
+}
+
+/* ISO base media file format (mp4, mov, m4v, 3gp) */
+
+type mp4Box struct {
+	typ        string
+	start, end int64
+}
+
+func readMP4Box(r io.ReadSeeker, offset, limit int64) (*mp4Box, error) {
+	if offset+8 > limit {
+		return nil, io.EOF
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return nil, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:4]))
+	box := &mp4Box{typ: string(header[4:8]), start: offset + 8}
+
+	switch size {
+	case 0:
+		size = limit - offset
+	case 1:
+		if _, err := io.ReadFull(r, header[8:16]); err != nil {
+			return nil, err
+		}
+		size = int64(binary.BigEndian.Uint64(header[8:16]))
+		box.start += 8
+	}
+
+	box.end = offset + size
+	if size < 8 || box.end > limit {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return box, nil
+}
+
+func readMP4BoxData(r io.ReadSeeker, box *mp4Box, max int64) ([]byte, error) {
+	length := box.end - box.start
+	if length > max {
+		length = max
+	}
+	if _, err := r.Seek(box.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// walkMP4 calls fn for every box between offset and limit. If fn returns
+// true the box is descended into.
+func walkMP4(r io.ReadSeeker, offset, limit int64, fn func(box *mp4Box) (bool, error)) error {
+	for offset < limit {
+		box, err := readMP4Box(r, offset, limit)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		descend, err := fn(box)
+		if err != nil {
+			return err
+		}
+		if descend {
+			if err := walkMP4(r, box.start, box.end, fn); err != nil {
+				return err
+			}
+		}
+
+		offset = box.end
+	}
+	return nil
+}
+
+func readMP4(r io.ReadSeeker, size int64) (*VideoMetadata, error) {
+	meta := &VideoMetadata{Container: "mp4", Tracks: []MediaTrack{}}
+	var track *MediaTrack
+
+	err := walkMP4(r, 0, size, func(box *mp4Box) (bool, error) {
+		switch box.typ {
+		case "moov", "mdia", "minf", "stbl":
+			return true, nil
+		case "trak":
+			meta.Tracks = append(meta.Tracks, MediaTrack{Number: len(meta.Tracks) + 1})
+			track = &meta.Tracks[len(meta.Tracks)-1]
+			return true, nil
+		case "ftyp":
+			data, err := readMP4BoxData(r, box, 4)
+			if err != nil {
+				return false, err
+			}
+			if brand := strings.TrimSpace(string(data)); brand == "qt" {
+				meta.Container = "mov"
+			}
+		case "mvhd":
+			data, err := readMP4BoxData(r, box, 32)
+			if err != nil {
+				return false, err
+			}
+			timescale, duration := mp4TimeInfo(data)
+			if timescale > 0 {
+				meta.Duration = float64(duration) / float64(timescale)
+			}
+		case "tkhd":
+			if track == nil {
+				return false, nil
+			}
+			data, err := readMP4BoxData(r, box, 96)
+			if err != nil {
+				return false, err
+			}
+			if len(data) >= 8 {
+				// width and height are 16.16 fixed point values at the end
+				track.Width = int(binary.BigEndian.Uint32(data[len(data)-8:]) >> 16)
+				track.Height = int(binary.BigEndian.Uint32(data[len(data)-4:]) >> 16)
+			}
+		case "mdhd":
+			if track == nil {
+				return false, nil
+			}
+			data, err := readMP4BoxData(r, box, 36)
+			if err != nil {
+				return false, err
+			}
+			track.Language = mp4Language(data)
+		case "hdlr":
+			if track == nil {
+				return false, nil
+			}
+			data, err := readMP4BoxData(r, box, 12)
+			if err != nil {
+				return false, err
+			}
+			if len(data) >= 12 {
+				track.Type = mp4HandlerType(string(data[8:12]))
+			}
+		case "stsd":
+			if track == nil {
+				return false, nil
+			}
+			data, err := readMP4BoxData(r, box, 16)
+			if err != nil {
+				return false, err
+			}
+			if len(data) >= 16 {
+				track.Codec = strings.TrimSpace(string(data[12:16]))
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range meta.Tracks {
+		if meta.Tracks[i].Type != "video" {
+			meta.Tracks[i].Width, meta.Tracks[i].Height = 0, 0
+		}
+	}
+
+	return meta, nil
+}
+
+func mp4TimeInfo(data []byte) (timescale uint32, duration uint64) {
+	if len(data) < 20 {
+		return 0, 0
+	}
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(data[20:24]), binary.BigEndian.Uint64(data[24:32])
+	}
+	return binary.BigEndian.Uint32(data[12:16]), uint64(binary.BigEndian.Uint32(data[16:20]))
+}
+
+func mp4Language(data []byte) string {
+	offset := 20
+	if len(data) > 0 && data[0] == 1 {
+		offset = 32
+	}
+	if len(data) < offset+2 {
+		return ""
+	}
+
+	// ISO-639-2/T packed as three 5 bit values
+	packed := binary.BigEndian.Uint16(data[offset:])
+	lang := []byte{
+		byte(packed>>10&0x1F) + 0x60,
+		byte(packed>>5&0x1F) + 0x60,
+		byte(packed&0x1F) + 0x60,
+	}
+	if lang[0] < 'a' || lang[0] > 'z' || string(lang) == "und" {
+		return ""
+	}
+	return string(lang)
+}
+
+func mp4HandlerType(handler string) string {
+	switch handler {
+	case "vide":
+		return "video"
+	case "soun":
+		return "audio"
+	case "subt", "text", "sbtl", "clcp":
+		return "subtitle"
+	default:
+		return handler
+	}
+}
+
+/* Matroska / WebM */
+
+const (
+	ebmlDocType        = 0x4282
+	mkvSegment         = 0x18538067
+	mkvInfo            = 0x1549A966
+	mkvTimecodeScale   = 0x2AD7B1
+	mkvDuration        = 0x4489
+	mkvTracks          = 0x1654AE6B
+	mkvTrackEntry      = 0xAE
+	mkvTrackNumber     = 0xD7
+	mkvTrackType       = 0x83
+	mkvCodecID         = 0x86
+	mkvLanguage        = 0x22B59C
+	mkvLanguageBCP47   = 0x22B59D
+	mkvName            = 0x536E
+	mkvVideo           = 0xE0
+	mkvPixelWidth      = 0xB0
+	mkvPixelHeight     = 0xBA
+	mkvCluster         = 0x1F43B675
+	maxEBMLElementRead = 1024 * 1024
+)
+
+type ebmlElement struct {
+	id         uint64
+	start, end int64
+}
+
+// readEBMLVint reads an EBML variable size integer. When keepMarker is
+// true the length marker bit is kept (used for element IDs).
+func readEBMLVint(r io.Reader, keepMarker bool) (uint64, int, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(r, first); err != nil {
+		return 0, 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, errUnknownContainer
+	}
+
+	value := uint64(first[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> length)
+	}
+
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, err
+	}
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length, nil
+}
+
+func readEBMLElement(r io.ReadSeeker, offset, limit int64) (*ebmlElement, error) {
+	if offset >= limit {
+		return nil, io.EOF
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	id, idLen, err := readEBMLVint(r, true)
+	if err != nil {
+		return nil, err
+	}
+	size, sizeLen, err := readEBMLVint(r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	el := &ebmlElement{id: id, start: offset + int64(idLen+sizeLen)}
+	if el.start > limit {
+		// the header runs past the end of the parent
+		return nil, io.ErrUnexpectedEOF
+	}
+	if size == (uint64(1)<<(7*sizeLen))-1 {
+		el.end = limit // unknown size, spans until the end of the parent
+	} else {
+		el.end = el.start + int64(size)
+	}
+	if el.end > limit {
+		el.end = limit
+	}
+
+	return el, nil
+}
+
+func readEBMLData(r io.ReadSeeker, el *ebmlElement) ([]byte, error) {
+	length := el.end - el.start
+	if length < 0 || length > maxEBMLElementRead {
+		return nil, errUnknownContainer
+	}
+	if _, err := r.Seek(el.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+func ebmlUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	default:
+		return 0
+	}
+}
+
+// walkEBML calls fn for every element between offset and limit. If fn
+// returns true the element is descended into. Returning io.EOF from fn
+// stops the walk without error.
+func walkEBML(r io.ReadSeeker, offset, limit int64, fn func(el *ebmlElement) (bool, error)) error {
+	for offset < limit {
+		el, err := readEBMLElement(r, offset, limit)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		descend, err := fn(el)
+		if err != nil {
+			return err
+		}
+		if descend {
+			if err := walkEBML(r, el.start, el.end, fn); err != nil {
+				return err
+			}
+		}
+
+		offset = el.end
+	}
+	return nil
+}
+
+func readMatroska(r io.ReadSeeker, size int64) (*VideoMetadata, error) {
+	meta := &VideoMetadata{Container: "matroska", Tracks: []MediaTrack{}}
+	timecodeScale := uint64(1000000)
+	var duration float64
+	var track *MediaTrack
+
+	err := walkEBML(r, 0, size, func(el *ebmlElement) (bool, error) {
+		switch el.id {
+		case 0x1A45DFA3, mkvSegment, mkvInfo, mkvTracks, mkvVideo:
+			return true, nil
+		case mkvCluster:
+			// Headers are always written before the first cluster.
+			return false, io.EOF
+		case mkvTrackEntry:
+			meta.Tracks = append(meta.Tracks, MediaTrack{Language: "eng"})
+			track = &meta.Tracks[len(meta.Tracks)-1]
+			return true, nil
+		}
+
+		data, err := readEBMLData(r, el)
+		if err != nil {
+			return false, err
+		}
+
+		switch el.id {
+		case ebmlDocType:
+			meta.Container = string(data)
+		case mkvTimecodeScale:
+			timecodeScale = ebmlUint(data)
+		case mkvDuration:
+			duration = ebmlFloat(data)
+		}
+
+		if track == nil {
+			return false, nil
+		}
+
+		switch el.id {
+		case mkvTrackNumber:
+			track.Number = int(ebmlUint(data))
+		case mkvTrackType:
+			track.Type = mkvTrackTypeName(ebmlUint(data))
+		case mkvCodecID:
+			track.Codec = string(data)
+		case mkvLanguage, mkvLanguageBCP47:
+			track.Language = string(data)
+		case mkvName:
+			track.Name = string(data)
+		case mkvPixelWidth:
+			track.Width = int(ebmlUint(data))
+		case mkvPixelHeight:
+			track.Height = int(ebmlUint(data))
+		}
+		return false, nil
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	meta.Duration = duration * float64(timecodeScale) / 1e9
+	return meta, nil
+}
+
+func mkvTrackTypeName(t uint64) string {
+	switch t {
+	case 1:
+		return "video"
+	case 2:
+		return "audio"
+	case 0x11:
+		return "subtitle"
+	default:
+		return "other"
+	}
+}
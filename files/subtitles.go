@@ -0,0 +1,474 @@
+package files
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+const (
+	mkvClusterTimecode = 0xE7
+	mkvSimpleBlock     = 0xA3
+	mkvBlockGroup      = 0xA0
+	mkvBlock           = 0xA1
+	mkvBlockDuration   = 0x9B
+
+	// maxSubtitleTable limits the size of the MP4 sample tables loaded
+	// when extracting a subtitle track.
+	maxSubtitleTable = 16 * 1024 * 1024
+	maxSubtitleCue   = 64 * 1024
+
+	// defaultCueDuration is used for the cues whose end isn't stored
+	// in the container.
+	defaultCueDuration = 5 * time.Second
+)
+
+var (
+	reEmbeddedSubtitle = regexp.MustCompile(`^(.+)/track-(\d+)\.vtt$`)
+	reAssOverride      = regexp.MustCompile(`\{[^}]*\}`)
+	reBlankLines       = regexp.MustCompile(`\n\s*\n`)
+)
+
+// textSubtitleCodecs are the codecs of the embedded subtitles that can
+// be extracted as WebVTT. Image based subtitles (PGS, VobSub) can't.
+var textSubtitleCodecs = map[string]bool{
+	"S_TEXT/UTF8":   true,
+	"S_TEXT/ASS":    true,
+	"S_TEXT/SSA":    true,
+	"S_TEXT/WEBVTT": true,
+	"tx3g":          true,
+}
+
+type subtitleCue struct {
+	start, end time.Duration
+	text       string
+}
+
+// EmbeddedSubtitlePath returns the path used in Subtitles to reference a
+// subtitle track embedded in a video. It is a virtual file inside the video.
+func EmbeddedSubtitlePath(video string, track int) string {
+	return path.Join(video, "track-"+strconv.Itoa(track)+".vtt")
+}
+
+// ParseEmbeddedSubtitlePath is the inverse of EmbeddedSubtitlePath.
+func ParseEmbeddedSubtitlePath(p string) (video string, track int, ok bool) {
+	match := reEmbeddedSubtitle.FindStringSubmatch(p)
+	if match == nil {
+		return "", 0, false
+	}
+
+	track, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], track, true
+}
+
+// detectEmbeddedSubtitles adds the text subtitle tracks stored in the
+// video container to Subtitles.
+func (i *FileInfo) detectEmbeddedSubtitles() {
+	fd, err := i.Fs.Open(i.Path)
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+
+	meta, err := readVideoMetadata(fd, i.Size)
+	if err != nil {
+		if !errors.Is(err, errUnknownContainer) {
+			log.Printf("Error reading tracks of %s: %v", i.Path, err)
+		}
+		return
+	}
+
+	for _, track := range meta.Tracks {
+		if track.Type == "subtitle" && textSubtitleCodecs[track.Codec] {
+			i.addSubtitle(EmbeddedSubtitlePath(i.Path, track.Number))
+		}
+	}
+}
+
+// ExtractSubtitle writes the given embedded subtitle track as WebVTT.
+func (i *FileInfo) ExtractSubtitle(track int, w io.Writer) error {
+	fd, err := i.Fs.Open(i.Path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	meta, err := readVideoMetadata(fd, i.Size)
+	if errors.Is(err, errUnknownContainer) {
+		return fberrors.ErrInvalidOption
+	} else if err != nil {
+		return err
+	}
+
+	var info *MediaTrack
+	for idx := range meta.Tracks {
+		if meta.Tracks[idx].Number == track && meta.Tracks[idx].Type == "subtitle" {
+			info = &meta.Tracks[idx]
+		}
+	}
+	if info == nil {
+		return fberrors.ErrNotExist
+	}
+	if !textSubtitleCodecs[info.Codec] {
+		return fberrors.ErrInvalidOption
+	}
+
+	var cues []subtitleCue
+	if meta.Container == "mp4" || meta.Container == "mov" {
+		cues, err = readMP4Cues(fd, i.Size, track)
+	} else {
+		cues, err = readMatroskaCues(fd, i.Size, track, info.Codec)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeWebVTT(w, cues)
+}
+
+func writeWebVTT(w io.Writer, cues []subtitleCue) error {
+	sort.SliceStable(cues, func(a, b int) bool {
+		return cues[a].start < cues[b].start
+	})
+
+	for idx := range cues {
+		if cues[idx].end > cues[idx].start {
+			continue
+		}
+		cues[idx].end = cues[idx].start + defaultCueDuration
+		if idx+1 < len(cues) && cues[idx+1].start > cues[idx].start {
+			cues[idx].end = min(cues[idx].end, cues[idx+1].start)
+		}
+	}
+
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+
+	for _, cue := range cues {
+		text := strings.TrimSpace(reBlankLines.ReplaceAllString(cue.text, "\n"))
+		if text == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, "-->", "->")
+
+		_, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", vttTimestamp(cue.start), vttTimestamp(cue.end), text)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func vttTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// assText returns the text of an ASS/SSA event as stored in Matroska
+// (ReadOrder, Layer, Style, Name, MarginL, MarginR, MarginV, Effect, Text)
+// without the override tags.
+func assText(event string) string {
+	fields := strings.SplitN(event, ",", 9)
+	text := fields[len(fields)-1]
+	text = reAssOverride.ReplaceAllString(text, "")
+	text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+	return text
+}
+
+/* Matroska / WebM */
+
+func readMatroskaCues(r io.ReadSeeker, size int64, track int, codec string) ([]subtitleCue, error) {
+	var cues []subtitleCue
+	timecodeScale := uint64(1000000)
+	var clusterTimecode int64
+
+	addCue := func(relative int16, duration uint64, data []byte) {
+		start := (clusterTimecode + int64(relative)) * int64(timecodeScale)
+		cue := subtitleCue{
+			start: time.Duration(start),
+			end:   time.Duration(start + int64(duration*timecodeScale)),
+			text:  string(data),
+		}
+		if codec == "S_TEXT/ASS" || codec == "S_TEXT/SSA" {
+			cue.text = assText(cue.text)
+		}
+		cues = append(cues, cue)
+	}
+
+	err := walkEBML(r, 0, size, func(el *ebmlElement) (bool, error) {
+		switch el.id {
+		case mkvSegment, mkvInfo, mkvCluster:
+			return true, nil
+		case mkvTimecodeScale, mkvClusterTimecode:
+			data, err := readEBMLData(r, el)
+			if err != nil {
+				return false, err
+			}
+			if el.id == mkvTimecodeScale {
+				timecodeScale = ebmlUint(data)
+			} else {
+				clusterTimecode = int64(ebmlUint(data))
+			}
+		case mkvSimpleBlock:
+			relative, data, ok, err := readMatroskaBlock(r, el, track)
+			if err != nil || !ok {
+				return false, err
+			}
+			addCue(relative, 0, data)
+		case mkvBlockGroup:
+			var (
+				relative int16
+				duration uint64
+				data     []byte
+				found    bool
+			)
+			err := walkEBML(r, el.start, el.end, func(child *ebmlElement) (bool, error) {
+				var err error
+				switch child.id {
+				case mkvBlock:
+					relative, data, found, err = readMatroskaBlock(r, child, track)
+				case mkvBlockDuration:
+					var value []byte
+					value, err = readEBMLData(r, child)
+					duration = ebmlUint(value)
+				}
+				return false, err
+			})
+			if err != nil {
+				return false, err
+			}
+			if found {
+				addCue(relative, duration, data)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cues, nil
+}
+
+// readMatroskaBlock reads a (Simple)Block if it belongs to the given track.
+// The frame data is only loaded for that track.
+func readMatroskaBlock(r io.ReadSeeker, el *ebmlElement, track int) (int16, []byte, bool, error) {
+	if _, err := r.Seek(el.start, io.SeekStart); err != nil {
+		return 0, nil, false, err
+	}
+
+	number, length, err := readEBMLVint(r, false)
+	if err != nil || int(number) != track {
+		return 0, nil, false, err
+	}
+
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, false, err
+	}
+
+	size := el.end - el.start - int64(length) - 3
+	if size < 0 || size > maxSubtitleCue {
+		return 0, nil, false, errUnknownContainer
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, false, err
+	}
+
+	return int16(binary.BigEndian.Uint16(header)), data, true, nil
+}
+
+/* ISO base media file format */
+
+type mp4SampleTables struct {
+	timescale uint32
+	stts      []byte
+	stsc      []byte
+	stsz      []byte
+	stco      []byte
+	co64      bool
+}
+
+func readMP4Cues(r io.ReadSeeker, size int64, track int) ([]subtitleCue, error) {
+	tables := &mp4SampleTables{}
+	current := 0
+
+	err := walkMP4(r, 0, size, func(box *mp4Box) (bool, error) {
+		var err error
+		switch box.typ {
+		case "moov":
+			return true, nil
+		case "trak":
+			current++
+			return current == track, nil
+		case "mdia", "minf", "stbl":
+			return current == track, nil
+		case "mdhd":
+			var data []byte
+			data, err = readMP4BoxData(r, box, 32)
+			tables.timescale, _ = mp4TimeInfo(data)
+		case "stts":
+			tables.stts, err = readMP4BoxData(r, box, maxSubtitleTable)
+		case "stsc":
+			tables.stsc, err = readMP4BoxData(r, box, maxSubtitleTable)
+		case "stsz":
+			tables.stsz, err = readMP4BoxData(r, box, maxSubtitleTable)
+		case "stco", "co64":
+			tables.stco, err = readMP4BoxData(r, box, maxSubtitleTable)
+			tables.co64 = box.typ == "co64"
+		}
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tables.timescale == 0 {
+		return nil, errUnknownContainer
+	}
+
+	offsets, sizes := tables.samples()
+	times := tables.times(len(sizes))
+
+	cues := make([]subtitleCue, 0, len(sizes))
+	for idx := range sizes {
+		// an empty sample only holds its 2 bytes length and clears
+		// the screen
+		if sizes[idx] <= 2 || sizes[idx] > maxSubtitleCue {
+			continue
+		}
+
+		if _, err := r.Seek(offsets[idx], io.SeekStart); err != nil {
+			return nil, err
+		}
+		data := make([]byte, sizes[idx])
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		length := int(binary.BigEndian.Uint16(data))
+		if length > len(data)-2 {
+			length = len(data) - 2
+		}
+
+		cues = append(cues, subtitleCue{
+			start: tables.duration(times[idx]),
+			end:   tables.duration(times[idx+1]),
+			text:  string(data[2 : 2+length]),
+		})
+	}
+
+	return cues, nil
+}
+
+func (t *mp4SampleTables) duration(units uint64) time.Duration {
+	return time.Duration(units * uint64(time.Second) / uint64(t.timescale))
+}
+
+// entries returns the entries of a sample table box (after the version,
+// flags and entry count fields) and their count.
+func mp4TableEntries(data []byte, entrySize int) ([]byte, int) {
+	if len(data) < 8 {
+		return nil, 0
+	}
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	data = data[8:]
+	if count > len(data)/entrySize {
+		count = len(data) / entrySize
+	}
+	return data, count
+}
+
+// samples returns the offset and size of every sample.
+func (t *mp4SampleTables) samples() ([]int64, []int64) {
+	var sizes []int64
+	if len(t.stsz) >= 12 {
+		fixed := int64(binary.BigEndian.Uint32(t.stsz[4:8]))
+		count := int(binary.BigEndian.Uint32(t.stsz[8:12]))
+		if fixed != 0 {
+			for range min(count, maxSubtitleTable) {
+				sizes = append(sizes, fixed)
+			}
+		} else {
+			entries := t.stsz[12:]
+			count = min(count, len(entries)/4)
+			for idx := range count {
+				sizes = append(sizes, int64(binary.BigEndian.Uint32(entries[idx*4:])))
+			}
+		}
+	}
+
+	chunkSize := 4
+	if t.co64 {
+		chunkSize = 8
+	}
+	chunks, chunkCount := mp4TableEntries(t.stco, chunkSize)
+	stsc, stscCount := mp4TableEntries(t.stsc, 12)
+
+	offsets := make([]int64, 0, len(sizes))
+	sample := 0
+	for chunk := 0; chunk < chunkCount && sample < len(sizes); chunk++ {
+		var offset int64
+		if t.co64 {
+			offset = int64(binary.BigEndian.Uint64(chunks[chunk*8:]))
+		} else {
+			offset = int64(binary.BigEndian.Uint32(chunks[chunk*4:]))
+		}
+
+		// find the samples per chunk of the last run starting at or
+		// before this chunk (runs use 1-based chunk numbers)
+		perChunk := 1
+		for run := 0; run < stscCount; run++ {
+			if int(binary.BigEndian.Uint32(stsc[run*12:])) > chunk+1 {
+				break
+			}
+			perChunk = int(binary.BigEndian.Uint32(stsc[run*12+4:]))
+		}
+
+		for n := 0; n < perChunk && sample < len(sizes); n++ {
+			offsets = append(offsets, offset)
+			offset += sizes[sample]
+			sample++
+		}
+	}
+
+	return offsets, sizes[:len(offsets)]
+}
+
+// times returns the start time of every sample plus the end of the last
+// one, in timescale units.
+func (t *mp4SampleTables) times(samples int) []uint64 {
+	times := make([]uint64, 0, samples+1)
+	entries, count := mp4TableEntries(t.stts, 8)
+
+	var current uint64
+	for run := 0; run < count && len(times) < samples; run++ {
+		n := int(binary.BigEndian.Uint32(entries[run*8:]))
+		delta := uint64(binary.BigEndian.Uint32(entries[run*8+4:]))
+		for ; n > 0 && len(times) < samples; n-- {
+			times = append(times, current)
+			current += delta
+		}
+	}
+	for len(times) <= samples {
+		times = append(times, current)
+	}
+
+	return times
+}
@@ -0,0 +1,88 @@
+package files
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteWebVTT(t *testing.T) {
+	testCases := map[string]struct {
+		cues []subtitleCue
+		want string
+	}{
+		"empty": {nil, "WEBVTT\n\n"},
+		"timed": {
+			[]subtitleCue{{start: time.Second, end: 2500 * time.Millisecond, text: "Hello"}},
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nHello\n\n",
+		},
+		"sorted": {
+			[]subtitleCue{
+				{start: 3 * time.Second, end: 4 * time.Second, text: "second"},
+				{start: time.Second, end: 2 * time.Second, text: "first"},
+			},
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nfirst\n\n00:00:03.000 --> 00:00:04.000\nsecond\n\n",
+		},
+		"default duration": {
+			[]subtitleCue{{start: time.Hour + time.Minute, text: "no end"}},
+			"WEBVTT\n\n01:01:00.000 --> 01:01:05.000\nno end\n\n",
+		},
+		"ends at the next cue": {
+			[]subtitleCue{
+				{start: time.Second, text: "first"},
+				{start: 3 * time.Second, text: "second"},
+			},
+			"WEBVTT\n\n00:00:01.000 --> 00:00:03.000\nfirst\n\n00:00:03.000 --> 00:00:08.000\nsecond\n\n",
+		},
+		"blank lines and arrows": {
+			[]subtitleCue{{start: 0, end: time.Second, text: " a --> b\n\n \nc \n"}},
+			"WEBVTT\n\n00:00:00.000 --> 00:00:01.000\na -> b\nc\n\n",
+		},
+		"blank cues": {
+			[]subtitleCue{{start: 0, end: time.Second, text: " \n "}},
+			"WEBVTT\n\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeWebVTT(&buf, tc.cues))
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestAssText(t *testing.T) {
+	testCases := map[string]struct {
+		event string
+		want  string
+	}{
+		"plain":         {"0,0,Default,,0,0,0,,Hello", "Hello"},
+		"commas":        {"0,0,Default,,0,0,0,,Hello, world", "Hello, world"},
+		"override tags": {`1,0,Default,,0,0,0,,{\i1}Hello{\i0} {\pos(10,20)}world`, "Hello world"},
+		"line breaks":   {`2,0,Default,,0,0,0,,one\Ntwo\nthree\hfour`, "one\ntwo\nthree four"},
+		"no fields":     {"Hello", "Hello"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.want, assText(tc.event))
+		})
+	}
+}
+
+func TestEmbeddedSubtitlePath(t *testing.T) {
+	p := EmbeddedSubtitlePath("/videos/a.mkv", 3)
+	require.Equal(t, "/videos/a.mkv/track-3.vtt", p)
+
+	video, track, ok := ParseEmbeddedSubtitlePath(p)
+	require.True(t, ok)
+	require.Equal(t, "/videos/a.mkv", video)
+	require.Equal(t, 3, track)
+
+	_, _, ok = ParseEmbeddedSubtitlePath("/videos/a.vtt")
+	require.False(t, ok)
+}
@@ -0,0 +1,62 @@
+package files
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+)
+
+const imageMetadataCacheSize = 50000
+
+// imageMetadataCache keeps the EXIF information of images. The key
+// includes the modification time and size so changed files are read
+// again.
+var imageMetadataCache = ttlcache.New[string, *ImageMetadata](
+	ttlcache.WithCapacity[string, *ImageMetadata](imageMetadataCacheSize),
+)
+
+// IsImage reports whether the file is an image according to its extension.
+func (i *FileInfo) IsImage() bool {
+	return !i.IsDir && strings.HasPrefix(mime.TypeByExtension(i.Extension), "image/")
+}
+
+// ImageMetadata returns the EXIF information of an image. The result is
+// cached per file.
+func (i *FileInfo) ImageMetadata() (*ImageMetadata, error) {
+	key := fmt.Sprintf("%s:%d:%d", i.RealPath(), i.ModTime.UnixNano(), i.Size)
+	if item := imageMetadataCache.Get(key); item != nil {
+		return item.Value(), nil
+	}
+
+	fd, err := i.Fs.Open(i.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	meta, err := readImageMetadata(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	imageMetadataCache.Set(key, meta, ttlcache.DefaultTTL)
+	return meta, nil
+}
+
+// DateTaken returns when a picture was taken according to its EXIF
+// DateTimeOriginal. Files without it fall back to the modification time.
+func (i *FileInfo) DateTaken() time.Time {
+	if !i.IsImage() {
+		return i.ModTime
+	}
+
+	meta, err := i.ImageMetadata()
+	if err != nil || meta.DateTaken == nil {
+		return i.ModTime
+	}
+
+	return *meta.DateTaken
+}
@@ -0,0 +1,55 @@
+package fileutils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// CloneFile copies a file from source to dest like CopyFile, but shares
+// the data of the source instead when the file system supports reflinks,
+// which is instant whatever the size of the file.
+func CloneFile(afs afero.Fs, source, dest string, fileMode, dirMode fs.FileMode) error {
+	src, err := afs.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := afs.MkdirAll(filepath.Dir(dest), dirMode); err != nil {
+		return err
+	}
+
+	dst, err := afs.OpenFile(dest, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return err
+	}
+
+	srcFile, srcOk := osFile(src)
+	dstFile, dstOk := osFile(dst)
+	if srcOk && dstOk && reflink(dstFile, srcFile) == nil {
+		if err := dst.Close(); err != nil {
+			return err
+		}
+
+		info, err := src.Stat()
+		if err != nil {
+			return err
+		}
+		return afs.Chmod(dest, info.Mode())
+	}
+
+	dst.Close()
+	return CopyFile(afs, source, dest, fileMode, dirMode)
+}
+
+// osFile returns the file of the operating system behind the file.
+func osFile(f afero.File) (*os.File, bool) {
+	if base, ok := f.(*afero.BasePathFile); ok {
+		f = base.File
+	}
+	file, ok := f.(*os.File)
+	return file, ok
+}
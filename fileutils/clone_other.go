@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fileutils
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is only supported on Linux.
+func reflink(_, _ *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,32 @@
+package fileutils
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneFile(t *testing.T) {
+	for name, afs := range map[string]afero.Fs{
+		"os":     afero.NewBasePathFs(afero.NewOsFs(), t.TempDir()),
+		"memory": afero.NewMemMapFs(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, afs.MkdirAll("/dir", 0750))
+			require.NoError(t, afero.WriteFile(afs, "/src.bin", []byte("content"), 0640))
+			require.NoError(t, afero.WriteFile(afs, "/dir/dst.bin", []byte("previous content"), 0600))
+
+			require.NoError(t, CloneFile(afs, "/src.bin", "/dir/dst.bin", 0600, 0750))
+			got, err := afero.ReadFile(afs, "/dir/dst.bin")
+			require.NoError(t, err)
+			require.Equal(t, "content", string(got))
+
+			// the clone is independent of its source
+			require.NoError(t, afero.WriteFile(afs, "/dir/dst.bin", []byte("changed"), 0640))
+			got, err = afero.ReadFile(afs, "/src.bin")
+			require.NoError(t, err)
+			require.Equal(t, "content", string(got))
+		})
+	}
+}
@@ -1,11 +1,13 @@
 package fileutils
 
 import (
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/afero"
 )
@@ -72,6 +74,26 @@ func CopyFile(afs afero.Fs, source, dest string, fileMode, dirMode fs.FileMode)
 	return nil
 }
 
+// AddVersionSuffix returns the path with a "(n)" suffix added before its
+// extension, n being the first number for which no file exists.
+func AddVersionSuffix(source string, afs afero.Fs) string {
+	counter := 1
+	dir, name := path.Split(source)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for {
+		if _, err := afs.Stat(source); err != nil {
+			break
+		}
+		renamed := fmt.Sprintf("%s(%d)%s", base, counter, ext)
+		source = path.Join(dir, renamed)
+		counter++
+	}
+
+	return source
+}
+
 // CommonPrefix returns common directory path of provided files
 func CommonPrefix(sep byte, paths ...string) string {
 	// Handle special cases.
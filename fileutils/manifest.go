@@ -0,0 +1,69 @@
+package fileutils
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ManifestEntry is a line of a checksum manifest, as written by sha256sum
+// and b3sum.
+type ManifestEntry struct {
+	Sum  string
+	Name string
+}
+
+var manifestEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+var manifestUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+// WriteManifestEntry writes the line of the file to the manifest. As with
+// sha256sum, the lines of the names with backslashes or new lines start
+// with a backslash and have them escaped.
+func WriteManifestEntry(w io.Writer, sum, name string) error {
+	prefix := ""
+	if strings.ContainsAny(name, "\\\n\r") {
+		prefix = `\`
+		name = manifestEscaper.Replace(name)
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s  %s\n", prefix, sum, name)
+	return err
+}
+
+// ParseManifest reads the entries of a manifest, in text or binary mode,
+// ignoring the empty lines.
+func ParseManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		escaped := strings.HasPrefix(line, `\`)
+		if escaped {
+			line = line[1:]
+		}
+
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(name) < 2 || (name[0] != ' ' && name[0] != '*') {
+			return nil, fmt.Errorf("invalid manifest line %d", n)
+		}
+		if _, err := hex.DecodeString(sum); err != nil || sum == "" {
+			return nil, fmt.Errorf("invalid checksum on manifest line %d", n)
+		}
+
+		name = name[1:]
+		if escaped {
+			name = manifestUnescaper.Replace(name)
+		}
+		entries = append(entries, ManifestEntry{Sum: strings.ToLower(sum), Name: name})
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,28 @@
+package fileutils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteManifestEntry(&buf, "0a1b", "docs/a.txt"))
+	require.NoError(t, WriteManifestEntry(&buf, "2c3d", "odd\\name\n.txt"))
+	require.Equal(t, "0a1b  docs/a.txt\n\\2c3d  odd\\\\name\\n.txt\n", buf.String())
+
+	buf.WriteString("\n4E5F *binary mode.bin\r\n")
+	entries, err := ParseManifest(&buf)
+	require.NoError(t, err)
+	require.Equal(t, []ManifestEntry{
+		{Sum: "0a1b", Name: "docs/a.txt"},
+		{Sum: "2c3d", Name: "odd\\name\n.txt"},
+		{Sum: "4e5f", Name: "binary mode.bin"},
+	}, entries)
+
+	_, err = ParseManifest(strings.NewReader("nothex  a.txt\n"))
+	require.Error(t, err)
+}
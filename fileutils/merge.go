@@ -0,0 +1,208 @@
+package fileutils
+
+import (
+	"strings"
+)
+
+// maxMergeEdits bounds the work of the line diffs of Merge3. Beyond it, the
+// texts are considered completely different.
+const maxMergeEdits = 2000
+
+// Markers of the conflicts left by Merge3.
+const (
+	ConflictOurs   = "<<<<<<< ours\n"
+	ConflictSep    = "=======\n"
+	ConflictTheirs = ">>>>>>> theirs\n"
+)
+
+// Merge3 merges, line by line, the changes made to base in ours and theirs.
+// When both change the same lines differently, both versions are kept
+// between conflict markers, ours first. It returns the merged text with its
+// number of conflicts.
+func Merge3(base, ours, theirs string) (string, int) {
+	o, a, b := splitLines(base), splitLines(ours), splitLines(theirs)
+	ma, mb := matchLines(o, a), matchLines(o, b)
+
+	var merged strings.Builder
+	conflicts := 0
+
+	// i, j and k are the positions in base, ours and theirs
+	i, j, k := 0, 0, 0
+	for {
+		// the next line of base kept by both
+		next := i
+		for next < len(o) && (ma[next] < 0 || mb[next] < 0) {
+			next++
+		}
+
+		if next < len(o) && next == i && ma[next] == j && mb[next] == k {
+			merged.WriteString(o[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+
+		endA, endB := len(a), len(b)
+		if next < len(o) {
+			endA, endB = ma[next], mb[next]
+		}
+
+		chunkO, chunkA, chunkB := o[i:next], a[j:endA], b[k:endB]
+		switch {
+		case equalLines(chunkA, chunkO):
+			writeLines(&merged, chunkB)
+		case equalLines(chunkB, chunkO), equalLines(chunkA, chunkB):
+			writeLines(&merged, chunkA)
+		default:
+			conflicts++
+			merged.WriteString(ConflictOurs)
+			writeLines(&merged, chunkA)
+			terminateLine(&merged)
+			merged.WriteString(ConflictSep)
+			writeLines(&merged, chunkB)
+			terminateLine(&merged)
+			merged.WriteString(ConflictTheirs)
+		}
+
+		if next == len(o) {
+			return merged.String(), conflicts
+		}
+		i, j, k = next, endA, endB
+	}
+}
+
+// splitLines splits the text after every new line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(line)
+	}
+}
+
+// terminateLine ends the last line, so that the conflict markers start on
+// their own line.
+func terminateLine(sb *strings.Builder) {
+	if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+}
+
+// matchLines returns, for every line of a, the index of the same line of b
+// in a longest common subsequence of both, or -1 if it's not part of it.
+func matchLines(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	for _, m := range diffLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		matches[prefix+m[0]] = prefix + m[1]
+	}
+
+	return matches
+}
+
+// diffLines returns the pairs of matching lines of a shortest edit script
+// from a to b, using the algorithm of Myers. Nothing matches if the texts
+// need more than maxMergeEdits edits.
+func diffLines(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxMergeEdits)
+	offset := limit + 1
+
+	// v holds the furthest x reached on every diagonal k = x - y, and
+	// trace its values before every step, to walk the edits back.
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackLines(trace, n, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrackLines(trace [][]int, x, y int) [][2]int {
+	var matches [][2]int
+
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		}
+
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		matches = append(matches, [2]int{x, y})
+	}
+
+	return matches
+}
@@ -0,0 +1,63 @@
+package fileutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+
+	testCases := map[string]struct {
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		"unchanged": {
+			ours:   base,
+			theirs: base,
+			want:   base,
+		},
+		"separate changes": {
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nc\nD\ne\nf\n",
+			want:   "a\nB\nc\nD\ne\nf\n",
+		},
+		"same change": {
+			ours:   "a\nb\nx\nd\ne\n",
+			theirs: "a\nb\nx\nd\ne\n",
+			want:   "a\nb\nx\nd\ne\n",
+		},
+		"deletion": {
+			ours:   "a\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "a\nc\nd\nE\n",
+		},
+		"conflict": {
+			ours:      "a\nb\nours\nd\ne\n",
+			theirs:    "a\nb\ntheirs\nd\ne\n",
+			want:      "a\nb\n" + ConflictOurs + "ours\n" + ConflictSep + "theirs\n" + ConflictTheirs + "d\ne\n",
+			conflicts: 1,
+		},
+		"conflict without final new line": {
+			ours:      "a\nb\nc\nd\nours",
+			theirs:    "a\nb\nc\nd\ntheirs",
+			want:      "a\nb\nc\nd\n" + ConflictOurs + "ours\n" + ConflictSep + "theirs\n" + ConflictTheirs,
+			conflicts: 1,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, conflicts := Merge3(base, tc.ours, tc.theirs)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.conflicts, conflicts)
+		})
+	}
+
+	got, conflicts := Merge3("", "a\n", "b\n")
+	require.Equal(t, ConflictOurs+"a\n"+ConflictSep+"b\n"+ConflictTheirs, got)
+	require.Equal(t, 1, conflicts)
+}
@@ -0,0 +1,219 @@
+package fileutils
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/mholt/archives"
+)
+
+const (
+	// zipMethodAES is the compression method of the WinZip AES encrypted
+	// entries, the real one being in the extra field.
+	zipMethodAES = 99
+	// zipExtraAES is the ID of the WinZip AES extra field.
+	zipExtraAES = 0x9901
+
+	aesSaltSize     = 16
+	aesKeySize      = 32
+	aesVerifierSize = 2
+	aesAuthCodeSize = 10
+	aesIterations   = 1000
+)
+
+// ZipArchiver writes zip archives with the given deflate level, from
+// flate.HuffmanOnly to flate.BestCompression. When a password is set, the
+// files are encrypted with WinZip AES-256 (AE-2).
+type ZipArchiver struct {
+	Level    int
+	Password string
+}
+
+// Archive writes the files as a zip archive. It implements
+// archives.Archiver.
+func (z ZipArchiver) Archive(ctx context.Context, output io.Writer, files []archives.FileInfo) error {
+	if z.Level < flate.HuffmanOnly || z.Level > flate.BestCompression {
+		return fmt.Errorf("invalid compression level %d", z.Level)
+	}
+
+	zw := zip.NewWriter(output)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, z.Level)
+	})
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := z.writeFile(zw, file); err != nil {
+			return fmt.Errorf("%s: %w", file.NameInArchive, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func (z ZipArchiver) writeFile(zw *zip.Writer, file archives.FileInfo) error {
+	hdr, err := zip.FileInfoHeader(file)
+	if err != nil {
+		return err
+	}
+	hdr.Name = file.NameInArchive
+
+	if file.IsDir() {
+		hdr.Name += "/"
+		hdr.Method = zip.Store
+		_, err = zw.CreateHeader(hdr)
+		return err
+	}
+
+	// only the regular files have a content to store
+	if !file.Mode().IsRegular() {
+		return nil
+	}
+
+	fd, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	hdr.Method = zip.Deflate
+	if z.Password == "" {
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, fd)
+		return err
+	}
+
+	return z.writeEncrypted(zw, hdr, fd)
+}
+
+// writeEncrypted writes the file compressed then encrypted as described by
+// https://www.winzip.com/en/support/aes-encryption/.
+func (z ZipArchiver) writeEncrypted(zw *zip.Writer, hdr *zip.FileHeader, in io.Reader) error {
+	salt := make([]byte, aesSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	keys, err := pbkdf2.Key(sha1.New, z.Password, salt, aesIterations, 2*aesKeySize+aesVerifierSize)
+	if err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(keys[:aesKeySize])
+	if err != nil {
+		return err
+	}
+
+	// the sizes and the checksum aren't known yet, they are written in
+	// the data descriptor. AE-2 doesn't store the CRC.
+	hdr.Flags |= 0x1 | 0x8
+	hdr.CRC32 = 0
+	hdr.Method = zipMethodAES
+	hdr.Extra = append(hdr.Extra,
+		zipExtraAES&0xff, zipExtraAES>>8, 7, 0,
+		2, 0, // AE-2
+		'A', 'E',
+		3, // AES-256
+		byte(zip.Deflate), byte(zip.Deflate>>8),
+	)
+
+	raw, err := zw.CreateRaw(hdr)
+	if err != nil {
+		return err
+	}
+
+	out := &countWriter{w: raw}
+	if _, err := out.Write(salt); err != nil {
+		return err
+	}
+	if _, err := out.Write(keys[2*aesKeySize:]); err != nil {
+		return err
+	}
+
+	enc := &aesWriter{block: block, mac: hmac.New(sha1.New, keys[aesKeySize:2*aesKeySize]), w: out}
+	fw, err := flate.NewWriter(enc, z.Level)
+	if err != nil {
+		return err
+	}
+
+	size, err := io.Copy(fw, in)
+	if err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	if _, err := out.Write(enc.mac.Sum(nil)[:aesAuthCodeSize]); err != nil {
+		return err
+	}
+
+	hdr.UncompressedSize64 = uint64(size)
+	hdr.CompressedSize64 = uint64(out.n)
+	hdr.UncompressedSize = uint32(min(hdr.UncompressedSize64, math.MaxUint32))
+	hdr.CompressedSize = uint32(min(hdr.CompressedSize64, math.MaxUint32))
+	return nil
+}
+
+// aesWriter encrypts with AES in counter mode, the counter being little
+// endian and starting at one, and authenticates the encrypted data.
+type aesWriter struct {
+	block   cipher.Block
+	mac     hash.Hash
+	w       io.Writer
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func (a *aesWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	for i, b := range p {
+		if a.used == 0 {
+			for j := range a.counter {
+				a.counter[j]++
+				if a.counter[j] != 0 {
+					break
+				}
+			}
+			a.block.Encrypt(a.stream[:], a.counter[:])
+		}
+
+		buf[i] = b ^ a.stream[a.used]
+		a.used = (a.used + 1) % aes.BlockSize
+	}
+
+	a.mac.Write(buf)
+	if _, err := a.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,109 @@
+package fileutils
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mholt/archives"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipArchiver(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file.txt")
+	content := bytes.Repeat([]byte("content "), 100)
+	require.NoError(t, os.WriteFile(name, content, 0644))
+
+	files, err := archives.FilesFromDisk(context.Background(), nil, map[string]string{name: "file.txt"})
+	require.NoError(t, err)
+
+	var plain bytes.Buffer
+	require.NoError(t, ZipArchiver{Level: 9}.Archive(context.Background(), &plain, files))
+
+	zr, err := zip.NewReader(bytes.NewReader(plain.Bytes()), int64(plain.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	fd, err := zr.File[0].Open()
+	require.NoError(t, err)
+	got, err := io.ReadAll(fd)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+
+	var encrypted bytes.Buffer
+	require.NoError(t, ZipArchiver{Level: 9, Password: "secret"}.Archive(context.Background(), &encrypted, files))
+	require.NotContains(t, encrypted.String(), "content")
+
+	zr, err = zip.NewReader(bytes.NewReader(encrypted.Bytes()), int64(encrypted.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	file := zr.File[0]
+	require.Equal(t, uint16(zipMethodAES), file.Method)
+	require.Equal(t, uint64(len(content)), file.UncompressedSize64)
+	require.GreaterOrEqual(t, len(file.Extra), 11)
+	require.Equal(t, uint16(zipExtraAES), binary.LittleEndian.Uint16(file.Extra[len(file.Extra)-11:]))
+}
+
+func TestZipArchiverAES(t *testing.T) {
+	// random data isn't compressed, for the counter to go past a byte
+	name := filepath.Join(t.TempDir(), "file.bin")
+	content := make([]byte, 300<<10)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(name, content, 0644))
+
+	files, err := archives.FilesFromDisk(context.Background(), nil, map[string]string{name: "file.bin"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ZipArchiver{Level: 1, Password: "secret"}.Archive(context.Background(), &buf, files))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	raw, err := zr.File[0].OpenRaw()
+	require.NoError(t, err)
+	data, err := io.ReadAll(raw)
+	require.NoError(t, err)
+	require.Equal(t, zr.File[0].CompressedSize64, uint64(len(data)))
+
+	// salt, password verifier, encrypted data and authentication code
+	salt, verifier := data[:aesSaltSize], data[aesSaltSize:aesSaltSize+aesVerifierSize]
+	encrypted, code := data[aesSaltSize+aesVerifierSize:len(data)-aesAuthCodeSize], data[len(data)-aesAuthCodeSize:]
+
+	keys, err := pbkdf2.Key(sha1.New, "secret", salt, 1000, 66)
+	require.NoError(t, err)
+	require.Equal(t, keys[64:], verifier)
+
+	mac := hmac.New(sha1.New, keys[32:64])
+	mac.Write(encrypted)
+	require.Equal(t, mac.Sum(nil)[:10], code)
+
+	// AES-256 in counter mode, the counter being a little endian number
+	// starting at one
+	block, err := aes.NewCipher(keys[:32])
+	require.NoError(t, err)
+	deflated := make([]byte, len(encrypted))
+	var counter, stream [aes.BlockSize]byte
+	for i := range encrypted {
+		if i%aes.BlockSize == 0 {
+			binary.LittleEndian.PutUint64(counter[:8], uint64(i/aes.BlockSize)+1)
+			block.Encrypt(stream[:], counter[:])
+		}
+		deflated[i] = encrypted[i] ^ stream[i%aes.BlockSize]
+	}
+
+	got, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
@@ -0,0 +1,75 @@
+package fbhttp
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/filebrowser/filebrowser/v2/activity"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/storage"
+)
+
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 500
+)
+
+// recordActivity returns the listener saving the events shown in the
+// activity feeds.
+func recordActivity(store *storage.Storage) func(events.Event) {
+	return func(e events.Event) {
+		switch e.Type {
+		case events.Uploaded, events.Renamed, events.Deleted, events.Shared:
+		default:
+			return
+		}
+
+		err := store.Activity.Save(&activity.Entry{
+			Type:        e.Type,
+			Path:        e.Path,
+			Destination: e.Destination,
+			User:        e.User,
+			Time:        e.Time,
+		})
+		if err != nil {
+			log.Printf("WARNING: couldn't record the activity: %v", err)
+		}
+	}
+}
+
+// activityHandler lists, from the newest, the changes made to the directory
+// and to everything in it that the user can see.
+// GET /api/activity/{path}
+var activityHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	limit := defaultActivityLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return http.StatusBadRequest, nil
+		}
+		limit = min(n, maxActivityLimit)
+	}
+
+	scope := realPath(d.user.Fs, "/")
+	feed, err := d.store.Activity.Feed(realPath(d.user.Fs, p), limit, func(e *activity.Entry) bool {
+		src, srcOk := scopedPath(e.Path, scope, d)
+		dst, dstOk := scopedPath(e.Destination, scope, d)
+		e.Path, e.Destination = src, dst
+		return srcOk || dstOk
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, feed)
+})
@@ -0,0 +1,239 @@
+package fbhttp
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mholt/archives"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// errStopWalk stops walking the archive once the wanted entry is found.
+var errStopWalk = errors.New("stop walking the archive")
+
+// archiveEntryName returns the name of the entry, decoding the GBK zip
+// file names.
+func archiveEntryName(f archives.FileInfo) string {
+	if hdr, ok := f.Header.(zip.FileHeader); ok {
+		return decodeZipFileName(hdr.Name, hdr.Flags)
+	}
+	return f.NameInArchive
+}
+
+// cleanEntryName returns the absolute path of the entry inside the archive,
+// or false if it points out of it.
+func cleanEntryName(name string) (string, bool) {
+	// Windows-created archives use backslashes
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == ".." || strings.HasPrefix(name, "../") || strings.HasPrefix(name, "/") {
+		return "", false
+	}
+	return path.Clean("/" + name), true
+}
+
+// walkArchive calls fn for every entry of the archive with its path inside
+// the archive. The entries pointing out of the archive are skipped.
+func walkArchive(ctx context.Context, d *data, file *files.FileInfo, fn func(name string, f archives.FileInfo) error) error {
+	format, _ := getArchiveFormat(file.Name)
+	if format == nil {
+		return fmt.Errorf("unsupported archive format: %s: %w", file.Name, fberrors.ErrInvalidRequestParams)
+	}
+
+	fd, err := d.user.Fs.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	err = format.Extract(ctx, fd, func(_ context.Context, f archives.FileInfo) error {
+		name, ok := cleanEntryName(archiveEntryName(f))
+		if !ok || name == "/" {
+			return nil
+		}
+		return fn(name, f)
+	})
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+// listArchive returns the entries of the directory inside the archive as
+// a virtual directory. The directories that only exist through the paths
+// of their files are listed too.
+func listArchive(ctx context.Context, d *data, file *files.FileInfo, dir string) (*files.FileInfo, error) {
+	dir = path.Clean("/" + dir)
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+
+	listing := &files.Listing{Items: []*files.FileInfo{}}
+	items := map[string]*files.FileInfo{}
+	found := dir == "/"
+
+	err := walkArchive(ctx, d, file, func(name string, f archives.FileInfo) error {
+		if name == dir {
+			if !f.IsDir() {
+				return fmt.Errorf("%s is not a directory: %w", dir, fberrors.ErrInvalidRequestParams)
+			}
+			found = true
+			return nil
+		}
+
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			return nil
+		}
+		found = true
+
+		child, _, nested := strings.Cut(rest, "/")
+		entry := path.Join(dir, child)
+		item, ok := items[entry]
+		if !ok {
+			item = &files.FileInfo{
+				Path:         path.Join(file.Path, entry),
+				Name:         child,
+				Extension:    path.Ext(child),
+				IsDir:        true,
+				Mode:         fs.ModeDir | 0o755,
+				ModTime:      file.ModTime,
+				ArchiveEntry: entry,
+			}
+			items[entry] = item
+			listing.Items = append(listing.Items, item)
+		}
+
+		if nested {
+			return nil
+		}
+
+		item.IsDir = f.IsDir()
+		item.Mode = f.Mode()
+		item.ModTime = f.ModTime()
+		if !item.IsDir {
+			item.Size = f.Size()
+			item.Type = archiveEntryType(child, item.Size)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fberrors.ErrNotExist
+	}
+
+	for _, item := range listing.Items {
+		if item.IsDir {
+			listing.NumDirs++
+		} else {
+			listing.NumFiles++
+		}
+	}
+
+	name := path.Base(dir)
+	if dir == "/" {
+		name = file.Name
+	}
+
+	return &files.FileInfo{
+		Listing:      listing,
+		Path:         path.Join(file.Path, dir),
+		Name:         name,
+		ModTime:      file.ModTime,
+		Mode:         fs.ModeDir | 0o755,
+		IsDir:        true,
+		ArchiveEntry: dir,
+	}, nil
+}
+
+// archiveEntryType guesses the type of the entry from its name, the
+// entries being read-only.
+func archiveEntryType(name string, size int64) string {
+	mimetype := mime.TypeByExtension(path.Ext(name))
+
+	switch {
+	case strings.HasPrefix(mimetype, "video"):
+		return "video"
+	case strings.HasPrefix(mimetype, "audio"):
+		return "audio"
+	case strings.HasPrefix(mimetype, "image"):
+		return "image"
+	case strings.HasSuffix(mimetype, "pdf"):
+		return "pdf"
+	case strings.HasPrefix(mimetype, "text") && size <= 10*1024*1024: // 10 MB
+		return "textImmutable"
+	default:
+		return "blob"
+	}
+}
+
+// rawArchiveEntryHandler sends a single file of the archive.
+// GET /api/raw/{archive}?entry=...&inline=...
+func rawArchiveEntryHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo, entry string) (int, error) {
+	entry = path.Clean("/" + entry)
+
+	sent := false
+	err := walkArchive(r.Context(), d, file, func(name string, f archives.FileInfo) error {
+		if name != entry {
+			return nil
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a file: %w", entry, fberrors.ErrInvalidRequestParams)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		if mimetype := mime.TypeByExtension(path.Ext(name)); mimetype != "" {
+			w.Header().Set("Content-Type", mimetype)
+		}
+		if r.URL.Query().Get("inline") == "true" {
+			w.Header().Set("Content-Disposition", "inline")
+		} else {
+			w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(path.Base(name)))
+		}
+
+		sent = true
+		if _, err := io.Copy(w, rc); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+
+	switch {
+	case sent:
+		return 0, err
+	case err != nil:
+		return errToStatus(err), err
+	default:
+		return http.StatusNotFound, nil
+	}
+}
+
+// selectsEntry tells if the entry is one of the selected entries or is
+// inside one of them. Everything is selected when there is no selection.
+func selectsEntry(selected []string, name string) bool {
+	if len(selected) == 0 {
+		return true
+	}
+
+	for _, s := range selected {
+		if name == s || strings.HasPrefix(name, strings.TrimSuffix(s, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -14,6 +14,7 @@ import (
 
 	fbAuth "github.com/filebrowser/filebrowser/v2/auth"
 	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/users"
 )
@@ -132,6 +133,14 @@ func loginHandler(tokenExpireTime time.Duration) handleFunc {
 			return http.StatusInternalServerError, err
 		}
 
+		if d.Events != nil {
+			d.Events.Publish(events.Event{
+				Type:   events.LoggedIn,
+				User:   user.Username,
+				UserID: user.ID,
+			})
+		}
+
 		return printToken(w, r, d, user, tokenExpireTime)
 	}
 }
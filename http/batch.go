@@ -0,0 +1,360 @@
+package fbhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/spf13/afero"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+// maxBatchOperations limits the number of operations of a batch request.
+const maxBatchOperations = 1000
+
+// Conflict policies of the batch operations, for the existing destinations.
+const (
+	batchConflictFail      = "fail"
+	batchConflictSkip      = "skip"
+	batchConflictOverwrite = "overwrite"
+	batchConflictRename    = "rename"
+)
+
+// Statuses of the batch operations.
+const (
+	batchDone     = "done"
+	batchSkipped  = "skipped"
+	batchFailed   = "failed"
+	batchCanceled = "canceled"
+)
+
+type batchRequest struct {
+	Operations []batchOperation `json:"operations"`
+	Conflict   string           `json:"conflict"`
+	Async      bool             `json:"async"`
+}
+
+// batchOperation is one of copy, move, delete, chmod and archive. The
+// archive operation puts the files in the archive at the destination.
+type batchOperation struct {
+	Action      string   `json:"action"`
+	Path        string   `json:"path,omitempty"`
+	Destination string   `json:"destination,omitempty"`
+	Mode        string   `json:"mode,omitempty"`
+	Recursive   bool     `json:"recursive,omitempty"`
+	Files       []string `json:"files,omitempty"`
+	Algo        string   `json:"algo,omitempty"`
+}
+
+type batchResult struct {
+	Action      string `json:"action"`
+	Path        string `json:"path,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []*batchResult `json:"results"`
+}
+
+// batchHandler runs several file operations, each one on its own, and
+// reports the result of every one of them.
+// POST /api/batch
+func batchHandler(fileCache FileCache, jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		if err := req.validate(); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		if req.Async {
+			body, err := json.Marshal(req)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			return submitJob(w, r, d, jobManager, "batch", map[string]string{
+				"request": string(body),
+			})
+		}
+
+		return renderJSON(w, r, runBatch(r.Context(), d, fileCache, &req, nil))
+	})
+}
+
+func (req *batchRequest) validate() error {
+	if len(req.Operations) == 0 {
+		return errors.New("no operations")
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return fmt.Errorf("too many operations, the maximum is %d", maxBatchOperations)
+	}
+
+	switch req.Conflict {
+	case "":
+		req.Conflict = batchConflictFail
+	case batchConflictFail, batchConflictSkip, batchConflictOverwrite, batchConflictRename:
+	default:
+		return fmt.Errorf("invalid conflict policy %q", req.Conflict)
+	}
+
+	return nil
+}
+
+// batchJob runs a batch request in the background, the processed files
+// being the completed operations.
+func batchJob(fileCache FileCache) jobFunc {
+	return func(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+		var req batchRequest
+		if err := json.Unmarshal([]byte(job.Params["request"]), &req); err != nil {
+			return nil, err
+		}
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
+
+		tracker.SetTotal(0, int64(len(req.Operations)))
+		return runBatch(ctx, d, fileCache, &req, tracker), nil
+	}
+}
+
+// runBatch runs the operations in order. Once the context is canceled,
+// the remaining operations are reported as canceled.
+func runBatch(ctx context.Context, d *data, fileCache FileCache, req *batchRequest, tracker *jobs.Tracker) *batchResponse {
+	resp := &batchResponse{Results: make([]*batchResult, 0, len(req.Operations))}
+
+	for _, op := range req.Operations {
+		result := &batchResult{
+			Action:      op.Action,
+			Path:        op.Path,
+			Destination: op.Destination,
+			Status:      batchDone,
+		}
+
+		var err error
+		if ctx.Err() != nil {
+			result.Status = batchCanceled
+		} else {
+			result.Status, err = runBatchOperation(ctx, d, fileCache, op, req.Conflict, result, tracker)
+		}
+		if err != nil {
+			result.Status = batchFailed
+			result.Error = err.Error()
+		}
+
+		if tracker != nil {
+			tracker.AddFiles(1)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	return resp
+}
+
+func runBatchOperation(ctx context.Context, d *data, fileCache FileCache, op batchOperation, conflict string, result *batchResult, tracker *jobs.Tracker) (string, error) {
+	// the writes of a job are reported to its tracker
+	work := d
+	if tracker != nil {
+		work = trackedData(ctx, d, tracker)
+	}
+
+	switch op.Action {
+	case "copy", "move", "rename":
+		action := op.Action
+		if action == "move" {
+			action = "rename"
+		}
+
+		src, err := batchSource(d, op.Path)
+		if err != nil {
+			return "", err
+		}
+
+		dst, status, err := batchDestination(d, op.Destination, conflict)
+		if err != nil || status != "" {
+			return status, err
+		}
+		if err := checkParent(src, dst); err != nil {
+			return "", err
+		}
+
+		result.Destination = dst
+		return batchDone, d.RunHookDst(func(target string) error {
+			if target != dst {
+				var status string
+				var err error
+				target, status, err = batchDestination(d, target, conflict)
+				if err != nil {
+					return err
+				}
+				if status != "" {
+					return fberrors.ErrExist
+				}
+				if err := checkParent(src, target); err != nil {
+					return err
+				}
+				result.Destination = target
+			}
+			return patchAction(ctx, action, src, target, work, fileCache)
+		}, action, src, dst, d.user)
+	case "delete":
+		if !d.user.Perm.Delete {
+			return "", fberrors.ErrPermissionDenied
+		}
+
+		src, err := batchSource(d, op.Path)
+		if err != nil {
+			return "", err
+		}
+
+		return batchDone, deleteResource(ctx, d, fileCache, src)
+	case "chmod":
+		if !d.user.Perm.Modify {
+			return "", fberrors.ErrPermissionDenied
+		}
+
+		src, err := batchSource(d, op.Path)
+		if err != nil {
+			return "", err
+		}
+
+		mode, err := strconv.ParseUint(op.Mode, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return "", fmt.Errorf("invalid mode %q: %w", op.Mode, fberrors.ErrInvalidRequestParams)
+		}
+		if err := checkLock(d, src, op.Recursive); err != nil {
+			return "", err
+		}
+
+		return batchDone, d.RunHook(func() error {
+			return chmodResource(d, src, unixMode(mode), op.Recursive)
+		}, "chmod", src, "", d.user)
+	case "archive":
+		if !d.user.Perm.Create || !d.user.Perm.Download {
+			return "", fberrors.ErrPermissionDenied
+		}
+		if len(op.Files) == 0 {
+			return "", fmt.Errorf("no files to archive: %w", fberrors.ErrInvalidRequestParams)
+		}
+
+		filenames := make([]string, 0, len(op.Files))
+		for _, name := range op.Files {
+			src, err := batchSource(d, name)
+			if err != nil {
+				return "", err
+			}
+			filenames = append(filenames, src)
+		}
+
+		_, archiver, err := parseQueryAlgorithm(op.Algo)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", err, fberrors.ErrInvalidRequestParams)
+		}
+
+		dst, status, err := batchDestination(d, op.Destination, conflict)
+		if err != nil || status != "" {
+			return status, err
+		}
+
+		result.Destination = dst
+		_, allFiles := collectArchiveFiles(d, filenames, false)
+		return batchDone, d.RunHook(func() error {
+			return writeArchive(ctx, work, dst, archiver, allFiles)
+		}, "archive", dst, "", d.user)
+	default:
+		return "", fmt.Errorf("unsupported action %s: %w", op.Action, fberrors.ErrInvalidRequestParams)
+	}
+}
+
+// batchSource cleans the path of an operation and checks the user can
+// act on it.
+func batchSource(d *data, p string) (string, error) {
+	p = path.Clean("/" + p)
+	if p == "/" || !d.Check(p) {
+		return "", fberrors.ErrPermissionDenied
+	}
+
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return "", err
+	}
+
+	return p, nil
+}
+
+// batchDestination cleans the destination of an operation and applies the
+// conflict policy when it exists. A status is returned when the operation
+// must not run.
+func batchDestination(d *data, dst, conflict string) (string, string, error) {
+	dst = path.Clean("/" + dst)
+	if dst == "/" || !d.Check(dst) {
+		return "", "", fberrors.ErrPermissionDenied
+	}
+
+	if _, err := d.user.Fs.Stat(dst); err != nil {
+		return dst, "", nil
+	}
+
+	switch conflict {
+	case batchConflictSkip:
+		return dst, batchSkipped, nil
+	case batchConflictOverwrite:
+		if !d.user.Perm.Modify {
+			return "", "", fberrors.ErrPermissionDenied
+		}
+		return dst, "", nil
+	case batchConflictRename:
+		dst = fileutils.AddVersionSuffix(dst, d.user.Fs)
+		if !d.Check(dst) {
+			return "", "", fberrors.ErrPermissionDenied
+		}
+		return dst, "", nil
+	default:
+		return "", "", fberrors.ErrExist
+	}
+}
+
+// unixMode converts the octal mode of chmod, whose setuid, setgid and
+// sticky bits have their own flags in fs.FileMode.
+func unixMode(mode uint64) fs.FileMode {
+	m := fs.FileMode(mode) & fs.ModePerm
+	if mode&0o4000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
+
+// chmodResource changes the mode of the file, or of the directory and
+// everything in it when recursive.
+func chmodResource(d *data, target string, mode fs.FileMode, recursive bool) error {
+	if !recursive {
+		return d.user.Fs.Chmod(target, mode)
+	}
+
+	return afero.Walk(d.user.Fs, target, func(p string, _ fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Check(p) {
+			return nil
+		}
+		return d.user.Fs.Chmod(p, mode)
+	})
+}
@@ -0,0 +1,115 @@
+package fbhttp
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/diskcache"
+	"github.com/filebrowser/filebrowser/v2/locks"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestRunBatch(t *testing.T) {
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	afs := afero.NewBasePathFs(afero.NewMemMapFs(), "/")
+	require.NoError(t, afero.WriteFile(afs, "/a.txt", []byte("a"), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/b.txt", []byte("b"), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/secret/c.txt", []byte("c"), 0644))
+
+	set := &settings.Settings{FileMode: settings.DefaultFileMode, DirMode: settings.DefaultDirMode}
+	d := &data{
+		Runner:   &runner.Runner{Settings: set},
+		store:    store,
+		settings: set,
+		server:   &settings.Server{},
+		user: &users.User{
+			Fs:    afs,
+			Perm:  users.Permissions{Create: true, Rename: true, Modify: true, Delete: true, Download: true},
+			Rules: []rules.Rule{{Allow: false, Path: "/secret"}},
+		},
+	}
+
+	req := &batchRequest{
+		Conflict: batchConflictRename,
+		Operations: []batchOperation{
+			{Action: "copy", Path: "/a.txt", Destination: "/b.txt"},
+			{Action: "move", Path: "/secret/c.txt", Destination: "/c.txt"},
+			{Action: "chmod", Path: "/a.txt", Mode: "600"},
+			{Action: "archive", Files: []string{"/a.txt", "/b.txt"}, Destination: "/files.zip"},
+			{Action: "delete", Path: "/missing.txt"},
+			{Action: "delete", Path: "/b.txt"},
+			{Action: "unknown"},
+		},
+	}
+	require.NoError(t, req.validate())
+
+	resp := runBatch(context.Background(), d, diskcache.NewNoOp(), req, nil)
+	require.Len(t, resp.Results, len(req.Operations))
+
+	statuses := []string{}
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.Status)
+	}
+	require.Equal(t, []string{batchDone, batchFailed, batchDone, batchDone, batchFailed, batchDone, batchFailed}, statuses)
+	require.Equal(t, "/b(1).txt", resp.Results[0].Destination)
+
+	content, err := afero.ReadFile(afs, "/b(1).txt")
+	require.NoError(t, err)
+	require.Equal(t, "a", string(content))
+
+	info, err := afs.Stat("/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "-rw-------", info.Mode().String())
+
+	exists, err := afero.Exists(afs, "/files.zip")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = afero.Exists(afs, "/b.txt")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	exists, err = afero.Exists(afs, "/secret/c.txt")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp = runBatch(ctx, d, diskcache.NewNoOp(), req, nil)
+	require.Equal(t, batchCanceled, resp.Results[0].Status)
+
+	// the setuid, setgid and sticky bits
+	resp = runBatch(context.Background(), d, diskcache.NewNoOp(), &batchRequest{
+		Operations: []batchOperation{{Action: "chmod", Path: "/a.txt", Mode: "7755"}},
+	}, nil)
+	require.Equal(t, batchDone, resp.Results[0].Status)
+	info, err = afs.Stat("/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky|0o755, info.Mode())
+	require.NoError(t, afs.Chmod("/a.txt", 0o600))
+
+	// the mode of a file locked by another user isn't changed
+	require.NoError(t, store.Locks.Acquire(&locks.Lock{Path: "/a.txt", UserID: 99, Username: "bob", Enforced: true}))
+	resp = runBatch(context.Background(), d, diskcache.NewNoOp(), &batchRequest{
+		Operations: []batchOperation{{Action: "chmod", Path: "/a.txt", Mode: "644"}},
+	}, nil)
+	require.Equal(t, batchFailed, resp.Results[0].Status)
+	info, err = afs.Stat("/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "-rw-------", info.Mode().String())
+}
@@ -0,0 +1,637 @@
+package fbhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/checksums"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+// manifestNames are the conventional names of the manifests of the
+// algorithms.
+var manifestNames = map[string]string{
+	"md5":    "MD5SUMS",
+	"sha1":   "SHA1SUMS",
+	"sha256": "SHA256SUMS",
+	"sha512": "SHA512SUMS",
+	"blake3": "B3SUMS",
+}
+
+type manifestResult struct {
+	Path  string `json:"path"`
+	Algo  string `json:"algo"`
+	Files int    `json:"files"`
+}
+
+// verifyReport lists, relative to the verified directory, the files that
+// don't match their checksums.
+type verifyReport struct {
+	Algo    string   `json:"algo,omitempty"`
+	Checked int      `json:"checked"`
+	Missing []string `json:"missing"` // checksummed but gone
+	Changed []string `json:"changed"` // with a different checksum
+	Extra   []string `json:"extra"`   // without a checksum
+	// Modified lists the files modified since their checksum was stored,
+	// which can't be verified.
+	Modified []string `json:"modified,omitempty"`
+	// Backfilled counts the files without a checksum whose sha256 sum was
+	// stored.
+	Backfilled int `json:"backfilled,omitempty"`
+}
+
+// manifestHandler generates the checksum manifest of a directory tree.
+// POST /api/manifest/{dir}?algo=sha256&destination=...&override=true
+// destination: the manifest, the conventional name in the directory if empty
+func manifestHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Create || !d.Check(r.URL.Path) {
+			return http.StatusForbidden, nil
+		}
+
+		info, err := d.user.Fs.Stat(r.URL.Path)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if !info.IsDir() {
+			return http.StatusBadRequest, errors.New("not a directory")
+		}
+
+		query := r.URL.Query()
+		algo := query.Get("algo")
+		if algo == "" {
+			algo = "sha256"
+		}
+		if _, err := files.NewHash(algo); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		dst := query.Get("destination")
+		if dst == "" {
+			dst = path.Join(r.URL.Path, manifestNames[algo])
+		}
+		dst = path.Clean("/" + dst)
+		if !d.Check(dst) {
+			return http.StatusForbidden, nil
+		}
+
+		override := query.Get("override") == "true"
+		if override && !d.user.Perm.Modify {
+			return http.StatusForbidden, nil
+		}
+		if _, err := d.user.Fs.Stat(dst); err == nil && !override {
+			return http.StatusConflict, nil
+		}
+
+		return submitJob(w, r, d, jobManager, "manifest", map[string]string{
+			"path":        path.Clean("/" + r.URL.Path),
+			"algo":        algo,
+			"destination": dst,
+		})
+	})
+}
+
+func manifestJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	dir, algo, dst := job.Params["path"], job.Params["algo"], job.Params["destination"]
+	if !d.user.Perm.Create || !d.Check(dir) || !d.Check(dst) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+	if err := checkLock(d, dst, false); err != nil {
+		return nil, err
+	}
+
+	names, err := treeFiles(ctx, d, dir, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest bytes.Buffer
+	count := 0
+	for _, name := range names {
+		if path.Join(dir, name) == dst {
+			continue
+		}
+
+		sum, err := fileChecksum(d.user.Fs, path.Join(dir, name), algo, tracker)
+		if err != nil {
+			return nil, err
+		}
+		if err := fileutils.WriteManifestEntry(&manifest, sum, name); err != nil {
+			return nil, err
+		}
+		count++
+	}
+
+	if d.Events != nil {
+		defer d.Events.Begin(realPath(d.user.Fs, dst))()
+	}
+	if _, err := writeFile(d.user.Fs, dst, &manifest, d.settings.FileMode, d.settings.DirMode); err != nil {
+		return nil, err
+	}
+	if d.Events != nil {
+		d.Events.Publish(events.Event{
+			Type: events.Created,
+			Path: realPath(d.user.Fs, dst),
+			User: d.user.Username,
+		})
+	}
+
+	return manifestResult{Path: dst, Algo: algo, Files: count}, nil
+}
+
+// verifyHandler verifies a directory tree against a checksum manifest, or
+// against the checksums stored for the uploads.
+// POST /api/verify/{dir}?manifest=...&algo=...
+// POST /api/verify/{dir}?stored=true&backfill=...
+// algo: guessed from the manifest if empty
+// backfill: stores the sha256 sums of the files without a checksum, for the
+// identical uploads to be found from them
+func verifyHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.Check(r.URL.Path) {
+			return http.StatusForbidden, nil
+		}
+
+		info, err := d.user.Fs.Stat(r.URL.Path)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if !info.IsDir() {
+			return http.StatusBadRequest, errors.New("not a directory")
+		}
+
+		query := r.URL.Query()
+		params := map[string]string{
+			"path":   path.Clean("/" + r.URL.Path),
+			"algo":   query.Get("algo"),
+			"stored": query.Get("stored"),
+		}
+		if query.Get("backfill") == "true" {
+			params["backfill"] = "true"
+		}
+
+		if params["stored"] != "true" {
+			manifest := query.Get("manifest")
+			if manifest == "" {
+				return http.StatusBadRequest, errors.New("missing manifest")
+			}
+
+			manifest = path.Clean("/" + manifest)
+			if !d.Check(manifest) {
+				return http.StatusForbidden, nil
+			}
+			if _, err := d.user.Fs.Stat(manifest); err != nil {
+				return errToStatus(err), err
+			}
+			params["manifest"] = manifest
+		}
+
+		if params["algo"] != "" {
+			if _, err := files.NewHash(params["algo"]); err != nil {
+				return http.StatusBadRequest, err
+			}
+		}
+
+		return submitJob(w, r, d, jobManager, "verify", params)
+	})
+}
+
+func verifyJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	dir := job.Params["path"]
+	if !d.Check(dir) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	if job.Params["stored"] == "true" {
+		return verifyStored(ctx, d, dir, job.Params["backfill"] == "true", tracker)
+	}
+
+	manifest := job.Params["manifest"]
+	if !d.Check(manifest) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	return verifyManifest(ctx, d, dir, manifest, job.Params["algo"], tracker)
+}
+
+func verifyManifest(ctx context.Context, d *data, dir, manifest, algo string, tracker *jobs.Tracker) (*verifyReport, error) {
+	f, err := d.user.Fs.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fileutils.ParseManifest(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if algo == "" {
+		algo = guessManifestAlgo(manifest, entries)
+	}
+
+	names, err := treeFiles(ctx, d, dir, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = path.Join(dir, name) != manifest
+	}
+
+	report := newVerifyReport(algo)
+	listed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+entry.Name), "/")
+		listed[name] = true
+		if !present[name] {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+
+		sum, err := fileChecksum(d.user.Fs, path.Join(dir, name), algo, tracker)
+		if err != nil {
+			return nil, err
+		}
+		report.Checked++
+		if sum != entry.Sum {
+			report.Changed = append(report.Changed, name)
+		}
+	}
+
+	for _, name := range names {
+		if present[name] && !listed[name] {
+			report.Extra = append(report.Extra, name)
+		}
+	}
+
+	return report, nil
+}
+
+// verifyStored verifies the files against their stored checksums, storing
+// the sha256 sum of the ones without any when backfill is set.
+func verifyStored(ctx context.Context, d *data, dir string, backfill bool, tracker *jobs.Tracker) (*verifyReport, error) {
+	realDir := realPath(d.user.Fs, dir)
+	records, err := d.store.Checksums.Within(realDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*checksums.Record, len(records))
+	for _, record := range records {
+		rel, err := filepath.Rel(realDir, record.Path)
+		if err != nil {
+			continue
+		}
+		byName[filepath.ToSlash(rel)] = record
+	}
+
+	names, err := treeFiles(ctx, d, dir, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newVerifyReport("")
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, ok := byName[name]
+		if !ok && backfill {
+			if err := backfillChecksum(d, path.Join(dir, name), tracker); err != nil {
+				return nil, err
+			}
+			report.Backfilled++
+			continue
+		}
+		if !ok {
+			report.Extra = append(report.Extra, name)
+			continue
+		}
+		delete(byName, name)
+
+		info, err := d.user.Fs.Stat(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if !record.Current(info.Size(), info.ModTime()) {
+			report.Modified = append(report.Modified, name)
+			continue
+		}
+
+		sum, err := fileChecksum(d.user.Fs, path.Join(dir, name), record.Algo, tracker)
+		if err != nil {
+			return nil, err
+		}
+		report.Checked++
+		if sum != record.Sum {
+			report.Changed = append(report.Changed, name)
+		}
+	}
+
+	for name := range byName {
+		if d.Check(path.Join(dir, name)) {
+			report.Missing = append(report.Missing, name)
+		}
+	}
+	sort.Strings(report.Missing)
+
+	return report, nil
+}
+
+func newVerifyReport(algo string) *verifyReport {
+	return &verifyReport{
+		Algo:    algo,
+		Missing: []string{},
+		Changed: []string{},
+		Extra:   []string{},
+	}
+}
+
+// guessManifestAlgo guesses the algorithm of the manifest from its name or
+// from the length of its checksums.
+func guessManifestAlgo(name string, entries []fileutils.ManifestEntry) string {
+	name = strings.ToLower(path.Base(name))
+	if strings.Contains(name, "b3") || strings.Contains(name, "blake3") {
+		return "blake3"
+	}
+
+	for _, algo := range []string{"md5", "sha1", "sha512", "sha256"} {
+		if strings.Contains(name, algo) {
+			return algo
+		}
+	}
+
+	if len(entries) > 0 {
+		switch len(entries[0].Sum) {
+		case 32:
+			return "md5"
+		case 40:
+			return "sha1"
+		case 128:
+			return "sha512"
+		}
+	}
+	return "sha256"
+}
+
+// treeFiles returns the sorted names, relative to the directory, of the
+// files of the tree that the user can see, setting the total of the job.
+func treeFiles(ctx context.Context, d *data, dir string, tracker *jobs.Tracker) ([]string, error) {
+	var names []string
+	var size int64
+
+	err := walkTree(ctx, d, dir, func(p string, info os.FileInfo) {
+		names = append(names, strings.TrimPrefix(p, strings.TrimSuffix(dir, "/")+"/"))
+		size += info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	tracker.SetTotal(size, int64(len(names)))
+	return names, nil
+}
+
+// walkTree calls fn with the regular files of the tree that the user can
+// see, without following the links to directories.
+func walkTree(ctx context.Context, d *data, dir string, fn func(p string, info os.FileInfo)) error {
+	stack := []string{dir}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		entries, err := afero.ReadDir(d.user.Fs, current)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			child := path.Join(current, entry.Name())
+			if !d.Check(child) {
+				continue
+			}
+
+			if entry.IsDir() {
+				if !isSymlinkDir(d.user.Fs, child, entry) {
+					stack = append(stack, child)
+				}
+				continue
+			}
+
+			if entry.Mode().IsRegular() {
+				fn(child, entry)
+			}
+		}
+	}
+
+	return nil
+}
+
+// fileChecksum returns the checksum of the file in hexadecimal.
+func fileChecksum(afs afero.Fs, p, algo string, tracker *jobs.Tracker) (string, error) {
+	h, err := files.NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := afs.Open(p)
+	if err != nil {
+		return "", err
+	}
+
+	reader := jobs.TrackReads(f, tracker)
+	defer reader.Close()
+
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadHash returns the hash computing the checksum stored for the
+// uploads, nil when none is stored.
+func uploadHash(d *data) hash.Hash {
+	if d.settings.UploadChecksum == "" {
+		return nil
+	}
+
+	h, err := files.NewHash(d.settings.UploadChecksum)
+	if err != nil {
+		return nil
+	}
+	return h
+}
+
+// storeChecksum saves the checksum of the file just uploaded, as computed
+// by the hash returned by uploadHash.
+func storeChecksum(d *data, p string, info os.FileInfo, h hash.Hash) {
+	if h == nil {
+		return
+	}
+
+	err := d.store.Checksums.Save(&checksums.Record{
+		Path:     realPath(d.user.Fs, p),
+		Algo:     d.settings.UploadChecksum,
+		Sum:      hex.EncodeToString(h.Sum(nil)),
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+		Created:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("WARNING: couldn't store the checksum of %s: %v", p, err)
+	}
+}
+
+// storeFileChecksum computes and saves the checksum of the file uploaded in
+// several requests.
+func storeFileChecksum(d *data, p string) {
+	h := uploadHash(d)
+	if h == nil {
+		return
+	}
+
+	f, err := d.user.Fs.Open(p)
+	if err != nil {
+		log.Printf("WARNING: couldn't store the checksum of %s: %v", p, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err == nil {
+		_, err = io.Copy(h, f)
+	}
+	if err != nil {
+		log.Printf("WARNING: couldn't store the checksum of %s: %v", p, err)
+		return
+	}
+
+	storeChecksum(d, p, info, h)
+}
+
+// storeVerifiedChecksum saves the sha256 sum an upload was verified
+// against, so that identical uploads can be found from it, unless the
+// checksums stored for the uploads are of another algorithm.
+func storeVerifiedChecksum(d *data, p, sum string) {
+	if d.settings.UploadChecksum != "" && d.settings.UploadChecksum != "sha256" {
+		storeFileChecksum(d, p)
+		return
+	}
+
+	info, err := d.user.Fs.Stat(p)
+	if err == nil {
+		err = d.store.Checksums.Save(&checksums.Record{
+			Path:     realPath(d.user.Fs, p),
+			Algo:     "sha256",
+			Sum:      sum,
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+			Created:  time.Now(),
+		})
+	}
+	if err != nil {
+		log.Printf("WARNING: couldn't store the checksum of %s: %v", p, err)
+	}
+}
+
+// findIdentical returns a file the user can access with the given sha256
+// sum and size. Only the files with a stored sha256 checksum are found:
+// the ones uploaded with a declared sum or while the upload checksum is
+// sha256, and the ones it's backfilled for by verifying the stored
+// checksums with backfill=true. The file must be left unchanged since, and
+// its content still have the sum.
+func findIdentical(d *data, sum string, size int64) (string, bool) {
+	scope := realPath(d.user.Fs, "/")
+	records, err := d.store.Checksums.Within(scope)
+	if err != nil {
+		return "", false
+	}
+
+	for _, r := range records {
+		if r.Algo != "sha256" || r.Sum != sum || r.Size != size {
+			continue
+		}
+
+		p, ok := scopedPath(r.Path, scope, d)
+		if !ok {
+			continue
+		}
+
+		info, err := d.user.Fs.Stat(p)
+		if err != nil || !info.Mode().IsRegular() || !r.Current(info.Size(), info.ModTime()) {
+			continue
+		}
+
+		// the size and time of a file may be kept while changing it
+		actual, err := fileChecksum(d.user.Fs, p, "sha256", &jobs.Tracker{})
+		if err == nil && actual == sum {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+// backfillChecksum computes and saves the sha256 sum of the file.
+func backfillChecksum(d *data, p string, tracker *jobs.Tracker) error {
+	info, err := d.user.Fs.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	sum, err := fileChecksum(d.user.Fs, p, "sha256", tracker)
+	if err != nil {
+		return err
+	}
+
+	return d.store.Checksums.Save(&checksums.Record{
+		Path:     realPath(d.user.Fs, p),
+		Algo:     "sha256",
+		Sum:      sum,
+		Size:     info.Size(),
+		Modified: info.ModTime(),
+		Created:  time.Now(),
+	})
+}
+
+// uploadMatches reports whether the completed upload has the declared
+// sha256 sum, removing it otherwise.
+func uploadMatches(d *data, p, sum string) (bool, error) {
+	actual, err := fileChecksum(d.user.Fs, p, "sha256", &jobs.Tracker{})
+	if err != nil {
+		return false, err
+	}
+	if actual == sum {
+		return true, nil
+	}
+	return false, d.user.Fs.Remove(p)
+}
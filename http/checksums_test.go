@@ -0,0 +1,71 @@
+package fbhttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+func TestFindIdentical(t *testing.T) {
+	ts := newTestServer(t)
+	content := []byte("hello")
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "a.txt"), content, 0644))
+
+	sum := sha256.Sum256(content)
+	metadata := "sha256 " + base64.StdEncoding.EncodeToString([]byte(hex.EncodeToString(sum[:])))
+	upload := func(name string) string {
+		resp := ts.do(t, http.MethodPost, "/api/tus/"+name, nil, "Upload-Length", "5", "Upload-Metadata", metadata)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		data, err := os.ReadFile(filepath.Join(ts.root, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// the files without a stored checksum aren't found
+	require.Empty(t, upload("b.txt"))
+
+	user, err := ts.store.Users.Get(ts.root, uint(1))
+	require.NoError(t, err)
+	set, err := ts.store.Settings.Get()
+	require.NoError(t, err)
+	d := &data{store: ts.store, settings: set, user: user}
+
+	report, err := verifyStored(context.Background(), d, "/", true, &jobs.Tracker{})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Backfilled)
+	require.Empty(t, report.Extra)
+
+	// until it's backfilled
+	require.Equal(t, "hello", upload("c.txt"))
+
+	// the cloned upload is complete for the client resuming it
+	resp := ts.do(t, http.MethodHead, "/api/tus/c.txt", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("Upload-Offset"))
+	require.Equal(t, "5", resp.Header.Get("Upload-Length"))
+	resp = ts.do(t, http.MethodPatch, "/api/tus/c.txt", nil, "Content-Type", "application/offset+octet-stream", "Upload-Offset", "5")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, "5", resp.Header.Get("Upload-Offset"))
+	resp = ts.do(t, http.MethodPatch, "/api/tus/c.txt", nil, "Content-Type", "application/offset+octet-stream", "Upload-Offset", "0")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the content is checked again, the size and time may be kept
+	for _, name := range []string{"a.txt", "c.txt"} {
+		p := filepath.Join(ts.root, name)
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(p, []byte("HELLO"), 0644))
+		require.NoError(t, os.Chtimes(p, time.Time{}, info.ModTime()))
+	}
+	require.Empty(t, upload("d.txt"))
+}
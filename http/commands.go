@@ -2,10 +2,11 @@ package fbhttp
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
-	"os/exec"
 	"slices"
 	"strings"
 	"time"
@@ -25,7 +26,9 @@ var upgrader = websocket.Upgrader{
 }
 
 var (
-	cmdNotAllowed = []byte("Command not allowed.")
+	cmdNotAllowed  = []byte("Command not allowed.")
+	cmdOutputLimit = []byte("Output limit reached, the command was stopped.")
+	cmdTimedOut    = []byte("Command timed out.")
 )
 
 func wsErr(ws *websocket.Conn, r *http.Request, status int, err error) {
@@ -69,7 +72,7 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 		return 0, nil
 	}
 
-	command, name, err := runner.ParseCommand(d.settings, raw)
+	_, name, err := runner.ParseCommand(d.settings, raw)
 	if err != nil {
 		if err := conn.WriteMessage(websocket.TextMessage, []byte(err.Error())); err != nil {
 			wsErr(conn, r, http.StatusInternalServerError, err)
@@ -77,7 +80,7 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 		return 0, nil
 	}
 
-	if !slices.Contains(d.user.Commands, name) {
+	if !slices.Contains(d.user.Commands, name) || !d.Check(r.URL.Path) {
 		if err := conn.WriteMessage(websocket.TextMessage, cmdNotAllowed); err != nil {
 			wsErr(conn, r, http.StatusInternalServerError, err)
 		}
@@ -85,8 +88,14 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 		return 0, nil
 	}
 
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Dir = d.user.FullPath(r.URL.Path)
+	ctx, cancel := context.WithTimeout(r.Context(), d.settings.GetCommandTimeout())
+	defer cancel()
+
+	cmd, err := d.Runner.Command(ctx, raw, r.URL.Path, d.user)
+	if err != nil {
+		wsErr(conn, r, http.StatusInternalServerError, err)
+		return 0, nil
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -100,19 +109,37 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 		return 0, nil
 	}
 
-	if err := cmd.Start(); err != nil {
+	p, err := d.Runner.Start(cmd, "command", d.user)
+	if err != nil {
 		wsErr(conn, r, http.StatusInternalServerError, err)
 		return 0, nil
 	}
 
+	// the command is stopped once its output reaches the limit
+	var sent uint64
+	maxOutput := d.settings.Sandbox.GetMaxOutput()
 	s := bufio.NewScanner(io.MultiReader(stdout, stderr))
 	for s.Scan() {
+		if sent += uint64(len(s.Bytes())) + 1; sent > maxOutput {
+			cancel()
+			if err := conn.WriteMessage(websocket.TextMessage, cmdOutputLimit); err != nil {
+				log.Print(err)
+			}
+			break
+		}
+
 		if err := conn.WriteMessage(websocket.TextMessage, s.Bytes()); err != nil {
 			log.Print(err)
 		}
 	}
 
-	if err := cmd.Wait(); err != nil {
+	err = p.Wait()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if err := conn.WriteMessage(websocket.TextMessage, cmdTimedOut); err != nil {
+			log.Print(err)
+		}
+	}
+	if err != nil {
 		wsErr(conn, r, http.StatusInternalServerError, err)
 	}
 
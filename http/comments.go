@@ -0,0 +1,207 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/comments"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
+)
+
+// maxCommentLength is the length of the longest comment, in bytes.
+const maxCommentLength = 10000
+
+type commentRequest struct {
+	Body string `json:"body"`
+}
+
+// decodeComment reads the body of the comment from the request.
+func decodeComment(w http.ResponseWriter, r *http.Request) (string, error) {
+	var req commentRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*maxCommentLength)).Decode(&req); err != nil {
+		return "", fberrors.ErrInvalidRequestParams
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" || len(body) > maxCommentLength {
+		return "", fberrors.ErrInvalidRequestParams
+	}
+	return body, nil
+}
+
+// mentionUsers sets the users mentioned in the comment, ignoring the names
+// of the users that don't exist, and notifies the ones newly mentioned.
+func mentionUsers(d *data, c *comments.Comment) {
+	previous := c.Mentions
+	c.Mentions = []string{}
+
+	for _, name := range comments.ParseMentions(c.Body) {
+		user, err := d.store.Users.Get(d.server.Root, name)
+		if err != nil {
+			continue
+		}
+		c.Mentions = append(c.Mentions, user.Username)
+
+		if d.Events == nil || user.ID == c.UserID || slices.Contains(previous, user.Username) {
+			continue
+		}
+		// the event only reaches the user if they can see the path
+		d.Events.Publish(events.Event{
+			Type:   events.Mentioned,
+			Path:   c.Path,
+			User:   c.Username,
+			UserID: user.ID,
+		})
+	}
+}
+
+// userComment returns the comment as seen by the user, with the path
+// relative to their scope, and whether they can see it at all.
+func userComment(d *data, c *comments.Comment) (*comments.Comment, bool) {
+	p, ok := scopedPath(c.Path, realPath(d.user.Fs, "/"), d)
+	if !ok {
+		return nil, false
+	}
+
+	scoped := *c
+	scoped.Path = p
+	return &scoped, true
+}
+
+// commentsGetHandler lists the comments of the path, the oldest first.
+// GET /api/comments/{path}
+var commentsGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	list, err := d.store.Comments.FindByPath(realPath(d.user.Fs, p))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	scoped := make([]*comments.Comment, 0, len(list))
+	for _, c := range list {
+		if c, ok := userComment(d, c); ok {
+			scoped = append(scoped, c)
+		}
+	}
+
+	return renderJSON(w, r, scoped)
+})
+
+// commentPostHandler comments the path, notifying the mentioned users.
+// POST /api/comments/{path}
+var commentPostHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	body, err := decodeComment(w, r)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	id, err := comments.NewID()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	c := &comments.Comment{
+		ID:       id,
+		Path:     realPath(d.user.Fs, p),
+		UserID:   d.user.ID,
+		Username: d.user.Username,
+		Body:     body,
+		Created:  time.Now(),
+	}
+	mentionUsers(d, c)
+
+	if err := d.store.Comments.Save(c); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	scoped, _ := userComment(d, c)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return renderJSON(w, r, scoped)
+})
+
+// getComment returns the comment of the ID in the URL, if the user can see
+// it.
+func getComment(r *http.Request, d *data) (*comments.Comment, error) {
+	id := strings.TrimPrefix(r.URL.Path, "/")
+	if id == "" || strings.Contains(id, "/") {
+		return nil, fberrors.ErrInvalidRequestParams
+	}
+
+	c, err := d.store.Comments.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := userComment(d, c); !ok {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return c, nil
+}
+
+// commentPutHandler edits a comment of the user.
+// PUT /api/comments/{id}
+var commentPutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	c, err := getComment(r, d)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if c.UserID != d.user.ID {
+		return http.StatusForbidden, nil
+	}
+
+	body, err := decodeComment(w, r)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	c.Body = body
+	c.Edited = time.Now()
+	mentionUsers(d, c)
+
+	if err := d.store.Comments.Save(c); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	scoped, _ := userComment(d, c)
+	return renderJSON(w, r, scoped)
+})
+
+// commentDeleteHandler deletes a comment. The admins can delete the
+// comments of the other users.
+// DELETE /api/comments/{id}
+var commentDeleteHandler = withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	c, err := getComment(r, d)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if c.UserID != d.user.ID && !d.user.Perm.Admin {
+		return http.StatusForbidden, nil
+	}
+
+	if err := d.store.Comments.Delete(c.ID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+})
@@ -0,0 +1,214 @@
+package fbhttp
+
+import (
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/mholt/archives"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+// archivePasswordHeader holds the password of the encrypted zip archives,
+// so that it doesn't end up in the logs with the URL.
+const archivePasswordHeader = "X-Archive-Password"
+
+type archiveResult struct {
+	Path string `json:"path"`
+}
+
+// resourceCompress creates an archive of the selected files on the server.
+// PATCH /api/resources/{dir}?action=compress&files=...&destination=...&algo=...&level=...
+// files: the names of the files of the directory, the whole directory if empty
+// level: 1 (fastest) to 9 (smallest), the default of the algorithm if empty
+func resourceCompress(w http.ResponseWriter, r *http.Request, d *data, jobManager *jobs.Manager) (int, error) {
+	if !d.user.Perm.Create || !d.user.Perm.Download {
+		return http.StatusForbidden, nil
+	}
+
+	query := r.URL.Query()
+	dst := query.Get("destination")
+	if dst == "" {
+		return http.StatusBadRequest, errors.New("missing destination")
+	}
+
+	dst = path.Clean("/" + dst)
+	if dst == "/" || !d.Check(dst) || !d.Check(r.URL.Path) {
+		return http.StatusForbidden, nil
+	}
+
+	algo, level := query.Get("algo"), query.Get("level")
+	password := r.Header.Get(archivePasswordHeader)
+	if _, _, err := compressionArchiver(algo, level, password); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	override := query.Get("override") == "true"
+	rename := query.Get("rename") == "true"
+	target, err := patchDestination(d, dst, override, rename)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	// the job applies the options again once it runs
+	if query.Get("async") == "true" {
+		// the job records are stored in clear
+		if password != "" {
+			return http.StatusBadRequest, errors.New("encrypted archives can't be created in the background")
+		}
+
+		return submitJob(w, r, d, jobManager, "compress", map[string]string{
+			"path":        r.URL.Path,
+			"files":       query.Get("files"),
+			"algo":        algo,
+			"level":       level,
+			"destination": dst,
+			"override":    strconv.FormatBool(override),
+			"rename":      strconv.FormatBool(rename),
+		})
+	}
+	dst = target
+
+	err = d.RunHook(func() error {
+		return compressFiles(r.Context(), d, r.URL.Path, query.Get("files"), algo, level, password, dst)
+	}, "archive", dst, "", d.user)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, archiveResult{Path: dst})
+}
+
+func compressJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	if !d.user.Perm.Create || !d.user.Perm.Download {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	dst := job.Params["destination"]
+	if !d.Check(dst) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	// the destination may have been created since the job was submitted
+	dst, err := patchDestination(d, dst, job.Params["override"] == "true", job.Params["rename"] == "true")
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := trackedData(ctx, d, tracker)
+	err = d.RunHook(func() error {
+		return compressFiles(ctx, tracked, job.Params["path"], job.Params["files"], job.Params["algo"], job.Params["level"], "", dst)
+	}, "archive", dst, "", d.user)
+	if err != nil {
+		return nil, err
+	}
+
+	return archiveResult{Path: dst}, nil
+}
+
+// compressFiles writes the archive of the files of the directory, as
+// listed by the files query parameter, to the destination.
+func compressFiles(ctx context.Context, d *data, dir, query, algo, level, password, dst string) error {
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       dir,
+		Modify:     d.user.Perm.Modify,
+		Expand:     false,
+		ReadHeader: false,
+		Checker:    d,
+	})
+	if err != nil {
+		return err
+	}
+
+	filenames, err := parseQueryFiles(query, file)
+	if err != nil {
+		return err
+	}
+
+	_, archiver, err := compressionArchiver(algo, level, password)
+	if err != nil {
+		return err
+	}
+
+	// the files excluded by the rules are left out by getFiles
+	_, allFiles := collectArchiveFiles(d, filenames, false)
+	if len(allFiles) == 0 {
+		return fberrors.ErrNotExist
+	}
+
+	return writeArchive(ctx, d, dst, archiver, allFiles)
+}
+
+// compressionArchiver returns the archiver of the algorithm, as accepted by
+// parseQueryAlgorithm, with the given compression level from 1 to 9. Only
+// the zip archives can be encrypted.
+func compressionArchiver(algo, level, password string) (string, archives.Archiver, error) {
+	extension, archiver, err := parseQueryAlgorithm(algo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if password != "" && extension != ".zip" {
+		return "", nil, errors.New("only zip archives can be encrypted")
+	}
+
+	if level == "" && password == "" {
+		return extension, archiver, nil
+	}
+
+	lvl := 0
+	if level != "" {
+		lvl, err = strconv.Atoi(level)
+		if err != nil || lvl < 1 || lvl > 9 {
+			return "", nil, fmt.Errorf("invalid compression level %q", level)
+		}
+	}
+
+	switch extension {
+	case ".zip":
+		if lvl == 0 {
+			lvl = flate.DefaultCompression
+		}
+		return extension, fileutils.ZipArchiver{Level: lvl, Password: password}, nil
+	case ".tar.gz":
+		return extension, archives.CompressedArchive{
+			Compression: archives.Gz{CompressionLevel: lvl},
+			Archival:    archives.Tar{},
+		}, nil
+	case ".tar.bz2":
+		return extension, archives.CompressedArchive{
+			Compression: archives.Bz2{CompressionLevel: lvl},
+			Archival:    archives.Tar{},
+		}, nil
+	case ".tar.lz4":
+		// the levels of lz4 go from 1<<8 to 1<<16
+		return extension, archives.CompressedArchive{
+			Compression: archives.Lz4{CompressionLevel: 1 << (7 + lvl)},
+			Archival:    archives.Tar{},
+		}, nil
+	case ".tar.br":
+		return extension, archives.CompressedArchive{
+			Compression: archives.Brotli{Quality: lvl},
+			Archival:    archives.Tar{},
+		}, nil
+	case ".tar.zst":
+		return extension, archives.CompressedArchive{
+			Compression: archives.Zstd{EncoderOptions: []zstd.EOption{
+				zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(lvl)),
+			}},
+			Archival: archives.Tar{},
+		}, nil
+	default:
+		return "", nil, fmt.Errorf("the compression level isn't supported by %s", extension)
+	}
+}
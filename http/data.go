@@ -1,12 +1,15 @@
 package fbhttp
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/tomasen/realip"
 
+	"github.com/filebrowser/filebrowser/v2/events"
 	"github.com/filebrowser/filebrowser/v2/rules"
 	"github.com/filebrowser/filebrowser/v2/runner"
 	"github.com/filebrowser/filebrowser/v2/settings"
@@ -47,7 +50,7 @@ func (d *data) Check(path string) bool {
 	return allow
 }
 
-func handle(fn handleFunc, prefix string, store *storage.Storage, server *settings.Server) http.Handler {
+func handle(fn handleFunc, prefix string, store *storage.Storage, server *settings.Server, bus *events.Bus) http.Handler {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		for k, v := range globalHeaders {
 			w.Header().Set(k, v)
@@ -60,7 +63,7 @@ func handle(fn handleFunc, prefix string, store *storage.Storage, server *settin
 		}
 
 		status, err := fn(w, r, &data{
-			Runner:   &runner.Runner{Enabled: server.EnableExec, Settings: settings},
+			Runner:   &runner.Runner{Enabled: server.EnableExec, Settings: settings, Events: bus},
 			store:    store,
 			settings: settings,
 			server:   server,
@@ -71,6 +74,12 @@ func handle(fn handleFunc, prefix string, store *storage.Storage, server *settin
 			log.Printf("%s: %v %s %v", r.URL.Path, status, clientIP, err)
 		}
 
+		var hookErr *runner.HookError
+		if status >= 400 && errors.As(err, &hookErr) {
+			hookErrorResponse(w, status, hookErr)
+			return
+		}
+
 		if status != 0 {
 			txt := http.StatusText(status)
 			if status == http.StatusBadRequest && err != nil {
@@ -83,3 +92,22 @@ func handle(fn handleFunc, prefix string, store *storage.Storage, server *settin
 
 	return stripPrefix(prefix, handler)
 }
+
+// hookErrorResponse writes the failure of a hook with its details, for
+// the client to show why the operation failed.
+func hookErrorResponse(w http.ResponseWriter, status int, hookErr *runner.HookError) {
+	body, err := json.Marshal(struct {
+		Status  int               `json:"status"`
+		Message string            `json:"message"`
+		Hook    *runner.HookError `json:"hook"`
+	}{status, hookErr.Error(), hookErr})
+	if err != nil {
+		http.Error(w, strconv.Itoa(status)+" "+http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
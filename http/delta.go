@@ -0,0 +1,173 @@
+package fbhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/checksums"
+	"github.com/filebrowser/filebrowser/v2/delta"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+// deltaChange is a change with the ETag of the file, which the client can
+// send back to upload it only if it's unchanged.
+type deltaChange struct {
+	delta.Change
+	ETag string `json:"etag,omitempty"`
+}
+
+// deltaResponse lists the changes of a directory tree since the snapshot
+// of the cursor. Reset is set when the cursor is unknown, in which case
+// the changes list the whole tree.
+type deltaResponse struct {
+	Cursor  string        `json:"cursor"`
+	Reset   bool          `json:"reset"`
+	Changes []deltaChange `json:"changes"`
+}
+
+// deltaHandler returns the changes of a directory tree since the cursor,
+// with the sizes, the modification times and the sha256 sums of the files,
+// and the cursor to ask for the next changes.
+// GET /api/delta/{dir}?cursor=...
+var deltaHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Download || !d.Check(r.URL.Path) {
+		return http.StatusForbidden, nil
+	}
+
+	dir := path.Clean("/" + r.URL.Path)
+	info, err := d.user.Fs.Stat(dir)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if !info.IsDir() {
+		return http.StatusBadRequest, nil
+	}
+
+	fullPath := realPath(d.user.Fs, dir)
+	cursor := r.URL.Query().Get("cursor")
+
+	var prev map[string]delta.Entry
+	reset := true
+	if cursor != "" {
+		snap, err := d.store.Delta.Get(cursor)
+		switch {
+		case err == nil && snap.UserID == d.user.ID && snap.Path == fullPath:
+			prev, reset = snap.Entries, false
+		case err != nil && !errors.Is(err, fberrors.ErrNotExist):
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	entries, err := snapshotTree(r.Context(), d, dir, prev)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	resp := deltaResponse{Cursor: cursor, Reset: reset, Changes: []deltaChange{}}
+	for _, c := range delta.Diff(prev, entries) {
+		change := deltaChange{Change: c}
+		if !c.Dir && !c.Deleted {
+			change.ETag = fileETag(c.Modified, c.Size)
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+
+	// Without changes, the client can keep asking from the same snapshot.
+	if reset || len(resp.Changes) > 0 {
+		snap := &delta.Snapshot{
+			UserID:  d.user.ID,
+			Path:    fullPath,
+			Entries: entries,
+			Created: time.Now(),
+		}
+		if snap.ID, err = delta.NewID(); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if err := d.store.Delta.Save(snap); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		resp.Cursor = snap.ID
+	}
+
+	return renderJSON(w, r, resp)
+})
+
+// snapshotTree returns the entries of the tree under dir that the user can
+// see, by path relative to dir. The sha256 sums are taken from the previous
+// snapshot or from the stored checksums of the files left unchanged since,
+// and computed for the others.
+func snapshotTree(ctx context.Context, d *data, dir string, prev map[string]delta.Entry) (map[string]delta.Entry, error) {
+	known := map[string]*checksums.Record{}
+	if records, err := d.store.Checksums.Within(realPath(d.user.Fs, dir)); err == nil {
+		for _, rec := range records {
+			if rec.Algo == "sha256" {
+				known[rec.Path] = rec
+			}
+		}
+	}
+
+	prefix := strings.TrimSuffix(dir, "/")
+	entries := map[string]delta.Entry{}
+	stack := []string{dir}
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		list, err := afero.ReadDir(d.user.Fs, current)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range list {
+			child := path.Join(current, info.Name())
+			if !d.Check(child) {
+				continue
+			}
+
+			rel := strings.TrimPrefix(child, prefix)
+			if info.IsDir() {
+				if !isSymlinkDir(d.user.Fs, child, info) {
+					entries[rel] = delta.Entry{Dir: true}
+					stack = append(stack, child)
+				}
+				continue
+			}
+
+			if !info.Mode().IsRegular() {
+				continue
+			}
+
+			e := delta.Entry{Size: info.Size(), Modified: info.ModTime()}
+			old, ok := prev[rel]
+			switch rec, stored := known[realPath(d.user.Fs, child)]; {
+			case ok && old.Sha256 != "" && old.Unchanged(e):
+				e.Sha256 = old.Sha256
+			case stored && rec.Current(e.Size, e.Modified):
+				e.Sha256 = rec.Sum
+			default:
+				e.Sha256, err = fileChecksum(d.user.Fs, child, "sha256", &jobs.Tracker{})
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			entries[rel] = e
+		}
+	}
+
+	return entries, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/afero"
 
 	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/jobs"
 )
 
 type dirSizeResponse struct {
@@ -17,38 +18,46 @@ type dirSizeResponse struct {
 	NumDirs  int64 `json:"numDirs"`
 }
 
-var dirSizeHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	info, err := files.NewFileInfo(&files.FileOptions{
-		Fs:         d.user.Fs,
-		Path:       r.URL.Path,
-		Modify:     d.user.Perm.Modify,
-		Expand:     false,
-		ReadHeader: false,
-		Checker:    d,
-		Content:    false,
-	})
-	if err != nil {
-		return errToStatus(err), err
-	}
+func dirSizeHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		info, err := files.NewFileInfo(&files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       r.URL.Path,
+			Modify:     d.user.Perm.Modify,
+			Expand:     false,
+			ReadHeader: false,
+			Checker:    d,
+			Content:    false,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
 
-	if !info.IsDir {
-		return http.StatusBadRequest, nil
-	}
+		if !info.IsDir {
+			return http.StatusBadRequest, nil
+		}
 
-	size, numFiles, numDirs, err := computeDirSize(r.Context(), d.user.Fs, info.Path, d)
-	if err != nil {
-		if errorsIsCanceled(err) {
-			return 0, err
+		if r.URL.Query().Get("async") == "true" {
+			return submitJob(w, r, d, jobManager, "dirsize", map[string]string{
+				"path": info.Path,
+			})
+		}
+
+		size, numFiles, numDirs, err := computeDirSize(r.Context(), d.user.Fs, info.Path, d)
+		if err != nil {
+			if errorsIsCanceled(err) {
+				return 0, err
+			}
+			return errToStatus(err), err
 		}
-		return errToStatus(err), err
-	}
 
-	return renderJSON(w, r, dirSizeResponse{
-		Size:     size,
-		NumFiles: numFiles,
-		NumDirs:  numDirs,
+		return renderJSON(w, r, dirSizeResponse{
+			Size:     size,
+			NumFiles: numFiles,
+			NumDirs:  numDirs,
+		})
 	})
-})
+}
 
 func computeDirSize(ctx context.Context, fs afero.Fs, root string, checker interface{ Check(string) bool }) (size, numFiles, numDirs int64, err error) {
 	root = path.Clean(path.Join("/", root))
@@ -0,0 +1,509 @@
+package fbhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/afero"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+// partialHashSize is the size of the beginning of the files hashed to rule
+// out most of the files of the same size before hashing them completely.
+const partialHashSize = 64 * 1024
+
+// maxDedupePaths limits the number of files of a dedupe request.
+const maxDedupePaths = 1000
+
+type duplicateSet struct {
+	Size   int64    `json:"size"`
+	Sum    string   `json:"sum"`
+	Paths  []string `json:"paths"`
+	Wasted int64    `json:"wasted"` // the bytes used by all but one of the copies
+}
+
+type duplicatesResult struct {
+	Algo   string          `json:"algo"`
+	Files  int             `json:"files"`
+	Sets   []*duplicateSet `json:"sets"`
+	Wasted int64           `json:"wasted"`
+}
+
+// duplicateFile is a file found by the duplicates job.
+type duplicateFile struct {
+	path string
+	info os.FileInfo
+}
+
+// duplicateGroup holds the files with the same checksum.
+type duplicateGroup struct {
+	sum   string
+	files []duplicateFile
+}
+
+// duplicatesHandler finds the files of the tree with the same content.
+// POST /api/duplicates/{dir}?algo=sha256&minSize=1
+func duplicatesHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.Check(r.URL.Path) {
+			return http.StatusForbidden, nil
+		}
+
+		info, err := d.user.Fs.Stat(r.URL.Path)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if !info.IsDir() {
+			return http.StatusBadRequest, errors.New("not a directory")
+		}
+
+		query := r.URL.Query()
+		algo := query.Get("algo")
+		if algo == "" {
+			algo = "sha256"
+		}
+		if _, err := files.NewHash(algo); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		minSize := query.Get("minSize")
+		if minSize != "" {
+			if n, err := strconv.ParseInt(minSize, 10, 64); err != nil || n < 0 {
+				return http.StatusBadRequest, fmt.Errorf("invalid minimum size %q", minSize)
+			}
+		}
+
+		return submitJob(w, r, d, jobManager, "duplicates", map[string]string{
+			"path":    path.Clean("/" + r.URL.Path),
+			"algo":    algo,
+			"minSize": minSize,
+		})
+	})
+}
+
+func duplicatesJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	dir, algo := job.Params["path"], job.Params["algo"]
+	if !d.Check(dir) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	// the empty files are all the same, but don't waste anything
+	minSize := int64(1)
+	if v := job.Params["minSize"]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		minSize = max(n, 1)
+	}
+
+	result := &duplicatesResult{Algo: algo, Sets: []*duplicateSet{}}
+
+	bySize := map[int64][]duplicateFile{}
+	err := walkTree(ctx, d, dir, func(p string, info os.FileInfo) {
+		result.Files++
+		if info.Size() >= minSize {
+			bySize[info.Size()] = append(bySize[info.Size()], duplicateFile{path: p, info: info})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the files of the same size, then with the same beginning
+	var candidates []duplicateGroup
+	for size, group := range bySize {
+		if len(group) < 2 {
+			continue
+		}
+
+		limit := int64(-1)
+		if size > partialHashSize {
+			limit = partialHashSize
+		}
+		groups, err := groupBySum(ctx, d.user.Fs, group, algo, limit, nil)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, groups...)
+	}
+
+	var totalBytes, totalFiles int64
+	for _, group := range candidates {
+		if size := group.files[0].info.Size(); size > partialHashSize {
+			totalBytes += size * int64(len(group.files))
+			totalFiles += int64(len(group.files))
+		}
+	}
+	tracker.SetTotal(totalBytes, totalFiles)
+
+	for _, group := range candidates {
+		// the small files were hashed completely already
+		sets := []duplicateGroup{group}
+		if group.files[0].info.Size() > partialHashSize {
+			sets, err = groupBySum(ctx, d.user.Fs, group.files, algo, -1, tracker)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, set := range sets {
+			if s := newDuplicateSet(set); s != nil {
+				result.Sets = append(result.Sets, s)
+				result.Wasted += s.Wasted
+			}
+		}
+	}
+
+	sort.Slice(result.Sets, func(i, j int) bool {
+		if result.Sets[i].Wasted != result.Sets[j].Wasted {
+			return result.Sets[i].Wasted > result.Sets[j].Wasted
+		}
+		return result.Sets[i].Paths[0] < result.Sets[j].Paths[0]
+	})
+
+	return result, nil
+}
+
+// groupBySum groups the files by the checksum of their first limit bytes,
+// or of their whole content if limit is negative, leaving out the files
+// without a copy.
+func groupBySum(ctx context.Context, afs afero.Fs, group []duplicateFile, algo string, limit int64, tracker *jobs.Tracker) ([]duplicateGroup, error) {
+	bySum := map[string][]duplicateFile{}
+	for _, f := range group {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var sum string
+		var err error
+		if limit < 0 && tracker != nil {
+			sum, err = fileChecksum(afs, f.path, algo, tracker)
+		} else {
+			sum, err = prefixChecksum(afs, f.path, algo, limit)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			// removed since the tree was walked
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		bySum[sum] = append(bySum[sum], f)
+	}
+
+	var groups []duplicateGroup
+	for sum, list := range bySum {
+		if len(list) > 1 {
+			groups = append(groups, duplicateGroup{sum: sum, files: list})
+		}
+	}
+	return groups, nil
+}
+
+// prefixChecksum returns the checksum of the first limit bytes of the file,
+// of its whole content if limit is negative.
+func prefixChecksum(afs afero.Fs, p, algo string, limit int64) (string, error) {
+	h, err := files.NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := afs.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if limit >= 0 {
+		reader = io.LimitReader(f, limit)
+	}
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newDuplicateSet returns the set of the files with the same content, nil
+// if they are all hard links to the same file.
+func newDuplicateSet(group duplicateGroup) *duplicateSet {
+	var distinct []os.FileInfo
+	for _, f := range group.files {
+		linked := false
+		for _, info := range distinct {
+			if os.SameFile(info, f.info) {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			distinct = append(distinct, f.info)
+		}
+	}
+	if len(distinct) < 2 {
+		return nil
+	}
+
+	size := group.files[0].info.Size()
+	s := &duplicateSet{
+		Size:   size,
+		Sum:    group.sum,
+		Wasted: size * int64(len(distinct)-1),
+	}
+	for _, f := range group.files {
+		s.Paths = append(s.Paths, f.path)
+	}
+	sort.Strings(s.Paths)
+
+	return s
+}
+
+type dedupeRequest struct {
+	Action string   `json:"action"` // delete or link
+	Keep   string   `json:"keep"`   // the copy kept
+	Paths  []string `json:"paths"`  // the copies deleted or replaced by hard links to the kept one
+	Async  bool     `json:"async"`
+}
+
+// dedupeHandler deletes the copies of a file, or replaces them with hard
+// links to it, after checking they still have the same content.
+// POST /api/dedupe
+func dedupeHandler(fileCache FileCache, jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		var req dedupeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		if err := req.validate(); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		if !d.user.Perm.Delete || (req.Action == "link" && !d.user.Perm.Create) {
+			return http.StatusForbidden, nil
+		}
+
+		if req.Async {
+			body, err := json.Marshal(req)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			return submitJob(w, r, d, jobManager, "dedupe", map[string]string{
+				"request": string(body),
+			})
+		}
+
+		return renderJSON(w, r, runDedupe(r.Context(), d, fileCache, &req, nil))
+	})
+}
+
+func (req *dedupeRequest) validate() error {
+	if req.Action != "delete" && req.Action != "link" {
+		return fmt.Errorf("invalid action %q", req.Action)
+	}
+	if req.Keep == "" || len(req.Paths) == 0 {
+		return fberrors.ErrInvalidRequestParams
+	}
+	if len(req.Paths) > maxDedupePaths {
+		return fmt.Errorf("too many paths, the maximum is %d", maxDedupePaths)
+	}
+	return nil
+}
+
+// dedupeJob runs a dedupe request in the background, the processed files
+// being the copies handled.
+func dedupeJob(fileCache FileCache) jobFunc {
+	return func(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+		var req dedupeRequest
+		if err := json.Unmarshal([]byte(job.Params["request"]), &req); err != nil {
+			return nil, err
+		}
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
+		if !d.user.Perm.Delete || (req.Action == "link" && !d.user.Perm.Create) {
+			return nil, fberrors.ErrPermissionDenied
+		}
+
+		tracker.SetTotal(0, int64(len(req.Paths)))
+		return runDedupe(ctx, d, fileCache, &req, tracker), nil
+	}
+}
+
+// runDedupe handles the copies in order, reporting the result of each one
+// as the batch operations.
+func runDedupe(ctx context.Context, d *data, fileCache FileCache, req *dedupeRequest, tracker *jobs.Tracker) *batchResponse {
+	resp := &batchResponse{Results: make([]*batchResult, 0, len(req.Paths))}
+	keep := path.Clean("/" + req.Keep)
+
+	for _, p := range req.Paths {
+		p = path.Clean("/" + p)
+		result := &batchResult{
+			Action:      req.Action,
+			Path:        p,
+			Destination: keep,
+			Status:      batchDone,
+		}
+
+		var err error
+		if ctx.Err() != nil {
+			result.Status = batchCanceled
+		} else {
+			result.Status, err = dedupeFile(ctx, d, fileCache, req.Action, keep, p)
+		}
+		if err != nil {
+			result.Status = batchFailed
+			result.Error = err.Error()
+		}
+
+		if tracker != nil {
+			tracker.AddFiles(1)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	return resp
+}
+
+func dedupeFile(ctx context.Context, d *data, fileCache FileCache, action, keep, p string) (string, error) {
+	if !d.Check(keep) || !d.Check(p) || p == "/" {
+		return "", fberrors.ErrPermissionDenied
+	}
+	if p == keep {
+		return "", fberrors.ErrInvalidRequestParams
+	}
+
+	keepInfo, err := d.user.Fs.Stat(keep)
+	if err != nil {
+		return "", err
+	}
+	info, err := d.user.Fs.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if !keepInfo.Mode().IsRegular() || !info.Mode().IsRegular() {
+		return "", fberrors.ErrInvalidRequestParams
+	}
+
+	if os.SameFile(keepInfo, info) {
+		if action == "link" {
+			return batchSkipped, nil
+		}
+	} else {
+		// the files could have changed since they were found
+		same, err := sameContent(ctx, d.user.Fs, keep, p, keepInfo.Size(), info.Size())
+		if err != nil {
+			return "", err
+		}
+		if !same {
+			return "", errors.New("the files aren't the same anymore")
+		}
+	}
+
+	if action == "delete" {
+		return batchDone, deleteResource(ctx, d, fileCache, p)
+	}
+
+	if err := checkLock(d, p, false); err != nil {
+		return "", err
+	}
+
+	return batchDone, d.RunHook(func() error {
+		return hardLink(d.user.Fs, keep, p)
+	}, "save", p, "", d.user)
+}
+
+// sameContent compares the files byte by byte.
+func sameContent(ctx context.Context, afs afero.Fs, a, b string, sizeA, sizeB int64) (bool, error) {
+	if sizeA != sizeB {
+		return false, nil
+	}
+
+	fa, err := afs.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := afs.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		endA := errors.Is(errA, io.EOF) || errors.Is(errA, io.ErrUnexpectedEOF)
+		endB := errors.Is(errB, io.EOF) || errors.Is(errB, io.ErrUnexpectedEOF)
+		switch {
+		case errA != nil && !endA:
+			return false, errA
+		case errB != nil && !endB:
+			return false, errB
+		case endA || endB:
+			return endA && endB, nil
+		}
+	}
+}
+
+// hardLink replaces the file at newname with a hard link to oldname. Both
+// must be on the disk of the server.
+func hardLink(afs afero.Fs, oldname, newname string) error {
+	realPathFs, ok := afs.(interface {
+		RealPath(name string) (string, error)
+	})
+	if !ok {
+		return errors.New("hard links aren't supported")
+	}
+
+	src, err := realPathFs.RealPath(oldname)
+	if err != nil {
+		return err
+	}
+	dst, err := realPathFs.RealPath(newname)
+	if err != nil {
+		return err
+	}
+
+	// the link replaces the file at once
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+"."+hex.EncodeToString(suffix))
+
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package fbhttp
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/diskcache"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/locks"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestSameContent(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 200*1024)
+	changed := bytes.Clone(large)
+	changed[len(changed)-1] = 'b'
+
+	afs := afero.NewMemMapFs()
+	contents := map[string][]byte{
+		"/empty":   {},
+		"/empty2":  {},
+		"/short":   []byte("abc"),
+		"/short2":  []byte("abd"),
+		"/large":   large,
+		"/large2":  bytes.Clone(large),
+		"/changed": changed,
+	}
+	for p, content := range contents {
+		require.NoError(t, afero.WriteFile(afs, p, content, 0644))
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/empty", "/empty2", true},
+		{"/short", "/short2", false},
+		{"/large", "/large2", true},
+		{"/large", "/changed", false},
+		{"/short", "/large", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+tt.b, func(t *testing.T) {
+			same, err := sameContent(context.Background(), afs, tt.a, tt.b,
+				int64(len(contents[tt.a])), int64(len(contents[tt.b])))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, same)
+		})
+	}
+
+	_, err := sameContent(context.Background(), afs, "/short", "/missing", 3, 3)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRunDedupe(t *testing.T) {
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	root := t.TempDir()
+	afs := afero.NewBasePathFs(afero.NewOsFs(), root)
+	require.NoError(t, afs.MkdirAll("/secret", 0755))
+	require.NoError(t, afs.MkdirAll("/dir", 0755))
+	for p, content := range map[string]string{
+		"/keep.txt":       "content",
+		"/copy.txt":       "content",
+		"/changed.txt":    "CONTENT",
+		"/locked.txt":     "content",
+		"/removed.txt":    "content",
+		"/secret/cp.txt":  "content",
+		"/dir/nested.txt": "content",
+	} {
+		require.NoError(t, afero.WriteFile(afs, p, []byte(content), 0644))
+	}
+	require.NoError(t, os.Link(filepath.Join(root, "keep.txt"), filepath.Join(root, "linked.txt")))
+
+	set := &settings.Settings{FileMode: settings.DefaultFileMode, DirMode: settings.DefaultDirMode}
+	user := &users.User{
+		Fs:    afs,
+		Perm:  users.Permissions{Create: true, Modify: true, Delete: true},
+		Rules: []rules.Rule{{Allow: false, Path: "/secret"}},
+	}
+	d := &data{
+		Runner:   &runner.Runner{Settings: set},
+		store:    store,
+		settings: set,
+		server:   &settings.Server{},
+		user:     user,
+	}
+	require.NoError(t, store.Locks.Acquire(&locks.Lock{
+		Path: user.FullPath("/locked.txt"), UserID: 99, Username: "bob", Enforced: true,
+	}))
+
+	resp := runDedupe(context.Background(), d, diskcache.NewNoOp(), &dedupeRequest{
+		Action: "link",
+		Keep:   "/keep.txt",
+		Paths:  []string{"/copy.txt", "/linked.txt", "/changed.txt", "/locked.txt", "/secret/cp.txt", "/dir", "/keep.txt"},
+	}, nil)
+
+	statuses := map[string]string{}
+	for _, result := range resp.Results {
+		statuses[result.Path] = result.Status
+	}
+	require.Equal(t, map[string]string{
+		"/copy.txt":      batchDone,
+		"/linked.txt":    batchSkipped,
+		"/changed.txt":   batchFailed,
+		"/locked.txt":    batchFailed,
+		"/secret/cp.txt": batchFailed,
+		"/dir":           batchFailed,
+		"/keep.txt":      batchFailed,
+	}, statuses)
+	require.Equal(t, "the files aren't the same anymore", resp.Results[2].Error)
+	require.Equal(t, fberrors.ErrPermissionDenied.Error(), resp.Results[4].Error)
+
+	keepInfo, err := os.Stat(filepath.Join(root, "keep.txt"))
+	require.NoError(t, err)
+	for name, linked := range map[string]bool{"copy.txt": true, "changed.txt": false, "locked.txt": false, "secret/cp.txt": false} {
+		info, err := os.Stat(filepath.Join(root, name))
+		require.NoError(t, err)
+		require.Equal(t, linked, os.SameFile(keepInfo, info), name)
+	}
+	content, err := os.ReadFile(filepath.Join(root, "changed.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "CONTENT", string(content))
+
+	// no temporary link is left behind
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.NotEqual(t, '.', entry.Name()[0], entry.Name())
+	}
+
+	// the copies are deleted, hard links included
+	resp = runDedupe(context.Background(), d, diskcache.NewNoOp(), &dedupeRequest{
+		Action: "delete",
+		Keep:   "/keep.txt",
+		Paths:  []string{"/linked.txt", "/removed.txt"},
+	}, nil)
+	require.Equal(t, batchDone, resp.Results[0].Status)
+	require.Equal(t, batchDone, resp.Results[1].Status)
+	require.NoFileExists(t, filepath.Join(root, "linked.txt"))
+	require.NoFileExists(t, filepath.Join(root, "removed.txt"))
+	require.FileExists(t, filepath.Join(root, "keep.txt"))
+
+	// the user must be allowed to delete files to dedupe them
+	user.Perm.Delete = false
+	_, err = dedupeJob(diskcache.NewNoOp())(context.Background(), d, &jobs.Job{
+		Params: map[string]string{"request": `{"action":"delete","keep":"/keep.txt","paths":["/copy.txt"]}`},
+	}, nil)
+	require.ErrorIs(t, err, fberrors.ErrPermissionDenied)
+	require.FileExists(t, filepath.Join(root, "copy.txt"))
+}
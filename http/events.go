@@ -0,0 +1,122 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/events"
+)
+
+// eventsKeepAlive is how often a comment is sent on an idle event stream
+// so that the proxies don't close it.
+const eventsKeepAlive = 30 * time.Second
+
+// eventsHandler streams to the user, as server-sent events, the changes
+// in their scope and the progress of their jobs.
+func eventsHandler(bus *events.Bus, watcher *events.Watcher) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		updates, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		scope := d.user.FullPath("/")
+		if watcher != nil {
+			defer watcher.Add(scope)()
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		flusher, _ := w.(http.Flusher)
+		flush := func() {
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		flush()
+
+		ticker := time.NewTicker(eventsKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return 0, nil
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return 0, err
+				}
+				flush()
+			case e, ok := <-updates:
+				if !ok {
+					return 0, nil
+				}
+
+				e, ok = userEvent(e, d, scope)
+				if !ok {
+					continue
+				}
+
+				body, err := json.Marshal(e)
+				if err != nil {
+					return 0, err
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, body); err != nil {
+					return 0, err
+				}
+				flush()
+			}
+		}
+	})
+}
+
+// userEvent returns the event as seen by the user, with the paths relative
+// to their scope, and whether they can see it at all.
+func userEvent(e events.Event, d *data, scope string) (events.Event, bool) {
+	if e.UserID != 0 && e.UserID != d.user.ID {
+		return e, false
+	}
+
+	if e.Job != nil {
+		return e, true
+	}
+
+	src, srcOk := scopedPath(e.Path, scope, d)
+	dst, dstOk := scopedPath(e.Destination, scope, d)
+	if !srcOk && !dstOk {
+		return e, false
+	}
+
+	e.Path = src
+	e.Destination = dst
+	return e, true
+}
+
+// scopedPath returns the full path relative to the scope, if it is inside
+// the scope and allowed by the rules.
+func scopedPath(fullPath, scope string, d *data) (string, bool) {
+	if fullPath == "" {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(scope, fullPath)
+	if err != nil {
+		return "", false
+	}
+
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+
+	p := path.Clean("/" + rel)
+	if !d.Check(p) {
+		return "", false
+	}
+
+	return p, true
+}
@@ -1,138 +1,370 @@
 package fbhttp
 
 import (
-	"archive/tar"
-	"archive/zip"
-	"compress/gzip"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
 	"unicode/utf8"
 
-	"github.com/spf13/afero"
+	"github.com/mholt/archives"
 	"golang.org/x/text/encoding/simplifiedchinese"
 
 	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/settings"
 )
 
+// errExtractLimit means the archive exceeds one of the extraction limits.
+var errExtractLimit = errors.New("archive exceeds the extraction limits")
+
+// extractFormats maps the archive extensions to their extractor. Longer
+// extensions must come first.
+var extractFormats = []struct {
+	ext       string
+	extractor archives.Extractor
+}{
+	{".tar.gz", archives.CompressedArchive{Compression: archives.Gz{}, Extraction: archives.Tar{}}},
+	{".tgz", archives.CompressedArchive{Compression: archives.Gz{}, Extraction: archives.Tar{}}},
+	{".tar.bz2", archives.CompressedArchive{Compression: archives.Bz2{}, Extraction: archives.Tar{}}},
+	{".tbz2", archives.CompressedArchive{Compression: archives.Bz2{}, Extraction: archives.Tar{}}},
+	{".tar.xz", archives.CompressedArchive{Compression: archives.Xz{}, Extraction: archives.Tar{}}},
+	{".txz", archives.CompressedArchive{Compression: archives.Xz{}, Extraction: archives.Tar{}}},
+	{".tar.zst", archives.CompressedArchive{Compression: archives.Zstd{}, Extraction: archives.Tar{}}},
+	{".tar.lz4", archives.CompressedArchive{Compression: archives.Lz4{}, Extraction: archives.Tar{}}},
+	{".tar.sz", archives.CompressedArchive{Compression: archives.Sz{}, Extraction: archives.Tar{}}},
+	{".tar.br", archives.CompressedArchive{Compression: archives.Brotli{}, Extraction: archives.Tar{}}},
+	{".tar", archives.Tar{}},
+	{".zip", archives.Zip{}},
+	{".7z", archives.SevenZip{}},
+	{".rar", archives.Rar{}},
+}
+
+// Conflict policies for the files that already exist in the destination.
+const (
+	extractOverwrite = "overwrite"
+	extractSkip      = "skip"
+	extractRename    = "rename"
+)
+
+// Status of every entry in the extraction result.
+const (
+	entryExtracted = "extracted"
+	entryCreated   = "created"
+	entrySkipped   = "skipped"
+	entryRenamed   = "renamed"
+	entryFailed    = "failed"
+)
+
+type extractEntry struct {
+	Name   string `json:"name"`           // path inside the archive
+	Path   string `json:"path,omitempty"` // path of the extracted file
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type extractResult struct {
+	Destination string          `json:"destination"`
+	Entries     []*extractEntry `json:"entries"`
+}
+
+// archiveExtraction extracts the entries of an archive into a directory, enforcing
+// the extraction limits and the conflict policy.
+type archiveExtraction struct {
+	d           *data
+	destination string
+	conflict    string
+	entries     []string // the selected entries, all of them if empty
+	limits      settings.Extract
+	maxSize     uint64
+	written     uint64
+	result      *extractResult
+}
+
 // extractHandler handles archive extraction requests
-// POST /api/extract/{path}?destination=...&mode=...
+// POST /api/extract/{path}?destination=...&mode=...&conflict=...&entry=...&async=...
 // mode: "here" (extract to same directory) or "subdir" (extract to subdirectory named after archive)
-var extractHandler = withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if !d.user.Perm.Create {
-		return http.StatusForbidden, nil
-	}
+// conflict: "overwrite" (default), "skip" or "rename" existing files
+// entry: the entries to extract, with everything in them, repeated for every entry
+// async: "true" to extract in a background job
+func extractHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Create {
+			return http.StatusForbidden, nil
+		}
 
-	archivePath := r.URL.Path
+		archivePath := r.URL.Path
+
+		// Get file info
+		file, err := files.NewFileInfo(&files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       archivePath,
+			Modify:     d.user.Perm.Modify,
+			Expand:     false,
+			ReadHeader: d.server.TypeDetectionByHeader,
+			Checker:    d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
 
-	// Get file info
-	file, err := files.NewFileInfo(&files.FileOptions{
-		Fs:         d.user.Fs,
-		Path:       archivePath,
-		Modify:     d.user.Perm.Modify,
-		Expand:     false,
-		ReadHeader: d.server.TypeDetectionByHeader,
-		Checker:    d,
+		if file.IsDir {
+			return http.StatusBadRequest, errors.New("cannot extract a directory")
+		}
+
+		format, _ := getArchiveFormat(file.Name)
+		if format == nil {
+			return http.StatusBadRequest, fmt.Errorf("unsupported archive format: %s", file.Name)
+		}
+
+		conflict := r.URL.Query().Get("conflict")
+		switch conflict {
+		case "":
+			conflict = extractOverwrite
+		case extractOverwrite, extractSkip, extractRename:
+		default:
+			return http.StatusBadRequest, fmt.Errorf("invalid conflict policy %q", conflict)
+		}
+
+		// Determine destination directory
+		mode := r.URL.Query().Get("mode")
+		destination := r.URL.Query().Get("destination")
+
+		if destination != "" {
+			destination, err = url.QueryUnescape(destination)
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+		} else if mode == "subdir" {
+			// Extract to subdirectory named after archive (without extension)
+			baseName := path.Base(archivePath)
+			dirName := getArchiveBaseName(baseName)
+			destination = path.Join(path.Dir(archivePath), dirName)
+		} else {
+			// Default: extract to same directory as the archive
+			destination = path.Dir(archivePath)
+		}
+
+		// Check destination permission
+		if !d.Check(destination) {
+			return http.StatusForbidden, nil
+		}
+
+		entries := r.URL.Query()["entry"]
+		for i, entry := range entries {
+			entries[i] = path.Clean("/" + entry)
+		}
+
+		if r.URL.Query().Get("async") == "true" {
+			selection, err := json.Marshal(entries)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+
+			return submitJob(w, r, d, jobManager, "extract", map[string]string{
+				"path":        archivePath,
+				"destination": destination,
+				"conflict":    conflict,
+				"entries":     string(selection),
+			})
+		}
+
+		result, err := extractArchive(r.Context(), d, file, destination, conflict, entries)
+		if errors.Is(err, errExtractLimit) {
+			return http.StatusRequestEntityTooLarge, err
+		} else if err != nil {
+			if errorsIsCanceled(err) {
+				return 0, err
+			}
+			return http.StatusUnprocessableEntity, err
+		}
+
+		return renderJSON(w, r, result)
 	})
+}
+
+// extractArchive extracts the archive file, or only the selected entries
+// when there are some, into the destination directory.
+func extractArchive(ctx context.Context, d *data, file *files.FileInfo, destination, conflict string, entries []string) (*extractResult, error) {
+	format, _ := getArchiveFormat(file.Name)
+	if format == nil {
+		return nil, fmt.Errorf("unsupported archive format: %s", file.Name)
+	}
+
+	// Ensure destination exists
+	if err := d.user.Fs.MkdirAll(destination, d.settings.DirMode); err != nil {
+		return nil, err
+	}
+
+	fd, err := d.user.Fs.Open(file.Path)
 	if err != nil {
-		return errToStatus(err), err
+		return nil, err
+	}
+	defer fd.Close()
+
+	ex := &archiveExtraction{
+		d:           d,
+		destination: destination,
+		conflict:    conflict,
+		entries:     entries,
+		limits:      d.settings.Extract,
+		maxSize:     math.MaxInt64,
+		result:      &extractResult{Destination: destination, Entries: []*extractEntry{}},
+	}
+	if d.settings.Extract.MaxSize > 0 {
+		ex.maxSize = d.settings.Extract.MaxSize
+	}
+	if ratio := d.settings.Extract.MaxRatio; ratio > 0 && uint64(file.Size)*ratio < ex.maxSize {
+		ex.maxSize = uint64(file.Size) * ratio
 	}
 
-	if file.IsDir {
-		return http.StatusBadRequest, errors.New("cannot extract a directory")
+	if err := format.Extract(ctx, fd, ex.extractFile); err != nil {
+		return nil, err
 	}
 
-	// Determine destination directory
-	mode := r.URL.Query().Get("mode")
-	destination := r.URL.Query().Get("destination")
+	return ex.result, nil
+}
 
-	if destination != "" {
-		destination, err = url.QueryUnescape(destination)
-		if err != nil {
-			return http.StatusBadRequest, err
+// getArchiveFormat returns the extractor for the file and the extension
+// that identified it.
+func getArchiveFormat(filename string) (archives.Extractor, string) {
+	lower := strings.ToLower(filename)
+	for _, format := range extractFormats {
+		if strings.HasSuffix(lower, format.ext) {
+			return format.extractor, format.ext
 		}
-	} else if mode == "subdir" {
-		// Extract to subdirectory named after archive (without extension)
-		baseName := path.Base(archivePath)
-		dirName := getArchiveBaseName(baseName)
-		destination = path.Join(path.Dir(archivePath), dirName)
-	} else {
-		// Default: extract to same directory as the archive
-		destination = path.Dir(archivePath)
 	}
+	return nil, ""
+}
+
+// getArchiveBaseName removes archive extensions from filename
+func getArchiveBaseName(filename string) string {
+	_, ext := getArchiveFormat(filename)
+	return filename[:len(filename)-len(ext)]
+}
 
-	// Check destination permission
-	if !d.Check(destination) {
-		return http.StatusForbidden, nil
+func (ex *archiveExtraction) extractFile(_ context.Context, f archives.FileInfo) error {
+	name := archiveEntryName(f)
+
+	// Check for path traversal attack (zip slip)
+	filePath, ok := cleanEntryName(name)
+	if len(ex.entries) > 0 && (!ok || !selectsEntry(ex.entries, filePath)) {
+		return nil
 	}
 
-	// Ensure destination exists
-	if err := d.user.Fs.MkdirAll(destination, d.settings.DirMode); err != nil {
-		return errToStatus(err), err
+	entry := &extractEntry{Name: name}
+	ex.result.Entries = append(ex.result.Entries, entry)
+
+	if ex.limits.MaxEntries > 0 && uint64(len(ex.result.Entries)) > ex.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", errExtractLimit, ex.limits.MaxEntries)
+	}
+
+	if !ok {
+		entry.Status = entryFailed
+		entry.Error = "invalid file path in archive"
+		return nil
+	}
+
+	entry.Path = path.Join(ex.destination, filePath)
+	if !ex.d.Check(entry.Path) {
+		entry.Status = entryFailed
+		entry.Error = "permission denied"
+		return nil
 	}
 
-	// Determine archive type and extract
-	lowerPath := strings.ToLower(archivePath)
 	switch {
-	case strings.HasSuffix(lowerPath, ".zip"):
-		err = extractZip(d.user.Fs, archivePath, destination, d.settings.FileMode, d.settings.DirMode)
-	case strings.HasSuffix(lowerPath, ".tar.gz") || strings.HasSuffix(lowerPath, ".tgz"):
-		err = extractTarGz(d.user.Fs, archivePath, destination, d.settings.FileMode, d.settings.DirMode)
-	case strings.HasSuffix(lowerPath, ".tar"):
-		err = extractTar(d.user.Fs, archivePath, destination, d.settings.FileMode, d.settings.DirMode)
-	default:
-		return http.StatusBadRequest, errors.New("unsupported archive format: only .zip, .tar.gz, .tgz, and .tar are supported")
+	case f.IsDir():
+		entry.Status = entryCreated
+		if err := ex.d.user.Fs.MkdirAll(entry.Path, ex.d.settings.DirMode); err != nil {
+			entry.Status = entryFailed
+			entry.Error = err.Error()
+		}
+		return nil
+	case !f.Mode().IsRegular():
+		// Skip symlinks, hard links and special files for security
+		entry.Status = entrySkipped
+		return nil
 	}
 
-	if err != nil {
-		return errToStatus(err), err
+	if size := f.Size(); size > 0 && ex.written+uint64(size) > ex.maxSize {
+		return fmt.Errorf("%w: more than %d bytes", errExtractLimit, ex.maxSize)
 	}
 
-	return http.StatusOK, nil
-})
+	entry.Status = entryExtracted
+	if _, err := ex.d.user.Fs.Stat(entry.Path); err == nil {
+		switch ex.conflict {
+		case extractSkip:
+			entry.Status = entrySkipped
+			return nil
+		case extractRename:
+			entry.Path = fileutils.AddVersionSuffix(entry.Path, ex.d.user.Fs)
+			entry.Status = entryRenamed
+		default:
+			if !ex.d.user.Perm.Modify {
+				entry.Status = entryFailed
+				entry.Error = "permission denied"
+				return nil
+			}
+			if err := checkLock(ex.d, entry.Path, false); err != nil {
+				entry.Status = entryFailed
+				entry.Error = err.Error()
+				return nil
+			}
+		}
+	}
 
-// getArchiveBaseName removes archive extensions from filename
-func getArchiveBaseName(filename string) string {
-	lower := strings.ToLower(filename)
-	switch {
-	case strings.HasSuffix(lower, ".tar.gz"):
-		return filename[:len(filename)-7]
-	case strings.HasSuffix(lower, ".tgz"):
-		return filename[:len(filename)-4]
-	case strings.HasSuffix(lower, ".tar"):
-		return filename[:len(filename)-4]
-	case strings.HasSuffix(lower, ".zip"):
-		return filename[:len(filename)-4]
-	default:
-		return filename
+	if err := ex.writeFile(f, entry.Path); err != nil {
+		if errors.Is(err, errExtractLimit) {
+			return err
+		}
+		entry.Status = entryFailed
+		entry.Error = err.Error()
 	}
+
+	return nil
 }
 
-// extractZip extracts a ZIP archive using streaming (low memory usage)
-func extractZip(afs afero.Fs, archivePath, destination string, fileMode, dirMode os.FileMode) error {
-	// Get the real path for zip.OpenReader
-	realPath := archivePath
-	if bpfs, ok := afs.(*afero.BasePathFs); ok {
-		realPath = afero.FullBaseFsPath(bpfs, archivePath)
+func (ex *archiveExtraction) writeFile(f archives.FileInfo, dst string) error {
+	afs := ex.d.user.Fs
+	if err := afs.MkdirAll(path.Dir(dst), ex.d.settings.DirMode); err != nil {
+		return err
 	}
 
-	// Open the zip file
-	zipReader, err := zip.OpenReader(realPath)
+	rc, err := f.Open()
 	if err != nil {
-		return fmt.Errorf("failed to open zip file: %w", err)
+		return fmt.Errorf("failed to open file in archive: %w", err)
 	}
-	defer zipReader.Close()
+	defer rc.Close()
 
-	for _, f := range zipReader.File {
-		if err := extractZipFile(afs, f, destination, fileMode, dirMode); err != nil {
-			return err
+	outFile, err := afs.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, ex.d.settings.FileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	// The sizes in the headers can't be trusted, so the limit is
+	// enforced while writing too.
+	remaining := ex.maxSize - ex.written
+	n, err := io.CopyBuffer(outFile, io.LimitReader(rc, int64(min(remaining, math.MaxInt64-1))+1), make([]byte, 32*1024))
+	outFile.Close()
+	ex.written += uint64(n)
+
+	if err == nil && uint64(n) > remaining {
+		err = fmt.Errorf("%w: more than %d bytes", errExtractLimit, ex.maxSize)
+	}
+	if err != nil {
+		_ = afs.Remove(dst)
+		if !errors.Is(err, errExtractLimit) {
+			err = fmt.Errorf("failed to extract file: %w", err)
 		}
+		return err
 	}
 
 	return nil
@@ -174,168 +406,3 @@ func decodeZipFileName(name string, flags uint16) string {
 
 	return name
 }
-
-// extractZipFile extracts a single file from a ZIP archive
-func extractZipFile(afs afero.Fs, f *zip.File, destination string, fileMode, dirMode os.FileMode) error {
-	// Decode file name (handle GBK encoding)
-	fileName := decodeZipFileName(f.Name, f.Flags)
-
-	// Normalize path separators: convert backslashes to forward slashes first (for Windows-created archives)
-	// then use path.Clean for validation
-	filePath := strings.ReplaceAll(fileName, "\\", "/")
-	filePath = path.Clean(filePath)
-
-	// Check for path traversal attack (zip slip)
-	if strings.HasPrefix(filePath, "../") || strings.HasPrefix(filePath, "/") || strings.Contains(filePath, "/../") {
-		return fmt.Errorf("invalid file path in archive: %s", f.Name)
-	}
-
-	// Convert to OS-specific path separators for file system operations
-	targetPath := filepath.Join(destination, filepath.FromSlash(filePath))
-
-	if f.FileInfo().IsDir() {
-		return afs.MkdirAll(targetPath, dirMode)
-	}
-
-	// Create parent directory
-	if err := afs.MkdirAll(filepath.Dir(targetPath), dirMode); err != nil {
-		return err
-	}
-
-	// Open source file in archive
-	rc, err := f.Open()
-	if err != nil {
-		return fmt.Errorf("failed to open file in archive: %w", err)
-	}
-	defer rc.Close()
-
-	// Create destination file
-	outFile, err := afs.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer outFile.Close()
-
-	// Stream copy with limited buffer (8KB) to minimize memory usage
-	buf := make([]byte, 8*1024)
-	_, err = io.CopyBuffer(outFile, rc, buf)
-	if err != nil {
-		return fmt.Errorf("failed to extract file: %w", err)
-	}
-
-	return nil
-}
-
-// extractTarGz extracts a .tar.gz archive using streaming
-func extractTarGz(afs afero.Fs, archivePath, destination string, fileMode, dirMode os.FileMode) error {
-	// Get the real path
-	realPath := archivePath
-	if bpfs, ok := afs.(*afero.BasePathFs); ok {
-		realPath = afero.FullBaseFsPath(bpfs, archivePath)
-	}
-
-	// Open the gzip file
-	file, err := os.Open(realPath)
-	if err != nil {
-		return fmt.Errorf("failed to open archive: %w", err)
-	}
-	defer file.Close()
-
-	// Create gzip reader
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer gzReader.Close()
-
-	return extractTarReader(afs, gzReader, destination, fileMode, dirMode)
-}
-
-// extractTar extracts a .tar archive using streaming
-func extractTar(afs afero.Fs, archivePath, destination string, fileMode, dirMode os.FileMode) error {
-	// Get the real path
-	realPath := archivePath
-	if bpfs, ok := afs.(*afero.BasePathFs); ok {
-		realPath = afero.FullBaseFsPath(bpfs, archivePath)
-	}
-
-	// Open the tar file
-	file, err := os.Open(realPath)
-	if err != nil {
-		return fmt.Errorf("failed to open archive: %w", err)
-	}
-	defer file.Close()
-
-	return extractTarReader(afs, file, destination, fileMode, dirMode)
-}
-
-// extractTarReader extracts from a tar reader (used by both tar and tar.gz)
-func extractTarReader(afs afero.Fs, reader io.Reader, destination string, fileMode, dirMode os.FileMode) error {
-	tarReader := tar.NewReader(reader)
-
-	// Use a buffer for streaming extraction
-	buf := make([]byte, 8*1024)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
-		}
-
-		// Normalize path separators: convert backslashes to forward slashes first (for Windows-created archives)
-		// then use path.Clean for validation
-		filePath := strings.ReplaceAll(header.Name, "\\", "/")
-		filePath = path.Clean(filePath)
-
-		// Check for path traversal attack
-		if strings.HasPrefix(filePath, "../") || strings.HasPrefix(filePath, "/") || strings.Contains(filePath, "/../") {
-			return fmt.Errorf("invalid file path in archive: %s", header.Name)
-		}
-
-		// Convert to OS-specific path separators for file system operations
-		targetPath := filepath.Join(destination, filepath.FromSlash(filePath))
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := afs.MkdirAll(targetPath, dirMode); err != nil {
-				return fmt.Errorf("failed to create directory: %w", err)
-			}
-
-		case tar.TypeReg:
-			// Create parent directory
-			if err := afs.MkdirAll(filepath.Dir(targetPath), dirMode); err != nil {
-				return err
-			}
-
-			// Create file
-			outFile, err := afs.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
-			if err != nil {
-				return fmt.Errorf("failed to create file: %w", err)
-			}
-
-			// Stream copy
-			_, err = io.CopyBuffer(outFile, tarReader, buf)
-			outFile.Close()
-			if err != nil {
-				return fmt.Errorf("failed to extract file: %w", err)
-			}
-
-		case tar.TypeSymlink:
-			// Skip symlinks for security
-			continue
-
-		case tar.TypeLink:
-			// Skip hard links for security
-			continue
-
-		default:
-			// Skip unknown types
-			continue
-		}
-	}
-
-	return nil
-}
@@ -0,0 +1,192 @@
+package fbhttp
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/mholt/archives"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/locks"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func newTestZip(t *testing.T, entries map[string]string) *bytes.Reader {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func newTestExtraction(t *testing.T, afs afero.Fs, conflict string, limits settings.Extract) *archiveExtraction {
+	t.Helper()
+
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	d := &data{
+		store: store,
+		user:  &users.User{Fs: afs, Perm: users.Permissions{Create: true, Modify: true}},
+		settings: &settings.Settings{
+			FileMode: settings.DefaultFileMode,
+			DirMode:  settings.DefaultDirMode,
+			Extract:  limits,
+		},
+	}
+
+	return &archiveExtraction{
+		d:           d,
+		destination: "/dst",
+		conflict:    conflict,
+		limits:      limits,
+		maxSize:     limits.MaxSize,
+		result:      &extractResult{Destination: "/dst"},
+	}
+}
+
+func TestExtractConflicts(t *testing.T) {
+	entries := map[string]string{
+		"a.txt":       "new",
+		"dir/b.txt":   "b",
+		"../evil.txt": "evil",
+	}
+
+	testCases := map[string]struct {
+		conflict string
+		status   string
+		path     string
+		content  string
+		locked   bool
+	}{
+		"overwrite": {conflict: extractOverwrite, status: entryExtracted, path: "/dst/a.txt", content: "new"},
+		"skip":      {conflict: extractSkip, status: entrySkipped, path: "/dst/a.txt", content: "old"},
+		"rename":    {conflict: extractRename, status: entryRenamed, path: "/dst/a(1).txt", content: "new"},
+		"locked":    {conflict: extractOverwrite, status: entryFailed, path: "/dst/a.txt", content: "old", locked: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			afs := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(afs, "/dst/a.txt", []byte("old"), 0644))
+
+			ex := newTestExtraction(t, afs, tc.conflict, settings.Extract{MaxSize: 1024})
+			if tc.locked {
+				require.NoError(t, ex.d.store.Locks.Acquire(&locks.Lock{Path: "/dst/a.txt", UserID: 99, Username: "bob", Enforced: true}))
+			}
+			require.NoError(t, archives.Zip{}.Extract(context.Background(), newTestZip(t, entries), ex.extractFile))
+
+			results := map[string]*extractEntry{}
+			for _, entry := range ex.result.Entries {
+				results[entry.Name] = entry
+			}
+
+			require.Equal(t, tc.status, results["a.txt"].Status)
+			require.Equal(t, tc.path, results["a.txt"].Path)
+			content, err := afero.ReadFile(afs, tc.path)
+			require.NoError(t, err)
+			require.Equal(t, tc.content, string(content))
+
+			require.Equal(t, entryExtracted, results["dir/b.txt"].Status)
+			require.Equal(t, entryFailed, results["../evil.txt"].Status)
+			exists, err := afero.Exists(afs, "/evil.txt")
+			require.NoError(t, err)
+			require.False(t, exists)
+		})
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	entries := map[string]string{
+		"a.txt": string(bytes.Repeat([]byte("a"), 600)),
+		"b.txt": string(bytes.Repeat([]byte("b"), 600)),
+	}
+
+	t.Run("size", func(t *testing.T) {
+		ex := newTestExtraction(t, afero.NewMemMapFs(), extractOverwrite, settings.Extract{MaxSize: 1000})
+		err := archives.Zip{}.Extract(context.Background(), newTestZip(t, entries), ex.extractFile)
+		require.ErrorIs(t, err, errExtractLimit)
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		ex := newTestExtraction(t, afero.NewMemMapFs(), extractOverwrite, settings.Extract{MaxSize: 10000, MaxEntries: 1})
+		err := archives.Zip{}.Extract(context.Background(), newTestZip(t, entries), ex.extractFile)
+		require.ErrorIs(t, err, errExtractLimit)
+	})
+}
+
+func TestExtractSelection(t *testing.T) {
+	entries := map[string]string{
+		"a.txt":         "a",
+		"dir/b.txt":     "b",
+		"dir/sub/c.txt": "c",
+		"dirty.txt":     "d",
+	}
+
+	afs := afero.NewMemMapFs()
+	ex := newTestExtraction(t, afs, extractOverwrite, settings.Extract{MaxSize: 1024})
+	ex.entries = []string{"/dir"}
+	require.NoError(t, archives.Zip{}.Extract(context.Background(), newTestZip(t, entries), ex.extractFile))
+
+	extracted := []string{}
+	for _, entry := range ex.result.Entries {
+		extracted = append(extracted, entry.Name)
+	}
+	require.ElementsMatch(t, []string{"dir/b.txt", "dir/sub/c.txt"}, extracted)
+}
+
+func TestListArchive(t *testing.T) {
+	content, err := io.ReadAll(newTestZip(t, map[string]string{
+		"a.txt":         "a",
+		"dir/b.txt":     "b",
+		"dir/sub/c.txt": "c",
+	}))
+	require.NoError(t, err)
+
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(afs, "/files.zip", content, 0644))
+
+	d := &data{user: &users.User{Fs: afs}}
+	file := &files.FileInfo{Path: "/files.zip", Name: "files.zip"}
+
+	listing, err := listArchive(context.Background(), d, file, "")
+	require.NoError(t, err)
+	require.Equal(t, "/", listing.ArchiveEntry)
+	require.Equal(t, 1, listing.NumDirs)
+	require.Equal(t, 1, listing.NumFiles)
+
+	listing, err = listArchive(context.Background(), d, file, "/dir")
+	require.NoError(t, err)
+	items := map[string]*files.FileInfo{}
+	for _, item := range listing.Items {
+		items[item.ArchiveEntry] = item
+	}
+	require.Len(t, items, 2)
+	require.True(t, items["/dir/sub"].IsDir)
+	require.Equal(t, "/files.zip/dir/b.txt", items["/dir/b.txt"].Path)
+	require.Equal(t, "textImmutable", items["/dir/b.txt"].Type)
+
+	_, err = listArchive(context.Background(), d, file, "/missing")
+	require.ErrorIs(t, err, fberrors.ErrNotExist)
+}
@@ -0,0 +1,435 @@
+package fbhttp
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// fetchAttempts is how many times a download is attempted, each attempt
+// resuming where the previous one was interrupted.
+const fetchAttempts = 5
+
+const fetchMaxRedirects = 10
+
+// internalNetworks are the ranges of addresses which aren't publicly
+// routable, besides the private, loopback and link-local ones.
+var internalNetworks = parseNetworks("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func internalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostPolicy decides which hosts URLs can be fetched from, see
+// settings.Fetch for the format of the lists.
+type hostPolicy struct {
+	allowed []string
+	denied  []string
+}
+
+func newHostPolicy(set settings.Fetch) hostPolicy {
+	return hostPolicy{allowed: set.AllowedHosts, denied: set.DeniedHosts}
+}
+
+// matchHost reports whether an entry of the list matches the host name or
+// its address, which is nil when it isn't known yet.
+func matchHost(list []string, host string, ip net.IP) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, entry := range list {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		if suffix, ok := strings.CutPrefix(entry, "*"); ok && strings.HasPrefix(suffix, ".") {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if entry != "" && entry == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns an error if the host can't be connected to at the address.
+func (p hostPolicy) check(host string, ip net.IP) error {
+	switch {
+	case matchHost(p.denied, host, ip):
+	case len(p.allowed) > 0 && !matchHost(p.allowed, host, ip):
+	case len(p.allowed) == 0 && internalIP(ip):
+	default:
+		return nil
+	}
+	return fmt.Errorf("fetching from %s (%s) is not allowed: %w", host, ip, fberrors.ErrPermissionDenied)
+}
+
+// checkName returns an error if the host is denied regardless of its
+// address. The addresses are only checked when connecting, as the host may
+// resolve to other ones by then.
+func (p hostPolicy) checkName(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return p.check(host, ip)
+	}
+	if matchHost(p.denied, host, nil) {
+		return fmt.Errorf("fetching from %s is not allowed: %w", host, fberrors.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// dialContext resolves the host and connects to its addresses, refusing
+// to if any of them isn't allowed.
+func (p hostPolicy) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if err := p.check(host, a.IP); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, a := range addrs {
+			var conn net.Conn
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(a.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// checkFetchURL returns an error if the URL can't be fetched from.
+func checkFetchURL(u *url.URL, policy hostPolicy) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q: %w", u.Scheme, fberrors.ErrInvalidRequestParams)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("URL without host: %w", fberrors.ErrInvalidRequestParams)
+	}
+	return policy.checkName(u.Hostname())
+}
+
+// fetchClient returns a client which only connects to the hosts allowed
+// by the settings, following redirects to them too.
+func fetchClient(set settings.Fetch) *http.Client {
+	policy := newHostPolicy(set)
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			// no proxy, which would be connected to instead of the host
+			Proxy:                 nil,
+			DialContext:           policy.dialContext(dialer),
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= fetchMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkFetchURL(req.URL, policy)
+		},
+	}
+}
+
+// parseFetchChecksum splits a checksum given as algo:hex.
+func parseFetchChecksum(checksum string) (algo, sum string, err error) {
+	algo, sum, ok := strings.Cut(checksum, ":")
+	sum = strings.ToLower(sum)
+	if !ok {
+		return "", "", fmt.Errorf("invalid checksum %q: %w", checksum, fberrors.ErrInvalidRequestParams)
+	}
+	if _, err := files.NewHash(algo); err != nil {
+		return "", "", err
+	}
+	if _, err := hex.DecodeString(sum); err != nil || sum == "" {
+		return "", "", fmt.Errorf("invalid checksum %q: %w", checksum, fberrors.ErrInvalidRequestParams)
+	}
+	return algo, sum, nil
+}
+
+// fetchName returns the name of the file downloaded from the URL.
+func fetchName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return "download"
+	}
+	return name
+}
+
+type fetchRequest struct {
+	URL      string `json:"url"`
+	Checksum string `json:"checksum"` // such as sha256:<hex>, verified once downloaded
+}
+
+type fetchResult struct {
+	URL      string `json:"url"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// fetchHandler downloads a URL to the path, or into it when it is a
+// directory, in a "fetch" job.
+func fetchHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Create || !d.Check(r.URL.Path) {
+			return http.StatusForbidden, nil
+		}
+
+		var req fetchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		if err := checkFetchURL(u, newHostPolicy(d.settings.Fetch)); err != nil {
+			return errToStatus(err), err
+		}
+		if req.Checksum != "" {
+			if _, _, err := parseFetchChecksum(req.Checksum); err != nil {
+				return http.StatusBadRequest, err
+			}
+		}
+
+		dst := path.Clean("/" + r.URL.Path)
+		if info, err := d.user.Fs.Stat(dst); strings.HasSuffix(r.URL.Path, "/") || (err == nil && info.IsDir()) {
+			dst = path.Join(dst, fetchName(u))
+		}
+		if !d.Check(dst) {
+			return http.StatusForbidden, nil
+		}
+
+		override := r.URL.Query().Get("override") == "true"
+		if override && !d.user.Perm.Modify {
+			return http.StatusForbidden, nil
+		}
+		if info, err := d.user.Fs.Stat(dst); err == nil && (info.IsDir() || !override) {
+			return http.StatusConflict, nil
+		}
+
+		return submitJob(w, r, d, jobManager, "fetch", map[string]string{
+			"url":         u.String(),
+			"destination": dst,
+			"checksum":    req.Checksum,
+			"override":    strconv.FormatBool(override),
+		})
+	})
+}
+
+func fetchJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	rawURL, dst := job.Params["url"], job.Params["destination"]
+	if !d.user.Perm.Create || !d.Check(dst) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	if _, err := d.user.Fs.Stat(dst); err == nil {
+		if job.Params["override"] != "true" {
+			return nil, fberrors.ErrExist
+		}
+		if !d.user.Perm.Modify {
+			return nil, fberrors.ErrPermissionDenied
+		}
+		if err := checkLock(d, dst, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.user.Fs.MkdirAll(path.Dir(dst), d.settings.DirMode); err != nil {
+		return nil, err
+	}
+
+	// the download goes to a hidden file, only renamed once complete
+	part := path.Join(path.Dir(dst), "."+path.Base(dst)+".fetch")
+	defer func() {
+		_ = d.user.Fs.Remove(part)
+	}()
+
+	tracked := trackedData(ctx, d, tracker)
+	size, err := download(ctx, fetchClient(d.settings.Fetch), rawURL, tracked.user.Fs, part, d.settings.FileMode, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &fetchResult{URL: rawURL, Path: dst, Size: size}
+	if checksum := job.Params["checksum"]; checksum != "" {
+		algo, sum, err := parseFetchChecksum(checksum)
+		if err != nil {
+			return nil, err
+		}
+
+		actual, err := fileChecksum(d.user.Fs, part, algo, &jobs.Tracker{})
+		if err != nil {
+			return nil, err
+		}
+		if actual != sum {
+			return nil, fmt.Errorf("%s checksum mismatch: expected %s, got %s", algo, sum, actual)
+		}
+		result.Checksum = checksum
+	}
+
+	err = d.RunHook(func() error {
+		return d.user.Fs.Rename(part, dst)
+	}, "upload", dst, "", d.user)
+	if err != nil {
+		return nil, err
+	}
+
+	storeFileChecksum(d, dst)
+	return result, nil
+}
+
+// download writes the content of the URL to p, resuming with range
+// requests when the transfer is interrupted.
+func download(ctx context.Context, client *http.Client, rawURL string, afs afero.Fs, p string, mode os.FileMode, tracker *jobs.Tracker) (int64, error) {
+	f, err := afs.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		offset    int64
+		validator string // ETag or Last-Modified, so that a resumed transfer gets the same content
+		lastErr   error
+	)
+	for attempt := 0; attempt < fetchAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return 0, err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if validator != "" {
+				req.Header.Set("If-Range", validator)
+			}
+		}
+
+		resp, err := client.Do(req)
+		switch {
+		case err != nil && (ctx.Err() != nil || errors.Is(err, fberrors.ErrPermissionDenied)):
+			return 0, err
+		case err != nil:
+			lastErr = err
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		case resp.StatusCode == http.StatusOK:
+			// the content is sent from its beginning, not resumed
+			if offset > 0 {
+				if err := f.Truncate(0); err != nil {
+					resp.Body.Close()
+					return 0, err
+				}
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					resp.Body.Close()
+					return 0, err
+				}
+				tracker.AddBytes(-offset)
+				offset = 0
+			}
+
+			validator = resp.Header.Get("ETag")
+			if validator == "" {
+				validator = resp.Header.Get("Last-Modified")
+			}
+			if resp.ContentLength >= 0 {
+				tracker.SetTotal(resp.ContentLength, 1)
+			}
+		default:
+			resp.Body.Close()
+			err := fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+			if resp.StatusCode < http.StatusInternalServerError {
+				return 0, err
+			}
+			lastErr = err
+			continue
+		}
+
+		n, err := io.Copy(f, resp.Body)
+		resp.Body.Close()
+		offset += n
+		if err == nil {
+			return offset, nil
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		lastErr = err
+	}
+
+	return 0, fmt.Errorf("fetching %s: %w", rawURL, lastErr)
+}
@@ -0,0 +1,119 @@
+package fbhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestHostPolicy(t *testing.T) {
+	public := net.ParseIP("93.184.216.34")
+	internal := net.ParseIP("10.1.2.3")
+
+	open := hostPolicy{}
+	require.NoError(t, open.check("example.com", public))
+	require.Error(t, open.check("example.com", internal))
+	require.Error(t, open.check("localhost", net.ParseIP("127.0.0.1")))
+	require.Error(t, open.check("metadata", net.ParseIP("169.254.169.254")))
+	require.Error(t, open.check("mapped", net.ParseIP("::ffff:127.0.0.1")))
+	require.Error(t, open.checkName("127.0.0.1"))
+	require.NoError(t, open.checkName("example.com"))
+
+	denied := hostPolicy{denied: []string{"*.example.com", "93.184.0.0/16"}}
+	require.Error(t, denied.checkName("www.example.com"))
+	require.NoError(t, denied.checkName("example.org"))
+	require.Error(t, denied.check("example.org", public))
+
+	allowed := hostPolicy{allowed: []string{"nas.lan", "192.168.1.0/24"}}
+	require.NoError(t, allowed.check("nas.lan", internal))
+	require.NoError(t, allowed.check("printer", net.ParseIP("192.168.1.20")))
+	require.Error(t, allowed.check("example.com", public))
+}
+
+func TestFetchJob(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	sum := sha256.Sum256(content)
+
+	var requests atomic.Int32
+	var resumed atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first transfer is interrupted halfway, to be resumed
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", "1048576")
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write(content[:len(content)/2])
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		resumed.Store(r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	afs := afero.NewBasePathFs(afero.NewMemMapFs(), "/")
+	newData := func(set settings.Fetch) *data {
+		s := &settings.Settings{Fetch: set}
+		return &data{
+			Runner:   &runner.Runner{Settings: s},
+			settings: s,
+			user:     &users.User{Fs: afs, Perm: users.Permissions{Create: true}},
+		}
+	}
+	job := &jobs.Job{Params: map[string]string{
+		"url":         srv.URL + "/file.bin",
+		"destination": "/downloads/file.bin",
+		"checksum":    "sha256:" + hex.EncodeToString(sum[:]),
+	}}
+
+	// the test server listens on the loopback, which isn't allowed by default
+	_, err := fetchJob(context.Background(), newData(settings.Fetch{}), job, &jobs.Tracker{})
+	require.ErrorIs(t, err, fberrors.ErrPermissionDenied)
+
+	d := newData(settings.Fetch{AllowedHosts: []string{"127.0.0.1"}})
+	tracker := &jobs.Tracker{}
+	result, err := fetchJob(context.Background(), d, job, tracker)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), result.(*fetchResult).Size)
+	require.Equal(t, int32(2), requests.Load())
+	require.Equal(t, "bytes=524288-", resumed.Load())
+	require.Equal(t, int64(len(content)), tracker.Progress().Bytes)
+
+	got, err := afero.ReadFile(afs, "/downloads/file.bin")
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+	exists, err := afero.Exists(afs, "/downloads/.file.bin.fetch")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	// an existing file is only replaced when asked to
+	_, err = fetchJob(context.Background(), d, job, &jobs.Tracker{})
+	require.ErrorIs(t, err, fberrors.ErrExist)
+
+	job.Params["destination"] = "/downloads/other.bin"
+	job.Params["checksum"] = "sha256:" + hex.EncodeToString(make([]byte, 32))
+	_, err = fetchJob(context.Background(), d, job, &jobs.Tracker{})
+	require.ErrorContains(t, err, "checksum mismatch")
+	exists, err = afero.Exists(afs, "/downloads/other.bin")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
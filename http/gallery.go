@@ -0,0 +1,289 @@
+package fbhttp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/img"
+)
+
+// defaultDuplicateThreshold is the maximum Hamming distance between two
+// perceptual hashes for the images to be considered duplicates.
+const defaultDuplicateThreshold = 5
+
+type galleryItem struct {
+	Path  string                `json:"path"`
+	Name  string                `json:"name"`
+	Size  int64                 `json:"size"`
+	Taken time.Time             `json:"taken"`
+	GPS   *files.GPSCoordinates `json:"gps,omitempty"`
+}
+
+type galleryGroup struct {
+	Date  string         `json:"date"`
+	Items []*galleryItem `json:"items"`
+}
+
+// galleryHandler returns the images under a directory tree.
+// GET /api/gallery/{path}?view=...
+// view: "timeline" (default) groups the images by capture date, "map" returns
+// the images with GPS coordinates and "duplicates" returns sets of images
+// that look alike.
+func galleryHandler(imgSvc ImgService, fileCache FileCache) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		root, err := files.NewFileInfo(&files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       r.URL.Path,
+			Modify:     d.user.Perm.Modify,
+			Expand:     false,
+			ReadHeader: false,
+			Checker:    d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if !root.IsDir {
+			return http.StatusBadRequest, nil
+		}
+
+		images, err := collectImages(r.Context(), d.user.Fs, root.Path, d)
+		if err != nil {
+			if errorsIsCanceled(err) {
+				return 0, err
+			}
+			return errToStatus(err), err
+		}
+
+		switch r.URL.Query().Get("view") {
+		case "", "timeline":
+			return renderJSON(w, r, galleryTimeline(images, r.URL.Query().Get("group")))
+		case "map":
+			return renderJSON(w, r, galleryLocations(images))
+		case "duplicates":
+			threshold := defaultDuplicateThreshold
+			if t := r.URL.Query().Get("threshold"); t != "" {
+				threshold, err = strconv.Atoi(t)
+				if err != nil || threshold < 0 || threshold > 64 {
+					return http.StatusBadRequest, fmt.Errorf("invalid threshold %q", t)
+				}
+			}
+
+			duplicates, err := galleryDuplicates(r.Context(), imgSvc, fileCache, images, threshold)
+			if err != nil {
+				if errorsIsCanceled(err) {
+					return 0, err
+				}
+				return http.StatusInternalServerError, err
+			}
+			return renderJSON(w, r, duplicates)
+		default:
+			return http.StatusBadRequest, fmt.Errorf("unknown view %q", r.URL.Query().Get("view"))
+		}
+	})
+}
+
+func collectImages(ctx context.Context, afs afero.Fs, root string, checker interface{ Check(string) bool }) ([]*files.FileInfo, error) {
+	var images []*files.FileInfo
+
+	err := afero.Walk(afs, root, func(fPath string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+
+		fPath = path.Join("/", filepath.ToSlash(fPath))
+		if !checker.Check(fPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		file := &files.FileInfo{
+			Fs:        afs,
+			Path:      fPath,
+			Name:      info.Name(),
+			Size:      info.Size(),
+			ModTime:   info.ModTime(),
+			Mode:      info.Mode(),
+			IsDir:     info.IsDir(),
+			Extension: filepath.Ext(info.Name()),
+		}
+		if file.IsImage() {
+			images = append(images, file)
+		}
+		return nil
+	})
+
+	return images, err
+}
+
+func newGalleryItem(file *files.FileInfo) *galleryItem {
+	item := &galleryItem{
+		Path:  file.Path,
+		Name:  file.Name,
+		Size:  file.Size,
+		Taken: file.ModTime,
+	}
+
+	meta, err := file.ImageMetadata()
+	if err != nil {
+		log.Printf("Error reading metadata of %s: %v", file.Path, err)
+		return item
+	}
+
+	if meta.DateTaken != nil {
+		item.Taken = *meta.DateTaken
+	}
+	item.GPS = meta.GPS
+	return item
+}
+
+func galleryTimeline(images []*files.FileInfo, group string) []*galleryGroup {
+	layout := "2006-01-02"
+	switch group {
+	case "month":
+		layout = "2006-01"
+	case "year":
+		layout = "2006"
+	}
+
+	items := make([]*galleryItem, 0, len(images))
+	for _, file := range images {
+		items = append(items, newGalleryItem(file))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Taken.After(items[j].Taken)
+	})
+
+	groups := []*galleryGroup{}
+	for _, item := range items {
+		date := item.Taken.Format(layout)
+		if len(groups) == 0 || groups[len(groups)-1].Date != date {
+			groups = append(groups, &galleryGroup{Date: date})
+		}
+		last := groups[len(groups)-1]
+		last.Items = append(last.Items, item)
+	}
+
+	return groups
+}
+
+func galleryLocations(images []*files.FileInfo) []*galleryItem {
+	locations := []*galleryItem{}
+	for _, file := range images {
+		if item := newGalleryItem(file); item.GPS != nil {
+			locations = append(locations, item)
+		}
+	}
+	return locations
+}
+
+func galleryDuplicates(ctx context.Context, imgSvc ImgService, fileCache FileCache,
+	images []*files.FileInfo, threshold int) ([][]*galleryItem, error) {
+	var hashed []*files.FileInfo
+	var hashes []uint64
+
+	for _, file := range images {
+		hash, err := perceptualHash(ctx, imgSvc, fileCache, file)
+		if err != nil {
+			if errorsIsCanceled(err) {
+				return nil, err
+			}
+			log.Printf("Error hashing image %s: %v", file.Path, err)
+			continue
+		}
+		hashed = append(hashed, file)
+		hashes = append(hashes, hash)
+	}
+
+	// union-find over the images whose hashes are close enough
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if img.HashDistance(hashes[i], hashes[j]) <= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	sets := map[int][]*galleryItem{}
+	var order []int
+	for i, file := range hashed {
+		root := find(i)
+		if _, ok := sets[root]; !ok {
+			order = append(order, root)
+		}
+		sets[root] = append(sets[root], &galleryItem{
+			Path:  file.Path,
+			Name:  file.Name,
+			Size:  file.Size,
+			Taken: file.ModTime,
+		})
+	}
+
+	duplicates := [][]*galleryItem{}
+	for _, root := range order {
+		if len(sets[root]) > 1 {
+			duplicates = append(duplicates, sets[root])
+		}
+	}
+
+	return duplicates, nil
+}
+
+func perceptualHash(ctx context.Context, imgSvc ImgService, fileCache FileCache, file *files.FileInfo) (uint64, error) {
+	cacheKey := fmt.Sprintf("phash:%x%x", file.RealPath(), file.ModTime.Unix())
+	cached, ok, err := fileCache.Load(ctx, cacheKey)
+	if err != nil {
+		return 0, err
+	}
+	if ok && len(cached) == 8 {
+		return binary.BigEndian.Uint64(cached), nil
+	}
+
+	fd, err := file.Fs.Open(file.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	hash, err := imgSvc.PerceptualHash(ctx, fd)
+	if err != nil {
+		return 0, err
+	}
+
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, hash)
+	if err := fileCache.Store(ctx, cacheKey, value); err != nil {
+		log.Printf("failed to cache perceptual hash: %v", err)
+	}
+
+	return hash, nil
+}
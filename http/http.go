@@ -1,11 +1,15 @@
 package fbhttp
 
 import (
+	"context"
 	"io/fs"
+	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/jobs"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
 )
@@ -15,8 +19,13 @@ type modifyRequest struct {
 	Which []string `json:"which"` // Answer to: which fields?
 }
 
+// NewHandler returns the handler of the API and the frontend. Its
+// background tasks run until the context is done.
 func NewHandler(
+	ctx context.Context,
 	imgSvc ImgService,
+	transcoder Transcoder,
+	jobManager *jobs.Manager,
 	fileCache FileCache,
 	store *storage.Storage,
 	server *settings.Server,
@@ -24,6 +33,20 @@ func NewHandler(
 ) (http.Handler, error) {
 	server.Clean()
 
+	bus := events.NewBus()
+	watcher, err := events.NewWatcher(bus)
+	if err != nil {
+		log.Printf("WARNING: can't watch the file system for changes: %v", err)
+	}
+	usage := newUsageCache()
+	registerJobs(jobManager, fileCache, usage, store, server, bus)
+	bus.Listen(recordActivity(store))
+	bus.Listen(usage.invalidate)
+	go runSchedules(ctx, store, server, bus)
+	webhookDispatcher := newWebhookDispatcher(store, server)
+	bus.Listen(webhookDispatcher.queue)
+	go webhookDispatcher.run(ctx)
+
 	r := mux.NewRouter()
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +62,7 @@ func NewHandler(
 	r = r.SkipClean(true)
 
 	monkey := func(fn handleFunc, prefix string) http.Handler {
-		return handle(fn, prefix, store, server)
+		return handle(fn, prefix, store, server, bus)
 	}
 
 	r.HandleFunc("/health", healthHandler)
@@ -64,31 +87,68 @@ func NewHandler(
 	api.PathPrefix("/resources").Handler(monkey(resourceDeleteHandler(fileCache), "/api/resources")).Methods("DELETE")
 	api.PathPrefix("/resources").Handler(monkey(resourcePostHandler(fileCache), "/api/resources")).Methods("POST")
 	api.PathPrefix("/resources").Handler(monkey(resourcePutHandler, "/api/resources")).Methods("PUT")
-	api.PathPrefix("/resources").Handler(monkey(resourcePatchHandler(fileCache), "/api/resources")).Methods("PATCH")
+	api.PathPrefix("/resources").Handler(monkey(resourcePatchHandler(fileCache, jobManager), "/api/resources")).Methods("PATCH")
+
+	api.PathPrefix("/locks").Handler(monkey(lockGetHandler, "/api/locks")).Methods("GET")
+	api.PathPrefix("/locks").Handler(monkey(lockPostHandler, "/api/locks")).Methods("POST")
+	api.PathPrefix("/locks").Handler(monkey(lockDeleteHandler, "/api/locks")).Methods("DELETE")
+	api.PathPrefix("/tags").Handler(monkey(tagsGetHandler, "/api/tags")).Methods("GET")
+	api.PathPrefix("/tags").Handler(monkey(tagsPutHandler, "/api/tags")).Methods("PUT")
+	api.PathPrefix("/comments").Handler(monkey(commentsGetHandler, "/api/comments")).Methods("GET")
+	api.PathPrefix("/comments").Handler(monkey(commentPostHandler, "/api/comments")).Methods("POST")
+	api.PathPrefix("/comments").Handler(monkey(commentPutHandler, "/api/comments")).Methods("PUT")
+	api.PathPrefix("/comments").Handler(monkey(commentDeleteHandler, "/api/comments")).Methods("DELETE")
+	api.PathPrefix("/activity").Handler(monkey(activityHandler, "/api/activity")).Methods("GET")
+	api.PathPrefix("/merge").Handler(monkey(mergeHandler, "/api/merge")).Methods("POST")
+	api.PathPrefix("/duplicates").Handler(monkey(duplicatesHandler(jobManager), "/api/duplicates")).Methods("POST")
+	api.Handle("/dedupe", monkey(dedupeHandler(fileCache, jobManager), "")).Methods("POST")
+	api.PathPrefix("/fetch").Handler(monkey(fetchHandler(jobManager), "/api/fetch")).Methods("POST")
+	api.Handle("/batch", monkey(batchHandler(fileCache, jobManager), "")).Methods("POST")
 
 	api.PathPrefix("/tus").Handler(monkey(tusPostHandler(), "/api/tus")).Methods("POST")
 	api.PathPrefix("/tus").Handler(monkey(tusHeadHandler(), "/api/tus")).Methods("HEAD", "GET")
 	api.PathPrefix("/tus").Handler(monkey(tusPatchHandler(), "/api/tus")).Methods("PATCH")
 	api.PathPrefix("/tus").Handler(monkey(tusDeleteHandler(), "/api/tus")).Methods("DELETE")
+	api.PathPrefix("/tus").Handler(monkey(tusOptionsHandler, "/api/tus")).Methods("OPTIONS")
 
+	api.Handle("/events", monkey(eventsHandler(bus, watcher), "")).Methods("GET")
+
+	api.PathPrefix("/usagetree").Handler(monkey(usageTreeHandler(usage, jobManager), "/api/usagetree")).Methods("GET")
 	api.PathPrefix("/usage").Handler(monkey(diskUsage, "/api/usage")).Methods("GET")
-	api.PathPrefix("/dirsize").Handler(monkey(dirSizeHandler, "/api/dirsize")).Methods("GET")
+	api.PathPrefix("/delta").Handler(monkey(deltaHandler, "/api/delta")).Methods("GET")
+	api.PathPrefix("/dirsize").Handler(monkey(dirSizeHandler(jobManager), "/api/dirsize")).Methods("GET")
 
 	api.Path("/shares").Handler(monkey(shareListHandler, "/api/shares")).Methods("GET")
 	api.PathPrefix("/share").Handler(monkey(shareGetsHandler, "/api/share")).Methods("GET")
 	api.PathPrefix("/share").Handler(monkey(sharePostHandler, "/api/share")).Methods("POST")
 	api.PathPrefix("/share").Handler(monkey(shareDeleteHandler, "/api/share")).Methods("DELETE")
 
+	jobsRouter := api.PathPrefix("/jobs").Subrouter()
+	jobsRouter.Handle("", monkey(jobsListHandler(jobManager), "")).Methods("GET")
+	jobsRouter.Handle("/{id}", monkey(jobGetHandler(jobManager), "")).Methods("GET")
+	jobsRouter.Handle("/{id}", monkey(jobDeleteHandler(jobManager), "")).Methods("DELETE")
+	jobsRouter.Handle("/{id}/watch", monkey(jobWatchHandler(jobManager), "")).Methods("GET")
+	jobsRouter.Handle("/{id}/cancel", monkey(jobCancelHandler(jobManager), "")).Methods("POST")
+	jobsRouter.Handle("/{id}/retry", monkey(jobRetryHandler(jobManager), "")).Methods("POST")
+
 	api.Handle("/settings", monkey(settingsGetHandler, "")).Methods("GET")
 	api.Handle("/settings", monkey(settingsPutHandler, "")).Methods("PUT")
+	api.PathPrefix("/schedules").Handler(monkey(schedulesHandler, "/api/schedules")).Methods("GET")
+	api.PathPrefix("/processes").Handler(monkey(processesHandler, "/api/processes")).Methods("GET", "DELETE")
+	api.PathPrefix("/webhooks").Handler(monkey(webhooksHandler(webhookDispatcher), "/api/webhooks")).Methods("GET", "POST")
 
-	api.PathPrefix("/raw").Handler(monkey(rawHandler, "/api/raw")).Methods("GET")
+	api.PathPrefix("/raw").Handler(monkey(rawHandler(jobManager), "/api/raw")).Methods("GET")
 	api.PathPrefix("/preview/{size}/{path:.*}").
 		Handler(monkey(previewHandler(imgSvc, fileCache, server.EnableThumbnails, server.ResizePreview), "/api/preview")).Methods("GET")
 	api.PathPrefix("/command").Handler(monkey(commandsHandler, "/api/command")).Methods("GET")
 	api.PathPrefix("/search").Handler(monkey(searchHandler, "/api/search")).Methods("GET")
-	api.PathPrefix("/subtitle").Handler(monkey(subtitleHandler, "/api/subtitle")).Methods("GET")
-	api.PathPrefix("/extract").Handler(monkey(extractHandler, "/api/extract")).Methods("POST")
+	api.PathPrefix("/hls").Handler(monkey(hlsHandler(transcoder, fileCache), "/api/hls")).Methods("GET")
+	api.PathPrefix("/subtitle").Handler(monkey(subtitleHandler(fileCache), "/api/subtitle")).Methods("GET")
+	api.PathPrefix("/manifest").Handler(monkey(manifestHandler(jobManager), "/api/manifest")).Methods("POST")
+	api.PathPrefix("/verify").Handler(monkey(verifyHandler(jobManager), "/api/verify")).Methods("POST")
+	api.PathPrefix("/extract").Handler(monkey(extractHandler(jobManager), "/api/extract")).Methods("POST")
+	api.PathPrefix("/metadata").Handler(monkey(metadataHandler, "/api/metadata")).Methods("GET")
+	api.PathPrefix("/gallery").Handler(monkey(galleryHandler(imgSvc, fileCache), "/api/gallery")).Methods("GET")
 
 	public := api.PathPrefix("/public").Subrouter()
 	public.PathPrefix("/dl").Handler(monkey(publicDlHandler, "/api/public/dl/")).Methods("GET")
@@ -0,0 +1,360 @@
+package fbhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+)
+
+// jobFunc runs a job on behalf of the user who submitted it.
+type jobFunc func(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error)
+
+func registerJobs(jobManager *jobs.Manager, fileCache FileCache, usage *usageCache, store *storage.Storage, server *settings.Server, bus *events.Bus) {
+	jobManager.Register("copy", withJobData(patchJob(fileCache), store, server, bus))
+	jobManager.Register("rename", withJobData(patchJob(fileCache), store, server, bus))
+	jobManager.Register("extract", withJobData(extractJob, store, server, bus))
+	jobManager.Register("archive", withJobData(archiveJob, store, server, bus))
+	jobManager.Register("dirsize", withJobData(dirSizeJob, store, server, bus))
+	jobManager.Register("usagetree", withJobData(usageTreeJob(usage), store, server, bus))
+	jobManager.Register("compress", withJobData(compressJob, store, server, bus))
+	jobManager.Register("manifest", withJobData(manifestJob, store, server, bus))
+	jobManager.Register("verify", withJobData(verifyJob, store, server, bus))
+	jobManager.Register("duplicates", withJobData(duplicatesJob, store, server, bus))
+	jobManager.Register("dedupe", withJobData(dedupeJob(fileCache), store, server, bus))
+	jobManager.Register("fetch", withJobData(fetchJob, store, server, bus))
+	jobManager.Register("batch", withJobData(batchJob(fileCache), store, server, bus))
+	jobManager.OnUpdate(bus.PublishJob)
+}
+
+// withJobData builds the request data of the job's user, loading the
+// user and the settings again since they may have changed since the job
+// was submitted.
+func withJobData(fn jobFunc, store *storage.Storage, server *settings.Server, bus *events.Bus) jobs.Func {
+	return func(ctx context.Context, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+		settings, err := store.Settings.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		user, err := store.Users.Get(server.Root, job.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(ctx, &data{
+			Runner:   &runner.Runner{Enabled: server.EnableExec, Settings: settings, Events: bus},
+			store:    store,
+			settings: settings,
+			server:   server,
+			user:     user,
+		}, job, tracker)
+	}
+}
+
+// trackedData returns a copy of the data whose user file system reports
+// the writes to the tracker. The hooks must still get the original user.
+func trackedData(ctx context.Context, d *data, tracker *jobs.Tracker) *data {
+	user := *d.user
+	user.Fs = jobs.TrackFs(ctx, d.user.Fs, tracker)
+
+	tracked := *d
+	tracked.user = &user
+	return &tracked
+}
+
+func patchJob(fileCache FileCache) jobFunc {
+	return func(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+		src, dst := job.Params["src"], job.Params["dst"]
+		if !d.Check(src) || !d.Check(dst) {
+			return nil, fberrors.ErrPermissionDenied
+		}
+
+		// the destination may have been created since the job was submitted
+		override := job.Params["override"] == "true"
+		dst, err := patchDestination(d, dst, override, job.Params["rename"] == "true")
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := d.user.Fs.Stat(src)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			size, numFiles, _, err := computeDirSize(ctx, d.user.Fs, src, d)
+			if err != nil {
+				return nil, err
+			}
+			tracker.SetTotal(size, numFiles)
+		} else {
+			tracker.SetTotal(info.Size(), 1)
+		}
+
+		tracked := trackedData(ctx, d, tracker)
+		return nil, d.RunHookDst(func(target string) error {
+			if target != dst {
+				if err := checkHookDestination(d, src, target, override); err != nil {
+					return err
+				}
+			}
+			return patchAction(ctx, job.Type, src, target, tracked, fileCache)
+		}, job.Type, src, dst, d.user)
+	}
+}
+
+func extractJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	if !d.user.Perm.Create {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	destination := job.Params["destination"]
+	if !d.Check(destination) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       job.Params["path"],
+		Modify:     d.user.Perm.Modify,
+		Expand:     false,
+		ReadHeader: false,
+		Checker:    d,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	if selection := job.Params["entries"]; selection != "" {
+		if err := json.Unmarshal([]byte(selection), &entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return extractArchive(ctx, trackedData(ctx, d, tracker), file, destination, job.Params["conflict"], entries)
+}
+
+func archiveJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	if !d.user.Perm.Download || !d.user.Perm.Create {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       job.Params["path"],
+		Modify:     d.user.Perm.Modify,
+		Expand:     false,
+		ReadHeader: false,
+		Checker:    d,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name, archiver, allFiles, err := prepareArchive(d, file, job.Params["files"], job.Params["algo"], false)
+	if err != nil {
+		return nil, err
+	}
+
+	var totalBytes, totalFiles int64
+	for i := range allFiles {
+		if allFiles[i].IsDir() {
+			continue
+		}
+		totalBytes += allFiles[i].Size()
+		totalFiles++
+
+		open := allFiles[i].Open
+		allFiles[i].Open = func() (fs.File, error) {
+			fd, err := open()
+			if err != nil {
+				return nil, err
+			}
+			return jobs.TrackReads(fd, tracker), nil
+		}
+	}
+	tracker.SetTotal(totalBytes, totalFiles)
+
+	dst := job.Params["destination"]
+	if dst == "" {
+		dst = fileutils.AddVersionSuffix(path.Join(file.Path, name), d.user.Fs)
+	}
+	if !d.Check(dst) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	if err := writeArchive(ctx, d, dst, archiver, allFiles); err != nil {
+		return nil, err
+	}
+
+	return archiveResult{Path: dst}, nil
+}
+
+func dirSizeJob(ctx context.Context, d *data, job *jobs.Job, tracker *jobs.Tracker) (interface{}, error) {
+	size, numFiles, numDirs, err := computeDirSize(ctx, d.user.Fs, job.Params["path"], d)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker.AddBytes(size)
+	tracker.AddFiles(numFiles)
+	return dirSizeResponse{
+		Size:     size,
+		NumFiles: numFiles,
+		NumDirs:  numDirs,
+	}, nil
+}
+
+// submitJob queues a job for the user and replies with it.
+func submitJob(w http.ResponseWriter, r *http.Request, d *data, jobManager *jobs.Manager, typ string, params map[string]string) (int, error) {
+	job, err := jobManager.Submit(d.user.ID, typ, params)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	return renderJSON(w, r, job)
+}
+
+// getJob returns the job from the request, if it belongs to the user.
+func getJob(r *http.Request, d *data, jobManager *jobs.Manager) (*jobs.Job, error) {
+	job, err := jobManager.Get(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	if job.UserID != d.user.ID {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return job, nil
+}
+
+func jobsListHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		list, err := jobManager.List(d.user.ID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return renderJSON(w, r, list)
+	})
+}
+
+func jobGetHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := getJob(r, d, jobManager)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		return renderJSON(w, r, job)
+	})
+}
+
+// jobWatchHandler streams the job as server-sent events until it finishes.
+func jobWatchHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := getJob(r, d, jobManager)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		updates, stop, err := jobManager.Watch(job.ID)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		defer stop()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		flusher, _ := w.(http.Flusher)
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return 0, nil
+			case update, ok := <-updates:
+				if !ok {
+					return 0, nil
+				}
+
+				body, err := json.Marshal(update)
+				if err != nil {
+					return 0, err
+				}
+				if _, err := fmt.Fprintf(w, "event: job\ndata: %s\n\n", body); err != nil {
+					return 0, err
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+		}
+	})
+}
+
+func jobCancelHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := getJob(r, d, jobManager)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		err = jobManager.Cancel(job.ID)
+		return errToStatus(err), err
+	})
+}
+
+func jobRetryHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := getJob(r, d, jobManager)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		job, err = jobManager.Retry(job.ID)
+		if errors.Is(err, jobs.ErrNotRetryable) {
+			return http.StatusConflict, err
+		} else if err != nil {
+			return errToStatus(err), err
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		return renderJSON(w, r, job)
+	})
+}
+
+func jobDeleteHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		job, err := getJob(r, d, jobManager)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if !job.Done() {
+			return http.StatusConflict, nil
+		}
+
+		err = jobManager.Delete(job.ID)
+		return errToStatus(err), err
+	})
+}
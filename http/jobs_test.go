@@ -0,0 +1,113 @@
+package fbhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/runner"
+)
+
+func TestAsyncPatchDestination(t *testing.T) {
+	ts := newTestServer(t)
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "a.txt"), []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "b.txt"), []byte("old"), 0644))
+
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(ts.root, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+	copyAsync := func(options string) *jobs.Job {
+		resp := ts.do(t, http.MethodPatch, "/api/resources/a.txt?action=copy&destination=%2Fb.txt&async=true"+options, nil)
+		if resp.StatusCode != http.StatusAccepted {
+			require.Equal(t, http.StatusConflict, resp.StatusCode)
+			return nil
+		}
+
+		job := &jobs.Job{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(job))
+		require.Eventually(t, func() bool {
+			resp := ts.do(t, http.MethodGet, "/api/jobs/"+job.ID, nil)
+			return json.NewDecoder(resp.Body).Decode(job) == nil && job.Done()
+		}, 5*time.Second, 10*time.Millisecond)
+		return job
+	}
+
+	// an existing destination is kept, as with the synchronous copy
+	require.Nil(t, copyAsync(""))
+
+	job := copyAsync("&rename=true")
+	require.Equal(t, jobs.StatusDone, job.Status)
+	require.Equal(t, "old", read("b.txt"))
+	require.Equal(t, "new", read("b(1).txt"))
+
+	job = copyAsync("&override=true")
+	require.Equal(t, jobs.StatusDone, job.Status)
+	require.Equal(t, "new", read("b.txt"))
+
+	// the destination created after the job was submitted
+	set, err := ts.store.Settings.Get()
+	require.NoError(t, err)
+	server, err := ts.store.Settings.GetServer()
+	require.NoError(t, err)
+	user, err := ts.store.Users.Get(server.Root, "admin")
+	require.NoError(t, err)
+	d := &data{Runner: &runner.Runner{Settings: set}, store: ts.store, settings: set, server: server, user: user}
+
+	job = &jobs.Job{Type: "copy", Params: map[string]string{"src": "/a.txt", "dst": "/b.txt", "override": "false", "rename": "false"}}
+	_, err = patchJob(nil)(context.Background(), d, job, &jobs.Tracker{})
+	require.ErrorIs(t, err, fberrors.ErrExist)
+
+	user.Perm.Modify = false
+	job.Params["override"] = "true"
+	_, err = patchJob(nil)(context.Background(), d, job, &jobs.Tracker{})
+	require.ErrorIs(t, err, fberrors.ErrPermissionDenied)
+}
+
+func TestCompressJobDestination(t *testing.T) {
+	ts := newTestServer(t)
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "b.txt"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "a.zip"), []byte("old"), 0644))
+
+	set, err := ts.store.Settings.Get()
+	require.NoError(t, err)
+	server, err := ts.store.Settings.GetServer()
+	require.NoError(t, err)
+	user, err := ts.store.Users.Get(server.Root, "admin")
+	require.NoError(t, err)
+	d := &data{Runner: &runner.Runner{Settings: set}, store: ts.store, settings: set, server: server, user: user}
+
+	job := &jobs.Job{Type: "compress", Params: map[string]string{
+		"path": "/", "files": "a.txt,b.txt", "algo": "zip", "destination": "/a.zip", "override": "false", "rename": "false",
+	}}
+	_, err = compressJob(context.Background(), d, job, &jobs.Tracker{})
+	require.ErrorIs(t, err, fberrors.ErrExist)
+
+	job.Params["rename"] = "true"
+	result, err := compressJob(context.Background(), d, job, &jobs.Tracker{})
+	require.NoError(t, err)
+	require.Equal(t, archiveResult{Path: "/a(1).zip"}, result)
+	require.FileExists(t, filepath.Join(ts.root, "a(1).zip"))
+
+	job.Params["rename"] = "false"
+	job.Params["override"] = "true"
+	_, err = compressJob(context.Background(), d, job, &jobs.Tracker{})
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(ts.root, "a.zip"))
+	require.NoError(t, err)
+	require.NotEqual(t, "old", string(b))
+
+	// a conflict is reported before the job is submitted
+	resp := ts.do(t, http.MethodPatch, "/api/resources/?action=compress&files=a.txt,b.txt&destination=%2Fa.zip&async=true", nil)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
@@ -0,0 +1,166 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/locks"
+)
+
+type lockRequest struct {
+	Timeout  int64  `json:"timeout"` // in seconds, the lock never expires if zero
+	Note     string `json:"note"`
+	Enforced bool   `json:"enforced"`
+}
+
+// checkLock fails with fberrors.ErrLocked if another user holds an enforced
+// lock on the path, or on something in it when recursive.
+func checkLock(d *data, p string, recursive bool) error {
+	lock, err := d.store.Locks.Blocking(realPath(d.user.Fs, p), d.user.ID, recursive)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		return fmt.Errorf("%w by %s", fberrors.ErrLocked, lock.Username)
+	}
+	return nil
+}
+
+// userLock returns the lock as seen by the user, with the path relative to
+// their scope, and whether they can see it at all.
+func userLock(d *data, lock *locks.Lock) (*locks.Lock, bool) {
+	p, ok := scopedPath(lock.Path, realPath(d.user.Fs, "/"), d)
+	if !ok {
+		return nil, false
+	}
+
+	scoped := *lock
+	scoped.Path = p
+	return &scoped, true
+}
+
+// annotateLocks sets the locks of the file and of the files it lists.
+func annotateLocks(d *data, file *files.FileInfo) error {
+	active, err := d.store.Locks.Active()
+	if err != nil || len(active) == 0 {
+		return err
+	}
+
+	byPath := make(map[string]*locks.Lock, len(active))
+	for _, lock := range active {
+		byPath[lock.Path] = lock
+	}
+
+	annotate := func(f *files.FileInfo) {
+		if lock, ok := byPath[realPath(d.user.Fs, f.Path)]; ok {
+			f.Lock, _ = userLock(d, lock)
+		}
+	}
+
+	annotate(file)
+	if file.Listing != nil {
+		for _, item := range file.Items {
+			annotate(item)
+		}
+	}
+
+	return nil
+}
+
+// lockGetHandler lists the locks of the path and of everything in it.
+// GET /api/locks/{path}
+var lockGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	active, err := d.store.Locks.Active()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	dir := path.Clean("/" + r.URL.Path)
+	list := []*locks.Lock{}
+	for _, lock := range active {
+		scoped, ok := userLock(d, lock)
+		if !ok || (scoped.Path != dir && !strings.HasPrefix(scoped.Path, strings.TrimSuffix(dir, "/")+"/")) {
+			continue
+		}
+		list = append(list, scoped)
+	}
+
+	return renderJSON(w, r, list)
+})
+
+// lockPostHandler locks the path for the user, or renews their lock.
+// POST /api/locks/{path}
+var lockPostHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Modify {
+		return http.StatusForbidden, nil
+	}
+
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	var req lockRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	if req.Timeout < 0 {
+		return http.StatusBadRequest, errors.New("invalid timeout")
+	}
+
+	lock := &locks.Lock{
+		Path:     realPath(d.user.Fs, p),
+		UserID:   d.user.ID,
+		Username: d.user.Username,
+		Note:     req.Note,
+		Enforced: req.Enforced,
+		Created:  time.Now(),
+	}
+	if req.Timeout > 0 {
+		lock.Expires = lock.Created.Add(time.Duration(req.Timeout) * time.Second)
+	}
+
+	if err := d.store.Locks.Acquire(lock); err != nil {
+		return errToStatus(err), err
+	}
+
+	scoped, _ := userLock(d, lock)
+	return renderJSON(w, r, scoped)
+})
+
+// lockDeleteHandler releases the lock of the path. The admins can break
+// the locks of the other users.
+// DELETE /api/locks/{path}
+var lockDeleteHandler = withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+
+	lock, err := d.store.Locks.Get(realPath(d.user.Fs, p))
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if lock.UserID != d.user.ID && !d.user.Perm.Admin {
+		return http.StatusForbidden, nil
+	}
+
+	if err := d.store.Locks.Release(lock.Path); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+})
@@ -0,0 +1,57 @@
+package fbhttp
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/locks"
+	"github.com/filebrowser/filebrowser/v2/share"
+)
+
+func TestDeleteLocked(t *testing.T) {
+	ts := newTestServer(t)
+	target := filepath.Join(ts.root, "a.txt")
+	require.NoError(t, os.WriteFile(target, []byte("a"), 0644))
+	require.NoError(t, ts.store.Share.Save(&share.Link{Hash: "h", Path: "/a.txt", UserID: 1}))
+	require.NoError(t, ts.store.Locks.Acquire(&locks.Lock{Path: target, UserID: 99, Username: "bob", Enforced: true, Created: time.Now()}))
+
+	resp := ts.do(t, http.MethodDelete, "/api/resources/a.txt", nil)
+	require.Equal(t, http.StatusLocked, resp.StatusCode)
+	require.FileExists(t, target)
+	_, err := ts.store.Share.GetByHash("h")
+	require.NoError(t, err)
+
+	require.NoError(t, ts.store.Locks.Release(target))
+	resp = ts.do(t, http.MethodDelete, "/api/resources/a.txt", nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NoFileExists(t, target)
+	_, err = ts.store.Share.GetByHash("h")
+	require.Error(t, err)
+}
+
+func TestTusPatchLocked(t *testing.T) {
+	ts := newTestServer(t)
+	target := filepath.Join(ts.root, "a.txt")
+
+	resp := ts.do(t, http.MethodPost, "/api/tus/a.txt", nil, "Upload-Length", "2")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, ts.store.Locks.Acquire(&locks.Lock{Path: target, UserID: 99, Username: "bob", Enforced: true, Created: time.Now()}))
+
+	patch := func() *http.Response {
+		return ts.do(t, http.MethodPatch, "/api/tus/a.txt", strings.NewReader("hi"),
+			"Content-Type", "application/offset+octet-stream", "Upload-Offset", "0")
+	}
+	require.Equal(t, http.StatusLocked, patch().StatusCode)
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+
+	require.NoError(t, ts.store.Locks.Release(target))
+	require.Equal(t, http.StatusNoContent, patch().StatusCode)
+}
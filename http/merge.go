@@ -0,0 +1,84 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+)
+
+// maxMergeSize is the size of the largest text files that can be merged,
+// as for the files opened in the editor.
+const maxMergeSize = 10 * 1024 * 1024
+
+type mergeRequest struct {
+	Base    string `json:"base"`    // the content the changes were made on
+	Content string `json:"content"` // the changed content
+}
+
+type mergeResponse struct {
+	Content   string       `json:"content"`
+	Conflicts int          `json:"conflicts"`
+	Version   *fileVersion `json:"version"`
+}
+
+// mergeHandler merges the changes made to a text file with the changes
+// saved since, for the editor to save the result with the returned version
+// in If-Match.
+// POST /api/merge/{path}
+var mergeHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Modify {
+		return http.StatusForbidden, nil
+	}
+
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       r.URL.Path,
+		Modify:     d.user.Perm.Modify,
+		Expand:     false,
+		ReadHeader: false,
+		Checker:    d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if file.IsDir {
+		return http.StatusBadRequest, errors.New("cannot merge a directory")
+	}
+
+	var req mergeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 3*maxMergeSize)).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	info := statVersion(d.user.Fs, r.URL.Path)
+	if info == nil {
+		return http.StatusNotFound, nil
+	}
+	if info.Size() > maxMergeSize {
+		return http.StatusRequestEntityTooLarge, errors.New("file too large to merge")
+	}
+
+	current, err := afero.ReadFile(d.user.Fs, r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if !utf8.Valid(current) {
+		return http.StatusUnprocessableEntity, errors.New("not a text file")
+	}
+
+	merged, conflicts := fileutils.Merge3(req.Base, req.Content, string(current))
+
+	version := newFileVersion(info)
+	w.Header().Set("ETag", version.ETag)
+	return renderJSON(w, r, &mergeResponse{
+		Content:   merged,
+		Conflicts: conflicts,
+		Version:   version,
+	})
+})
@@ -0,0 +1,144 @@
+package fbhttp
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/img"
+)
+
+// imageExtensions are the extensions of the images missing from the MIME
+// types, which can't be served when their GPS information can't be removed.
+var imageExtensions = map[string]bool{
+	".heic": true, ".heif": true, ".avif": true, ".jxl": true, ".dng": true,
+	".cr2": true, ".cr3": true, ".nef": true, ".arw": true, ".orf": true, ".rw2": true,
+}
+
+var metadataHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       r.URL.Path,
+		Modify:     d.user.Perm.Modify,
+		Expand:     true,
+		ReadHeader: d.server.TypeDetectionByHeader,
+		Checker:    d,
+		Content:    false,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if file.IsDir {
+		return http.StatusBadRequest, nil
+	}
+
+	meta, err := file.Metadata()
+	if errors.Is(err, fberrors.ErrInvalidOption) {
+		return http.StatusNotImplemented, nil
+	} else if err != nil {
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, meta)
+})
+
+// gpsStrippedFile is a file whose bytes holding GPS information are
+// replaced by the patches removing it.
+type gpsStrippedFile struct {
+	afero.File
+	patches []img.Patch
+	pos     int64
+}
+
+func (f *gpsStrippedFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.patch(p[:n], f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *gpsStrippedFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.patch(p[:n], off)
+	return n, err
+}
+
+func (f *gpsStrippedFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.File.Seek(offset, whence)
+	if err == nil {
+		f.pos = pos
+	}
+	return pos, err
+}
+
+// patch applies the patches to the bytes read at the given offset.
+func (f *gpsStrippedFile) patch(p []byte, off int64) {
+	for _, patch := range f.patches {
+		start := max(patch.Offset, off)
+		end := min(patch.Offset+int64(len(patch.Data)), off+int64(len(p)))
+		if start < end {
+			copy(p[start-off:end-off], patch.Data[start-patch.Offset:end-patch.Offset])
+		}
+	}
+}
+
+// openWithoutGPS opens a file and, if it's an image with GPS information,
+// hides it from the returned reader. The images whose GPS information can't
+// be removed aren't opened, failing with img.ErrGPSNotStripped.
+func openWithoutGPS(afs afero.Fs, name string) (afero.File, error) {
+	fd, err := afs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return fd, nil
+	}
+
+	patches, err := img.StripFileGPS(fd, info.Size())
+	if errors.Is(err, img.ErrUnknownFormat) && !isImageFile(name) {
+		err = nil
+	}
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if len(patches) == 0 {
+		return fd, nil
+	}
+	return &gpsStrippedFile{File: fd, patches: patches}, nil
+}
+
+func isImageFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return strings.HasPrefix(mime.TypeByExtension(ext), "image/") || imageExtensions[ext]
+}
+
+// openFile opens a file, removing the GPS information of images when
+// stripGPS is set. The images are recognized by their content, whatever
+// their name.
+func openFile(afs afero.Fs, name string, stripGPS bool) (afero.File, error) {
+	if stripGPS {
+		return openWithoutGPS(afs, name)
+	}
+	return afs.Open(name)
+}
@@ -0,0 +1,86 @@
+package fbhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestOpenFileWithoutGPS(t *testing.T) {
+	jpeg, err := os.ReadFile("../img/testdata/IMG_2578.JPG")
+	require.NoError(t, err)
+
+	// the EXIF segment is past the first 256 KB
+	far := append([]byte{}, jpeg[:2]...)
+	for range 5 {
+		far = append(far, 0xFF, 0xE2, 0xFF, 0xFF)
+		far = append(far, make([]byte, 0xFFFF-2)...)
+	}
+	far = append(far, jpeg[2:]...)
+
+	// the GPS properties of an XMP packet
+	packet := "http://ns.adobe.com/xap/1.0/\x00" +
+		`<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:GPSLatitude="51,30.4N"/>`
+	xmp := append([]byte{}, jpeg[:2]...)
+	xmp = append(xmp, 0xFF, 0xE1, 0, byte(2+len(packet)))
+	xmp = append(xmp, packet...)
+	xmp = append(xmp, jpeg[2:]...)
+
+	afs := afero.NewBasePathFs(afero.NewMemMapFs(), "/")
+	require.NoError(t, afero.WriteFile(afs, "/photos/a.jpg", jpeg, 0644))
+	require.NoError(t, afero.WriteFile(afs, "/photos/far.png", far, 0644))
+	require.NoError(t, afero.WriteFile(afs, "/photos/xmp.jpg", xmp, 0644))
+	require.NoError(t, afero.WriteFile(afs, "/photos/b.heic", []byte("unknown content"), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/photos/c.txt", []byte("text"), 0644))
+
+	for _, name := range []string{"/photos/a.jpg", "/photos/far.png", "/photos/xmp.jpg"} {
+		fd, err := openFile(afs, name, true)
+		require.NoError(t, err)
+		served, err := io.ReadAll(fd)
+		require.NoError(t, err)
+
+		// the same bytes are served by ReadAt
+		servedAt := make([]byte, len(served))
+		_, err = fd.ReadAt(servedAt, 0)
+		require.NoError(t, err)
+		require.NoError(t, fd.Close())
+		require.Equal(t, served, servedAt)
+
+		original, err := afero.ReadFile(afs, name)
+		require.NoError(t, err)
+		require.Len(t, served, len(original))
+		require.NotEqual(t, original, served)
+
+		patches, err := img.StripFileGPS(bytes.NewReader(served), int64(len(served)))
+		require.NoError(t, err)
+		require.Empty(t, patches, name)
+		require.NotContains(t, string(served), "51,30.4N")
+	}
+
+	// the images whose GPS information can't be removed aren't served
+	_, err = openFile(afs, "/photos/b.heic", true)
+	require.ErrorIs(t, err, img.ErrGPSNotStripped)
+	require.Equal(t, http.StatusUnsupportedMediaType, errToStatus(err))
+
+	fd, err := openFile(afs, "/photos/c.txt", true)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+
+	// nor archived
+	d := &data{settings: &settings.Settings{}, user: &users.User{Fs: afs}}
+	_, allFiles := collectArchiveFiles(d, []string{"/photos"}, true)
+	var names []string
+	for _, f := range allFiles {
+		names = append(names, f.NameInArchive)
+	}
+	require.ElementsMatch(t, []string{"a.jpg", "far.png", "xmp.jpg", "c.txt"}, names)
+}
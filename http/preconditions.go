@@ -0,0 +1,122 @@
+package fbhttp
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// fileVersion describes the current version of a file, for the clients to
+// detect the concurrent changes.
+type fileVersion struct {
+	ETag     string    `json:"etag"`
+	Modified time.Time `json:"modified"`
+	Size     int64     `json:"size"`
+}
+
+func newFileVersion(info fs.FileInfo) *fileVersion {
+	return &fileVersion{
+		ETag:     fileETag(info.ModTime(), info.Size()),
+		Modified: info.ModTime(),
+		Size:     info.Size(),
+	}
+}
+
+// fileETag returns the entity tag of the version of the file.
+func fileETag(modified time.Time, size int64) string {
+	return fmt.Sprintf(`"%x%x"`, modified.UnixNano(), size)
+}
+
+// statVersion returns the info of the file, nil if it doesn't exist.
+func statVersion(afs afero.Fs, p string) fs.FileInfo {
+	info, err := afs.Stat(p)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// checkPreconditions evaluates the If-Match and If-Unmodified-Since headers
+// of the request against the file, info being nil when it doesn't exist.
+func checkPreconditions(r *http.Request, info fs.FileInfo) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if info == nil {
+			return false
+		}
+
+		etag := fileETag(info.ModTime(), info.Size())
+		for _, tag := range strings.Split(ifMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			// weak tags never match
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := r.Header.Get("If-Unmodified-Since"); since != "" && info != nil {
+		t, err := http.ParseTime(since)
+		if err != nil {
+			return true
+		}
+		return !info.ModTime().Truncate(time.Second).After(t)
+	}
+
+	return true
+}
+
+// preconditionFailed responds with the current version of the file.
+func preconditionFailed(w http.ResponseWriter, r *http.Request, info fs.FileInfo) (int, error) {
+	if info == nil {
+		return http.StatusPreconditionFailed, nil
+	}
+
+	w.Header().Set("ETag", fileETag(info.ModTime(), info.Size()))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	return renderJSON(w, r, newFileVersion(info))
+}
+
+// writeLocks serializes the conditional writes of each file, for two clients
+// holding the same version not to both pass the preconditions and have the
+// first change silently overwritten by the second.
+var writeLocks = struct {
+	sync.Mutex
+	locks map[string]*writeLock
+}{locks: map[string]*writeLock{}}
+
+type writeLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockWrite locks the writes of the file at the given full path, returning
+// the function unlocking them.
+func lockWrite(p string) func() {
+	writeLocks.Lock()
+	lock, ok := writeLocks.locks[p]
+	if !ok {
+		lock = &writeLock{}
+		writeLocks.locks[p] = lock
+	}
+	lock.refs++
+	writeLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		writeLocks.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(writeLocks.locks, p)
+		}
+		writeLocks.Unlock()
+	}
+}
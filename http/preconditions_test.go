@@ -0,0 +1,69 @@
+//go:build !windows
+
+package fbhttp
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestConcurrentSaves(t *testing.T) {
+	// the hook runs between the check of the preconditions and the write,
+	// delaying it for the saves to overlap
+	ts := newTestServer(t, func(set *settings.Settings, server *settings.Server) {
+		server.EnableExec = true
+		set.Shell = []string{"sh", "-c"}
+		set.Commands = map[string][]string{"before_save": {"sleep 0.2"}}
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(ts.root, "a.txt"), []byte("a"), 0644))
+
+	resp := ts.do(t, http.MethodGet, "/api/resources/a.txt", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// the clients all hold the same version, only one of them may save
+	const clients = 4
+	statuses := make(chan int, clients)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := strings.NewReader(strings.Repeat("b", i+2))
+			req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/resources/a.txt", body)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			req.Header.Set("X-Auth", ts.token)
+			req.Header.Set("If-Match", etag)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	require.Equal(t, map[int]int{
+		http.StatusOK:                 1,
+		http.StatusPreconditionFailed: clients - 1,
+	}, counts)
+}
@@ -26,6 +26,7 @@ type PreviewSize int
 type ImgService interface {
 	FormatFromExtension(ext string) (img.Format, error)
 	Resize(ctx context.Context, in io.Reader, width, height int, out io.Writer, options ...img.Option) error
+	PerceptualHash(ctx context.Context, in io.Reader) (uint64, error)
 }
 
 type FileCache interface {
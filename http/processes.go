@@ -0,0 +1,33 @@
+package fbhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/runner"
+)
+
+// processesHandler lists the running commands, or kills one of them.
+// GET /api/processes
+// DELETE /api/processes/{id}
+var processesHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, _ *data) (int, error) {
+	p := strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case r.Method == http.MethodGet && p == "":
+		return renderJSON(w, r, runner.Processes())
+	case r.Method == http.MethodDelete && p != "":
+		id, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return http.StatusNotFound, nil
+		}
+
+		if err := runner.Kill(id); err != nil {
+			return errToStatus(err), err
+		}
+		return http.StatusNoContent, nil
+	default:
+		return http.StatusNotFound, nil
+	}
+})
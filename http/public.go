@@ -115,10 +115,10 @@ var publicShareHandler = withHashFile(func(w http.ResponseWriter, r *http.Reques
 var publicDlHandler = withHashFile(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	file := d.raw.(*files.FileInfo)
 	if !file.IsDir {
-		return rawFileHandler(w, r, file)
+		return serveRawFile(w, r, file, d.settings.StripGPSOnShare)
 	}
 
-	return rawDirHandler(w, r, d, file)
+	return rawDirHandler(w, r, d, file, d.settings.StripGPSOnShare)
 })
 
 func authenticateShareRequest(r *http.Request, l *share.Link) (int, error) {
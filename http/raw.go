@@ -1,11 +1,15 @@
 package fbhttp
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	gopath "path"
 	"path/filepath"
 	"strings"
@@ -14,7 +18,7 @@ import (
 
 	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/fileutils"
-	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/filebrowser/filebrowser/v2/jobs"
 )
 
 func slashClean(name string) string {
@@ -24,9 +28,9 @@ func slashClean(name string) string {
 	return gopath.Clean(name)
 }
 
-func parseQueryFiles(r *http.Request, f *files.FileInfo, _ *users.User) ([]string, error) {
+func parseQueryFiles(query string, f *files.FileInfo) ([]string, error) {
 	var fileSlice []string
-	names := strings.Split(r.URL.Query().Get("files"), ",")
+	names := strings.Split(query, ",")
 
 	if len(names) == 0 {
 		fileSlice = append(fileSlice, f.Path)
@@ -45,8 +49,8 @@ func parseQueryFiles(r *http.Request, f *files.FileInfo, _ *users.User) ([]strin
 	return fileSlice, nil
 }
 
-func parseQueryAlgorithm(r *http.Request) (string, archives.Archival, error) {
-	switch r.URL.Query().Get("algo") {
+func parseQueryAlgorithm(algo string) (string, archives.Archival, error) {
+	switch algo {
 	case "zip", "true", "":
 		return ".zip", archives.Zip{}, nil
 	case "tar":
@@ -79,36 +83,60 @@ func setContentDisposition(w http.ResponseWriter, r *http.Request, file *files.F
 	}
 }
 
-var rawHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if !d.user.Perm.Download {
-		return http.StatusAccepted, nil
-	}
+func rawHandler(jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Download {
+			return http.StatusAccepted, nil
+		}
 
-	file, err := files.NewFileInfo(&files.FileOptions{
-		Fs:         d.user.Fs,
-		Path:       r.URL.Path,
-		Modify:     d.user.Perm.Modify,
-		Expand:     false,
-		ReadHeader: d.server.TypeDetectionByHeader,
-		Checker:    d,
-	})
-	if err != nil {
-		return errToStatus(err), err
-	}
+		file, err := files.NewFileInfo(&files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       r.URL.Path,
+			Modify:     d.user.Perm.Modify,
+			Expand:     false,
+			ReadHeader: d.server.TypeDetectionByHeader,
+			Checker:    d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
 
-	if files.IsNamedPipe(file.Mode) {
-		setContentDisposition(w, r, file)
-		return 0, nil
-	}
+		if files.IsNamedPipe(file.Mode) {
+			setContentDisposition(w, r, file)
+			return 0, nil
+		}
 
-	if !file.IsDir {
-		return rawFileHandler(w, r, file)
-	}
+		if entry := r.URL.Query().Get("entry"); entry != "" && !file.IsDir {
+			return rawArchiveEntryHandler(w, r, d, file, entry)
+		}
+
+		if !file.IsDir {
+			return rawFileHandler(w, r, file)
+		}
+
+		if r.URL.Query().Get("async") == "true" {
+			if !d.user.Perm.Create {
+				return http.StatusForbidden, nil
+			}
 
-	return rawDirHandler(w, r, d, file)
-})
+			destination := r.URL.Query().Get("destination")
+			if destination != "" && !d.Check(destination) {
+				return http.StatusForbidden, nil
+			}
 
-func getFiles(d *data, path, commonPath string) ([]archives.FileInfo, error) {
+			return submitJob(w, r, d, jobManager, "archive", map[string]string{
+				"path":        file.Path,
+				"files":       r.URL.Query().Get("files"),
+				"algo":        r.URL.Query().Get("algo"),
+				"destination": destination,
+			})
+		}
+
+		return rawDirHandler(w, r, d, file, false)
+	})
+}
+
+func getFiles(d *data, path, commonPath string, stripGPS bool) ([]archives.FileInfo, error) {
 	if !d.Check(path) {
 		return nil, nil
 	}
@@ -118,6 +146,15 @@ func getFiles(d *data, path, commonPath string) ([]archives.FileInfo, error) {
 		return nil, err
 	}
 
+	// the images whose GPS information can't be removed are left out
+	if stripGPS && !info.IsDir() {
+		fd, err := openFile(d.user.Fs, path, true)
+		if err != nil {
+			return nil, err
+		}
+		fd.Close()
+	}
+
 	var archiveFiles []archives.FileInfo
 
 	if path != commonPath {
@@ -128,7 +165,7 @@ func getFiles(d *data, path, commonPath string) ([]archives.FileInfo, error) {
 			FileInfo:      info,
 			NameInArchive: nameInArchive,
 			Open: func() (fs.File, error) {
-				return d.user.Fs.Open(path)
+				return openFile(d.user.Fs, path, stripGPS)
 			},
 		})
 	}
@@ -147,7 +184,7 @@ func getFiles(d *data, path, commonPath string) ([]archives.FileInfo, error) {
 
 		for _, name := range names {
 			fPath := filepath.Join(path, name)
-			subFiles, err := getFiles(d, fPath, commonPath)
+			subFiles, err := getFiles(d, fPath, commonPath, stripGPS)
 			if err != nil {
 				log.Printf("Failed to get files from %s: %v", fPath, err)
 				continue
@@ -159,29 +196,36 @@ func getFiles(d *data, path, commonPath string) ([]archives.FileInfo, error) {
 	return archiveFiles, nil
 }
 
-func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
-	filenames, err := parseQueryFiles(r, file, d.user)
+func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo, stripGPS bool) (int, error) {
+	name, archiver, allFiles, err := prepareArchive(d, file, r.URL.Query().Get("files"), r.URL.Query().Get("algo"), stripGPS)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
-	extension, archiver, err := parseQueryAlgorithm(r)
-	if err != nil {
+	w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(name))
+
+	if err := archiver.Archive(r.Context(), w, allFiles); err != nil {
 		return http.StatusInternalServerError, err
 	}
 
-	commonDir := fileutils.CommonPrefix(filepath.Separator, filenames...)
+	return 0, nil
+}
 
-	var allFiles []archives.FileInfo
-	for _, fname := range filenames {
-		archiveFiles, err := getFiles(d, fname, commonDir)
-		if err != nil {
-			log.Printf("Failed to get files from %s: %v", fname, err)
-			continue
-		}
-		allFiles = append(allFiles, archiveFiles...)
+// prepareArchive lists the files to archive from the files and algo query
+// parameters and returns the name of the archive with its archiver.
+func prepareArchive(d *data, file *files.FileInfo, query, algo string, stripGPS bool) (string, archives.Archival, []archives.FileInfo, error) {
+	filenames, err := parseQueryFiles(query, file)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	extension, archiver, err := parseQueryAlgorithm(algo)
+	if err != nil {
+		return "", nil, nil, err
 	}
 
+	commonDir, allFiles := collectArchiveFiles(d, filenames, stripGPS)
+
 	name := filepath.Base(commonDir)
 	if name == "." || name == "" || name == string(filepath.Separator) {
 		if file.Name != "" {
@@ -189,7 +233,7 @@ func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.
 		} else {
 			actual, statErr := file.Fs.Stat(".")
 			if statErr != nil {
-				return http.StatusInternalServerError, statErr
+				return "", nil, nil, statErr
 			}
 			name = actual.Name()
 		}
@@ -197,20 +241,72 @@ func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.
 	if len(filenames) > 1 {
 		name = "_" + name
 	}
-	name += extension
-	w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(name))
 
-	if err := archiver.Archive(r.Context(), w, allFiles); err != nil {
-		return http.StatusInternalServerError, err
+	return name + extension, archiver, allFiles, nil
+}
+
+// collectArchiveFiles lists the files to archive, named relative to their
+// common directory.
+func collectArchiveFiles(d *data, filenames []string, stripGPS bool) (string, []archives.FileInfo) {
+	commonDir := fileutils.CommonPrefix(filepath.Separator, filenames...)
+
+	var allFiles []archives.FileInfo
+	for _, fname := range filenames {
+		archiveFiles, err := getFiles(d, fname, commonDir, stripGPS)
+		if err != nil {
+			log.Printf("Failed to get files from %s: %v", fname, err)
+			continue
+		}
+		allFiles = append(allFiles, archiveFiles...)
 	}
 
-	return 0, nil
+	return commonDir, allFiles
+}
+
+// writeArchive creates the archive file, writing it to a hidden file next
+// to it first, for an existing one to be replaced only once it's complete.
+func writeArchive(ctx context.Context, d *data, dst string, archiver archives.Archiver, allFiles []archives.FileInfo) error {
+	if err := checkLock(d, dst, false); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	tmp := gopath.Join(gopath.Dir(dst), "."+gopath.Base(dst)+"."+hex.EncodeToString(suffix))
+	if d.Events != nil {
+		defer d.Events.Begin(realPath(d.user.Fs, tmp))()
+	}
+
+	out, err := d.user.Fs.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, d.settings.FileMode)
+	if err != nil {
+		return err
+	}
+
+	err = archiver.Archive(ctx, out, allFiles)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = d.user.Fs.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = d.user.Fs.Remove(tmp)
+		return err
+	}
+
+	return nil
 }
 
 func rawFileHandler(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error) {
-	fd, err := file.Fs.Open(file.Path)
+	return serveRawFile(w, r, file, false)
+}
+
+func serveRawFile(w http.ResponseWriter, r *http.Request, file *files.FileInfo, stripGPS bool) (int, error) {
+	fd, err := openFile(file.Fs, file.Path, stripGPS)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errToStatus(err), err
 	}
 	defer fd.Close()
 
@@ -0,0 +1,68 @@
+package fbhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/mholt/archives"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// failingArchiver writes part of an archive, then fails.
+type failingArchiver struct{}
+
+func (failingArchiver) Archive(_ context.Context, out io.Writer, _ []archives.FileInfo) error {
+	_, _ = out.Write([]byte("partial"))
+	return errors.New("archiving failed")
+}
+
+func TestWriteArchive(t *testing.T) {
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	afs := afero.NewBasePathFs(afero.NewMemMapFs(), "/")
+	require.NoError(t, afero.WriteFile(afs, "/a.txt", []byte("a"), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/files.zip", []byte("previous"), 0644))
+
+	set := &settings.Settings{FileMode: settings.DefaultFileMode, DirMode: settings.DefaultDirMode}
+	d := &data{
+		Runner:   &runner.Runner{Settings: set},
+		store:    store,
+		settings: set,
+		user:     &users.User{Fs: afs},
+	}
+
+	// the existing file is kept when archiving fails
+	require.Error(t, writeArchive(context.Background(), d, "/files.zip", failingArchiver{}, nil))
+	content, err := afero.ReadFile(afs, "/files.zip")
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(content))
+
+	entries, err := afero.ReadDir(afs, "/")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// and replaced once the archive is complete
+	_, allFiles := collectArchiveFiles(d, []string{"/a.txt"}, false)
+	require.NoError(t, writeArchive(context.Background(), d, "/files.zip", archives.Zip{}, allFiles))
+	content, err = afero.ReadFile(afs, "/files.zip")
+	require.NoError(t, err)
+	require.Equal(t, "PK", string(content[:2]))
+
+	entries, err = afero.ReadDir(afs, "/")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
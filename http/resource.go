@@ -12,14 +12,17 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/spf13/afero"
 
 	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
 	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/jobs"
 )
 
 var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
@@ -36,12 +39,34 @@ var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 		return errToStatus(err), err
 	}
 
+	// the entries of the archives are listed as a virtual directory
+	if r.URL.Query().Has("archive") && !file.IsDir {
+		file, err = listArchive(r.Context(), d, file, r.URL.Query().Get("archive"))
+		if err != nil {
+			return errToStatus(err), err
+		}
+	}
+
+	if file.ArchiveEntry == "" {
+		if err := annotateLocks(d, file); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if err := annotateTags(d, file); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
 	if file.IsDir {
 		file.Sorting = d.user.Sorting
 		file.ApplySort()
 		return renderJSON(w, r, file)
 	}
 
+	// the version of the target of the links, as checked when saving
+	if info := statVersion(d.user.Fs, r.URL.Path); info != nil {
+		w.Header().Set("ETag", fileETag(info.ModTime(), info.Size()))
+	}
+
 	if checksum := r.URL.Query().Get("checksum"); checksum != "" {
 		err := file.Checksum(checksum)
 		if errors.Is(err, fberrors.ErrInvalidOption) {
@@ -63,39 +88,63 @@ func resourceDeleteHandler(fileCache FileCache) handleFunc {
 			return http.StatusForbidden, nil
 		}
 
-		file, err := files.NewFileInfo(&files.FileOptions{
-			Fs:         d.user.Fs,
-			Path:       r.URL.Path,
-			Modify:     d.user.Perm.Modify,
-			Expand:     false,
-			ReadHeader: d.server.TypeDetectionByHeader,
-			Checker:    d,
-		})
+		err := deleteResource(r.Context(), d, fileCache, r.URL.Path)
 		if err != nil {
 			return errToStatus(err), err
 		}
 
-		err = d.store.Share.DeleteWithPathPrefix(file.Path)
-		if err != nil {
+		return http.StatusNoContent, nil
+	})
+}
+
+// deleteResource removes the file or directory along with its shares and
+// thumbnails.
+func deleteResource(ctx context.Context, d *data, fileCache FileCache, target string) error {
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       target,
+		Modify:     d.user.Perm.Modify,
+		Expand:     false,
+		ReadHeader: d.server.TypeDetectionByHeader,
+		Checker:    d,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := checkLock(d, target, true); err != nil {
+		return err
+	}
+
+	// the shares and thumbnails go once the before hooks let the file go
+	err = d.RunHook(func() error {
+		if err := d.store.Share.DeleteWithPathPrefix(file.Path); err != nil {
 			log.Printf("WARNING: Error(s) occurred while deleting associated shares with file: %s", err)
 		}
 
-		// delete thumbnails
-		err = delThumbs(r.Context(), fileCache, file)
-		if err != nil {
-			return errToStatus(err), err
+		if err := delThumbs(ctx, fileCache, file); err != nil {
+			return err
 		}
 
-		err = d.RunHook(func() error {
-			return d.user.Fs.RemoveAll(r.URL.Path)
-		}, "delete", r.URL.Path, "", d.user)
+		return d.user.Fs.RemoveAll(target)
+	}, "delete", target, "", d.user)
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return errToStatus(err), err
-		}
+	if err := d.store.Tags.DeleteWithin(realPath(d.user.Fs, target)); err != nil {
+		return err
+	}
 
-		return http.StatusNoContent, nil
-	})
+	if err := d.store.Comments.DeleteWithin(realPath(d.user.Fs, target)); err != nil {
+		return err
+	}
+
+	if err := d.store.Checksums.DeleteWithin(realPath(d.user.Fs, target)); err != nil {
+		return err
+	}
+
+	return d.store.Locks.ReleaseWithin(realPath(d.user.Fs, target))
 }
 
 func resourcePostHandler(fileCache FileCache) handleFunc {
@@ -107,9 +156,18 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 		// Directories creation on POST.
 		if strings.HasSuffix(r.URL.Path, "/") {
 			err := d.user.Fs.MkdirAll(r.URL.Path, d.settings.DirMode)
+			if err == nil && d.Events != nil {
+				d.Events.Publish(events.Event{
+					Type: events.Created,
+					Path: d.user.FullPath(r.URL.Path),
+					User: d.user.Username,
+				})
+			}
 			return errToStatus(err), err
 		}
 
+		defer lockWrite(d.user.FullPath(r.URL.Path))()
+
 		file, err := files.NewFileInfo(&files.FileOptions{
 			Fs:         d.user.Fs,
 			Path:       r.URL.Path,
@@ -128,20 +186,35 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 				return http.StatusForbidden, nil
 			}
 
+			if current := statVersion(d.user.Fs, r.URL.Path); !checkPreconditions(r, current) {
+				return preconditionFailed(w, r, current)
+			}
+
+			if err := checkLock(d, r.URL.Path, false); err != nil {
+				return errToStatus(err), err
+			}
+
 			err = delThumbs(r.Context(), fileCache, file)
 			if err != nil {
 				return errToStatus(err), err
 			}
+		} else if !checkPreconditions(r, nil) {
+			return preconditionFailed(w, r, nil)
 		}
 
 		err = d.RunHook(func() error {
-			info, writeErr := writeFile(d.user.Fs, r.URL.Path, r.Body, d.settings.FileMode, d.settings.DirMode)
+			body, h := io.Reader(r.Body), uploadHash(d)
+			if h != nil {
+				body = io.TeeReader(r.Body, h)
+			}
+
+			info, writeErr := writeFile(d.user.Fs, r.URL.Path, body, d.settings.FileMode, d.settings.DirMode)
 			if writeErr != nil {
 				return writeErr
 			}
 
-			etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
-			w.Header().Set("ETag", etag)
+			storeChecksum(d, r.URL.Path, info, h)
+			w.Header().Set("ETag", fileETag(info.ModTime(), info.Size()))
 			return nil
 		}, "upload", r.URL.Path, "", d.user)
 
@@ -163,6 +236,8 @@ var resourcePutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 		return http.StatusMethodNotAllowed, nil
 	}
 
+	defer lockWrite(d.user.FullPath(r.URL.Path))()
+
 	exists, err := afero.Exists(d.user.Fs, r.URL.Path)
 	if err != nil {
 		return http.StatusInternalServerError, err
@@ -171,25 +246,43 @@ var resourcePutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 		return http.StatusNotFound, nil
 	}
 
+	// the file must not have changed since the client read it
+	if current := statVersion(d.user.Fs, r.URL.Path); !checkPreconditions(r, current) {
+		return preconditionFailed(w, r, current)
+	}
+
+	if err := checkLock(d, r.URL.Path, false); err != nil {
+		return errToStatus(err), err
+	}
+
 	err = d.RunHook(func() error {
-		info, writeErr := writeFile(d.user.Fs, r.URL.Path, r.Body, d.settings.FileMode, d.settings.DirMode)
+		body, h := io.Reader(r.Body), uploadHash(d)
+		if h != nil {
+			body = io.TeeReader(r.Body, h)
+		}
+
+		info, writeErr := writeFile(d.user.Fs, r.URL.Path, body, d.settings.FileMode, d.settings.DirMode)
 		if writeErr != nil {
 			return writeErr
 		}
 
-		etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
-		w.Header().Set("ETag", etag)
+		storeChecksum(d, r.URL.Path, info, h)
+		w.Header().Set("ETag", fileETag(info.ModTime(), info.Size()))
 		return nil
 	}, "save", r.URL.Path, "", d.user)
 
 	return errToStatus(err), err
 })
 
-func resourcePatchHandler(fileCache FileCache) handleFunc {
-	return withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+func resourcePatchHandler(fileCache FileCache, jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		action := r.URL.Query().Get("action")
+		if action == "compress" {
+			return resourceCompress(w, r, d, jobManager)
+		}
+
 		src := r.URL.Path
 		dst := r.URL.Query().Get("destination")
-		action := r.URL.Query().Get("action")
 		dst, err := url.QueryUnescape(dst)
 		if !d.Check(src) || !d.Check(dst) {
 			return http.StatusForbidden, nil
@@ -208,22 +301,32 @@ func resourcePatchHandler(fileCache FileCache) handleFunc {
 
 		override := r.URL.Query().Get("override") == "true"
 		rename := r.URL.Query().Get("rename") == "true"
-		if !override && !rename {
-			if _, err = d.user.Fs.Stat(dst); err == nil {
-				return http.StatusConflict, nil
-			}
-		}
-		if rename {
-			dst = addVersionSuffix(dst, d.user.Fs)
+		target, err := patchDestination(d, dst, override, rename)
+		if err != nil {
+			return errToStatus(err), err
 		}
 
-		// Permission for overwriting the file
-		if override && !d.user.Perm.Modify {
-			return http.StatusForbidden, nil
+		// the job applies the options again once it runs
+		if r.URL.Query().Get("async") == "true" {
+			if action != "copy" && action != "rename" {
+				return http.StatusBadRequest, fmt.Errorf("unsupported action %s: %w", action, fberrors.ErrInvalidRequestParams)
+			}
+			return submitJob(w, r, d, jobManager, action, map[string]string{
+				"src":      src,
+				"dst":      dst,
+				"override": strconv.FormatBool(override),
+				"rename":   strconv.FormatBool(rename),
+			})
 		}
+		dst = target
 
-		err = d.RunHook(func() error {
-			return patchAction(r.Context(), action, src, dst, d, fileCache)
+		err = d.RunHookDst(func(target string) error {
+			if target != dst {
+				if err := checkHookDestination(d, src, target, override); err != nil {
+					return err
+				}
+			}
+			return patchAction(r.Context(), action, src, target, d, fileCache)
 		}, action, src, dst, d.user)
 
 		return errToStatus(err), err
@@ -244,22 +347,43 @@ func checkParent(src, dst string) error {
 	return nil
 }
 
-func addVersionSuffix(source string, afs afero.Fs) string {
-	counter := 1
-	dir, name := path.Split(source)
-	ext := filepath.Ext(name)
-	base := strings.TrimSuffix(name, ext)
-
-	for {
-		if _, err := afs.Stat(source); err != nil {
-			break
+// patchDestination returns the destination of a copy, a move or an
+// archive. An existing destination is only replaced with override, which
+// requires the modify permission, or else kept with rename by suffixing the
+// new name.
+func patchDestination(d *data, dst string, override, rename bool) (string, error) {
+	if !override && !rename {
+		if _, err := d.user.Fs.Stat(dst); err == nil {
+			return "", fberrors.ErrExist
 		}
-		renamed := fmt.Sprintf("%s(%d)%s", base, counter, ext)
-		source = path.Join(dir, renamed)
-		counter++
+	}
+	if rename {
+		dst = fileutils.AddVersionSuffix(dst, d.user.Fs)
 	}
 
-	return source
+	// Permission for overwriting the file
+	if override && !d.user.Perm.Modify {
+		return "", fberrors.ErrPermissionDenied
+	}
+
+	return dst, nil
+}
+
+// checkHookDestination checks the destination a before hook rewrote the
+// one of an operation to, as the one of the request.
+func checkHookDestination(d *data, src, dst string, override bool) error {
+	if dst == "/" || !d.Check(dst) {
+		return fberrors.ErrPermissionDenied
+	}
+	if err := checkParent(src, dst); err != nil {
+		return err
+	}
+	if !override {
+		if _, err := d.user.Fs.Stat(dst); err == nil {
+			return fberrors.ErrExist
+		}
+	}
+	return nil
 }
 
 func writeFile(afs afero.Fs, dst string, in io.Reader, fileMode, dirMode fs.FileMode) (os.FileInfo, error) {
@@ -307,6 +431,10 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 			return fberrors.ErrPermissionDenied
 		}
 
+		if err := checkLock(d, dst, true); err != nil {
+			return err
+		}
+
 		return fileutils.Copy(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode)
 	case "rename":
 		if !d.user.Perm.Rename {
@@ -315,6 +443,13 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 		src = path.Clean("/" + src)
 		dst = path.Clean("/" + dst)
 
+		if err := checkLock(d, src, true); err != nil {
+			return err
+		}
+		if err := checkLock(d, dst, true); err != nil {
+			return err
+		}
+
 		file, err := files.NewFileInfo(&files.FileOptions{
 			Fs:         d.user.Fs,
 			Path:       src,
@@ -333,7 +468,25 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 			return err
 		}
 
-		return fileutils.MoveFile(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode)
+		err = fileutils.MoveFile(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode)
+		if err != nil {
+			return err
+		}
+
+		// the tags, the comments, the checksums and the locks follow the files
+		if err := d.store.Tags.Move(realPath(d.user.Fs, src), realPath(d.user.Fs, dst)); err != nil {
+			return err
+		}
+
+		if err := d.store.Comments.Move(realPath(d.user.Fs, src), realPath(d.user.Fs, dst)); err != nil {
+			return err
+		}
+
+		if err := d.store.Checksums.Move(realPath(d.user.Fs, src), realPath(d.user.Fs, dst)); err != nil {
+			return err
+		}
+
+		return d.store.Locks.Move(realPath(d.user.Fs, src), realPath(d.user.Fs, dst))
 	default:
 		return fmt.Errorf("unsupported action %s: %w", action, fberrors.ErrInvalidRequestParams)
 	}
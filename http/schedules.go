@@ -0,0 +1,174 @@
+package fbhttp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/schedules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+)
+
+const defaultRunsLimit = 20
+
+// runSchedules runs the scheduled commands at the minutes matched by their
+// cron expressions, reading the settings each minute for their changes to
+// apply, until the context is done. A command still running when its next
+// run is due skips it.
+func runSchedules(ctx context.Context, store *storage.Storage, server *settings.Server, bus *events.Bus) {
+	if !server.EnableExec {
+		return
+	}
+
+	var running sync.Map
+	for {
+		now := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+
+		set, err := store.Settings.Get()
+		if err != nil {
+			log.Printf("could not get the settings of the schedules: %v", err)
+			continue
+		}
+
+		now = time.Now()
+		for _, s := range set.Schedules {
+			c, err := runner.ParseCron(s.Cron)
+			if err != nil || !c.Matches(now) {
+				continue
+			}
+
+			if _, busy := running.LoadOrStore(s.Name, true); busy {
+				log.Printf("schedule %s is still running, skipping its run", s.Name)
+				continue
+			}
+
+			go func() {
+				defer running.Delete(s.Name)
+				runSchedule(store, server, bus, set, s)
+			}()
+		}
+	}
+}
+
+func runSchedule(store *storage.Storage, server *settings.Server, bus *events.Bus, set *settings.Settings, s settings.Schedule) {
+	var run *schedules.Run
+	user, err := store.Users.Get(server.Root, s.Username)
+	if err == nil {
+		r := &runner.Runner{Enabled: server.EnableExec, Settings: set, Events: bus}
+		run = r.RunSchedule(s, user)
+	} else {
+		now := time.Now()
+		run = &schedules.Run{
+			Schedule: s.Name,
+			Command:  s.Command,
+			Username: s.Username,
+			Started:  now,
+			Finished: now,
+			ExitCode: -1,
+			Error:    fmt.Sprintf("could not get the user: %v", err),
+		}
+	}
+
+	if err := store.Schedules.Save(run); err != nil {
+		log.Printf("WARNING: couldn't record the run of schedule %s: %v", s.Name, err)
+	}
+}
+
+// checkSchedules validates the schedules, cleaning their paths.
+func checkSchedules(d *data, list []settings.Schedule) error {
+	names := map[string]bool{}
+	for i := range list {
+		s := &list[i]
+		switch {
+		case s.Name == "" || strings.Contains(s.Name, "/"):
+			return fmt.Errorf("invalid schedule name %q: %w", s.Name, fberrors.ErrInvalidRequestParams)
+		case names[s.Name]:
+			return fmt.Errorf("duplicate schedule name %q: %w", s.Name, fberrors.ErrInvalidRequestParams)
+		case strings.TrimSpace(s.Command) == "":
+			return fmt.Errorf("schedule %s has no command: %w", s.Name, fberrors.ErrInvalidRequestParams)
+		}
+		names[s.Name] = true
+
+		if _, err := runner.ParseCron(s.Cron); err != nil {
+			return fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+		if _, err := d.store.Users.Get(d.server.Root, s.Username); err != nil {
+			return fmt.Errorf("schedule %s: unknown user %q", s.Name, s.Username)
+		}
+		s.Path = path.Clean("/" + s.Path)
+	}
+	return nil
+}
+
+// scheduleInfo is a schedule with the time of its next run and its last
+// run, without the output.
+type scheduleInfo struct {
+	settings.Schedule
+	Next    *time.Time     `json:"next,omitempty"`
+	LastRun *schedules.Run `json:"lastRun,omitempty"`
+}
+
+// schedulesHandler lists the scheduled commands, or the runs of one of
+// them from the newest.
+// GET /api/schedules
+// GET /api/schedules/{name}/runs?limit=20
+var schedulesHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := strings.Trim(r.URL.Path, "/")
+	if p == "" {
+		list := []scheduleInfo{}
+		for _, s := range d.settings.Schedules {
+			info := scheduleInfo{Schedule: s}
+			if c, err := runner.ParseCron(s.Cron); err == nil {
+				if next := c.Next(time.Now()); !next.IsZero() {
+					info.Next = &next
+				}
+			}
+
+			runs, err := d.store.Schedules.Runs(s.Name, 1)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			if len(runs) > 0 {
+				info.LastRun = runs[0]
+				info.LastRun.Output = ""
+			}
+			list = append(list, info)
+		}
+		return renderJSON(w, r, list)
+	}
+
+	name, ok := strings.CutSuffix(p, "/runs")
+	if !ok || strings.Contains(name, "/") {
+		return http.StatusNotFound, nil
+	}
+
+	limit := defaultRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return http.StatusBadRequest, nil
+		}
+		limit = n
+	}
+
+	runs, err := d.store.Schedules.Runs(name, limit)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return renderJSON(w, r, runs)
+})
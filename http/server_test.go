@@ -0,0 +1,113 @@
+package fbhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/asdine/storm/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/diskcache"
+	"github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/transcode"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// testServer serves the API on a root directory and a database in
+// temporary directories, logged in as an admin.
+type testServer struct {
+	*httptest.Server
+	store *storage.Storage
+	root  string
+	token string
+}
+
+// newTestServer starts a test server, with its settings changed by the
+// configure functions.
+func newTestServer(t *testing.T, configure ...func(*settings.Settings, *settings.Server)) *testServer {
+	t.Helper()
+
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	set := &settings.Settings{
+		Key:        []byte("key"),
+		AuthMethod: auth.MethodJSONAuth,
+		FileMode:   settings.DefaultFileMode,
+		DirMode:    settings.DefaultDirMode,
+		Tus: settings.Tus{
+			ChunkSize:  settings.DefaultTusChunkSize,
+			RetryCount: settings.DefaultTusRetryCount,
+			Expiration: settings.DefaultTusExpiration,
+		},
+	}
+	root := t.TempDir()
+	server := &settings.Server{Root: root}
+	for _, fn := range configure {
+		fn(set, server)
+	}
+	require.NoError(t, store.Settings.Save(set))
+	require.NoError(t, store.Settings.SaveServer(server))
+	require.NoError(t, store.Auth.Save(&auth.JSONAuth{}))
+
+	pwd, err := users.HashPwd("password")
+	require.NoError(t, err)
+	require.NoError(t, store.Users.Save(&users.User{
+		Username: "admin",
+		Password: pwd,
+		Scope:    "/",
+		Perm: users.Permissions{
+			Admin: true, Execute: true, Create: true, Rename: true,
+			Modify: true, Delete: true, Share: true, Download: true,
+		},
+	}))
+
+	jobManager, err := jobs.NewManager(store.Jobs, 1)
+	require.NoError(t, err)
+	handler, err := NewHandler(t.Context(), img.New(1), transcode.New("", 1), jobManager, diskcache.NewNoOp(), store, server, fstest.MapFS{})
+	require.NoError(t, err)
+
+	ts := &testServer{Server: httptest.NewServer(handler), store: store, root: root}
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Post(ts.URL+"/api/login", "application/json", strings.NewReader(`{"username":"admin","password":"password"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	token, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	ts.token = string(token)
+
+	return ts
+}
+
+// do sends the request as the admin, with the headers given as pairs of
+// names and values.
+func (ts *testServer) do(t *testing.T, method, p string, body io.Reader, headers ...string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+p, body)
+	require.NoError(t, err)
+	req.Header.Set("X-Auth", ts.token)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
@@ -2,8 +2,11 @@ package fbhttp
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/rules"
 	"github.com/filebrowser/filebrowser/v2/settings"
 )
@@ -18,8 +21,16 @@ type settingsData struct {
 	Rules                 []rules.Rule          `json:"rules"`
 	Branding              settings.Branding     `json:"branding"`
 	Tus                   settings.Tus          `json:"tus"`
+	Extract               settings.Extract      `json:"extract"`
+	Fetch                 settings.Fetch        `json:"fetch"`
 	Shell                 []string              `json:"shell"`
 	Commands              map[string][]string   `json:"commands"`
+	CommandTimeout        string                `json:"commandTimeout"`
+	Sandbox               settings.Sandbox      `json:"sandbox"`
+	Schedules             []settings.Schedule   `json:"schedules"`
+	Webhooks              []settings.Webhook    `json:"webhooks"`
+	StripGPSOnShare       bool                  `json:"stripGPSOnShare"`
+	UploadChecksum        string                `json:"uploadChecksum"`
 }
 
 var settingsGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
@@ -33,8 +44,16 @@ var settingsGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request,
 		Rules:                 d.settings.Rules,
 		Branding:              d.settings.Branding,
 		Tus:                   d.settings.Tus,
+		Extract:               d.settings.Extract,
+		Fetch:                 d.settings.Fetch,
 		Shell:                 d.settings.Shell,
 		Commands:              d.settings.Commands,
+		CommandTimeout:        d.settings.CommandTimeout,
+		Sandbox:               d.settings.Sandbox,
+		Schedules:             d.settings.Schedules,
+		Webhooks:              d.settings.Webhooks,
+		StripGPSOnShare:       d.settings.StripGPSOnShare,
+		UploadChecksum:        d.settings.UploadChecksum,
 	}
 
 	return renderJSON(w, r, data)
@@ -47,6 +66,32 @@ var settingsPutHandler = withAdmin(func(_ http.ResponseWriter, r *http.Request,
 		return http.StatusBadRequest, err
 	}
 
+	if req.UploadChecksum != "" {
+		if _, err := files.NewHash(req.UploadChecksum); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if req.Tus.Expiration != "" {
+		if _, err := time.ParseDuration(req.Tus.Expiration); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if req.CommandTimeout != "" {
+		if timeout, err := time.ParseDuration(req.CommandTimeout); err != nil || timeout <= 0 {
+			return http.StatusBadRequest, fmt.Errorf("invalid command timeout %q", req.CommandTimeout)
+		}
+	}
+
+	if err := checkSchedules(d, req.Schedules); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err := checkWebhooks(req.Webhooks); err != nil {
+		return http.StatusBadRequest, err
+	}
+
 	d.settings.Signup = req.Signup
 	d.settings.CreateUserDir = req.CreateUserDir
 	d.settings.MinimumPasswordLength = req.MinimumPasswordLength
@@ -55,9 +100,17 @@ var settingsPutHandler = withAdmin(func(_ http.ResponseWriter, r *http.Request,
 	d.settings.Rules = req.Rules
 	d.settings.Branding = req.Branding
 	d.settings.Tus = req.Tus
+	d.settings.Extract = req.Extract
+	d.settings.Fetch = req.Fetch
 	d.settings.Shell = req.Shell
 	d.settings.Commands = req.Commands
+	d.settings.CommandTimeout = req.CommandTimeout
+	d.settings.Sandbox = req.Sandbox
+	d.settings.Schedules = req.Schedules
+	d.settings.Webhooks = req.Webhooks
 	d.settings.HideLoginButton = req.HideLoginButton
+	d.settings.StripGPSOnShare = req.StripGPSOnShare
+	d.settings.UploadChecksum = req.UploadChecksum
 
 	err = d.store.Settings.Save(d.settings)
 	return errToStatus(err), err
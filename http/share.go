@@ -15,6 +15,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
 	"github.com/filebrowser/filebrowser/v2/share"
 )
 
@@ -158,6 +159,14 @@ var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request
 		return http.StatusInternalServerError, err
 	}
 
+	if d.Events != nil {
+		d.Events.Publish(events.Event{
+			Type: events.Shared,
+			Path: realPath(d.user.Fs, r.URL.Path),
+			User: d.user.Username,
+		})
+	}
+
 	return renderJSON(w, r, s)
 })
 
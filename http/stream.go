@@ -0,0 +1,182 @@
+package fbhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/transcode"
+)
+
+type Transcoder interface {
+	Enabled() bool
+	Probe(ctx context.Context, input string) (duration float64, height int, err error)
+	Segment(ctx context.Context, input string, rendition transcode.Rendition, index int, out io.Writer) error
+}
+
+type mediaInfo struct {
+	Duration float64 `json:"duration"`
+	Height   int     `json:"height"`
+}
+
+// hlsHandler streams a video as HLS, transcoding it on the fly.
+// GET /api/hls/{path} returns the master playlist,
+// GET /api/hls/{path}?rendition=720p the playlist of a rendition and
+// GET /api/hls/{path}?rendition=720p&segment=3 a segment.
+func hlsHandler(transcoder Transcoder, fileCache FileCache) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Download {
+			return http.StatusAccepted, nil
+		}
+
+		if !transcoder.Enabled() {
+			return http.StatusNotImplemented, nil
+		}
+
+		file, err := files.NewFileInfo(&files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       r.URL.Path,
+			Modify:     d.user.Perm.Modify,
+			Expand:     true,
+			ReadHeader: d.server.TypeDetectionByHeader,
+			Checker:    d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if file.IsDir || file.Type != "video" {
+			return http.StatusBadRequest, nil
+		}
+
+		query := r.URL.Query()
+		if query.Get("rendition") == "" {
+			info, err := getMediaInfo(r.Context(), transcoder, fileCache, file)
+			if err != nil {
+				return errToStatus(err), err
+			}
+
+			playlist := transcode.MasterPlaylist(transcode.Renditions(info.Height), func(rendition transcode.Rendition) string {
+				return "?" + url.Values{"rendition": {rendition.Name}}.Encode()
+			})
+			return servePlaylist(w, playlist)
+		}
+
+		rendition, ok := transcode.FindRendition(query.Get("rendition"))
+		if !ok {
+			return http.StatusBadRequest, fmt.Errorf("unknown rendition %q", query.Get("rendition"))
+		}
+
+		info, err := getMediaInfo(r.Context(), transcoder, fileCache, file)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if query.Get("segment") == "" {
+			playlist := transcode.MediaPlaylist(info.Duration, func(index int) string {
+				return "?" + url.Values{"rendition": {rendition.Name}, "segment": {strconv.Itoa(index)}}.Encode()
+			})
+			return servePlaylist(w, playlist)
+		}
+
+		index, err := strconv.Atoi(query.Get("segment"))
+		if err != nil || index < 0 || index >= transcode.Segments(info.Duration) {
+			return http.StatusNotFound, nil
+		}
+
+		return hlsSegmentHandler(w, r, transcoder, fileCache, file, rendition, index)
+	})
+}
+
+func hlsSegmentHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	transcoder Transcoder,
+	fileCache FileCache,
+	file *files.FileInfo,
+	rendition transcode.Rendition,
+	index int,
+) (int, error) {
+	cacheKey := fmt.Sprintf("%x%xhls%s%d", file.RealPath(), file.ModTime.Unix(), rendition.Name, index)
+	segment, ok, err := fileCache.Load(r.Context(), cacheKey)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !ok {
+		buf := &bytes.Buffer{}
+		if err := transcoder.Segment(r.Context(), file.RealPath(), rendition, index, buf); err != nil {
+			if errorsIsCanceled(err) {
+				return 0, err
+			}
+			return http.StatusInternalServerError, err
+		}
+		segment = buf.Bytes()
+
+		go func() {
+			if err := fileCache.Store(context.Background(), cacheKey, segment); err != nil {
+				log.Printf("failed to cache hls segment: %v", err)
+			}
+		}()
+	}
+
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Set("Content-Type", "video/mp2t")
+	http.ServeContent(w, r, "", file.ModTime, bytes.NewReader(segment))
+	return 0, nil
+}
+
+func servePlaylist(w http.ResponseWriter, playlist []byte) (int, error) {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	if _, err := w.Write(playlist); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return 0, nil
+}
+
+// getMediaInfo returns the duration and height of a video. They're read
+// from the container when it is supported and asked to ffmpeg otherwise.
+func getMediaInfo(ctx context.Context, transcoder Transcoder, fileCache FileCache, file *files.FileInfo) (*mediaInfo, error) {
+	info := &mediaInfo{}
+
+	meta, err := file.Metadata()
+	if err == nil && meta.Video != nil {
+		info.Duration = meta.Video.Duration
+		for _, track := range meta.Video.Tracks {
+			if track.Type == "video" && track.Height > info.Height {
+				info.Height = track.Height
+			}
+		}
+	}
+	if info.Duration > 0 {
+		return info, nil
+	}
+
+	cacheKey := fmt.Sprintf("%x%xprobe", file.RealPath(), file.ModTime.Unix())
+	if cached, ok, err := fileCache.Load(ctx, cacheKey); err == nil && ok {
+		if err := json.Unmarshal(cached, info); err == nil {
+			return info, nil
+		}
+	}
+
+	info.Duration, info.Height, err = transcoder.Probe(ctx, file.RealPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if value, err := json.Marshal(info); err == nil {
+		if err := fileCache.Store(ctx, cacheKey, value); err != nil {
+			log.Printf("failed to cache media info: %v", err)
+		}
+	}
+
+	return info, nil
+}
@@ -2,39 +2,55 @@ package fbhttp
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/asticode/go-astisub"
 
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
 	"github.com/filebrowser/filebrowser/v2/files"
 )
 
-var subtitleHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if !d.user.Perm.Download {
-		return http.StatusAccepted, nil
-	}
+func subtitleHandler(fileCache FileCache) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Download {
+			return http.StatusAccepted, nil
+		}
 
-	file, err := files.NewFileInfo(&files.FileOptions{
-		Fs:         d.user.Fs,
-		Path:       r.URL.Path,
-		Modify:     d.user.Perm.Modify,
-		Expand:     false,
-		ReadHeader: d.server.TypeDetectionByHeader,
-		Checker:    d,
-	})
-	if err != nil {
-		return errToStatus(err), err
-	}
+		fPath, track, embedded := files.ParseEmbeddedSubtitlePath(r.URL.Path)
+		if !embedded {
+			fPath = r.URL.Path
+		}
 
-	if file.IsDir {
-		return http.StatusBadRequest, nil
-	}
+		file, err := files.NewFileInfo(&files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       fPath,
+			Modify:     d.user.Perm.Modify,
+			Expand:     false,
+			ReadHeader: d.server.TypeDetectionByHeader,
+			Checker:    d,
+		})
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if file.IsDir {
+			return http.StatusBadRequest, nil
+		}
+
+		if embedded {
+			return embeddedSubtitleHandler(w, r, fileCache, file, track)
+		}
 
-	return subtitleFileHandler(w, r, file)
-})
+		return subtitleFileHandler(w, r, fileCache, file)
+	})
+}
 
-func subtitleFileHandler(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error) {
+func subtitleFileHandler(w http.ResponseWriter, r *http.Request, fileCache FileCache, file *files.FileInfo) (int, error) {
 	// if its not a subtitle file, reject
 	if !files.IsSupportedSubtitle(file.Name) {
 		return http.StatusBadRequest, nil
@@ -46,35 +62,87 @@ func subtitleFileHandler(w http.ResponseWriter, r *http.Request, file *files.Fil
 	}
 	defer fd.Close()
 
-	// load subtitle for conversion to vtt
-	var sub *astisub.Subtitles
-	if strings.HasSuffix(file.Name, ".srt") {
-		sub, err = astisub.ReadFromSRT(fd)
-	} else if strings.HasSuffix(file.Name, ".ass") || strings.HasSuffix(file.Name, ".ssa") {
-		sub, err = astisub.ReadFromSSA(fd)
+	setSubtitleHeaders(w, r, file)
+
+	// serve vtt file directly
+	if strings.HasSuffix(file.Name, ".vtt") {
+		http.ServeContent(w, r, file.Name, file.ModTime, fd)
+		return 0, nil
 	}
+
+	cacheKey := subtitleCacheKey(file, "vtt")
+	vtt, ok, err := fileCache.Load(r.Context(), cacheKey)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errToStatus(err), err
 	}
 
+	if !ok {
+		// load subtitle for conversion to vtt
+		var sub *astisub.Subtitles
+		if strings.HasSuffix(file.Name, ".srt") {
+			sub, err = astisub.ReadFromSRT(fd)
+		} else {
+			sub, err = astisub.ReadFromSSA(fd)
+		}
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		var buf = &bytes.Buffer{}
+		err = sub.WriteToWebVTT(buf)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		vtt = buf.Bytes()
+		storeSubtitle(fileCache, cacheKey, vtt)
+	}
+
+	// serve the converted vtt from buffer
+	http.ServeContent(w, r, file.Name, file.ModTime, bytes.NewReader(vtt))
+	return 0, nil
+}
+
+func embeddedSubtitleHandler(w http.ResponseWriter, r *http.Request, fileCache FileCache,
+	file *files.FileInfo, track int) (int, error) {
+	cacheKey := subtitleCacheKey(file, fmt.Sprintf("track%d", track))
+	vtt, ok, err := fileCache.Load(r.Context(), cacheKey)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !ok {
+		var buf = &bytes.Buffer{}
+		err = file.ExtractSubtitle(track, buf)
+		if errors.Is(err, fberrors.ErrInvalidOption) {
+			return http.StatusNotImplemented, nil
+		} else if err != nil {
+			return errToStatus(err), err
+		}
+		vtt = buf.Bytes()
+		storeSubtitle(fileCache, cacheKey, vtt)
+	}
+
+	setSubtitleHeaders(w, r, file)
+	http.ServeContent(w, r, file.Name, file.ModTime, bytes.NewReader(vtt))
+	return 0, nil
+}
+
+func setSubtitleHeaders(w http.ResponseWriter, r *http.Request, file *files.FileInfo) {
 	setContentDisposition(w, r, file)
 	w.Header().Add("Content-Security-Policy", `script-src 'none';`)
 	w.Header().Set("Cache-Control", "private")
 	// force type to text/vtt
 	w.Header().Set("Content-Type", "text/vtt")
+}
 
-	// serve vtt file directly
-	if sub == nil {
-		http.ServeContent(w, r, file.Name, file.ModTime, fd)
-		return 0, nil
-	}
+func storeSubtitle(fileCache FileCache, cacheKey string, vtt []byte) {
+	go func() {
+		if err := fileCache.Store(context.Background(), cacheKey, vtt); err != nil {
+			log.Printf("failed to cache subtitle: %v", err)
+		}
+	}()
+}
 
-	// convert others to vtt and serve from buffer
-	var buf = &bytes.Buffer{}
-	err = sub.WriteToWebVTT(buf)
-	if err != nil {
-		return http.StatusInternalServerError, err
-	}
-	http.ServeContent(w, r, file.Name, file.ModTime, bytes.NewReader(buf.Bytes()))
-	return 0, nil
+func subtitleCacheKey(f *files.FileInfo, variant string) string {
+	return fmt.Sprintf("%x%xsubtitle%s", f.RealPath(), f.ModTime.Unix(), variant)
 }
@@ -0,0 +1,86 @@
+package fbhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/diskcache"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestSubtitleFileHandler(t *testing.T) {
+	afs := afero.NewBasePathFs(afero.NewMemMapFs(), "/")
+	require.NoError(t, afero.WriteFile(afs, "/a.srt", []byte("1\n00:00:01,000 --> 00:00:02,500\nHello\nworld\n\n2\n00:01:00,000 --> 00:01:01,000\nBye\n"), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/a.ass", []byte(`[Script Info]
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:01.00,0:00:02.50,Default,,0,0,0,,Hello\Nworld
+`), 0644))
+
+	d := &data{settings: &settings.Settings{}, user: &users.User{Fs: afs}}
+	require.NoError(t, afero.WriteFile(afs, "/a.vtt", []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHi\n"), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/a.txt", []byte("text"), 0644))
+
+	fileCache := diskcache.New(afero.NewMemMapFs(), "/")
+	serve := func(name string) (*httptest.ResponseRecorder, int) {
+		file, err := files.NewFileInfo(&files.FileOptions{Fs: afs, Path: name, Checker: d})
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		status, err := subtitleFileHandler(w, httptest.NewRequest(http.MethodGet, "/", nil), fileCache, file)
+		require.NoError(t, err)
+		return w, status
+	}
+
+	testCases := map[string]string{
+		"/a.srt": "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\nworld\n\n2\n00:01:00.000 --> 00:01:01.000\nBye\n",
+		"/a.ass": "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\nworld\n",
+		"/a.vtt": "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nHi\n",
+	}
+	for name, want := range testCases {
+		t.Run(name, func(t *testing.T) {
+			w, status := serve(name)
+			require.Zero(t, status)
+			require.Equal(t, want, w.Body.String())
+			require.Equal(t, "text/vtt", w.Header().Get("Content-Type"))
+		})
+	}
+
+	_, status := serve("/a.txt")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// the conversions are cached, and served from the cache afterwards
+	file, err := files.NewFileInfo(&files.FileOptions{Fs: afs, Path: "/a.srt", Checker: d})
+	require.NoError(t, err)
+	key := subtitleCacheKey(file, "vtt")
+	require.Eventually(t, func() bool {
+		_, ok, err := fileCache.Load(context.Background(), key)
+		return err == nil && ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, fileCache.Store(context.Background(), key, []byte("WEBVTT\n\ncached\n")))
+	w, status := serve("/a.srt")
+	require.Zero(t, status)
+	require.Equal(t, "WEBVTT\n\ncached\n", w.Body.String())
+
+	// the files served as they are aren't
+	file, err = files.NewFileInfo(&files.FileOptions{Fs: afs, Path: "/a.vtt", Checker: d})
+	require.NoError(t, err)
+	_, ok, err := fileCache.Load(context.Background(), subtitleCacheKey(file, "vtt"))
+	require.NoError(t, err)
+	require.False(t, ok)
+}
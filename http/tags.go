@@ -0,0 +1,143 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/tags"
+)
+
+// maxTagsRequestSize limits the size of the tags and metadata of a file.
+const maxTagsRequestSize = 1 << 20
+
+type tagsRequest struct {
+	Tags     []string          `json:"tags"`
+	Starred  bool              `json:"starred"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// tagsResponse holds the entry of the user and the shared one.
+type tagsResponse struct {
+	Path   string      `json:"path"`
+	User   *tags.Entry `json:"user"`
+	Shared *tags.Entry `json:"shared"`
+}
+
+// tagsSearcher gives the tags of the files to search.Search.
+type tagsSearcher struct {
+	d         *data
+	summaries map[string]*tags.Summary
+}
+
+func (t *tagsSearcher) Tags(p string) *tags.Summary {
+	return t.summaries[realPath(t.d.user.Fs, p)]
+}
+
+func newTagsSearcher(d *data) (*tagsSearcher, error) {
+	summaries, err := d.store.Tags.Summaries(d.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &tagsSearcher{d: d, summaries: summaries}, nil
+}
+
+// annotateTags sets the tags of the file and of the files it lists.
+func annotateTags(d *data, file *files.FileInfo) error {
+	summaries, err := d.store.Tags.Summaries(d.user.ID)
+	if err != nil || len(summaries) == 0 {
+		return err
+	}
+
+	file.Summary = summaries[realPath(d.user.Fs, file.Path)]
+	if file.Listing != nil {
+		for _, item := range file.Items {
+			item.Summary = summaries[realPath(d.user.Fs, item.Path)]
+		}
+	}
+
+	return nil
+}
+
+func getTags(d *data, p string) (*tagsResponse, error) {
+	fullPath := realPath(d.user.Fs, p)
+
+	user, err := d.store.Tags.Get(d.user.ID, fullPath)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := d.store.Tags.Get(0, fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// do not expose the paths on the server
+	user.Path, shared.Path = p, p
+	return &tagsResponse{Path: p, User: user, Shared: shared}, nil
+}
+
+// tagsGetHandler returns the tags, the star and the metadata of the file.
+// GET /api/tags/{path}
+var tagsGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	resp, err := getTags(d, p)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, resp)
+})
+
+// tagsPutHandler replaces the entry of the user for the file, or the shared
+// one which requires the permission to modify the files.
+// PUT /api/tags/{path}?shared=true
+var tagsPutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+
+	shared := r.URL.Query().Get("shared") == "true"
+	if shared && !d.user.Perm.Modify {
+		return http.StatusForbidden, nil
+	}
+
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	var req tagsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTagsRequestSize)).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	entry := &tags.Entry{
+		Path:     realPath(d.user.Fs, p),
+		UserID:   d.user.ID,
+		Tags:     req.Tags,
+		Starred:  req.Starred,
+		Metadata: req.Metadata,
+	}
+	if shared {
+		entry.UserID = 0
+	}
+
+	if err := d.store.Tags.Save(entry); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	resp, err := getTags(d, p)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, resp)
+})
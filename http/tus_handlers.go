@@ -1,78 +1,152 @@
 package fbhttp
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/jellydator/ttlcache/v3"
 	"github.com/spf13/afero"
 
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
 	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/uploads"
 )
 
-const maxUploadWait = 3 * time.Minute
+const tusVersion = "1.0.0"
 
-// Tracks active uploads along with their respective upload lengths
-var activeUploads = initActiveUploads()
+// tusExtensions are the extensions of the tus protocol that are implemented.
+const tusExtensions = "creation,termination,concatenation,checksum,expiration"
 
-func initActiveUploads() *ttlcache.Cache[string, int64] {
-	cache := ttlcache.New[string, int64]()
-	cache.OnEviction(func(_ context.Context, reason ttlcache.EvictionReason, item *ttlcache.Item[string, int64]) {
-		if reason == ttlcache.EvictionReasonExpired {
-			fmt.Printf("deleting incomplete upload file: \"%s\"", item.Key())
-			os.Remove(item.Key())
-		}
-	})
-	go cache.Start()
+// statusChecksumMismatch is the status defined by the tus checksum extension
+// for a chunk which doesn't match its checksum.
+const statusChecksumMismatch = 460
 
-	return cache
-}
+// tusChecksumAlgorithms are the algorithms accepted for the Upload-Checksum.
+var tusChecksumAlgorithms = []string{"md5", "sha1", "sha256", "sha512", "blake3"}
 
-func registerUpload(filePath string, fileSize int64) {
-	activeUploads.Set(filePath, fileSize, maxUploadWait)
-}
+// Tracks the uploads whose data is being written, which must not expire
+// meanwhile.
+var transfers sync.Map
 
-func completeUpload(filePath string) {
-	activeUploads.Delete(filePath)
+// ExpireUploads removes the uploads left inactive past their expiration
+// every minute, until the context is done.
+func ExpireUploads(ctx context.Context, store *storage.Storage) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			removeExpiredUploads(store, now)
+		}
+	}
 }
 
-func getActiveUploadLength(filePath string) (int64, error) {
-	item := activeUploads.Get(filePath)
-	if item == nil {
-		return 0, fmt.Errorf("no active upload found for the given path")
+// removeExpiredUploads removes the files of the uploads expired at the
+// given time, except the ones being written.
+func removeExpiredUploads(store *storage.Storage, now time.Time) {
+	list, err := store.Uploads.Expired(now)
+	if err != nil {
+		log.Printf("could not get the expired uploads: %v", err)
+		return
 	}
 
-	return item.Value(), nil
+	for _, u := range list {
+		if _, ok := transfers.Load(u.Path); ok {
+			continue
+		}
+
+		log.Printf("deleting incomplete upload file: %q", u.Path)
+		if err := os.Remove(u.Path); err != nil && !os.IsNotExist(err) {
+			log.Printf("could not delete %q: %v", u.Path, err)
+			continue
+		}
+		if err := store.Uploads.Delete(u.Path); err != nil {
+			log.Printf("could not delete the upload of %q: %v", u.Path, err)
+		}
+	}
 }
 
-func keepUploadActive(filePath string) func() {
-	stop := make(chan bool)
+// saveUpload records the upload with its expiration extended, and tells it
+// to the client.
+func saveUpload(w http.ResponseWriter, d *data, u *uploads.Upload) error {
+	u.Expires = time.Now().Add(d.settings.Tus.GetExpiration())
+	w.Header().Set("Upload-Expires", u.Expires.UTC().Format(http.TimeFormat))
+	return d.store.Uploads.Save(u)
+}
 
-	go func() {
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
+// getUpload returns the upload of the user writing to p.
+func getUpload(d *data, p string) (*uploads.Upload, error) {
+	u, err := d.store.Uploads.Get(realPath(d.user.Fs, p))
+	if err != nil {
+		return nil, err
+	}
+	if u.UserID != d.user.ID {
+		return nil, fberrors.ErrNotExist
+	}
+	return u, nil
+}
 
-		for {
-			select {
-			case <-stop:
-				return
-			case <-ticker.C:
-				activeUploads.Touch(filePath)
-			}
-		}
-	}()
+// completedSize returns the size of the file of a completed upload to p,
+// whose record is gone, or which was cloned from an identical file.
+func completedSize(d *data, p string) (int64, bool) {
+	if _, err := d.store.Uploads.Get(realPath(d.user.Fs, p)); !errors.Is(err, fberrors.ErrNotExist) {
+		return 0, false
+	}
+	info, err := d.user.Fs.Stat(p)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// partPath returns the path of the hidden file receiving a part of an
+// upload to p.
+func partPath(p, id string) string {
+	return path.Join(path.Dir(p), "."+path.Base(p)+".part-"+id)
+}
 
-	return func() {
-		close(stop)
+// tusUploadPath returns the path of the file receiving the data of the
+// request, which is a part file when the request is about a partial upload.
+func tusUploadPath(r *http.Request) (string, error) {
+	id := r.URL.Query().Get("upload")
+	if id == "" {
+		return r.URL.Path, nil
+	}
+	if !uploads.ValidID(id) {
+		return "", fmt.Errorf("invalid upload %q: %w", id, fberrors.ErrInvalidRequestParams)
 	}
+	return partPath(r.URL.Path, id), nil
+}
+
+func tusOptionsHandler(w http.ResponseWriter, _ *http.Request, _ *data) (int, error) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Checksum-Algorithm", strings.Join(tusChecksumAlgorithms, ","))
+	return http.StatusNoContent, nil
 }
 
 func tusPostHandler() handleFunc {
@@ -80,6 +154,36 @@ func tusPostHandler() handleFunc {
 		if !d.user.Perm.Create || !d.Check(r.URL.Path) {
 			return http.StatusForbidden, nil
 		}
+
+		var (
+			parts        []string
+			uploadLength int64
+			err          error
+		)
+		switch concat := r.Header.Get("Upload-Concat"); {
+		case concat == "partial":
+			return tusPostPartial(w, r, d)
+		case strings.HasPrefix(concat, "final;"):
+			parts, err = finalUploadParts(d, strings.TrimPrefix(concat, "final;"))
+			if err != nil {
+				return errToStatus(err), err
+			}
+		case concat != "":
+			return http.StatusBadRequest, fmt.Errorf("invalid upload concatenation %q", concat)
+		default:
+			uploadLength, err = getUploadLength(r)
+			if err != nil {
+				return http.StatusBadRequest, fmt.Errorf("invalid upload length: %w", err)
+			}
+		}
+
+		declared, err := getUploadSha256(r)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		defer lockWrite(d.user.FullPath(r.URL.Path))()
+
 		file, err := files.NewFileInfo(&files.FileOptions{
 			Fs:         d.user.Fs,
 			Path:       r.URL.Path,
@@ -118,7 +222,27 @@ func tusPostHandler() handleFunc {
 				return http.StatusForbidden, nil
 			}
 
+			if current := statVersion(d.user.Fs, r.URL.Path); !checkPreconditions(r, current) {
+				return preconditionFailed(w, r, current)
+			}
+
+			if err := checkLock(d, r.URL.Path, false); err != nil {
+				return errToStatus(err), err
+			}
+
 			fileFlags |= os.O_TRUNC
+		} else if !checkPreconditions(r, nil) {
+			return preconditionFailed(w, r, nil)
+		}
+
+		if declared != "" && parts == nil {
+			if src, ok := findIdentical(d, declared, uploadLength); ok {
+				return tusPostIdentical(w, r, d, src, declared, uploadLength)
+			}
+		}
+
+		if d.Events != nil {
+			defer d.Events.Begin(d.user.FullPath(r.URL.Path))()
 		}
 
 		openFile, err := d.user.Fs.OpenFile(r.URL.Path, fileFlags, d.settings.FileMode)
@@ -127,35 +251,209 @@ func tusPostHandler() handleFunc {
 		}
 		defer openFile.Close()
 
-		file, err = files.NewFileInfo(&files.FileOptions{
-			Fs:         d.user.Fs,
-			Path:       r.URL.Path,
-			Modify:     d.user.Perm.Modify,
-			Expand:     false,
-			ReadHeader: false,
-			Checker:    d,
-			Content:    false,
+		location, err := url.JoinPath("/", d.server.BaseURL, "/api/tus", r.URL.Path)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid path: %w", err)
+		}
+
+		if parts != nil {
+			if err := concatUploads(d, openFile, parts); err != nil {
+				return errToStatus(err), err
+			}
+
+			if status, err := completeUpload(d, r.URL.Path, declared); err != nil {
+				return status, err
+			}
+
+			w.Header().Set("Location", location)
+			return http.StatusCreated, nil
+		}
+
+		// Enables the user to utilize the PATCH endpoint for uploading file data
+		err = saveUpload(w, d, &uploads.Upload{
+			Path:   realPath(d.user.Fs, r.URL.Path),
+			UserID: d.user.ID,
+			Length: uploadLength,
+			Sha256: declared,
 		})
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		w.Header().Set("Location", location)
+		return http.StatusCreated, nil
+	})
+}
+
+// tusPostIdentical completes an upload at once by cloning a file of the
+// user which already has the declared content.
+func tusPostIdentical(w http.ResponseWriter, r *http.Request, d *data, src, sum string, length int64) (int, error) {
+	location, err := url.JoinPath("/", d.server.BaseURL, "/api/tus", r.URL.Path)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid path: %w", err)
+	}
+
+	if src != r.URL.Path {
+		err = d.RunHook(func() error {
+			return fileutils.CloneFile(d.user.Fs, src, r.URL.Path, d.settings.FileMode, d.settings.DirMode)
+		}, "upload", r.URL.Path, "", d.user)
 		if err != nil {
 			return errToStatus(err), err
 		}
+		storeVerifiedChecksum(d, r.URL.Path, sum)
+	}
+
+	w.Header().Set("Location", location)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(length, 10))
+	return http.StatusCreated, nil
+}
+
+// completeUpload verifies the completed upload against the sha256 sum
+// declared for it, if any, then runs its hooks, removing it when a before
+// hook rejects it.
+func completeUpload(d *data, p, declared string) (int, error) {
+	if declared != "" {
+		ok, err := uploadMatches(d, p, declared)
+		switch {
+		case err != nil:
+			return http.StatusInternalServerError, err
+		case !ok:
+			return statusChecksumMismatch, fmt.Errorf("%s doesn't match its declared sha256 %s", p, declared)
+		}
+	}
+
+	err := d.RunHook(func() error {
+		if declared == "" {
+			storeFileChecksum(d, p)
+		} else {
+			storeVerifiedChecksum(d, p, declared)
+		}
+		return nil
+	}, "upload", p, "", d.user)
+
+	var hookErr *runner.HookError
+	if errors.As(err, &hookErr) && hookErr.Rejected() {
+		if rmErr := d.user.Fs.Remove(p); rmErr != nil {
+			log.Printf("could not remove the rejected upload %q: %v", p, rmErr)
+		}
+	}
+	if err != nil {
+		return errToStatus(err), err
+	}
+	return 0, nil
+}
+
+// tusPostPartial creates a partial upload, whose data goes to a part file
+// until a final upload concatenates it with the other parts.
+func tusPostPartial(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	uploadLength, err := getUploadLength(r)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid upload length: %w", err)
+	}
+
+	if err := d.user.Fs.MkdirAll(path.Dir(r.URL.Path), d.settings.DirMode); err != nil {
+		return errToStatus(err), err
+	}
+
+	id, err := uploads.NewID()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	part := partPath(r.URL.Path, id)
+	partFile, err := d.user.Fs.OpenFile(part, os.O_CREATE|os.O_EXCL|os.O_WRONLY, d.settings.FileMode)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	partFile.Close()
+
+	err = saveUpload(w, d, &uploads.Upload{
+		Path:    realPath(d.user.Fs, part),
+		UserID:  d.user.ID,
+		Length:  uploadLength,
+		Partial: true,
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	location, err := url.JoinPath("/", d.server.BaseURL, "/api/tus", r.URL.Path)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("invalid path: %w", err)
+	}
+
+	w.Header().Set("Location", location+"?upload="+id)
+	return http.StatusCreated, nil
+}
+
+// finalUploadParts returns the part files of the space separated partial
+// upload URLs of a final upload, which must all be complete.
+func finalUploadParts(d *data, urls string) ([]string, error) {
+	prefix := path.Join("/", d.server.BaseURL, "/api/tus")
+
+	parts := []string{}
+	for _, raw := range strings.Fields(urls) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid partial upload %q: %w", raw, fberrors.ErrInvalidRequestParams)
+		}
+
+		p, ok := strings.CutPrefix(u.Path, prefix)
+		id := u.Query().Get("upload")
+		if !ok || p == "" || !uploads.ValidID(id) || !d.Check(p) {
+			return nil, fmt.Errorf("invalid partial upload %q: %w", raw, fberrors.ErrInvalidRequestParams)
+		}
+
+		part := partPath(p, id)
+		upload, err := getUpload(d, part)
+		if err != nil {
+			return nil, err
+		}
+		if !upload.Partial {
+			return nil, fmt.Errorf("%q is not a partial upload: %w", raw, fberrors.ErrInvalidRequestParams)
+		}
 
-		uploadLength, err := getUploadLength(r)
+		info, err := d.user.Fs.Stat(part)
 		if err != nil {
-			return http.StatusBadRequest, fmt.Errorf("invalid upload length: %w", err)
+			return nil, err
+		}
+		if info.Size() != upload.Length {
+			return nil, fmt.Errorf("partial upload %q is unfinished: %w", raw, fberrors.ErrInvalidRequestParams)
 		}
 
-		// Enables the user to utilize the PATCH endpoint for uploading file data
-		registerUpload(file.RealPath(), uploadLength)
+		parts = append(parts, part)
+	}
 
-		path, err := url.JoinPath("/", d.server.BaseURL, "/api/tus", r.URL.Path)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no partial uploads to concatenate: %w", fberrors.ErrInvalidRequestParams)
+	}
+	return parts, nil
+}
+
+// concatUploads writes the parts one after the other to dst and removes them.
+func concatUploads(d *data, dst afero.File, parts []string) error {
+	for _, part := range parts {
+		src, err := d.user.Fs.Open(part)
 		if err != nil {
-			return http.StatusBadRequest, fmt.Errorf("invalid path: %w", err)
+			return err
 		}
+		_, err = io.Copy(dst, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
 
-		w.Header().Set("Location", path)
-		return http.StatusCreated, nil
-	})
+	for _, part := range parts {
+		if err := d.user.Fs.Remove(part); err != nil {
+			return err
+		}
+		if err := d.store.Uploads.Delete(realPath(d.user.Fs, part)); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func tusHeadHandler() handleFunc {
@@ -165,25 +463,33 @@ func tusHeadHandler() handleFunc {
 			return http.StatusForbidden, nil
 		}
 
-		file, err := files.NewFileInfo(&files.FileOptions{
-			Fs:         d.user.Fs,
-			Path:       r.URL.Path,
-			Modify:     d.user.Perm.Modify,
-			Expand:     false,
-			ReadHeader: d.server.TypeDetectionByHeader,
-			Checker:    d,
-		})
+		p, err := tusUploadPath(r)
 		if err != nil {
 			return errToStatus(err), err
 		}
 
-		uploadLength, err := getActiveUploadLength(file.RealPath())
+		upload, err := getUpload(d, p)
 		if err != nil {
-			return http.StatusNotFound, err
+			size, ok := completedSize(d, r.URL.Path)
+			if !ok || p != r.URL.Path {
+				return http.StatusNotFound, err
+			}
+			w.Header().Set("Upload-Offset", strconv.FormatInt(size, 10))
+			w.Header().Set("Upload-Length", strconv.FormatInt(size, 10))
+			return http.StatusOK, nil
 		}
 
-		w.Header().Set("Upload-Offset", strconv.FormatInt(file.Size, 10))
-		w.Header().Set("Upload-Length", strconv.FormatInt(uploadLength, 10))
+		info, err := d.user.Fs.Stat(p)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if upload.Partial {
+			w.Header().Set("Upload-Concat", "partial")
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(info.Size(), 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
 
 		return http.StatusOK, nil
 	})
@@ -203,43 +509,60 @@ func tusPatchHandler() handleFunc {
 			return http.StatusBadRequest, fmt.Errorf("invalid upload offset")
 		}
 
-		file, err := files.NewFileInfo(&files.FileOptions{
-			Fs:         d.user.Fs,
-			Path:       r.URL.Path,
-			Modify:     d.user.Perm.Modify,
-			Expand:     false,
-			ReadHeader: d.server.TypeDetectionByHeader,
-			Checker:    d,
-		})
+		checksum, sum, err := getUploadChecksum(r)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
 
-		switch {
-		case errors.Is(err, afero.ErrFileNotFound):
-			return http.StatusNotFound, nil
-		case err != nil:
+		p, err := tusUploadPath(r)
+		if err != nil {
 			return errToStatus(err), err
 		}
 
-		uploadLength, err := getActiveUploadLength(file.RealPath())
+		upload, err := getUpload(d, p)
 		if err != nil {
-			return http.StatusNotFound, err
+			// nothing is left to write to a completed upload
+			size, ok := completedSize(d, r.URL.Path)
+			if !ok || p != r.URL.Path || uploadOffset != size || r.ContentLength != 0 {
+				return http.StatusNotFound, err
+			}
+			w.Header().Set("Upload-Offset", strconv.FormatInt(size, 10))
+			return http.StatusNoContent, nil
 		}
 
-		// Prevent the upload from being evicted during the transfer
-		stop := keepUploadActive(file.RealPath())
-		defer stop()
+		// the file may have been locked since the upload was created
+		if !upload.Partial {
+			if err := checkLock(d, p, false); err != nil {
+				return errToStatus(err), err
+			}
+		}
 
+		info, err := d.user.Fs.Stat(p)
 		switch {
-		case file.IsDir:
-			return http.StatusBadRequest, fmt.Errorf("cannot upload to a directory %s", file.RealPath())
-		case file.Size != uploadOffset:
+		case errors.Is(err, afero.ErrFileNotFound):
+			return http.StatusNotFound, nil
+		case err != nil:
+			return errToStatus(err), err
+		case info.IsDir():
+			return http.StatusBadRequest, fmt.Errorf("cannot upload to a directory %s", upload.Path)
+		case info.Size() != uploadOffset:
 			return http.StatusConflict, fmt.Errorf(
 				"%s file size doesn't match the provided offset: %d",
-				file.RealPath(),
+				upload.Path,
 				uploadOffset,
 			)
 		}
 
-		openFile, err := d.user.Fs.OpenFile(r.URL.Path, os.O_WRONLY|os.O_APPEND, d.settings.FileMode)
+		// Prevent the upload from expiring during the transfer
+		transfers.Store(upload.Path, struct{}{})
+		defer transfers.Delete(upload.Path)
+
+		// the upload is announced once it completes
+		if d.Events != nil && !upload.Partial {
+			defer d.Events.Begin(d.user.FullPath(r.URL.Path))()
+		}
+
+		openFile, err := d.user.Fs.OpenFile(p, os.O_WRONLY|os.O_APPEND, d.settings.FileMode)
 		if err != nil {
 			return http.StatusInternalServerError, fmt.Errorf("could not open file: %w", err)
 		}
@@ -250,8 +573,22 @@ func tusPatchHandler() handleFunc {
 			return http.StatusInternalServerError, fmt.Errorf("could not seek file: %w", err)
 		}
 
+		var dst io.Writer = openFile
+		if checksum != nil {
+			dst = io.MultiWriter(openFile, checksum)
+		}
+
 		defer r.Body.Close()
-		bytesWritten, err := io.Copy(openFile, r.Body)
+		bytesWritten, err := io.Copy(dst, r.Body)
+		if checksum != nil && (err != nil || !bytes.Equal(checksum.Sum(nil), sum)) {
+			// the chunk is discarded so that the client sends it again
+			if truncErr := openFile.Truncate(uploadOffset); truncErr != nil {
+				return http.StatusInternalServerError, fmt.Errorf("could not discard the chunk: %w", truncErr)
+			}
+			if err == nil {
+				return statusChecksumMismatch, fmt.Errorf("%s chunk at offset %d doesn't match its checksum", upload.Path, uploadOffset)
+			}
+		}
 		if err != nil {
 			return http.StatusInternalServerError, fmt.Errorf("could not write to file: %w", err)
 		}
@@ -259,9 +596,18 @@ func tusPatchHandler() handleFunc {
 		newOffset := uploadOffset + bytesWritten
 		w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
 
-		if newOffset >= uploadLength {
-			completeUpload(file.RealPath())
-			_ = d.RunHook(func() error { return nil }, "upload", r.URL.Path, "", d.user)
+		switch {
+		case newOffset < upload.Length, upload.Partial:
+			if err := saveUpload(w, d, upload); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		default:
+			if err := d.store.Uploads.Delete(upload.Path); err != nil {
+				return http.StatusInternalServerError, err
+			}
+			if status, err := completeUpload(d, r.URL.Path, upload.Sha256); err != nil {
+				return status, err
+			}
 		}
 
 		return http.StatusNoContent, nil
@@ -270,44 +616,42 @@ func tusPatchHandler() handleFunc {
 
 func tusDeleteHandler() handleFunc {
 	return withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
-		if r.URL.Path == "/" || !d.user.Perm.Create {
+		if r.URL.Path == "/" || !d.user.Perm.Create || !d.Check(r.URL.Path) {
 			return http.StatusForbidden, nil
 		}
 
-		file, err := files.NewFileInfo(&files.FileOptions{
-			Fs:         d.user.Fs,
-			Path:       r.URL.Path,
-			Modify:     d.user.Perm.Modify,
-			Expand:     false,
-			ReadHeader: d.server.TypeDetectionByHeader,
-			Checker:    d,
-		})
+		p, err := tusUploadPath(r)
 		if err != nil {
 			return errToStatus(err), err
 		}
 
-		_, err = getActiveUploadLength(file.RealPath())
+		upload, err := getUpload(d, p)
 		if err != nil {
 			return http.StatusNotFound, err
 		}
 
-		err = d.user.Fs.RemoveAll(r.URL.Path)
+		err = d.user.Fs.RemoveAll(p)
 		if err != nil {
 			return errToStatus(err), err
 		}
 
-		completeUpload(file.RealPath())
+		if err := d.store.Uploads.Delete(upload.Path); err != nil {
+			return http.StatusInternalServerError, err
+		}
 
 		return http.StatusNoContent, nil
 	})
 }
 
 func getUploadLength(r *http.Request) (int64, error) {
-	uploadOffset, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	uploadLength, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
 	if err != nil {
 		return 0, fmt.Errorf("invalid upload length: %w", err)
 	}
-	return uploadOffset, nil
+	if uploadLength < 0 {
+		return 0, fmt.Errorf("negative upload length %d", uploadLength)
+	}
+	return uploadLength, nil
 }
 
 func getUploadOffset(r *http.Request) (int64, error) {
@@ -315,5 +659,57 @@ func getUploadOffset(r *http.Request) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("invalid upload offset: %w", err)
 	}
+	if uploadOffset < 0 {
+		return 0, fmt.Errorf("negative upload offset %d", uploadOffset)
+	}
 	return uploadOffset, nil
 }
+
+// getUploadChecksum returns the hash to compute over the chunk and the sum
+// it must match, if the request has an Upload-Checksum.
+func getUploadChecksum(r *http.Request) (hash.Hash, []byte, error) {
+	header := r.Header.Get("Upload-Checksum")
+	if header == "" {
+		return nil, nil, nil
+	}
+
+	algo, encoded, _ := strings.Cut(header, " ")
+	if !slices.Contains(tusChecksumAlgorithms, algo) {
+		return nil, nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid upload checksum: %w", err)
+	}
+
+	h, err := files.NewHash(algo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, sum, nil
+}
+
+// getUploadSha256 returns the sha256 sum declared for the whole upload in
+// its Upload-Metadata, made of comma separated keys each followed by its
+// base64 encoded value.
+func getUploadSha256(r *http.Request) (string, error) {
+	for _, pair := range strings.Split(r.Header.Get("Upload-Metadata"), ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key != "sha256" {
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("invalid upload sha256: %w", err)
+		}
+		sum := strings.ToLower(string(value))
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return "", fmt.Errorf("invalid upload sha256 %q", sum)
+		}
+		return sum, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,177 @@
+//go:build !windows
+
+package fbhttp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestTusUploadRejectedByHook(t *testing.T) {
+	ts := newTestServer(t, func(set *settings.Settings, server *settings.Server) {
+		server.EnableExec = true
+		set.Shell = []string{"sh", "-c"}
+		set.Commands = map[string][]string{"before_upload": {
+			`case "$FILE" in *.exe) echo '{"reject": true, "message": "no executables"}';; esac`,
+		}}
+	})
+
+	for _, name := range []string{"a.exe", "a.txt"} {
+		resp := ts.do(t, http.MethodPost, "/api/tus/"+name, nil, "Upload-Length", "2")
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		resp = ts.do(t, http.MethodPatch, "/api/tus/"+name, strings.NewReader("hi"),
+			"Content-Type", "application/offset+octet-stream", "Upload-Offset", "0")
+
+		if name == "a.txt" {
+			require.Equal(t, http.StatusNoContent, resp.StatusCode)
+			require.FileExists(t, filepath.Join(ts.root, name))
+			continue
+		}
+
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		var body struct {
+			Message string `json:"message"`
+			Hook    struct {
+				Event   string `json:"event"`
+				Message string `json:"message"`
+			} `json:"hook"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.Equal(t, "before_upload", body.Hook.Event)
+		require.Equal(t, "no executables", body.Hook.Message)
+		require.NoFileExists(t, filepath.Join(ts.root, name))
+	}
+}
+
+func TestTusInvalidUploadLength(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, length := range []string{"-1", "abc", ""} {
+		resp := ts.do(t, http.MethodPost, "/api/tus/a.txt", nil, "Upload-Length", length)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, length)
+		resp = ts.do(t, http.MethodPost, "/api/tus/a.txt", nil, "Upload-Length", length, "Upload-Concat", "partial")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, length)
+	}
+	require.NoFileExists(t, filepath.Join(ts.root, "a.txt"))
+}
+
+func TestTusConcatenation(t *testing.T) {
+	ts := newTestServer(t)
+
+	var locations []string
+	for _, content := range []string{"hello ", "world"} {
+		resp := ts.do(t, http.MethodPost, "/api/tus/dir/a.txt", nil,
+			"Upload-Length", strconv.Itoa(len(content)), "Upload-Concat", "partial")
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		location := resp.Header.Get("Location")
+		require.Contains(t, location, "/api/tus/dir/a.txt?upload=")
+		locations = append(locations, location)
+
+		resp = ts.do(t, http.MethodHead, location, nil)
+		require.Equal(t, "partial", resp.Header.Get("Upload-Concat"))
+
+		// an unfinished part can't be concatenated
+		resp = ts.do(t, http.MethodPost, "/api/tus/dir/a.txt", nil,
+			"Upload-Concat", "final;"+strings.Join(locations, " "))
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = ts.do(t, http.MethodPatch, location, strings.NewReader(content),
+			"Content-Type", "application/offset+octet-stream", "Upload-Offset", "0")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+
+	resp := ts.do(t, http.MethodPost, "/api/tus/dir/a.txt", nil, "Upload-Concat", "final;/api/tus/dir/a.txt")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = ts.do(t, http.MethodPost, "/api/tus/dir/a.txt", nil,
+		"Upload-Concat", "final;"+strings.Join(locations, " "))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	content, err := os.ReadFile(filepath.Join(ts.root, "dir/a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(content))
+
+	// the parts are gone
+	entries, err := os.ReadDir(filepath.Join(ts.root, "dir"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	for _, location := range locations {
+		resp = ts.do(t, http.MethodHead, location, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestTusChecksumMismatch(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.do(t, http.MethodPost, "/api/tus/a.txt", nil, "Upload-Length", "4")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	checksum := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	patch := func(offset, content, sum string) *http.Response {
+		return ts.do(t, http.MethodPatch, "/api/tus/a.txt", strings.NewReader(content),
+			"Content-Type", "application/offset+octet-stream", "Upload-Offset", offset, "Upload-Checksum", sum)
+	}
+
+	resp = patch("0", "ab", checksum("xx"))
+	require.Equal(t, statusChecksumMismatch, resp.StatusCode)
+
+	// the chunk is discarded
+	resp = ts.do(t, http.MethodHead, "/api/tus/a.txt", nil)
+	require.Equal(t, "0", resp.Header.Get("Upload-Offset"))
+
+	resp = patch("0", "ab", "crc32 AAAA")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = patch("0", "ab", checksum("ab"))
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get("Upload-Offset"))
+	resp = patch("2", "cd", checksum("cd"))
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	content, err := os.ReadFile(filepath.Join(ts.root, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "abcd", string(content))
+}
+
+func TestTusExpiration(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, name := range []string{"old.txt", "busy.txt"} {
+		resp := ts.do(t, http.MethodPost, "/api/tus/"+name, nil, "Upload-Length", "4")
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get("Upload-Expires"))
+	}
+
+	// nothing has expired yet
+	removeExpiredUploads(ts.store, time.Now())
+	require.FileExists(t, filepath.Join(ts.root, "old.txt"))
+
+	busy := filepath.Join(ts.root, "busy.txt")
+	transfers.Store(busy, struct{}{})
+	defer transfers.Delete(busy)
+
+	removeExpiredUploads(ts.store, time.Now().Add(time.Hour*24*365))
+	require.NoFileExists(t, filepath.Join(ts.root, "old.txt"))
+	resp := ts.do(t, http.MethodHead, "/api/tus/old.txt", nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.FileExists(t, busy)
+	resp = ts.do(t, http.MethodHead, "/api/tus/busy.txt", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,452 @@
+package fbhttp
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+const (
+	defaultUsageDepth = 2
+	maxUsageDepth     = 10
+	defaultUsageTop   = 20
+	maxUsageTop       = 1000
+)
+
+// usageCacheTTL is how long a usage report is kept, the changes made out of
+// File Browser not being always noticed.
+const usageCacheTTL = 10 * time.Minute
+
+// maxUsageChildren is the number of largest children listed for every
+// directory, the others being only counted in its size.
+const maxUsageChildren = 100
+
+// maxUsageReports is the number of usage reports kept in the cache.
+const maxUsageReports = 256
+
+// usageAges are the buckets of the age histogram, by modification time.
+var usageAges = []struct {
+	name string
+	age  time.Duration
+}{
+	{"day", 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"year", 365 * 24 * time.Hour},
+	{"older", 0},
+}
+
+// usageNode is the usage of a directory, with the usage of its largest
+// children up to the requested depth, the largest first.
+type usageNode struct {
+	Name     string       `json:"name"`
+	Path     string       `json:"path"`
+	IsDir    bool         `json:"isDir"`
+	Size     int64        `json:"size"`
+	NumFiles int64        `json:"numFiles"`
+	NumDirs  int64        `json:"numDirs"`
+	Children []*usageNode `json:"children,omitempty"`
+}
+
+type usageFile struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// usageBucket is the usage of the files of an age, a type or an extension.
+type usageBucket struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	NumFiles int64  `json:"numFiles"`
+}
+
+type usageReport struct {
+	Tree       *usageNode     `json:"tree"`
+	Largest    []*usageFile   `json:"largest"`
+	Ages       []*usageBucket `json:"ages"`
+	Types      []*usageBucket `json:"types"`
+	Extensions []*usageBucket `json:"extensions"`
+	Computed   time.Time      `json:"computed"`
+}
+
+type usageKey struct {
+	userID     uint
+	path       string // full path on the server
+	depth, top int
+}
+
+// usageCache keeps the usage reports until a change is made in their tree.
+type usageCache struct {
+	mu      sync.Mutex
+	reports map[usageKey]*usageReport
+}
+
+func newUsageCache() *usageCache {
+	return &usageCache{reports: map[usageKey]*usageReport{}}
+}
+
+func (c *usageCache) get(key usageKey) *usageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report, ok := c.reports[key]
+	if !ok || time.Since(report.Computed) > usageCacheTTL {
+		return nil
+	}
+	return report
+}
+
+func (c *usageCache) put(key usageKey, report *usageReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.reports) >= maxUsageReports {
+		var oldest usageKey
+		for k, r := range c.reports {
+			if oldest.path == "" || r.Computed.Before(c.reports[oldest].Computed) {
+				oldest = k
+			}
+		}
+		delete(c.reports, oldest)
+	}
+	c.reports[key] = report
+}
+
+// invalidate drops the reports of the trees changed by the event.
+func (c *usageCache) invalidate(e events.Event) {
+	switch e.Type {
+	case events.Created, events.Modified, events.Deleted, events.Renamed, events.Copied, events.Uploaded:
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.reports {
+		for _, p := range []string{e.Path, e.Destination} {
+			if p != "" && (pathWithin(p, key.path) || pathWithin(key.path, p)) {
+				delete(c.reports, key)
+				break
+			}
+		}
+	}
+}
+
+// pathWithin reports whether the full path p is dir or is inside it.
+func pathWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// usageTreeHandler reports what fills the directory: the size of its tree
+// down to a depth, its largest files and the sizes by age and by type.
+// GET /api/usagetree/{dir}?depth=2&top=20&refresh=true&async=true
+func usageTreeHandler(cache *usageCache, jobManager *jobs.Manager) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		dir := path.Clean("/" + r.URL.Path)
+		if !d.Check(dir) {
+			return http.StatusForbidden, nil
+		}
+
+		info, err := d.user.Fs.Stat(dir)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if !info.IsDir() {
+			return http.StatusBadRequest, errors.New("not a directory")
+		}
+
+		query := r.URL.Query()
+		depth, err := queryInt(query.Get("depth"), defaultUsageDepth, 0, maxUsageDepth)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		top, err := queryInt(query.Get("top"), defaultUsageTop, 0, maxUsageTop)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		key := usageKey{userID: d.user.ID, path: realPath(d.user.Fs, dir), depth: depth, top: top}
+		if query.Get("refresh") != "true" {
+			if report := cache.get(key); report != nil {
+				return renderJSON(w, r, report)
+			}
+		}
+
+		if query.Get("async") == "true" {
+			return submitJob(w, r, d, jobManager, "usagetree", map[string]string{
+				"path":  dir,
+				"depth": strconv.Itoa(depth),
+				"top":   strconv.Itoa(top),
+			})
+		}
+
+		report, err := computeUsage(r.Context(), d, dir, depth, top)
+		if err != nil {
+			if errorsIsCanceled(err) {
+				return 0, err
+			}
+			return errToStatus(err), err
+		}
+		cache.put(key, report)
+
+		return renderJSON(w, r, report)
+	})
+}
+
+func usageTreeJob(cache *usageCache) jobFunc {
+	return func(ctx context.Context, d *data, job *jobs.Job, _ *jobs.Tracker) (interface{}, error) {
+		dir := job.Params["path"]
+		if !d.Check(dir) {
+			return nil, fberrors.ErrPermissionDenied
+		}
+
+		depth, err := queryInt(job.Params["depth"], defaultUsageDepth, 0, maxUsageDepth)
+		if err != nil {
+			return nil, err
+		}
+		top, err := queryInt(job.Params["top"], defaultUsageTop, 0, maxUsageTop)
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := computeUsage(ctx, d, dir, depth, top)
+		if err != nil {
+			return nil, err
+		}
+		cache.put(usageKey{userID: d.user.ID, path: realPath(d.user.Fs, dir), depth: depth, top: top}, report)
+
+		return report, nil
+	}
+}
+
+// queryInt parses an integer parameter between lowest and highest.
+func queryInt(value string, fallback, lowest, highest int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lowest || n > highest {
+		return 0, fberrors.ErrInvalidRequestParams
+	}
+	return n, nil
+}
+
+// usageScan accumulates the statistics of the files of the tree.
+type usageScan struct {
+	ctx     context.Context
+	afs     afero.Fs
+	checker interface{ Check(string) bool }
+	now     time.Time
+
+	largest    largestFiles
+	top        int
+	ages       []*usageBucket
+	types      map[string]*usageBucket
+	extensions map[string]*usageBucket
+}
+
+func computeUsage(ctx context.Context, d *data, dir string, depth, top int) (*usageReport, error) {
+	scan := &usageScan{
+		ctx:        ctx,
+		afs:        d.user.Fs,
+		checker:    d,
+		now:        time.Now(),
+		top:        top,
+		types:      map[string]*usageBucket{},
+		extensions: map[string]*usageBucket{},
+	}
+	for _, age := range usageAges {
+		scan.ages = append(scan.ages, &usageBucket{Name: age.name})
+	}
+
+	tree := &usageNode{Name: path.Base(dir), Path: dir, IsDir: true}
+	if err := scan.walk(tree, depth); err != nil {
+		return nil, err
+	}
+
+	largest := make([]*usageFile, len(scan.largest))
+	for i := len(largest) - 1; i >= 0; i-- {
+		largest[i] = heap.Pop(&scan.largest).(*usageFile)
+	}
+
+	return &usageReport{
+		Tree:       tree,
+		Largest:    largest,
+		Ages:       scan.ages,
+		Types:      sortedBuckets(scan.types, 0),
+		Extensions: sortedBuckets(scan.extensions, top),
+		Computed:   scan.now,
+	}, nil
+}
+
+// walk computes the usage of the directory, keeping its children while
+// depth is positive.
+func (s *usageScan) walk(node *usageNode, depth int) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := afero.ReadDir(s.afs, node.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		child := path.Join(node.Path, entry.Name())
+		if !s.checker.Check(child) {
+			continue
+		}
+
+		if entry.IsDir() {
+			sub := &usageNode{Name: entry.Name(), Path: child, IsDir: true}
+			if !isSymlinkDir(s.afs, child, entry) {
+				if err := s.walk(sub, depth-1); err != nil {
+					return err
+				}
+			}
+
+			node.Size += sub.Size
+			node.NumFiles += sub.NumFiles
+			node.NumDirs += sub.NumDirs + 1
+			if depth > 0 {
+				node.Children = append(node.Children, sub)
+			}
+			continue
+		}
+
+		s.addFile(child, entry)
+		node.Size += entry.Size()
+		node.NumFiles++
+		if depth > 0 {
+			node.Children = append(node.Children, &usageNode{Name: entry.Name(), Path: child, Size: entry.Size()})
+		}
+	}
+
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		return node.Children[i].Size > node.Children[j].Size
+	})
+	if len(node.Children) > maxUsageChildren {
+		node.Children = node.Children[:maxUsageChildren]
+	}
+	return nil
+}
+
+func (s *usageScan) addFile(p string, info os.FileInfo) {
+	size := info.Size()
+
+	if s.top > 0 && (len(s.largest) < s.top || size > s.largest[0].Size) {
+		if len(s.largest) == s.top {
+			heap.Pop(&s.largest)
+		}
+		heap.Push(&s.largest, &usageFile{Path: p, Size: size, Modified: info.ModTime()})
+	}
+
+	age := s.now.Sub(info.ModTime())
+	for i, bucket := range usageAges {
+		if bucket.age == 0 || age < bucket.age {
+			s.ages[i].Size += size
+			s.ages[i].NumFiles++
+			break
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(p))
+	addToBucket(s.types, usageType(ext), size)
+	if ext == "" {
+		ext = "none"
+	}
+	addToBucket(s.extensions, ext, size)
+}
+
+// usageType returns the type of the files of the extension.
+func usageType(ext string) string {
+	switch ext {
+	case ".zip", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".7z", ".rar", ".lz4", ".br", ".sz":
+		return "archive"
+	}
+
+	mimetype := mime.TypeByExtension(ext)
+	switch {
+	case strings.HasPrefix(mimetype, "video"):
+		return "video"
+	case strings.HasPrefix(mimetype, "audio"):
+		return "audio"
+	case strings.HasPrefix(mimetype, "image"):
+		return "image"
+	case strings.HasSuffix(mimetype, "pdf"):
+		return "pdf"
+	case strings.HasPrefix(mimetype, "text"):
+		return "text"
+	default:
+		return "other"
+	}
+}
+
+func addToBucket(buckets map[string]*usageBucket, name string, size int64) {
+	bucket, ok := buckets[name]
+	if !ok {
+		bucket = &usageBucket{Name: name}
+		buckets[name] = bucket
+	}
+	bucket.Size += size
+	bucket.NumFiles++
+}
+
+// sortedBuckets returns the buckets from the largest, at most limit of them
+// if it is positive.
+func sortedBuckets(buckets map[string]*usageBucket, limit int) []*usageBucket {
+	list := make([]*usageBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		list = append(list, bucket)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Size != list[j].Size {
+			return list[i].Size > list[j].Size
+		}
+		return list[i].Name < list[j].Name
+	})
+
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// largestFiles is a min-heap of the largest files found.
+type largestFiles []*usageFile
+
+func (h largestFiles) Len() int           { return len(h) }
+func (h largestFiles) Less(i, j int) bool { return h[i].Size < h[j].Size }
+func (h largestFiles) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *largestFiles) Push(x interface{}) {
+	*h = append(*h, x.(*usageFile))
+}
+
+func (h *largestFiles) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
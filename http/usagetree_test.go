@@ -0,0 +1,56 @@
+package fbhttp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestComputeUsage(t *testing.T) {
+	afs := afero.NewBasePathFs(afero.NewMemMapFs(), "/")
+	require.NoError(t, afero.WriteFile(afs, "/a.txt", make([]byte, 10), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/docs/b.pdf", make([]byte, 100), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/docs/deep/c.png", make([]byte, 1000), 0644))
+	require.NoError(t, afero.WriteFile(afs, "/secret/d.bin", make([]byte, 5000), 0644))
+
+	d := &data{
+		settings: &settings.Settings{},
+		user: &users.User{
+			Fs:    afs,
+			Rules: []rules.Rule{{Allow: false, Path: "/secret"}},
+		},
+	}
+
+	report, err := computeUsage(context.Background(), d, "/", 1, 2)
+	require.NoError(t, err)
+
+	tree := report.Tree
+	require.Equal(t, int64(1110), tree.Size)
+	require.Equal(t, int64(3), tree.NumFiles)
+	require.Equal(t, int64(2), tree.NumDirs)
+	require.Len(t, tree.Children, 2)
+	require.Equal(t, "/docs", tree.Children[0].Path)
+	require.Equal(t, int64(1100), tree.Children[0].Size)
+	require.Empty(t, tree.Children[0].Children)
+
+	require.Len(t, report.Largest, 2)
+	require.Equal(t, "/docs/deep/c.png", report.Largest[0].Path)
+	require.Equal(t, "/docs/b.pdf", report.Largest[1].Path)
+	require.Equal(t, "image", report.Types[0].Name)
+	require.Equal(t, int64(3), report.Ages[0].NumFiles)
+
+	cache := newUsageCache()
+	key := usageKey{path: "/srv/docs", depth: 1}
+	cache.put(key, report)
+	cache.invalidate(events.Event{Type: events.Uploaded, Path: "/srv/other/a.txt"})
+	require.NotNil(t, cache.get(key))
+	cache.invalidate(events.Event{Type: events.Renamed, Path: "/srv/x", Destination: "/srv/docs/x"})
+	require.Nil(t, cache.get(key))
+}
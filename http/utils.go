@@ -8,8 +8,11 @@ import (
 	"os"
 	"strings"
 
+	"github.com/spf13/afero"
+
 	libErrors "github.com/filebrowser/filebrowser/v2/errors"
 	imgErrors "github.com/filebrowser/filebrowser/v2/img"
+	"github.com/filebrowser/filebrowser/v2/runner"
 )
 
 func renderJSON(w http.ResponseWriter, _ *http.Request, data interface{}) (int, error) {
@@ -28,6 +31,7 @@ func renderJSON(w http.ResponseWriter, _ *http.Request, data interface{}) (int,
 }
 
 func errToStatus(err error) int {
+	var hookErr *runner.HookError
 	switch {
 	case err == nil:
 		return http.StatusOK
@@ -43,8 +47,17 @@ func errToStatus(err error) int {
 		return http.StatusBadRequest
 	case errors.Is(err, libErrors.ErrRootUserDeletion):
 		return http.StatusForbidden
+	case errors.Is(err, libErrors.ErrLocked):
+		return http.StatusLocked
+	case errors.As(err, &hookErr):
+		if hookErr.Rejected() {
+			return http.StatusForbidden
+		}
+		return http.StatusInternalServerError
 	case errors.Is(err, imgErrors.ErrImageTooLarge):
 		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, imgErrors.ErrGPSNotStripped):
+		return http.StatusUnsupportedMediaType
 	default:
 		return http.StatusInternalServerError
 	}
@@ -69,3 +82,16 @@ func stripPrefix(prefix string, h http.Handler) http.Handler {
 		h.ServeHTTP(w, r2)
 	})
 }
+
+// realPath returns the path of the file on the server, with which the data
+// about the files is stored since the users have different scopes.
+func realPath(afs afero.Fs, p string) string {
+	if realPathFs, ok := afs.(interface {
+		RealPath(name string) (string, error)
+	}); ok {
+		if fullPath, err := realPathFs.RealPath(p); err == nil {
+			return fullPath
+		}
+	}
+	return p
+}
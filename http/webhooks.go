@@ -0,0 +1,350 @@
+package fbhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// webhookEvents maps the types of events to the events of the webhooks.
+var webhookEvents = map[string]string{
+	events.Uploaded: "upload",
+	events.Modified: "save",
+	events.Renamed:  "rename",
+	events.Copied:   "copy",
+	events.Deleted:  "delete",
+	events.Shared:   "share",
+	events.LoggedIn: "login",
+}
+
+// webhookPayload is the body posted to the webhooks, with the paths from
+// the root.
+type webhookPayload struct {
+	Event       string    `json:"event"`
+	Path        string    `json:"path,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// webhookDispatcher queues the deliveries of the events to the webhooks
+// and sends them, attempting again the failed ones with a backoff.
+type webhookDispatcher struct {
+	store  *storage.Storage
+	server *settings.Server
+	client *http.Client
+	wake   chan struct{}
+}
+
+func newWebhookDispatcher(store *storage.Storage, server *settings.Server) *webhookDispatcher {
+	return &webhookDispatcher{
+		store:  store,
+		server: server,
+		client: &http.Client{Timeout: 10 * time.Second},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// queue is the events listener saving the deliveries of the event to the
+// webhooks configured for it.
+func (wd *webhookDispatcher) queue(e events.Event) {
+	evt, ok := webhookEvents[e.Type]
+	if !ok {
+		return
+	}
+
+	set, err := wd.store.Settings.Get()
+	if err != nil {
+		log.Printf("WARNING: couldn't queue the webhooks: %v", err)
+		return
+	}
+
+	var payload []byte
+	for _, hook := range set.Webhooks {
+		if !wd.matches(hook, evt, e) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(webhookPayload{
+				Event:       evt,
+				Path:        wd.rootPath(e.Path),
+				Destination: wd.rootPath(e.Destination),
+				User:        e.User,
+				Time:        e.Time,
+			})
+			if err != nil {
+				log.Printf("WARNING: couldn't queue the webhooks: %v", err)
+				return
+			}
+		}
+
+		err := wd.store.Webhooks.Save(&webhooks.Delivery{
+			Webhook:     hook.Name,
+			Event:       evt,
+			Payload:     payload,
+			Status:      webhooks.Pending,
+			NextAttempt: time.Now(),
+		})
+		if err != nil {
+			log.Printf("WARNING: couldn't queue the webhook %s: %v", hook.Name, err)
+		}
+	}
+
+	if payload != nil {
+		select {
+		case wd.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (wd *webhookDispatcher) matches(hook settings.Webhook, evt string, e events.Event) bool {
+	if len(hook.Events) > 0 && !slices.Contains(hook.Events, evt) {
+		return false
+	}
+	if hook.Path == "" || hook.Path == "/" {
+		return true
+	}
+
+	prefix := filepath.Join(wd.server.Root, filepath.FromSlash(hook.Path))
+	return (e.Path != "" && pathWithin(e.Path, prefix)) ||
+		(e.Destination != "" && pathWithin(e.Destination, prefix))
+}
+
+// rootPath returns the full path relative to the root.
+func (wd *webhookDispatcher) rootPath(fullPath string) string {
+	if fullPath == "" {
+		return ""
+	}
+
+	rel, err := filepath.Rel(wd.server.Root, fullPath)
+	if err != nil {
+		return ""
+	}
+	return path.Clean("/" + filepath.ToSlash(rel))
+}
+
+// run sends the due deliveries, then waits for new ones or for the next
+// attempt of the failed ones, until the context is done.
+func (wd *webhookDispatcher) run(ctx context.Context) {
+	for {
+		wait := time.Minute
+		if next := wd.deliverDue(time.Now()); !next.IsZero() {
+			wait = min(wait, time.Until(next))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wd.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliverDue sends the deliveries due at now and returns the time of the
+// next attempt of those left pending.
+func (wd *webhookDispatcher) deliverDue(now time.Time) time.Time {
+	list, err := wd.store.Webhooks.Pending()
+	if err != nil {
+		log.Printf("WARNING: couldn't get the pending webhook deliveries: %v", err)
+		return now.Add(time.Minute)
+	}
+
+	set, err := wd.store.Settings.Get()
+	if err != nil {
+		log.Printf("WARNING: couldn't get the webhooks: %v", err)
+		return now.Add(time.Minute)
+	}
+
+	var next time.Time
+	for _, dl := range list {
+		if dl.NextAttempt.After(now) {
+			if next.IsZero() || dl.NextAttempt.Before(next) {
+				next = dl.NextAttempt
+			}
+			continue
+		}
+
+		hook, ok := findWebhook(set.Webhooks, dl.Webhook)
+		if !ok {
+			dl.Status, dl.Error = webhooks.Failed, "the webhook was removed"
+		} else if wd.deliver(hook, dl); dl.Status == webhooks.Pending {
+			if next.IsZero() || dl.NextAttempt.Before(next) {
+				next = dl.NextAttempt
+			}
+		}
+
+		if err := wd.store.Webhooks.Save(dl); err != nil {
+			log.Printf("WARNING: couldn't save the webhook delivery %d: %v", dl.ID, err)
+		}
+	}
+
+	return next
+}
+
+// deliver posts the payload to the webhook, updating the delivery with
+// the result of the attempt.
+func (wd *webhookDispatcher) deliver(hook settings.Webhook, dl *webhooks.Delivery) {
+	dl.Attempts++
+	dl.ResponseCode, dl.Error = 0, ""
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(dl.Payload))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "File Browser")
+		req.Header.Set("X-Filebrowser-Event", dl.Event)
+		req.Header.Set("X-Filebrowser-Delivery", strconv.Itoa(dl.ID))
+		req.Header.Set("X-Filebrowser-Signature", webhooks.Signature(hook.Secret, dl.Payload))
+
+		var resp *http.Response
+		if resp, err = wd.client.Do(req); err == nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+
+			dl.ResponseCode = resp.StatusCode
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("unexpected status %s", resp.Status)
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		dl.Status = webhooks.Delivered
+	case dl.Attempts >= webhooks.MaxAttempts:
+		dl.Status, dl.Error = webhooks.Failed, err.Error()
+	default:
+		dl.Error = err.Error()
+		dl.NextAttempt = time.Now().Add(webhooks.Backoff(dl.Attempts))
+	}
+}
+
+func findWebhook(list []settings.Webhook, name string) (settings.Webhook, bool) {
+	for _, hook := range list {
+		if hook.Name == name {
+			return hook, true
+		}
+	}
+	return settings.Webhook{}, false
+}
+
+// checkWebhooks validates the webhooks, cleaning their paths.
+func checkWebhooks(list []settings.Webhook) error {
+	names := map[string]bool{}
+	known := map[string]bool{}
+	for _, evt := range webhookEvents {
+		known[evt] = true
+	}
+
+	for i := range list {
+		hook := &list[i]
+		switch {
+		case hook.Name == "" || strings.Contains(hook.Name, "/"):
+			return fmt.Errorf("invalid webhook name %q: %w", hook.Name, fberrors.ErrInvalidRequestParams)
+		case names[hook.Name]:
+			return fmt.Errorf("duplicate webhook name %q: %w", hook.Name, fberrors.ErrInvalidRequestParams)
+		}
+		names[hook.Name] = true
+
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %s: invalid url %q: %w", hook.Name, hook.URL, fberrors.ErrInvalidRequestParams)
+		}
+
+		for _, evt := range hook.Events {
+			if !known[evt] {
+				return fmt.Errorf("webhook %s: unknown event %q: %w", hook.Name, evt, fberrors.ErrInvalidRequestParams)
+			}
+		}
+
+		if hook.Path != "" {
+			hook.Path = path.Clean("/" + hook.Path)
+		}
+	}
+	return nil
+}
+
+// webhooksHandler lists the latest deliveries of a webhook, or sends it a
+// test delivery and returns it.
+// GET /api/webhooks/{name}/deliveries?limit=50
+// POST /api/webhooks/{name}/test
+func webhooksHandler(wd *webhookDispatcher) handleFunc {
+	return withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		name, action, ok := strings.Cut(strings.Trim(r.URL.Path, "/"), "/")
+		if !ok || strings.Contains(action, "/") {
+			return http.StatusNotFound, nil
+		}
+
+		hook, known := findWebhook(d.settings.Webhooks, name)
+
+		switch {
+		case r.Method == http.MethodGet && action == "deliveries":
+			limit := defaultDeliveriesLimit
+			if v := r.URL.Query().Get("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n <= 0 {
+					return http.StatusBadRequest, nil
+				}
+				limit = min(n, maxDeliveriesLimit)
+			}
+
+			list, err := d.store.Webhooks.Latest(name, limit)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			return renderJSON(w, r, list)
+		case r.Method == http.MethodPost && action == "test" && known:
+			payload, err := json.Marshal(webhookPayload{
+				Event: "test",
+				User:  d.user.Username,
+				Time:  time.Now(),
+			})
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+
+			// The delivery is saved first for its ID to be sent, but not as
+			// pending for the dispatcher to leave it, as it isn't attempted
+			// again.
+			dl := &webhooks.Delivery{Webhook: name, Event: "test", Payload: payload, Status: webhooks.Failed}
+			if err := d.store.Webhooks.Save(dl); err != nil {
+				return http.StatusInternalServerError, err
+			}
+
+			wd.deliver(hook, dl)
+			if dl.Status == webhooks.Pending {
+				dl.Status = webhooks.Failed
+			}
+			if err := d.store.Webhooks.Save(dl); err != nil {
+				return http.StatusInternalServerError, err
+			}
+			return renderJSON(w, r, dl)
+		default:
+			return http.StatusNotFound, nil
+		}
+	})
+}
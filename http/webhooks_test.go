@@ -0,0 +1,97 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	var requests atomic.Int32
+	received := make(chan webhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Filebrowser-Signature") != webhooks.Signature("s3cret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the first attempt fails, to be retried
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var payload webhookPayload
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer srv.Close()
+
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := bolt.NewStorage(db)
+	require.NoError(t, err)
+
+	root := t.TempDir()
+	require.NoError(t, store.Settings.Save(&settings.Settings{
+		Key: []byte("key"),
+		Webhooks: []settings.Webhook{
+			{Name: "docs", URL: srv.URL, Secret: "s3cret", Events: []string{"upload"}, Path: "/docs"},
+		},
+	}))
+
+	wd := newWebhookDispatcher(store, &settings.Server{Root: root})
+	wd.queue(events.Event{Type: events.Uploaded, Path: filepath.Join(root, "docs", "a.txt"), User: "alice"})
+	wd.queue(events.Event{Type: events.Uploaded, Path: filepath.Join(root, "other", "b.txt"), User: "alice"})
+	wd.queue(events.Event{Type: events.Deleted, Path: filepath.Join(root, "docs", "c.txt"), User: "alice"})
+
+	now := time.Now()
+	next := wd.deliverDue(now)
+	require.False(t, next.IsZero())
+	require.EqualValues(t, 1, requests.Load())
+
+	list, err := store.Webhooks.Latest("docs", 10)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, webhooks.Pending, list[0].Status)
+	require.Equal(t, http.StatusInternalServerError, list[0].ResponseCode)
+
+	// not due yet
+	require.True(t, next.Equal(wd.deliverDue(now)))
+	require.EqualValues(t, 1, requests.Load())
+
+	require.True(t, wd.deliverDue(next).IsZero())
+	payload := <-received
+	require.Equal(t, "upload", payload.Event)
+	require.Equal(t, "/docs/a.txt", payload.Path)
+	require.Equal(t, "alice", payload.User)
+
+	list, err = store.Webhooks.Latest("docs", 10)
+	require.NoError(t, err)
+	require.Equal(t, webhooks.Delivered, list[0].Status)
+	require.Equal(t, 2, list[0].Attempts)
+}
+
+func TestCheckWebhooks(t *testing.T) {
+	list := []settings.Webhook{{Name: "ci", URL: "https://ci.example.com/hook", Events: []string{"save", "login"}, Path: "projects/"}}
+	require.NoError(t, checkWebhooks(list))
+	require.Equal(t, "/projects", list[0].Path)
+
+	require.Error(t, checkWebhooks([]settings.Webhook{{Name: "ci", URL: "ftp://example.com"}}))
+	require.Error(t, checkWebhooks([]settings.Webhook{{Name: "ci", URL: "https://example.com", Events: []string{"chmod"}}}))
+	require.Error(t, checkWebhooks([]settings.Webhook{{Name: "ci", URL: "https://a.com"}, {Name: "ci", URL: "https://b.com"}}))
+}
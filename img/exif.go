@@ -0,0 +1,702 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// metadataBlock is the location of the metadata of an image file: a TIFF
+// structure holding the EXIF information, or an XMP packet.
+type metadataBlock struct {
+	offset, length int64
+	xmp            bool
+	// fixed is set for the XMP packets which can't be changed in place.
+	fixed bool
+	// crc is the offset of the CRC of the PNG chunk holding the block,
+	// which covers the chunk from its type at chunk.
+	chunk, crc int64
+}
+
+const (
+	jpegXMPPrefix         = "http://ns.adobe.com/xap/1.0/\x00"
+	jpegExtendedXMPPrefix = "http://ns.adobe.com/xmp/extension/\x00"
+	// the extended XMP chunks start with the GUID, the full length and the
+	// offset of the chunk
+	jpegExtendedXMPHeader = len(jpegExtendedXMPPrefix) + 40
+	pngXMPKeyword         = "XML:com.adobe.xmp\x00"
+	tagXMP                = 700
+)
+
+// heifBrands are the brands of the ISO base media files storing images.
+var heifBrands = []string{"mif1", "msf1", "heic", "heix", "heim", "heis", "hevc", "hevx", "avif", "avis", "avio"}
+
+// locateMetadata returns the EXIF and XMP blocks of a JPEG, TIFF, PNG,
+// WebP, HEIF, AVIF, GIF or SVG image. The other formats which can't hold
+// metadata have none.
+func locateMetadata(r io.ReadSeeker, size int64) ([]metadataBlock, error) {
+	head := make([]byte, 32)
+	n, err := readAt(r, 0, head)
+	if err != nil && n == 0 && size > 0 {
+		return nil, err
+	}
+	head = head[:n]
+
+	var blocks []metadataBlock
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8}):
+		blocks, err = locateJpegMetadata(r, size)
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		// the XMP packet may be past the part of the file loaded
+		xmp, err := tiffXMP(r, metadataBlock{offset: 0, length: size})
+		if err != nil {
+			return nil, err
+		}
+		return append(xmp, metadataBlock{offset: 0, length: min(size, maxExifSize)}), nil
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		blocks, err = locatePngMetadata(r, size)
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		blocks, err = locateWebpMetadata(r, size)
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		blocks, err = locateHeifMetadata(r, size, head)
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		return locateGifXMP(r, size)
+	case bytes.HasPrefix(head, []byte("BM")), bytes.HasPrefix(head, []byte{0, 0, 1, 0}):
+		return nil, nil
+	default:
+		// SVG and the other XML documents, which may embed an XMP packet
+		text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")), " \t\r\n")
+		if !bytes.HasPrefix(text, []byte("<")) {
+			return nil, ErrUnknownFormat
+		}
+		if size > maxExifSize {
+			return nil, ErrGPSNotStripped
+		}
+		return []metadataBlock{{offset: 0, length: size, xmp: true}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the EXIF information may hold an XMP packet too
+	for _, block := range blocks {
+		if block.xmp {
+			continue
+		}
+		xmp, err := tiffXMP(r, block)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, xmp...)
+	}
+
+	return blocks, nil
+}
+
+// tiffXMP returns the XMP packet of the TIFF structure of the block.
+func tiffXMP(r io.ReadSeeker, block metadataBlock) ([]metadataBlock, error) {
+	header := make([]byte, 8)
+	if block.length < 8 {
+		return nil, ErrGPSNotStripped
+	}
+	if _, err := readAt(r, block.offset, header); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, ErrGPSNotStripped
+	}
+
+	ifd0 := int64(order.Uint32(header[4:]))
+	if ifd0+2 > block.length {
+		return nil, ErrGPSNotStripped
+	}
+	if _, err := readAt(r, block.offset+ifd0, header[:2]); err != nil {
+		return nil, err
+	}
+	count := int64(order.Uint16(header))
+	if ifd0+2+count*ifdEntryLength > block.length {
+		return nil, ErrGPSNotStripped
+	}
+
+	entries := make([]byte, count*ifdEntryLength)
+	if _, err := readAt(r, block.offset+ifd0+2, entries); err != nil {
+		return nil, err
+	}
+	for entry := entries; len(entry) > 0; entry = entry[ifdEntryLength:] {
+		if order.Uint16(entry) != tagXMP {
+			continue
+		}
+
+		typeSize, ok := exifTypeSizes[order.Uint16(entry[2:])]
+		length := int64(typeSize) * int64(order.Uint32(entry[4:]))
+		if !ok || length <= 4 {
+			return nil, nil
+		}
+		offset := int64(order.Uint32(entry[8:]))
+		if offset+length > block.length || length > maxExifSize {
+			return nil, ErrGPSNotStripped
+		}
+
+		xmp := block
+		xmp.offset, xmp.length, xmp.xmp = block.offset+offset, length, true
+		return []metadataBlock{xmp}, nil
+	}
+
+	return nil, nil
+}
+
+func locateJpegMetadata(r io.ReadSeeker, size int64) ([]metadataBlock, error) {
+	var blocks []metadataBlock
+	header := make([]byte, 4+len(jpegExtendedXMPPrefix))
+
+	for pos := int64(2); pos+4 <= size; {
+		if _, err := readAt(r, pos, header[:4]); err != nil {
+			return nil, err
+		}
+		if header[0] != 0xFF {
+			return nil, ErrGPSNotStripped
+		}
+
+		marker := header[1]
+		switch {
+		case marker == 0xFF: // fill byte
+			pos++
+			continue
+		case marker == 0x01, marker >= 0xD0 && marker <= 0xD8: // no length
+			pos += 2
+			continue
+		case marker == 0xDA, marker == 0xD9: // start of scan, end of image
+			return blocks, nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 || pos+2+length > size {
+			return nil, ErrGPSNotStripped
+		}
+
+		if marker == 0xE1 {
+			prefix := header[4 : 4+min(int(length)-2, len(jpegExtendedXMPPrefix))]
+			if _, err := readAt(r, pos+4, prefix); err != nil {
+				return nil, err
+			}
+
+			// the extended XMP packets are split in several segments,
+			// which are each cleared on their own
+			start, xmp := int64(0), true
+			switch {
+			case bytes.HasPrefix(prefix, []byte("Exif\x00\x00")):
+				start, xmp = 6, false
+			case bytes.HasPrefix(prefix, []byte(jpegXMPPrefix)):
+				start = int64(len(jpegXMPPrefix))
+			case bytes.HasPrefix(prefix, []byte(jpegExtendedXMPPrefix)):
+				start = int64(jpegExtendedXMPHeader)
+			}
+			if start > 0 && start <= length-2 {
+				blocks = append(blocks, metadataBlock{offset: pos + 4 + start, length: length - 2 - start, xmp: xmp})
+			}
+		}
+
+		pos += 2 + length
+	}
+
+	return blocks, nil
+}
+
+func locatePngMetadata(r io.ReadSeeker, size int64) ([]metadataBlock, error) {
+	var blocks []metadataBlock
+	header := make([]byte, 8)
+
+	for pos := int64(8); pos+12 <= size; {
+		if _, err := readAt(r, pos, header); err != nil {
+			return nil, err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header))
+		if pos+12+length > size {
+			return nil, ErrGPSNotStripped
+		}
+
+		chunk := metadataBlock{offset: pos + 8, length: length, chunk: pos + 4, crc: pos + 8 + length}
+		switch string(header[4:]) {
+		case "eXIf":
+			blocks = append(blocks, chunk)
+		case "iTXt":
+			start, err := pngXMPStart(r, chunk)
+			if err != nil {
+				return nil, err
+			}
+			if start > 0 {
+				chunk.offset, chunk.length, chunk.xmp = chunk.offset+start, length-start, true
+				blocks = append(blocks, chunk)
+			}
+		case "IEND":
+			return blocks, nil
+		}
+
+		pos += 12 + length
+	}
+
+	return blocks, nil
+}
+
+// pngXMPStart returns where the text of an iTXt chunk holding an XMP packet
+// starts, after its keyword, compression, language and translated keyword.
+// It returns 0 for the other chunks.
+func pngXMPStart(r io.ReadSeeker, chunk metadataBlock) (int64, error) {
+	head := make([]byte, min(chunk.length, 1024))
+	if _, err := readAt(r, chunk.offset, head); err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(head, []byte(pngXMPKeyword)) {
+		return 0, nil
+	}
+
+	rest := head[len(pngXMPKeyword):]
+	// compressed packets can't be changed in place
+	if len(rest) < 2 || rest[0] != 0 {
+		return 0, ErrGPSNotStripped
+	}
+	rest = rest[2:]
+	for range 2 {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return 0, ErrGPSNotStripped
+		}
+		rest = rest[end+1:]
+	}
+
+	return int64(len(head) - len(rest)), nil
+}
+
+func locateWebpMetadata(r io.ReadSeeker, size int64) ([]metadataBlock, error) {
+	var blocks []metadataBlock
+	header := make([]byte, 8)
+
+	for pos := int64(12); pos+8 <= size; {
+		if _, err := readAt(r, pos, header); err != nil {
+			return nil, err
+		}
+
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		if pos+8+length > size {
+			return nil, ErrGPSNotStripped
+		}
+
+		switch string(header[:4]) {
+		case "EXIF":
+			block := metadataBlock{offset: pos + 8, length: length}
+
+			// some writers keep the prefix of the JPEG segment
+			prefix := make([]byte, 6)
+			if length >= 6 {
+				if _, err := readAt(r, block.offset, prefix); err != nil {
+					return nil, err
+				}
+			}
+			if string(prefix) == "Exif\x00\x00" {
+				block.offset += 6
+				block.length -= 6
+			}
+			blocks = append(blocks, block)
+		case "XMP ":
+			blocks = append(blocks, metadataBlock{offset: pos + 8, length: length, xmp: true})
+		}
+
+		pos += 8 + length + length%2
+	}
+
+	return blocks, nil
+}
+
+// locateGifXMP returns the XMP packet of a GIF image, stored in an
+// application extension. Its bytes are also read as the lengths of the
+// data sub-blocks of the extension, so it can't be changed.
+func locateGifXMP(r io.ReadSeeker, size int64) ([]metadataBlock, error) {
+	header := make([]byte, 14)
+	if size < 13 {
+		return nil, ErrGPSNotStripped
+	}
+	if _, err := readAt(r, 0, header[:13]); err != nil {
+		return nil, err
+	}
+
+	pos := int64(13)
+	if header[10]&0x80 != 0 {
+		pos += 3 << (header[10]&0x07 + 1)
+	}
+
+	var blocks []metadataBlock
+	for pos < size {
+		if _, err := readAt(r, pos, header[:1]); err != nil {
+			return nil, err
+		}
+
+		var err error
+		switch header[0] {
+		case 0x3B: // trailer
+			return blocks, nil
+		case 0x2C: // image, with its local color table and LZW code size
+			if _, err := readAt(r, pos, header[:10]); err != nil {
+				return nil, err
+			}
+			pos += 11
+			if header[9]&0x80 != 0 {
+				pos += 3 << (header[9]&0x07 + 1)
+			}
+			pos, err = skipGifSubBlocks(r, pos, size)
+		case 0x21: // extension
+			if _, err := readAt(r, pos, header[:3]); err != nil {
+				return nil, err
+			}
+			if header[1] != 0xFF || header[2] != 11 {
+				pos, err = skipGifSubBlocks(r, pos+2, size)
+				break
+			}
+
+			// application extension, with its identifier
+			if _, err := readAt(r, pos+3, header[3:14]); err != nil {
+				return nil, err
+			}
+			start := pos + 14
+			pos, err = skipGifSubBlocks(r, start, size)
+			if err == nil && string(header[3:14]) == "XMP DataXMP" {
+				blocks = append(blocks, metadataBlock{offset: start, length: pos - start, xmp: true, fixed: true})
+			}
+		default:
+			return nil, ErrGPSNotStripped
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrGPSNotStripped
+}
+
+// skipGifSubBlocks returns the offset following the data sub-blocks, and
+// their terminator, starting at pos.
+func skipGifSubBlocks(r io.ReadSeeker, pos, size int64) (int64, error) {
+	length := make([]byte, 1)
+	for {
+		if pos >= size {
+			return 0, ErrGPSNotStripped
+		}
+		if _, err := readAt(r, pos, length); err != nil {
+			return 0, err
+		}
+		pos += 1 + int64(length[0])
+		if length[0] == 0 {
+			return pos, nil
+		}
+	}
+}
+
+// locateHeifMetadata finds the EXIF and XMP items of the image, listed in
+// the meta box at the top level of the file.
+func locateHeifMetadata(r io.ReadSeeker, size int64, head []byte) ([]metadataBlock, error) {
+	ftypSize := min(int64(binary.BigEndian.Uint32(head)), int64(len(head)))
+	if ftypSize < 16 {
+		return nil, ErrGPSNotStripped
+	}
+	if !hasHeifBrand(head[8:ftypSize]) {
+		return nil, ErrUnknownFormat
+	}
+
+	header := make([]byte, 16)
+	for pos := int64(0); pos+8 <= size; {
+		if _, err := readAt(r, pos, header[:8]); err != nil {
+			return nil, err
+		}
+
+		length, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch length {
+		case 0:
+			length = size - pos
+		case 1:
+			if _, err := readAt(r, pos+8, header[8:]); err != nil {
+				return nil, err
+			}
+			length, headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if length < headerSize || pos+length > size {
+			return nil, ErrGPSNotStripped
+		}
+
+		if string(header[4:8]) == "meta" {
+			if length > maxExifSize {
+				return nil, ErrGPSNotStripped
+			}
+			meta := make([]byte, length-headerSize)
+			if _, err := readAt(r, pos+headerSize, meta); err != nil {
+				return nil, err
+			}
+			return heifMetadataItems(r, size, meta)
+		}
+
+		pos += length
+	}
+
+	return nil, nil
+}
+
+func hasHeifBrand(ftyp []byte) bool {
+	// major brand, minor version, then the compatible brands
+	for i := 0; i+4 <= len(ftyp); i += 4 {
+		if i == 4 {
+			continue
+		}
+		for _, brand := range heifBrands {
+			if string(ftyp[i:i+4]) == brand {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// heifMetadataItems returns the location of the EXIF and XMP items
+// described by the iinf and iloc boxes of the meta box.
+func heifMetadataItems(r io.ReadSeeker, size int64, meta []byte) ([]metadataBlock, error) {
+	if len(meta) < 4 {
+		return nil, ErrGPSNotStripped
+	}
+
+	var iinf, iloc []byte
+	if !isoBoxes(meta[4:], func(typ string, content []byte) {
+		switch typ {
+		case "iinf":
+			iinf = content
+		case "iloc":
+			iloc = content
+		}
+	}) {
+		return nil, ErrGPSNotStripped
+	}
+
+	items, ok := heifMetadataItemIDs(iinf)
+	if !ok {
+		return nil, ErrGPSNotStripped
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	extents, ok := heifItemExtents(iloc, items)
+	if !ok || len(extents) != len(items) {
+		return nil, ErrGPSNotStripped
+	}
+
+	blocks := make([]metadataBlock, 0, len(extents))
+	for _, extent := range extents {
+		if extent.offset < 0 || extent.offset+extent.length > size {
+			return nil, ErrGPSNotStripped
+		}
+		if extent.xmp {
+			if extent.length > maxExifSize {
+				return nil, ErrGPSNotStripped
+			}
+			blocks = append(blocks, extent)
+			continue
+		}
+
+		// the EXIF item starts with the offset of the TIFF header
+		start := make([]byte, 4)
+		if extent.length < 4 {
+			return nil, ErrGPSNotStripped
+		}
+		if _, err := readAt(r, extent.offset, start); err != nil {
+			return nil, err
+		}
+
+		skip := 4 + int64(binary.BigEndian.Uint32(start))
+		if skip > extent.length {
+			return nil, ErrGPSNotStripped
+		}
+		blocks = append(blocks, metadataBlock{offset: extent.offset + skip, length: extent.length - skip})
+	}
+
+	return blocks, nil
+}
+
+// heifMetadataItemIDs returns the IDs of the EXIF and XMP items, with
+// whether they are XMP packets.
+func heifMetadataItemIDs(iinf []byte) (map[uint64]bool, bool) {
+	items := map[uint64]bool{}
+	if iinf == nil {
+		return items, true
+	}
+
+	b := &boxReader{data: iinf}
+	version := b.uint(1)
+	b.uint(3)
+	if version == 0 {
+		b.uint(2)
+	} else {
+		b.uint(4)
+	}
+	if b.err {
+		return nil, false
+	}
+
+	ok := isoBoxes(b.data, func(typ string, content []byte) {
+		if typ != "infe" {
+			return
+		}
+
+		infe := &boxReader{data: content}
+		version := infe.uint(1)
+		infe.uint(3)
+		if version < 2 {
+			return
+		}
+
+		var id uint64
+		if version == 2 {
+			id = infe.uint(2)
+		} else {
+			id = infe.uint(4)
+		}
+		infe.uint(2)
+		itemType := string(infe.bytes(4))
+		infe.string() // name
+		switch {
+		case infe.err:
+		case itemType == "Exif":
+			items[id] = false
+		case itemType == "mime" && infe.string() == "application/rdf+xml" && !infe.err:
+			items[id] = true
+		}
+	})
+
+	return items, ok
+}
+
+// heifItemExtents returns the location of the given items, which must be
+// stored in the file itself as a single extent.
+func heifItemExtents(iloc []byte, items map[uint64]bool) ([]metadataBlock, bool) {
+	b := &boxReader{data: iloc}
+	version := b.uint(1)
+	b.uint(3)
+
+	sizes := b.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xF), int(sizes>>8&0xF)
+	baseOffsetSize, indexSize := int(sizes>>4&0xF), 0
+	if version == 1 || version == 2 {
+		indexSize = int(sizes & 0xF)
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	count := b.uint(idSize)
+
+	var extents []metadataBlock
+	for i := uint64(0); i < count && !b.err; i++ {
+		id := b.uint(idSize)
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = b.uint(2) & 0xF
+		}
+		reference := b.uint(2)
+		base := b.uint(baseOffsetSize)
+		extentCount := b.uint(2)
+
+		for e := uint64(0); e < extentCount && !b.err; e++ {
+			b.uint(indexSize)
+			offset := b.uint(offsetSize)
+			length := b.uint(lengthSize)
+
+			xmp, ok := items[id]
+			if !ok {
+				continue
+			}
+			if method != 0 || reference != 0 || extentCount != 1 || length == 0 {
+				return nil, false
+			}
+			extents = append(extents, metadataBlock{offset: int64(base + offset), length: int64(length), xmp: xmp})
+		}
+	}
+
+	return extents, !b.err
+}
+
+// isoBoxes calls fn with the type and content of the boxes of an ISO base
+// media file held in data. It reports whether they were all well formed.
+func isoBoxes(data []byte, fn func(typ string, content []byte)) bool {
+	for len(data) > 0 {
+		b := &boxReader{data: data}
+		length, headerSize := b.uint(4), uint64(8)
+		typ := b.bytes(4)
+		switch length {
+		case 0:
+			length = uint64(len(data))
+		case 1:
+			length, headerSize = b.uint(8), 16
+		}
+		if b.err || length < headerSize || length > uint64(len(data)) {
+			return false
+		}
+
+		fn(string(typ), data[headerSize:length])
+		data = data[length:]
+	}
+
+	return true
+}
+
+// boxReader reads the big endian fields of a box, err being set when
+// reading past its end.
+type boxReader struct {
+	data []byte
+	err  bool
+}
+
+func (b *boxReader) bytes(n int) []byte {
+	if n > len(b.data) {
+		b.err = true
+		b.data = nil
+		return nil
+	}
+
+	value := b.data[:n]
+	b.data = b.data[n:]
+	return value
+}
+
+// string reads a null terminated string.
+func (b *boxReader) string() string {
+	end := bytes.IndexByte(b.data, 0)
+	if end < 0 {
+		b.err = true
+		b.data = nil
+		return ""
+	}
+
+	value := string(b.data[:end])
+	b.data = b.data[end+1:]
+	return value
+}
+
+func (b *boxReader) uint(n int) uint64 {
+	var value uint64
+	for _, c := range b.bytes(n) {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
+
+// readAt reads len(p) bytes at the given offset.
+func readAt(r io.ReadSeeker, offset int64, p []byte) (int, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r, p)
+}
@@ -0,0 +1,311 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripFileGPS(t *testing.T) {
+	jpeg, err := os.ReadFile("testdata/IMG_2578.JPG")
+	require.NoError(t, err)
+
+	blocks, err := locateMetadata(bytes.NewReader(jpeg), int64(len(jpeg)))
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	require.False(t, blocks[0].xmp)
+	require.True(t, blocks[1].xmp)
+	tiff := jpeg[blocks[0].offset : blocks[0].offset+blocks[0].length]
+
+	// the segments before the EXIF one push it past the first 256 KB
+	far := append([]byte{}, jpeg[:2]...)
+	for range 5 {
+		far = append(far, 0xFF, 0xE2, 0xFF, 0xFF)
+		far = append(far, make([]byte, 0xFFFF-2)...)
+	}
+	far = append(far, jpeg[2:]...)
+
+	testCases := map[string][]byte{
+		"jpeg":          jpeg,
+		"jpeg far away": far,
+		"tiff":          tiff,
+		"png": concat(
+			[]byte("\x89PNG\r\n\x1a\n"),
+			pngChunk("IHDR", make([]byte, 13)),
+			pngChunk("eXIf", tiff),
+			pngChunk("IEND", nil),
+		),
+		"webp": riff(
+			riffChunk("VP8 ", []byte{1, 2, 3}),
+			riffChunk("EXIF", concat([]byte("Exif\x00\x00"), tiff)),
+		),
+		"heic": heif("heic", tiff, nil),
+		"avif": heif("avif", tiff, nil),
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.NotEmpty(t, gpsEntries(t, data))
+
+			patches, err := StripFileGPS(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			require.NotEmpty(t, patches)
+
+			stripped := bytes.Clone(data)
+			for _, patch := range patches {
+				copy(stripped[patch.Offset:], patch.Data)
+			}
+			require.Empty(t, gpsEntries(t, stripped))
+
+			if name == "png" {
+				chunk := stripped[8+25:]
+				length := binary.BigEndian.Uint32(chunk)
+				require.Equal(t, crc32.ChecksumIEEE(chunk[4:8+length]), binary.BigEndian.Uint32(chunk[8+length:]))
+			}
+
+			// nothing left to strip
+			patches, err = StripFileGPS(bytes.NewReader(stripped), int64(len(stripped)))
+			require.NoError(t, err)
+			require.Empty(t, patches)
+		})
+	}
+}
+
+func TestStripFileGPSFailures(t *testing.T) {
+	// the GPS directory is out of the EXIF block
+	broken := []byte("II*\x00\x08\x00\x00\x00\x01\x00\x25\x88\x04\x00\x01\x00\x00\x00\x00\x10\x00\x00")
+
+	testCases := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"gif":           {gifImage(nil), nil},
+		"svg":           {[]byte("\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>"), nil},
+		"no exif":       {[]byte("\xFF\xD8\xFF\xDA\x00\x02"), nil},
+		"text":          {[]byte("hello"), ErrUnknownFormat},
+		"video":         {concat(isoBox("ftyp", []byte("isom\x00\x00\x00\x00isommp41")), isoBox("moov", nil)), ErrUnknownFormat},
+		"broken tiff":   {broken, ErrGPSNotStripped},
+		"broken jpeg":   {concat([]byte("\xFF\xD8\xFF\xE1\x00\x1E"), []byte("Exif\x00\x00"), broken), ErrGPSNotStripped},
+		"garbage jpeg":  {[]byte("\xFF\xD8\x00\x00\x00\x00"), ErrGPSNotStripped},
+		"short segment": {[]byte("\xFF\xD8\xFF0\x00\x00"), ErrGPSNotStripped},
+		"truncated png": {concat(
+			[]byte("\x89PNG\r\n\x1a\n"),
+			pngChunk("IHDR", make([]byte, 13))[:20],
+		), ErrGPSNotStripped},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			patches, err := StripFileGPS(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Empty(t, patches)
+		})
+	}
+	require.ErrorIs(t, ErrUnknownFormat, ErrGPSNotStripped)
+}
+
+func TestStripFileXMPGPS(t *testing.T) {
+	jpeg, err := os.ReadFile("testdata/IMG_2578.JPG")
+	require.NoError(t, err)
+	gray, err := os.ReadFile("testdata/gray-sample.jpg")
+	require.NoError(t, err)
+
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description xmlns:exif="http://ns.adobe.com/exif/1.0/" xmlns:xmp="http://ns.adobe.com/xap/1.0/"` +
+		` xmp:CreatorTool="test" exif:GPSLatitude="51,30.4N" exif:GPSLongitude='0,7.6W'>` +
+		`<exif:GPSAltitude>12/1</exif:GPSAltitude></rdf:Description></rdf:RDF></x:xmpmeta>`)
+
+	// a TIFF structure whose first directory only has the XMP tag
+	tiff := concat([]byte("II*\x00\x08\x00\x00\x00\x01\x00\xBC\x02\x01\x00"),
+		binary.LittleEndian.AppendUint32(nil, uint32(len(packet))), []byte("\x1A\x00\x00\x00\x00\x00\x00\x00"), packet)
+
+	testCases := map[string][]byte{
+		"jpeg":          concat(jpeg[:2], jpegSegment(jpegXMPPrefix, packet), jpeg[2:]),
+		"jpeg extended": concat(gray[:2], jpegSegment(jpegExtendedXMPPrefix+strings.Repeat("0", 40), packet), gray[2:]),
+		"jpeg exif":     concat(gray[:2], jpegSegment("Exif\x00\x00", tiff), gray[2:]),
+		"tiff":          tiff,
+		"png": concat(
+			[]byte("\x89PNG\r\n\x1a\n"),
+			pngChunk("IHDR", make([]byte, 13)),
+			pngChunk("iTXt", concat([]byte(pngXMPKeyword+"\x00\x00en\x00\x00"), packet)),
+			pngChunk("IEND", nil),
+		),
+		"png exif": concat(
+			[]byte("\x89PNG\r\n\x1a\n"),
+			pngChunk("IHDR", make([]byte, 13)),
+			pngChunk("eXIf", tiff),
+			pngChunk("IEND", nil),
+		),
+		"webp": riff(riffChunk("VP8 ", []byte{1, 2, 3}), riffChunk("XMP ", packet)),
+		"heic": heif("heic", []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"), packet),
+		"svg":  concat([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><metadata>`), packet, []byte("</metadata></svg>")),
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			patches, err := StripFileGPS(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			require.NotEmpty(t, patches)
+
+			stripped := bytes.Clone(data)
+			for _, patch := range patches {
+				copy(stripped[patch.Offset:], patch.Data)
+			}
+			for _, value := range []string{"51,30.4N", "0,7.6W", "12/1"} {
+				require.NotContains(t, string(stripped), value)
+			}
+			require.Contains(t, string(stripped), `xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:CreatorTool="test" exif:GPSLatitude="        "`)
+			if name == "jpeg" {
+				require.Empty(t, gpsEntries(t, stripped))
+			}
+
+			if strings.HasPrefix(name, "png") {
+				chunk := stripped[8+25:]
+				length := binary.BigEndian.Uint32(chunk)
+				require.Equal(t, crc32.ChecksumIEEE(chunk[4:8+length]), binary.BigEndian.Uint32(chunk[8+length:]))
+			}
+
+			patches, err = StripFileGPS(bytes.NewReader(stripped), int64(len(stripped)))
+			require.NoError(t, err)
+			require.Empty(t, patches)
+		})
+	}
+
+	// the packet of a GIF image can't be changed
+	data := gifImage(packet)
+	_, err = StripFileGPS(bytes.NewReader(data), int64(len(data)))
+	require.ErrorIs(t, err, ErrGPSNotStripped)
+	data = gifImage([]byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"/>`))
+	patches, err := StripFileGPS(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Empty(t, patches)
+
+	// nor a compressed one
+	data = concat([]byte("\x89PNG\r\n\x1a\n"), pngChunk("iTXt", concat([]byte(pngXMPKeyword+"\x01\x00\x00\x00"), packet)))
+	_, err = StripFileGPS(bytes.NewReader(data), int64(len(data)))
+	require.ErrorIs(t, err, ErrGPSNotStripped)
+}
+
+func TestBlankXMPGPS(t *testing.T) {
+	testCases := map[string]struct {
+		packet, want string
+		blanked      bool
+	}{
+		"attributes": {
+			`<rdf:Description xmlns:gps="urn:gps" exif:GPSLatitude="1,2N" drone-dji:GpsLongitude='3.5' exif:Make="x"/>`,
+			`<rdf:Description xmlns:gps="urn:gps" exif:GPSLatitude="    " drone-dji:GpsLongitude='   ' exif:Make="x"/>`,
+			true,
+		},
+		"elements": {
+			`<exif:GPSVersionID>2.2</exif:GPSVersionID><exif:GPSAltitude><rdf:Description rdf:value="9"/></exif:GPSAltitude><exif:GPSMapDatum/>`,
+			`<exif:GPSVersionID>   </exif:GPSVersionID><exif:GPSAltitude><rdf:Description rdf:value=" "/></exif:GPSAltitude><exif:GPSMapDatum/>`,
+			true,
+		},
+		"no gps": {`<exif:Make>GPS</exif:Make>`, `<exif:Make>GPS</exif:Make>`, false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			packet := []byte(tc.packet)
+			blanked, err := blankXMPGPS(packet)
+			require.NoError(t, err)
+			require.Equal(t, tc.blanked, blanked)
+			require.Equal(t, tc.want, string(packet))
+		})
+	}
+
+	_, err := blankXMPGPS([]byte(`<exif:GPSLatitude>1,2N`))
+	require.ErrorIs(t, err, ErrGPSNotStripped)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func jpegSegment(prefix string, data []byte) []byte {
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(2+len(prefix)+len(data)))
+	return concat(segment, []byte(prefix), data)
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func riffChunk(typ string, data []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(typ), uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func riff(chunks ...[]byte) []byte {
+	body := concat(append([][]byte{[]byte("WEBP")}, chunks...)...)
+	return concat([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body)
+}
+
+func isoBox(typ string, content []byte) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+	box = append(box, typ...)
+	return append(box, content...)
+}
+
+// heif builds an image file holding an EXIF item, and an XMP one if
+// given, in its mdat box.
+func heif(brand string, tiff, xmp []byte) []byte {
+	ftyp := isoBox("ftyp", []byte(brand+"\x00\x00\x00\x00mif1"+brand))
+
+	// version 2 item info entries
+	items := [][]byte{concat([]byte("\x00\x00\x00\x06Exif\x00\x00"), tiff)}
+	infes := [][]byte{isoBox("infe", []byte("\x02\x00\x00\x00\x00\x01\x00\x00Exif\x00"))}
+	if xmp != nil {
+		items = append(items, xmp)
+		infes = append(infes, isoBox("infe", []byte("\x02\x00\x00\x00\x00\x02\x00\x00mime\x00application/rdf+xml\x00")))
+	}
+	iinf := isoBox("iinf", concat(append([][]byte{{0, 0, 0, 0, 0, byte(len(infes))}}, infes...)...))
+
+	iloc := func(offset int) []byte {
+		// 4 bytes offsets and lengths, the items with a single extent
+		content := []byte{0, 0, 0, 0, 0x44, 0x00, 0, byte(len(items))}
+		for i, item := range items {
+			content = append(content, 0, byte(i+1), 0, 0, 0, 1)
+			content = binary.BigEndian.AppendUint32(content, uint32(offset))
+			content = binary.BigEndian.AppendUint32(content, uint32(len(item)))
+			offset += len(item)
+		}
+		return isoBox("iloc", content)
+	}
+	meta := func(offset int) []byte {
+		return isoBox("meta", concat([]byte("\x00\x00\x00\x00"), iinf, iloc(offset)))
+	}
+
+	offset := len(ftyp) + len(meta(0)) + 8
+	return concat(ftyp, meta(offset), isoBox("mdat", concat(items...)))
+}
+
+// gifImage builds a 1x1 image with an XMP packet if given, in an application
+// extension ending with the magic trailer of the XMP specification.
+func gifImage(xmp []byte) []byte {
+	data := []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00")
+	if xmp != nil {
+		data = concat(data, []byte("\x21\xFF\x0BXMP DataXMP"), xmp, []byte{1})
+		for i := 255; i >= 0; i-- {
+			data = append(data, byte(i))
+		}
+		data = append(data, 0)
+	}
+	return concat(data, []byte("\x2C\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02\x4C\x01\x00\x3B"))
+}
@@ -0,0 +1,198 @@
+package img
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+)
+
+const (
+	tagGPSInfo     = 0x8825
+	ifdEntryLength = 12
+
+	// maxExifSize limits the size of the EXIF blocks loaded to remove
+	// their GPS information.
+	maxExifSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrGPSNotStripped means the GPS information of an image couldn't be
+	// removed, its metadata being malformed or unsupported.
+	ErrGPSNotStripped = errors.New("the GPS information of the image can't be removed")
+	// ErrUnknownFormat means the file isn't an image whose GPS information
+	// can be located.
+	ErrUnknownFormat = fmt.Errorf("%w: unknown image format", ErrGPSNotStripped)
+)
+
+// exifTypeSizes maps the EXIF field types to their size in bytes.
+var exifTypeSizes = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// Patch replaces the bytes of a file at the given offset.
+type Patch struct {
+	Offset int64
+	Data   []byte
+}
+
+// StripFileGPS returns the patches removing the GPS information from the
+// EXIF blocks and the XMP packets of a JPEG, TIFF, PNG, WebP, HEIF, AVIF,
+// GIF or SVG image, wherever they are in the file. The patches only zero
+// the GPS directories and blank the GPS properties, so that the file size
+// and every other offset stay valid, and fix the checksums covering them.
+// It fails with ErrGPSNotStripped when the metadata can't be read or
+// changed, and ErrUnknownFormat for the other formats.
+func StripFileGPS(r io.ReadSeeker, size int64) ([]Patch, error) {
+	blocks, err := locateMetadata(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var patches []Patch
+	var chunks []metadataBlock
+	for _, block := range blocks {
+		data := make([]byte, block.length)
+		if _, err := readAt(r, block.offset, data); err != nil {
+			return nil, err
+		}
+		// the blocks may overlap, such as a TIFF file and its XMP packet
+		applyPatches(data, block.offset, patches)
+
+		var stripped bool
+		if block.xmp {
+			stripped, err = blankXMPGPS(data)
+		} else {
+			stripped, err = stripTiffGPS(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !stripped {
+			continue
+		}
+		if block.fixed {
+			return nil, ErrGPSNotStripped
+		}
+
+		patches = append(patches, Patch{Offset: block.offset, Data: data})
+		if block.crc != 0 && !slices.ContainsFunc(chunks, func(b metadataBlock) bool { return b.crc == block.crc }) {
+			chunks = append(chunks, block)
+		}
+	}
+
+	// the CRC of a PNG chunk covers all of its patches
+	for _, block := range chunks {
+		data := make([]byte, block.crc-block.chunk)
+		if _, err := readAt(r, block.chunk, data); err != nil {
+			return nil, err
+		}
+		applyPatches(data, block.chunk, patches)
+		patches = append(patches, Patch{Offset: block.crc, Data: binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data))})
+	}
+
+	return patches, nil
+}
+
+// applyPatches applies the patches to the data read at the offset.
+func applyPatches(data []byte, offset int64, patches []Patch) {
+	end := offset + int64(len(data))
+	for _, patch := range patches {
+		start, stop := max(patch.Offset, offset), min(patch.Offset+int64(len(patch.Data)), end)
+		if start < stop {
+			copy(data[start-offset:stop-offset], patch.Data[start-patch.Offset:])
+		}
+	}
+}
+
+// stripTiffGPS removes the GPS directory of a TIFF structure, failing when
+// it, or a value it references, is out of the structure.
+func stripTiffGPS(tiff []byte) (bool, error) {
+	if len(tiff) < 8 {
+		return false, ErrGPSNotStripped
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return false, ErrGPSNotStripped
+	}
+
+	ifd0 := order.Uint32(tiff[4:])
+	entries, ok := ifdEntries(tiff, order, ifd0)
+	if !ok {
+		return false, ErrGPSNotStripped
+	}
+
+	for i := 0; i < entries; i++ {
+		entry := int(ifd0) + 2 + i*ifdEntryLength
+		if order.Uint16(tiff[entry:]) != tagGPSInfo {
+			continue
+		}
+
+		gpsIfd := order.Uint32(tiff[entry+8:])
+		return clearIfd(tiff, order, gpsIfd)
+	}
+
+	return false, nil
+}
+
+func ifdEntries(tiff []byte, order binary.ByteOrder, offset uint32) (int, bool) {
+	if int(offset)+2 > len(tiff) {
+		return 0, false
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	if int(offset)+2+count*ifdEntryLength > len(tiff) {
+		return 0, false
+	}
+
+	return count, true
+}
+
+// clearIfd zeroes the values referenced by the directory and then the
+// directory itself, leaving an empty directory behind. It reports
+// whether the directory had any entry.
+func clearIfd(tiff []byte, order binary.ByteOrder, offset uint32) (bool, error) {
+	count, ok := ifdEntries(tiff, order, offset)
+	if !ok {
+		return false, ErrGPSNotStripped
+	}
+	if count == 0 {
+		return false, nil
+	}
+
+	// the values are all checked before anything is cleared
+	var values [][]byte
+	for i := 0; i < count; i++ {
+		entry := tiff[int(offset)+2+i*ifdEntryLength:]
+		typeSize, ok := exifTypeSizes[order.Uint16(entry[2:])]
+		if !ok {
+			continue
+		}
+
+		size := uint64(typeSize) * uint64(order.Uint32(entry[4:]))
+		if size <= 4 {
+			continue
+		}
+
+		valueOffset := uint64(order.Uint32(entry[8:]))
+		if valueOffset+size > uint64(len(tiff)) {
+			return false, ErrGPSNotStripped
+		}
+		values = append(values, tiff[valueOffset:valueOffset+size])
+	}
+
+	for _, value := range values {
+		clear(value)
+	}
+	clear(tiff[int(offset)+2 : int(offset)+2+count*ifdEntryLength])
+	order.PutUint16(tiff[offset:], 0)
+	return true, nil
+}
@@ -0,0 +1,38 @@
+package img
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripFileGPSWithoutGPS(t *testing.T) {
+	data, err := os.ReadFile("testdata/gray-sample.jpg")
+	require.NoError(t, err)
+
+	patches, err := StripFileGPS(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Empty(t, patches)
+}
+
+func gpsEntries(t *testing.T, data []byte) []*exif.IfdTagEntry {
+	t.Helper()
+
+	rawExif, err := exif.SearchAndExtractExif(data)
+	require.NoError(t, err)
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	require.NoError(t, err)
+
+	_, index, err := exif.Collect(im, exif.NewTagIndex(), rawExif)
+	require.NoError(t, err)
+
+	ifd, err := index.RootIfd.ChildWithIfdPath(exifcommon.IfdGpsInfoStandardIfdIdentity)
+	require.NoError(t, err)
+
+	return ifd.Entries()
+}
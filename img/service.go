@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"image"
 	"io"
+	"math/bits"
 
 	"github.com/disintegration/imaging"
 	"github.com/dsoprea/go-exif/v3"
@@ -257,3 +258,44 @@ func getEmbeddedThumbnail(in io.Reader) ([]byte, io.Reader, error) {
 	thm, err := ifd.Thumbnail()
 	return thm, wrappedReader, err
 }
+
+// PerceptualHash computes the difference hash (dHash) of an image. Similar
+// images have hashes with a small Hamming distance, see HashDistance.
+func (s *Service) PerceptualHash(ctx context.Context, in io.Reader) (uint64, error) {
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return 0, err
+	}
+	defer s.sem.Release(1)
+
+	_, wrappedReader, err := s.detectFormat(in)
+	if err != nil {
+		return 0, err
+	}
+
+	img, err := imaging.Decode(wrappedReader, imaging.AutoOrientation(true))
+	if err != nil {
+		return 0, err
+	}
+
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+			hash <<= 1
+			if left < right {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash, nil
+}
+
+// HashDistance returns the number of bits that differ between two
+// perceptual hashes.
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -8,6 +8,7 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"os"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -444,3 +445,28 @@ func TestService_FormatFromExtension(t *testing.T) {
 		})
 	}
 }
+
+func TestService_PerceptualHash(t *testing.T) {
+	svc := New(1)
+
+	source, err := os.ReadFile("testdata/IMG_2578.JPG")
+	require.NoError(t, err)
+
+	original, err := svc.PerceptualHash(context.Background(), bytes.NewReader(source))
+	require.NoError(t, err)
+
+	resized := &bytes.Buffer{}
+	err = svc.Resize(context.Background(), bytes.NewReader(source), 320, 320, resized, WithFormat(FormatPng))
+	require.NoError(t, err)
+
+	similar, err := svc.PerceptualHash(context.Background(), resized)
+	require.NoError(t, err)
+	require.LessOrEqual(t, HashDistance(original, similar), 5)
+
+	other, err := os.ReadFile("testdata/20130612_142406.jpg")
+	require.NoError(t, err)
+
+	different, err := svc.PerceptualHash(context.Background(), bytes.NewReader(other))
+	require.NoError(t, err)
+	require.Greater(t, HashDistance(original, different), 5)
+}
@@ -0,0 +1,82 @@
+package img
+
+import (
+	"bytes"
+	"regexp"
+)
+
+var (
+	// xmpGPSAttribute matches the GPS properties of an XMP packet written
+	// as attributes, such as exif:GPSLatitude="51,30.4N".
+	xmpGPSAttribute = regexp.MustCompile(`([\w.-]+):(?i:gps)[\w.-]*\s*=\s*("[^"]*"|'[^']*')`)
+	// xmpGPSElement matches the opening tag of the GPS properties written
+	// as elements.
+	xmpGPSElement = regexp.MustCompile(`<([\w.-]+:(?i:gps)[\w.-]*)(?:\s[^>]*)?>`)
+	// xmlAttribute matches the attributes of the elements nested in a GPS
+	// property.
+	xmlAttribute = regexp.MustCompile(`([\w.:-]+)\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+// blankXMPGPS replaces the values of the GPS properties of an XMP packet
+// with spaces, keeping its size and its structure. It reports whether any
+// value was blanked, and fails when a property isn't closed.
+func blankXMPGPS(packet []byte) (bool, error) {
+	blanked := false
+	for _, m := range xmpGPSAttribute.FindAllSubmatchIndex(packet, -1) {
+		if string(packet[m[2]:m[3]]) != "xmlns" {
+			blanked = blank(packet[m[4]+1:m[5]-1]) || blanked
+		}
+	}
+
+	for _, m := range xmpGPSElement.FindAllSubmatchIndex(packet, -1) {
+		if packet[m[1]-2] == '/' {
+			continue // empty element
+		}
+
+		closing := []byte("</" + string(packet[m[2]:m[3]]))
+		end := bytes.Index(packet[m[1]:], closing)
+		if end < 0 {
+			return false, ErrGPSNotStripped
+		}
+		content := packet[m[1] : m[1]+end]
+
+		for _, a := range xmlAttribute.FindAllSubmatchIndex(content, -1) {
+			if !bytes.HasPrefix(content[a[2]:a[3]], []byte("xmlns")) {
+				blanked = blank(content[a[4]+1:a[5]-1]) || blanked
+			}
+		}
+		blanked = blankText(content) || blanked
+	}
+
+	return blanked, nil
+}
+
+// blank replaces the bytes of b with spaces, reporting whether any of them
+// wasn't one.
+func blank(b []byte) bool {
+	blanked := false
+	for i, c := range b {
+		if c != ' ' {
+			b[i] = ' '
+			blanked = true
+		}
+	}
+	return blanked
+}
+
+// blankText blanks the text of the XML fragment b, outside of its tags.
+func blankText(b []byte) bool {
+	blanked, tag := false, false
+	for i, c := range b {
+		switch {
+		case c == '<':
+			tag = true
+		case c == '>':
+			tag = false
+		case !tag && c != ' ':
+			b[i] = ' '
+			blanked = true
+		}
+	}
+	return blanked
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// TrackFs wraps a file system so that the bytes written and the files
+// created through it are reported to the tracker. Writes fail once the
+// context is canceled, which stops the operations that don't take a
+// context themselves.
+func TrackFs(ctx context.Context, afs afero.Fs, tracker *Tracker) afero.Fs {
+	return &trackedFs{Fs: afs, ctx: ctx, tracker: tracker}
+}
+
+// TrackReads wraps a file so that the bytes read from it are reported to
+// the tracker, the file being counted once closed.
+func TrackReads(file fs.File, tracker *Tracker) fs.File {
+	return &readTrackedFile{File: file, tracker: tracker}
+}
+
+type trackedFs struct {
+	afero.Fs
+	ctx     context.Context
+	tracker *Tracker
+}
+
+func (f *trackedFs) Create(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (f *trackedFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return f.Fs.OpenFile(name, flag, perm)
+	}
+
+	if err := f.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := f.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedFile{File: file, fs: f}, nil
+}
+
+// RealPath forwards to the wrapped file system, see files.FileInfo.RealPath.
+func (f *trackedFs) RealPath(name string) (string, error) {
+	if realPathFs, ok := f.Fs.(interface {
+		RealPath(name string) (string, error)
+	}); ok {
+		return realPathFs.RealPath(name)
+	}
+	return name, nil
+}
+
+func (f *trackedFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if lstater, ok := f.Fs.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(name)
+	}
+	info, err := f.Fs.Stat(name)
+	return info, false, err
+}
+
+type trackedFile struct {
+	afero.File
+	fs *trackedFs
+}
+
+func (f *trackedFile) Write(p []byte) (int, error) {
+	if err := f.fs.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.File.Write(p)
+	f.fs.tracker.AddBytes(int64(n))
+	return n, err
+}
+
+func (f *trackedFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *trackedFile) Close() error {
+	f.fs.tracker.AddFiles(1)
+	return f.File.Close()
+}
+
+type readTrackedFile struct {
+	fs.File
+	tracker *Tracker
+}
+
+func (f *readTrackedFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.tracker.AddBytes(int64(n))
+	return n, err
+}
+
+func (f *readTrackedFile) Close() error {
+	f.tracker.AddFiles(1)
+	return f.File.Close()
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusQueued   Status = "queued"
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// Job is the persistent record of a long running file operation.
+type Job struct {
+	ID       string            `json:"id" storm:"id"`
+	UserID   uint              `json:"userID" storm:"index"`
+	Type     string            `json:"type"`
+	Params   map[string]string `json:"params"`
+	Status   Status            `json:"status"`
+	Progress Progress          `json:"progress"`
+	Error    string            `json:"error,omitempty"`
+	Result   json.RawMessage   `json:"result,omitempty"`
+	Created  time.Time         `json:"created"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *Job) Done() bool {
+	switch j.Status {
+	case StatusDone, StatusFailed, StatusCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Progress tells how much of the work has been done. The totals are
+// zero when they aren't known.
+type Progress struct {
+	Bytes      int64 `json:"bytes"`
+	TotalBytes int64 `json:"totalBytes"`
+	Files      int64 `json:"files"`
+	TotalFiles int64 `json:"totalFiles"`
+}
+
+// Tracker is used by a running job to report its progress. It is safe
+// for concurrent use.
+type Tracker struct {
+	bytes, totalBytes atomic.Int64
+	files, totalFiles atomic.Int64
+}
+
+// AddBytes records n more processed bytes.
+func (t *Tracker) AddBytes(n int64) {
+	t.bytes.Add(n)
+}
+
+// AddFiles records n more processed files.
+func (t *Tracker) AddFiles(n int64) {
+	t.files.Add(n)
+}
+
+// SetTotal sets the expected amount of bytes and files.
+func (t *Tracker) SetTotal(bytes, files int64) {
+	t.totalBytes.Store(bytes)
+	t.totalFiles.Store(files)
+}
+
+// Progress returns a snapshot of the progress.
+func (t *Tracker) Progress() Progress {
+	return Progress{
+		Bytes:      t.bytes.Load(),
+		TotalBytes: t.totalBytes.Load(),
+		Files:      t.files.Load(),
+		TotalFiles: t.totalFiles.Load(),
+	}
+}
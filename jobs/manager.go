@@ -0,0 +1,367 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/marusama/semaphore/v2"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// progressInterval is how often the progress of the running jobs is
+// saved and sent to the watchers.
+const progressInterval = time.Second
+
+// ErrNotRetryable means the job can only be retried once it has failed
+// or has been canceled.
+var ErrNotRetryable = errors.New("only failed or canceled jobs can be retried")
+
+// Func runs a job. It must stop when the context is canceled. The result
+// is stored as JSON with the job.
+type Func func(ctx context.Context, job *Job, tracker *Tracker) (interface{}, error)
+
+// Manager runs the jobs in the background, a limited number at a time,
+// and keeps their records up to date.
+type Manager struct {
+	store *Storage
+	sem   semaphore.Semaphore
+
+	mu       sync.Mutex
+	funcs    map[string]Func
+	cancels  map[string]context.CancelFunc
+	watchers map[string]map[chan Job]struct{}
+	onUpdate func(Job)
+}
+
+// NewManager creates a job manager running at most workers jobs at the
+// same time. The jobs that were still queued or running when the server
+// stopped are marked as failed, so they can be retried.
+func NewManager(store *Storage, workers int) (*Manager, error) {
+	all, err := store.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, job := range all {
+		if job.Done() {
+			continue
+		}
+		job.Status = StatusFailed
+		job.Error = "interrupted by a server restart"
+		job.Finished = time.Now()
+		if err := store.Save(job); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Manager{
+		store:    store,
+		sem:      semaphore.New(workers),
+		funcs:    map[string]Func{},
+		cancels:  map[string]context.CancelFunc{},
+		watchers: map[string]map[chan Job]struct{}{},
+	}, nil
+}
+
+// Register sets the function running the jobs of the given type.
+func (m *Manager) Register(typ string, fn Func) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcs[typ] = fn
+}
+
+// OnUpdate sets a function called every time a job changes. It must not
+// block.
+func (m *Manager) OnUpdate(fn func(Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onUpdate = fn
+}
+
+// Submit creates a job and queues it. The params must hold everything
+// needed to run the job again if it is retried.
+func (m *Manager) Submit(userID uint, typ string, params map[string]string) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		ID:      id,
+		UserID:  userID,
+		Type:    typ,
+		Params:  params,
+		Status:  StatusQueued,
+		Created: time.Now(),
+	}
+
+	if err := m.start(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Get returns a job.
+func (m *Manager) Get(id string) (*Job, error) {
+	return m.store.Get(id)
+}
+
+// List returns the jobs of a user.
+func (m *Manager) List(userID uint) ([]*Job, error) {
+	list, err := m.store.FindByUserID(userID)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return []*Job{}, nil
+	}
+	return list, err
+}
+
+// Cancel stops a queued or running job.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	cancel, ok := m.cancels[id]
+	m.mu.Unlock()
+
+	if !ok {
+		if _, err := m.store.Get(id); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	cancel()
+	return nil
+}
+
+// Retry runs a failed or canceled job again.
+func (m *Manager) Retry(id string) (*Job, error) {
+	// the lock keeps concurrent retries from starting the job twice
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != StatusFailed && job.Status != StatusCanceled {
+		return nil, ErrNotRetryable
+	}
+
+	job.Status = StatusQueued
+	job.Progress = Progress{}
+	job.Error = ""
+	job.Result = nil
+	job.Started = time.Time{}
+	job.Finished = time.Time{}
+
+	if err := m.startLocked(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Delete removes the record of a finished job.
+func (m *Manager) Delete(id string) error {
+	job, err := m.store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if !job.Done() {
+		return fberrors.ErrInvalidRequestParams
+	}
+
+	return m.store.Delete(id)
+}
+
+// Watch returns a channel receiving the job every time it changes. The
+// channel is closed once the job has finished. The returned function
+// must be called to stop watching.
+func (m *Manager) Watch(id string) (<-chan Job, func(), error) {
+	// the lock keeps the job from being updated between the read and the
+	// registration of the watcher
+	m.mu.Lock()
+	job, err := m.store.Get(id)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, nil, err
+	}
+
+	ch := make(chan Job, 1)
+	ch <- *job
+
+	_, running := m.cancels[id]
+	if !running {
+		m.mu.Unlock()
+		close(ch)
+		return ch, func() {}, nil
+	}
+	if m.watchers[id] == nil {
+		m.watchers[id] = map[chan Job]struct{}{}
+	}
+	m.watchers[id][ch] = struct{}{}
+	m.mu.Unlock()
+
+	stop := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.watchers[id][ch]; ok {
+			delete(m.watchers[id], ch)
+			close(ch)
+		}
+	}
+
+	return ch, stop, nil
+}
+
+func (m *Manager) start(job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.startLocked(job)
+}
+
+// startLocked saves the job and runs it, with the lock held.
+func (m *Manager) startLocked(job *Job) error {
+	fn, ok := m.funcs[job.Type]
+	if !ok {
+		return fmt.Errorf("unknown job type %q: %w", job.Type, fberrors.ErrInvalidRequestParams)
+	}
+
+	if err := m.store.Save(job); err != nil {
+		return err
+	}
+	if m.onUpdate != nil {
+		m.onUpdate(*job)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[job.ID] = cancel
+
+	// the goroutine works on its own copy of the job
+	running := *job
+	go m.run(ctx, cancel, &running, fn)
+	return nil
+}
+
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, job *Job, fn Func) {
+	defer cancel()
+
+	if err := m.sem.Acquire(ctx, 1); err != nil {
+		job.Status = StatusCanceled
+		job.Finished = time.Now()
+		m.update(job, true)
+		return
+	}
+	defer m.sem.Release(1)
+
+	job.Status = StatusRunning
+	job.Started = time.Now()
+	m.update(job, false)
+
+	// the function gets its own copy, the progress is updated meanwhile
+	params := *job
+	tracker := &Tracker{}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				job.Progress = tracker.Progress()
+				m.update(job, false)
+			}
+		}
+	}()
+
+	result, err := m.call(ctx, &params, tracker, fn)
+	close(done)
+	wg.Wait()
+
+	job.Progress = tracker.Progress()
+	job.Finished = time.Now()
+	switch {
+	case ctx.Err() != nil:
+		job.Status = StatusCanceled
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = StatusDone
+		if result != nil {
+			if job.Result, err = json.Marshal(result); err != nil {
+				log.Printf("job %s: failed to encode result: %v", job.ID, err)
+			}
+		}
+	}
+
+	m.update(job, true)
+}
+
+// call runs the job function, recovering from panics so that a broken
+// job can't take the server down.
+func (m *Manager) call(ctx context.Context, job *Job, tracker *Tracker, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return fn(ctx, job, tracker)
+}
+
+// update saves the job and sends it to its watchers. When final is set the
+// job is removed from the running ones and the watchers are closed.
+func (m *Manager) update(job *Job, final bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.store.Save(job); err != nil {
+		log.Printf("job %s: failed to save: %v", job.ID, err)
+	}
+
+	if m.onUpdate != nil {
+		m.onUpdate(*job)
+	}
+
+	for ch := range m.watchers[job.ID] {
+		// only the latest state matters to slow watchers
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- *job
+
+		if final {
+			close(ch)
+		}
+	}
+
+	if final {
+		delete(m.watchers, job.ID)
+		delete(m.cancels, job.ID)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
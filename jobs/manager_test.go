@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type memoryBackend struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+func (b *memoryBackend) All() ([]*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := []*Job{}
+	for _, job := range b.jobs {
+		job := job
+		list = append(list, &job)
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) FindByUserID(id uint) ([]*Job, error) {
+	all, _ := b.All()
+	list := []*Job{}
+	for _, job := range all {
+		if job.UserID == id {
+			list = append(list, job)
+		}
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Get(id string) (*Job, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	job, ok := b.jobs[id]
+	if !ok {
+		return nil, fberrors.ErrNotExist
+	}
+	return &job, nil
+}
+
+func (b *memoryBackend) Save(j *Job) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.jobs[j.ID] = *j
+	return nil
+}
+
+func (b *memoryBackend) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.jobs, id)
+	return nil
+}
+
+func waitJob(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+
+	updates, stop, err := m.Watch(id)
+	require.NoError(t, err)
+	defer stop()
+
+	var last Job
+	for job := range updates {
+		last = job
+	}
+	return last
+}
+
+func TestManager(t *testing.T) {
+	store := NewStorage(&memoryBackend{jobs: map[string]Job{}})
+	m, err := NewManager(store, 1)
+	require.NoError(t, err)
+
+	fail := true
+	m.Register("count", func(_ context.Context, job *Job, tracker *Tracker) (interface{}, error) {
+		tracker.AddFiles(2)
+		if fail && job.Params["fail"] == "true" {
+			return nil, errors.New("boom")
+		}
+		return job.Params["name"], nil
+	})
+	m.Register("block", func(ctx context.Context, _ *Job, _ *Tracker) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	_, err = m.Submit(1, "unknown", nil)
+	require.ErrorIs(t, err, fberrors.ErrInvalidRequestParams)
+
+	job, err := m.Submit(1, "count", map[string]string{"name": "a"})
+	require.NoError(t, err)
+	done := waitJob(t, m, job.ID)
+	require.Equal(t, StatusDone, done.Status)
+	require.Equal(t, int64(2), done.Progress.Files)
+	require.JSONEq(t, `"a"`, string(done.Result))
+
+	_, err = m.Retry(job.ID)
+	require.ErrorIs(t, err, ErrNotRetryable)
+
+	job, err = m.Submit(1, "count", map[string]string{"fail": "true"})
+	require.NoError(t, err)
+	failed := waitJob(t, m, job.ID)
+	require.Equal(t, StatusFailed, failed.Status)
+	require.Equal(t, "boom", failed.Error)
+
+	fail = false
+	_, err = m.Retry(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusDone, waitJob(t, m, job.ID).Status)
+
+	job, err = m.Submit(2, "block", nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, m.Delete(job.ID), fberrors.ErrInvalidRequestParams)
+	require.NoError(t, m.Cancel(job.ID))
+	require.Equal(t, StatusCanceled, waitJob(t, m, job.ID).Status)
+	require.NoError(t, m.Delete(job.ID))
+
+	list, err := m.List(1)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+}
+
+// slowBackend widens the window between reading a job and saving it.
+type slowBackend struct {
+	*memoryBackend
+}
+
+func (b slowBackend) Get(id string) (*Job, error) {
+	job, err := b.memoryBackend.Get(id)
+	time.Sleep(10 * time.Millisecond)
+	return job, err
+}
+
+func TestManagerConcurrentRetry(t *testing.T) {
+	store := NewStorage(slowBackend{&memoryBackend{jobs: map[string]Job{}}})
+	require.NoError(t, store.Save(&Job{ID: "a", Type: "count", Status: StatusFailed}))
+	m, err := NewManager(store, 1)
+	require.NoError(t, err)
+
+	var runs atomic.Int32
+	m.Register("count", func(_ context.Context, _ *Job, _ *Tracker) (interface{}, error) {
+		runs.Add(1)
+		return nil, nil
+	})
+
+	var wg sync.WaitGroup
+	var retried atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Retry("a"); err == nil {
+				retried.Add(1)
+			} else {
+				require.ErrorIs(t, err, ErrNotRetryable)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, StatusDone, waitJob(t, m, "a").Status)
+	require.Equal(t, int32(1), retried.Load())
+	require.Equal(t, int32(1), runs.Load())
+}
+
+func TestManagerRestart(t *testing.T) {
+	store := NewStorage(&memoryBackend{jobs: map[string]Job{}})
+	require.NoError(t, store.Save(&Job{ID: "a", Status: StatusRunning}))
+	require.NoError(t, store.Save(&Job{ID: "b", Status: StatusDone}))
+
+	_, err := NewManager(store, 1)
+	require.NoError(t, err)
+
+	job, err := store.Get("a")
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, job.Status)
+
+	job, err = store.Get("b")
+	require.NoError(t, err)
+	require.Equal(t, StatusDone, job.Status)
+}
@@ -0,0 +1,36 @@
+package locks
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Lock claims a file, or a directory with everything in it, for a user.
+// Enforced locks block the changes of the other users while the advisory
+// ones only tell them about the claim.
+type Lock struct {
+	Path     string    `json:"path" storm:"id"` // full path on the server
+	UserID   uint      `json:"userID" storm:"index"`
+	Username string    `json:"username"`
+	Note     string    `json:"note,omitempty"`
+	Enforced bool      `json:"enforced"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"` // zero if the lock never expires
+}
+
+// Expired reports whether the lock has expired at the given time.
+func (l *Lock) Expired(now time.Time) bool {
+	return !l.Expires.IsZero() && !now.Before(l.Expires)
+}
+
+// Covers reports whether the lock applies to the path, being the locked
+// path or inside the locked directory.
+func (l *Lock) Covers(p string) bool {
+	return within(p, l.Path)
+}
+
+// within reports whether p is dir or is inside it.
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,176 @@
+package locks
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// StorageBackend is the interface to implement for a locks storage.
+type StorageBackend interface {
+	All() ([]*Lock, error)
+	Get(path string) (*Lock, error)
+	Save(l *Lock) error
+	Delete(path string) error
+}
+
+// Storage is a storage. It keeps the locks consistent, the expired ones
+// being ignored and removed along the way.
+type Storage struct {
+	back StorageBackend
+	mu   sync.Mutex
+}
+
+// NewStorage creates a locks storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// active returns the locks that haven't expired. The caller must hold mu.
+func (s *Storage) active() ([]*Lock, error) {
+	all, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return nil, err
+	}
+
+	now := time.Now()
+	locks := make([]*Lock, 0, len(all))
+	for _, l := range all {
+		if l.Expired(now) {
+			_ = s.back.Delete(l.Path)
+			continue
+		}
+		locks = append(locks, l)
+	}
+
+	return locks, nil
+}
+
+// Active returns the locks that haven't expired.
+func (s *Storage) Active() ([]*Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active()
+}
+
+// Get returns the lock of the path.
+func (s *Storage) Get(path string) (*Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.back.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if l.Expired(time.Now()) {
+		_ = s.back.Delete(path)
+		return nil, fberrors.ErrNotExist
+	}
+
+	return l, nil
+}
+
+// Acquire saves the lock, renewing it if the user already holds the lock
+// of the path. It fails with fberrors.ErrLocked when another user holds a
+// lock on the path, on a directory containing it or on something in it.
+func (s *Storage) Acquire(l *Lock) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks, err := s.active()
+	if err != nil {
+		return err
+	}
+
+	for _, other := range locks {
+		if other.UserID != l.UserID && (other.Covers(l.Path) || l.Covers(other.Path)) {
+			return fberrors.ErrLocked
+		}
+	}
+
+	return s.back.Save(l)
+}
+
+// Blocking returns the enforced lock of another user that prevents changing
+// the path, or everything in it when recursive, nil if there is none.
+func (s *Storage) Blocking(path string, userID uint, recursive bool) (*Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks, err := s.active()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range locks {
+		if !l.Enforced || l.UserID == userID {
+			continue
+		}
+		if l.Covers(path) || (recursive && within(l.Path, path)) {
+			return l, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Release removes the lock of the path.
+func (s *Storage) Release(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.back.Delete(path)
+}
+
+// ReleaseWithin removes the locks of the path and of everything in it, as
+// when it is deleted.
+func (s *Storage) ReleaseWithin(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks, err := s.active()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range locks {
+		if within(l.Path, path) {
+			if err := s.back.Delete(l.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Move moves the locks of the path and of everything in it to their new
+// path, as when it is renamed.
+func (s *Storage) Move(src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks, err := s.active()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range locks {
+		if !within(l.Path, src) {
+			continue
+		}
+
+		if err := s.back.Delete(l.Path); err != nil {
+			return err
+		}
+		l.Path = dst + l.Path[len(src):]
+		if err := s.back.Save(l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package locks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type memoryBackend struct {
+	locks map[string]Lock
+}
+
+func (b *memoryBackend) All() ([]*Lock, error) {
+	list := []*Lock{}
+	for _, l := range b.locks {
+		l := l
+		list = append(list, &l)
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Get(path string) (*Lock, error) {
+	l, ok := b.locks[path]
+	if !ok {
+		return nil, fberrors.ErrNotExist
+	}
+	return &l, nil
+}
+
+func (b *memoryBackend) Save(l *Lock) error {
+	b.locks[l.Path] = *l
+	return nil
+}
+
+func (b *memoryBackend) Delete(path string) error {
+	delete(b.locks, path)
+	return nil
+}
+
+func TestStorage(t *testing.T) {
+	s := NewStorage(&memoryBackend{locks: map[string]Lock{}})
+
+	require.NoError(t, s.Acquire(&Lock{Path: "/srv/docs", UserID: 1, Enforced: true}))
+	require.NoError(t, s.Acquire(&Lock{Path: "/srv/docs", UserID: 1, Enforced: true, Note: "renewed"}))
+	require.ErrorIs(t, s.Acquire(&Lock{Path: "/srv/docs/a.txt", UserID: 2}), fberrors.ErrLocked)
+	require.ErrorIs(t, s.Acquire(&Lock{Path: "/srv", UserID: 2}), fberrors.ErrLocked)
+	require.NoError(t, s.Acquire(&Lock{Path: "/srv/docsx", UserID: 2}))
+	require.NoError(t, s.Acquire(&Lock{Path: "/srv/old", UserID: 2, Enforced: true, Expires: time.Now().Add(-time.Second)}))
+
+	lock, err := s.Blocking("/srv/docs/a.txt", 2, false)
+	require.NoError(t, err)
+	require.Equal(t, "renewed", lock.Note)
+
+	lock, err = s.Blocking("/srv/docs/a.txt", 1, false)
+	require.NoError(t, err)
+	require.Nil(t, lock)
+
+	lock, err = s.Blocking("/srv", 2, false)
+	require.NoError(t, err)
+	require.Nil(t, lock)
+
+	lock, err = s.Blocking("/srv", 2, true)
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+
+	_, err = s.Get("/srv/old")
+	require.ErrorIs(t, err, fberrors.ErrNotExist)
+
+	require.NoError(t, s.Move("/srv/docs", "/srv/papers"))
+	_, err = s.Get("/srv/papers")
+	require.NoError(t, err)
+
+	require.NoError(t, s.ReleaseWithin("/srv"))
+	active, err := s.Active()
+	require.NoError(t, err)
+	require.Empty(t, active)
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "*/15 0-6,22 1 jan-mar mon-fri", "@daily", "0 12 * * 7", "5/10 * * * *"} {
+		_, err := ParseCron(expr)
+		require.NoError(t, err, expr)
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "1,,2 * * * *"} {
+		_, err := ParseCron(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		require.NoError(t, err)
+		return v
+	}
+
+	tests := []struct {
+		expr, from, next string
+	}{
+		{"*/15 * * * *", "2026-03-02 10:07", "2026-03-02 10:15"},
+		{"*/15 * * * *", "2026-03-02 10:45", "2026-03-02 11:00"},
+		{"@daily", "2026-03-02 10:07", "2026-03-03 00:00"},
+		{"30 2 * * sun", "2026-03-02 10:07", "2026-03-08 02:30"},
+		{"0 0 29 2 *", "2026-03-02 10:07", "2028-02-29 00:00"},
+		// Either of the restricted days matches.
+		{"0 9 15 * mon", "2026-03-10 10:00", "2026-03-15 09:00"},
+		{"0 9 20 * mon", "2026-03-10 10:00", "2026-03-16 09:00"},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		require.NoError(t, err)
+
+		next := c.Next(at(tt.from))
+		require.Equal(t, at(tt.next), next, tt.expr)
+		require.True(t, c.Matches(next), tt.expr)
+		require.False(t, c.Matches(next.Add(-time.Minute)), tt.expr)
+	}
+
+	c, err := ParseCron("0 0 30 2 *")
+	require.NoError(t, err)
+	require.True(t, c.Next(time.Now()).IsZero())
+}
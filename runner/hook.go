@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HookError is the failure of a hook command, or the rejection of the
+// operation by a before hook.
+type HookError struct {
+	Event    string `json:"event"`
+	Command  string `json:"command"`
+	ExitCode int    `json:"exitCode"` // -1 when the command didn't exit
+	TimedOut bool   `json:"timedOut,omitempty"`
+	Stderr   string `json:"stderr,omitempty"` // the end of the error output
+	Message  string `json:"message,omitempty"`
+	Err      error  `json:"-"`
+}
+
+func (e *HookError) Error() string {
+	switch {
+	case e.Message != "":
+		return fmt.Sprintf("%s hook: %s", e.Event, e.Message)
+	case e.TimedOut:
+		return fmt.Sprintf("%s hook %q timed out", e.Event, e.Command)
+	case e.Err != nil:
+		return fmt.Sprintf("%s hook %q: %v", e.Event, e.Command, e.Err)
+	default:
+		return fmt.Sprintf("%s hook %q rejected the operation", e.Event, e.Command)
+	}
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Rejected reports whether a before hook failed, the operation not being
+// done.
+func (e *HookError) Rejected() bool {
+	return strings.HasPrefix(e.Event, "before_")
+}
+
+// hookResponse is the JSON a before hook can print to modify the
+// operation: rejecting it with a message, which a failing hook can give
+// too, or rewriting its destination in the scope of the user.
+type hookResponse struct {
+	Reject      bool   `json:"reject"`
+	Message     string `json:"message"`
+	Destination string `json:"destination"`
+}
+
+// parseHookResponse returns the response in the output of a hook, or nil
+// when it isn't a JSON object.
+func parseHookResponse(out []byte) *hookResponse {
+	out = bytes.TrimSpace(out)
+	if !bytes.HasPrefix(out, []byte("{")) {
+		return nil
+	}
+
+	var resp hookResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil
+	}
+	return &resp
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if left := b.max - b.buf.Len(); len(p) > left {
+		b.buf.Write(p[:max(left, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
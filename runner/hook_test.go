@@ -0,0 +1,87 @@
+//go:build !windows
+
+package runner
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestRunHookDst(t *testing.T) {
+	root := t.TempDir()
+	user := &users.User{Username: "alice", Scope: "/", Fs: afero.NewBasePathFs(afero.NewOsFs(), root)}
+	r := &Runner{Enabled: true, Settings: &settings.Settings{
+		Shell:          []string{"sh", "-c"},
+		CommandTimeout: "1s",
+	}}
+
+	// the before hook rejects the operation with a message
+	r.Commands = map[string][]string{"before_upload": {
+		`echo '{"reject": true, "message": "no executables"}'`,
+	}}
+	called := false
+	err := r.RunHook(func() error {
+		called = true
+		return nil
+	}, "upload", "/a.exe", "", user)
+	var hookErr *HookError
+	require.True(t, errors.As(err, &hookErr))
+	require.True(t, hookErr.Rejected())
+	require.Equal(t, "no executables", hookErr.Message)
+	require.Equal(t, 0, hookErr.ExitCode)
+	require.False(t, called)
+
+	// the failing hook gets its exit code and the end of its errors
+	r.Commands = map[string][]string{"before_upload": {
+		`head -c 10000 /dev/zero | tr '\0' x >&2; echo done >&2; exit 4`,
+	}}
+	err = r.RunHook(func() error { return nil }, "upload", "/a.txt", "", user)
+	require.True(t, errors.As(err, &hookErr))
+	require.Equal(t, 4, hookErr.ExitCode)
+	require.Len(t, hookErr.Stderr, maxHookStderr)
+	require.True(t, strings.HasSuffix(hookErr.Stderr, "xdone\n"))
+
+	// the hook running longer than the timeout is killed
+	r.Commands = map[string][]string{"before_upload": {"exec sleep 10"}}
+	err = r.RunHook(func() error { return nil }, "upload", "/a.txt", "", user)
+	require.True(t, errors.As(err, &hookErr))
+	require.True(t, hookErr.TimedOut)
+
+	// the before hook rewrites the destination, seen by the after hook
+	r.Commands = map[string][]string{
+		"before_copy": {`echo '{"destination": "archive/b.txt"}'`},
+		"after_copy":  {`test "$DESTINATION" = "` + filepath.Join(root, "archive", "b.txt") + `"`},
+	}
+	var target string
+	err = r.RunHookDst(func(dst string) error {
+		target = dst
+		return nil
+	}, "copy", "/a.txt", "/b.txt", user)
+	require.NoError(t, err)
+	require.Equal(t, "/archive/b.txt", target)
+
+	// the operations without a destination can't have it rewritten
+	r.Commands = map[string][]string{"before_upload": {`echo '{"destination": "/elsewhere.txt"}'`}}
+	err = r.RunHook(func() error {
+		called = true
+		return nil
+	}, "upload", "/a.txt", "", user)
+	require.True(t, errors.As(err, &hookErr))
+	require.True(t, hookErr.Rejected())
+	require.Equal(t, "upload has no destination to rewrite", hookErr.Message)
+	require.False(t, called)
+
+	// an after hook failing doesn't reject the done operation
+	r.Commands = map[string][]string{"after_copy": {"exit 1"}}
+	err = r.RunHookDst(func(string) error { return nil }, "copy", "/a.txt", "/b.txt", user)
+	require.True(t, errors.As(err, &hookErr))
+	require.False(t, hookErr.Rejected())
+}
@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"cmp"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// Process is a running command, started by any runner.
+type Process struct {
+	ID       uint64    `json:"id"`
+	PID      int       `json:"pid"`
+	Command  string    `json:"command"`
+	Trigger  string    `json:"trigger"` // the event of a hook, "schedule" or "command"
+	Username string    `json:"username"`
+	Started  time.Time `json:"started"`
+	cmd      *exec.Cmd
+}
+
+var processes = struct {
+	sync.Mutex
+	last uint64
+	list map[uint64]*Process
+}{list: map[uint64]*Process{}}
+
+// Start starts the command and keeps it in the running processes until it
+// is waited for.
+func (r *Runner) Start(cmd *exec.Cmd, trigger string, user *users.User) (*Process, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &Process{
+		PID:      cmd.Process.Pid,
+		Command:  strings.Join(cmd.Args, " "),
+		Trigger:  trigger,
+		Username: user.Username,
+		Started:  time.Now(),
+		cmd:      cmd,
+	}
+
+	processes.Lock()
+	processes.last++
+	p.ID = processes.last
+	processes.list[p.ID] = p
+	processes.Unlock()
+
+	return p, nil
+}
+
+// Wait waits for the command to exit, removing it from the running
+// processes.
+func (p *Process) Wait() error {
+	defer func() {
+		processes.Lock()
+		delete(processes.list, p.ID)
+		processes.Unlock()
+	}()
+	return p.cmd.Wait()
+}
+
+// Processes returns the running processes, from the oldest.
+func Processes() []*Process {
+	processes.Lock()
+	defer processes.Unlock()
+
+	list := make([]*Process, 0, len(processes.list))
+	for _, p := range processes.list {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(a, b *Process) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return list
+}
+
+// Kill kills the running process, with the processes it started.
+func Kill(id uint64) error {
+	processes.Lock()
+	p, ok := processes.list[id]
+	processes.Unlock()
+	if !ok {
+		return fberrors.ErrNotExist
+	}
+	return killGroup(p.cmd.Process)
+}
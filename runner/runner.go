@@ -1,50 +1,96 @@
 package runner
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
+	"time"
 
+	"github.com/filebrowser/filebrowser/v2/events"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/users"
 )
 
+const (
+	// maxHookOutput is how much of the output of a hook is read for its
+	// response.
+	maxHookOutput = 64 << 10
+	// maxHookStderr is how much of the end of the error output of a hook
+	// is kept for its error.
+	maxHookStderr = 4 << 10
+	// commandWaitDelay is how long the output of a command is read after
+	// it exited or was killed.
+	commandWaitDelay = 5 * time.Second
+)
+
 // Runner is a commands runner.
 type Runner struct {
 	Enabled bool
 	*settings.Settings
+	// Events receives the changes made by the hooked operations.
+	Events *events.Bus
 }
 
 // RunHook runs the hooks for the before and after event.
 func (r *Runner) RunHook(fn func() error, evt, path, dst string, user *users.User) error {
-	path = user.FullPath(path)
-	dst = user.FullPath(dst)
+	return r.RunHookDst(func(string) error {
+		return fn()
+	}, evt, path, dst, user)
+}
 
+// RunHookDst runs the hooks for the before and after event of an operation
+// with a destination, which the before hooks can rewrite. fn gets the
+// destination to act on, in the scope of the user, and must check it when
+// it isn't dst. A before hook rewriting the destination of an operation
+// without one, dst being empty, rejects it.
+func (r *Runner) RunHookDst(fn func(dst string) error, evt, src, dst string, user *users.User) error {
 	if r.Enabled {
-		if val, ok := r.Commands["before_"+evt]; ok {
-			for _, command := range val {
-				err := r.exec(command, "before_"+evt, path, dst, user)
-				if err != nil {
-					return err
+		for _, command := range r.Commands["before_"+evt] {
+			out, err := r.exec(command, "before_"+evt, user.FullPath(src), user.FullPath(dst), user)
+			if err != nil {
+				return err
+			}
+			if out == nil || out.Destination == "" {
+				continue
+			}
+			if dst == "" {
+				// only copy and rename have a destination, not the
+				// operations on a single path such as upload and save
+				return &HookError{
+					Event:   "before_" + evt,
+					Command: command,
+					Message: fmt.Sprintf("%s has no destination to rewrite", evt),
 				}
 			}
+			dst = path.Clean("/" + out.Destination)
 		}
 	}
 
-	err := fn()
+	// events only get a destination when the operation has one
+	fullSrc, eventDst := user.FullPath(src), fullDst(user, dst)
+
+	err := r.run(func() error {
+		return fn(dst)
+	}, fullSrc, eventDst)
 	if err != nil {
 		return err
 	}
 
+	if r.Events != nil {
+		r.Events.PublishHook(evt, fullSrc, eventDst, user.Username)
+	}
+
 	if r.Enabled {
-		if val, ok := r.Commands["after_"+evt]; ok {
-			for _, command := range val {
-				err := r.exec(command, "after_"+evt, path, dst, user)
-				if err != nil {
-					return err
-				}
+		for _, command := range r.Commands["after_"+evt] {
+			_, err := r.exec(command, "after_"+evt, fullSrc, user.FullPath(dst), user)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -52,7 +98,25 @@ func (r *Runner) RunHook(fn func() error, evt, path, dst string, user *users.Use
 	return nil
 }
 
-func (r *Runner) exec(raw, evt, path, dst string, user *users.User) error {
+func fullDst(user *users.User, dst string) string {
+	if dst == "" {
+		return ""
+	}
+	return user.FullPath(dst)
+}
+
+func (r *Runner) run(fn func() error, path, dst string) error {
+	if r.Events != nil {
+		defer r.Events.Begin(path, dst)()
+	}
+	return fn()
+}
+
+// exec runs the command of the hook. A command ending with "&" is started
+// without waiting for it, otherwise it is killed after the command timeout
+// and its output is kept, for its error and the response of the before
+// hooks.
+func (r *Runner) exec(raw, evt, path, dst string, user *users.User) (*hookResponse, error) {
 	blocking := true
 
 	if strings.HasSuffix(raw, "&") {
@@ -60,59 +124,101 @@ func (r *Runner) exec(raw, evt, path, dst string, user *users.User) error {
 		raw = strings.TrimSpace(strings.TrimSuffix(raw, "&"))
 	}
 
-	command, _, err := ParseCommand(r.Settings, raw)
+	if !blocking {
+		cmd, err := r.command(context.Background(), raw, evt, path, dst, user)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		log.Printf("[INFO] Nonblocking Command: \"%s\"", strings.Join(cmd.Args, " "))
+		p, err := r.Start(cmd, evt, user)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			err := p.Wait()
+			if err != nil {
+				log.Printf("[INFO] Nonblocking Command \"%s\" failed: %s", strings.Join(cmd.Args, " "), err)
+			}
+		}()
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.GetCommandTimeout())
+	defer cancel()
+
+	cmd, err := r.command(ctx, raw, evt, path, dst, user)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	envMapping := func(key string) string {
-		switch key {
-		case "FILE":
-			return path
-		case "SCOPE":
-			return user.Scope
-		case "TRIGGER":
-			return evt
-		case "USERNAME":
-			return user.Username
-		case "DESTINATION":
-			return dst
-		default:
-			return os.Getenv(key)
-		}
+	stdout := &limitedBuffer{max: maxHookOutput}
+	stderr := &tailBuffer{max: maxHookStderr}
+	cmd.Stdout = io.MultiWriter(os.Stdout, stdout)
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+
+	log.Printf("[INFO] Blocking Command: \"%s\"", strings.Join(cmd.Args, " "))
+	p, err := r.Start(cmd, evt, user)
+	if err == nil {
+		err = p.Wait()
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// the command exited, leaving its output open to another process
+		err = nil
 	}
-	for i, arg := range command {
-		if i == 0 {
-			continue
-		}
 
-		command[i] = os.Expand(arg, envMapping)
+	var resp *hookResponse
+	if strings.HasPrefix(evt, "before_") {
+		resp = parseHookResponse(stdout.buf.Bytes())
 	}
 
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("FILE=%s", path))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("SCOPE=%s", user.Scope))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TRIGGER=%s", evt))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("USERNAME=%s", user.Username))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("DESTINATION=%s", dst))
+	if err == nil && (resp == nil || !resp.Reject) {
+		return resp, nil
+	}
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	hookErr := &HookError{
+		Event:    evt,
+		Command:  raw,
+		ExitCode: -1,
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+		Stderr:   stderr.String(),
+		Err:      err,
+	}
+	if cmd.ProcessState != nil {
+		hookErr.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if resp != nil {
+		hookErr.Message = resp.Message
+	}
+	log.Printf("[INFO] Blocking Command \"%s\" failed: %s", strings.Join(cmd.Args, " "), hookErr)
+	return nil, hookErr
+}
 
-	if !blocking {
-		log.Printf("[INFO] Nonblocking Command: \"%s\"", strings.Join(command, " "))
-		defer func() {
-			go func() {
-				err := cmd.Wait()
-				if err != nil {
-					log.Printf("[INFO] Nonblocking Command \"%s\" failed: %s", strings.Join(command, " "), err)
-				}
-			}()
-		}()
-		return cmd.Start()
+// command returns the command to run with the variables of the event set
+// and expanded in its arguments.
+func (r *Runner) command(ctx context.Context, raw, evt, path, dst string, user *users.User) (*exec.Cmd, error) {
+	command, _, err := ParseCommand(r.Settings, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	env := r.environ(user,
+		"FILE="+path,
+		"SCOPE="+user.Scope,
+		"TRIGGER="+evt,
+		"USERNAME="+user.Username,
+		"DESTINATION="+dst,
+	)
+	for i, arg := range command {
+		if i == 0 {
+			continue
+		}
+
+		command[i] = os.Expand(arg, getenv(env))
 	}
 
-	log.Printf("[INFO] Blocking Command: \"%s\"", strings.Join(command, " "))
-	return cmd.Run()
+	return r.sandboxed(ctx, command, env, user)
 }
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// Command returns the command of the command line to run in the directory
+// dir of the scope of the user, sandboxed.
+func (r *Runner) Command(ctx context.Context, raw, dir string, user *users.User) (*exec.Cmd, error) {
+	command, _, err := ParseCommand(r.Settings, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := r.sandboxed(ctx, command, r.environ(user), user)
+	if err != nil {
+		return nil, err
+	}
+
+	// the directory must not lead out of the scope through a link
+	scope, err := filepath.EvalSymlinks(cmd.Dir)
+	if err != nil {
+		return nil, err
+	}
+	full, err := filepath.EvalSymlinks(user.FullPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(scope, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fberrors.ErrPermissionDenied
+	}
+
+	cmd.Dir = full
+	return cmd, nil
+}
+
+// environ returns the environment of the commands run for the user, with
+// the variables vars.
+func (r *Runner) environ(user *users.User, vars ...string) []string {
+	scopeDir := user.FullPath("/")
+
+	var env []string
+	if !r.Sandbox.CleanEnv {
+		env = os.Environ()
+	} else {
+		for _, key := range append([]string{"PATH", "LANG"}, r.Sandbox.Env...) {
+			if val, ok := os.LookupEnv(key); ok {
+				env = append(env, key+"="+val)
+			}
+		}
+		env = append(env, "HOME="+scopeDir)
+	}
+
+	env = append(env, "SCOPE_DIR="+scopeDir)
+	return append(env, vars...)
+}
+
+// sandboxed returns the command to run with the environment env in the
+// directory of the scope of the user, by the wrapper of the sandbox when
+// there is one, with the resource limits of the sandbox and in its own
+// process group.
+func (r *Runner) sandboxed(ctx context.Context, command, env []string, user *users.User) (*exec.Cmd, error) {
+	if len(r.Sandbox.Wrapper) > 0 {
+		wrapper := make([]string, 0, len(r.Sandbox.Wrapper)+len(command))
+		for _, arg := range r.Sandbox.Wrapper {
+			wrapper = append(wrapper, os.Expand(arg, getenv(env)))
+		}
+		command = append(wrapper, command...)
+	}
+
+	command, err := limited(command, r.Sandbox)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = user.FullPath("/")
+	cmd.Env = env
+	cmd.WaitDelay = commandWaitDelay
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+// getenv returns the mapping of the variables of the environment env, the
+// last value of a variable being its own.
+func getenv(env []string) func(string) string {
+	return func(key string) string {
+		for i := len(env) - 1; i >= 0; i-- {
+			if val, ok := strings.CutPrefix(env[i], key+"="); ok {
+				return val
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// limited returns the command run by a shell setting the resource limits
+// of the sandbox before executing it, for them to apply from its start to
+// every process it starts.
+func limited(command []string, sb settings.Sandbox) ([]string, error) {
+	var limits []string
+	if sb.CPUTime > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", sb.CPUTime))
+	}
+	if sb.Memory > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", max(sb.Memory/1024, 1)))
+	}
+	if len(limits) == 0 {
+		return command, nil
+	}
+
+	script := strings.Join(limits, " && ") + ` && exec "$@"`
+	return append([]string{"/bin/sh", "-c", script, "sh"}, command...), nil
+}
+
+// setProcessGroup makes the command lead its own process group, for it to
+// be killed with the processes it started.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return killGroup(cmd.Process)
+	}
+}
+
+// killGroup kills the process group led by the process.
+func killGroup(p *os.Process) error {
+	err := syscall.Kill(-p.Pid, syscall.SIGKILL)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
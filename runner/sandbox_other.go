@@ -0,0 +1,27 @@
+//go:build !linux
+
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// limited fails when the sandbox has resource limits, which are only
+// supported on Linux, for the commands not to run without them.
+func limited(command []string, sb settings.Sandbox) ([]string, error) {
+	if sb.CPUTime > 0 || sb.Memory > 0 {
+		return nil, errors.New("the resource limits of the commands are only supported on Linux")
+	}
+	return command, nil
+}
+
+// setProcessGroup does nothing, the commands being killed alone.
+func setProcessGroup(_ *exec.Cmd) {}
+
+func killGroup(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestSandboxedCommand(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "docs"), 0755))
+	require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(root, "out")))
+	t.Setenv("FB_SECRET", "s3cret")
+
+	user := &users.User{Username: "alice", Scope: "/", Fs: afero.NewBasePathFs(afero.NewOsFs(), root)}
+	r := &Runner{Enabled: true, Settings: &settings.Settings{
+		Shell: []string{"sh", "-c"},
+		Sandbox: settings.Sandbox{
+			CleanEnv: true,
+			CPUTime:  5,
+			Wrapper:  []string{"env", "WRAPPED=$USERNAME"},
+		},
+	}}
+
+	// the limits apply to the processes the command starts too
+	cmd, err := r.command(context.Background(), `pwd; echo "$HOME $FB_SECRET"; printenv WRAPPED; sh -c 'ulimit -t'`, "before_upload", "/a.txt", "", user)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	p, err := r.Start(cmd, "before_upload", user)
+	require.NoError(t, err)
+	require.Contains(t, Processes(), p)
+	require.NoError(t, p.Wait())
+	require.NotContains(t, Processes(), p)
+
+	dir, err := filepath.EvalSymlinks(root)
+	require.NoError(t, err)
+	require.Equal(t, dir+"\n"+root+" \nalice\n5\n", out.String())
+
+	// the running process is killed with the processes it started
+	marker := filepath.Join(root, "marker")
+	cmd, err = r.command(context.Background(), "(sleep 1; touch "+marker+") & wait", "before_upload", "/a.txt", "", user)
+	require.NoError(t, err)
+	p, err = r.Start(cmd, "before_upload", user)
+	require.NoError(t, err)
+	require.NoError(t, Kill(p.ID))
+	require.Error(t, p.Wait())
+	require.ErrorIs(t, Kill(p.ID), fberrors.ErrNotExist)
+
+	// and so is the one timing out
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cmd, err = r.command(ctx, "(sleep 1; touch "+marker+") & wait", "before_upload", "/a.txt", "", user)
+	require.NoError(t, err)
+	p, err = r.Start(cmd, "before_upload", user)
+	require.NoError(t, err)
+	require.Error(t, p.Wait())
+
+	time.Sleep(1500 * time.Millisecond)
+	require.NoFileExists(t, marker)
+
+	// the command line runs in the directory, but not out of the scope
+	cmd, err = r.Command(context.Background(), "pwd", "/docs", user)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "docs"), cmd.Dir)
+	_, err = r.Command(context.Background(), "pwd", "/out", user)
+	require.ErrorIs(t, err, fberrors.ErrPermissionDenied)
+}
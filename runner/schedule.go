@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/schedules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// maxScheduleOutput is how much of the output of a scheduled command is
+// kept in its run.
+const maxScheduleOutput = 64 << 10
+
+// RunSchedule runs the command of the schedule as the user, in the
+// directory of the schedule, which is also its FILE. It returns the run
+// with the exit code and the output of the command, which is killed once
+// the command timeout has passed.
+func (r *Runner) RunSchedule(s settings.Schedule, user *users.User) *schedules.Run {
+	run := &schedules.Run{
+		Schedule: s.Name,
+		Command:  s.Command,
+		Username: user.Username,
+		Started:  time.Now(),
+		ExitCode: -1,
+	}
+
+	timeout := r.GetCommandTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dir := user.FullPath(s.Path)
+	cmd, err := r.command(ctx, s.Command, "schedule", dir, "", user)
+	if err == nil {
+		out := &limitedBuffer{max: maxScheduleOutput}
+		cmd.Dir, cmd.Stdout, cmd.Stderr = dir, out, out
+
+		log.Printf("[INFO] Scheduled Command %s: \"%s\"", s.Name, strings.Join(cmd.Args, " "))
+		var p *Process
+		if p, err = r.Start(cmd, "schedule", user); err == nil {
+			err = p.Wait()
+		}
+		run.Output, run.Truncated = out.buf.String(), out.truncated
+	}
+	run.Finished = time.Now()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Error = fmt.Sprintf("the command timed out after %s", timeout)
+	case err == nil:
+		run.ExitCode = 0
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		run.ExitCode = exitErr.ExitCode()
+	default:
+		run.Error = err.Error()
+	}
+
+	return run
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestRunSchedule(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "reports"), 0755))
+
+	user := &users.User{Username: "alice", Scope: "/", Fs: afero.NewBasePathFs(afero.NewOsFs(), root)}
+	r := &Runner{Enabled: true, Settings: &settings.Settings{Shell: []string{"sh", "-c"}}}
+
+	run := r.RunSchedule(settings.Schedule{
+		Name:     "report",
+		Command:  `pwd; echo "$USERNAME $TRIGGER"; exit 3`,
+		Username: "alice",
+		Path:     "/reports",
+	}, user)
+
+	require.Equal(t, 3, run.ExitCode)
+	require.Empty(t, run.Error)
+	dir, err := filepath.EvalSymlinks(filepath.Join(root, "reports"))
+	require.NoError(t, err)
+	require.Equal(t, dir+"\nalice schedule\n", run.Output)
+
+	run = r.RunSchedule(settings.Schedule{Name: "missing", Command: "true", Path: "/nowhere"}, user)
+	require.Equal(t, -1, run.ExitCode)
+	require.NotEmpty(t, run.Error)
+
+	// a hung command doesn't hold its schedule forever
+	r.Settings.CommandTimeout = "100ms"
+	run = r.RunSchedule(settings.Schedule{Name: "hung", Command: "echo started; exec sleep 10", Path: "/"}, user)
+	require.Equal(t, -1, run.ExitCode)
+	require.Equal(t, "the command timed out after 100ms", run.Error)
+	require.Equal(t, "started\n", run.Output)
+	require.Less(t, run.Finished.Sub(run.Started), 5*time.Second)
+}
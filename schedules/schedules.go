@@ -0,0 +1,17 @@
+package schedules
+
+import "time"
+
+// Run is a run of a scheduled command.
+type Run struct {
+	ID        int       `json:"id" storm:"id,increment"`
+	Schedule  string    `json:"schedule" storm:"index"`
+	Command   string    `json:"command"`
+	Username  string    `json:"username"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	ExitCode  int       `json:"exitCode"` // -1 when the command couldn't be run or was killed
+	Output    string    `json:"output,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"` // when the output was too long
+	Error     string    `json:"error,omitempty"`
+}
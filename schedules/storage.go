@@ -0,0 +1,74 @@
+package schedules
+
+import (
+	"errors"
+	"sort"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// keepRuns is how many of the latest runs of each schedule are kept.
+const keepRuns = 50
+
+// StorageBackend is the interface to implement for a runs storage.
+type StorageBackend interface {
+	FindBySchedule(name string) ([]*Run, error)
+	Save(r *Run) error
+	Delete(id int) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a runs storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Runs returns the latest runs of the schedule, from the newest.
+func (s *Storage) Runs(name string, limit int) ([]*Run, error) {
+	list, err := s.latest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (s *Storage) latest(name string) ([]*Run, error) {
+	list, err := s.back.FindBySchedule(name)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return []*Run{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+// Save saves the run, removing the oldest runs of the schedule.
+func (s *Storage) Save(r *Run) error {
+	if err := s.back.Save(r); err != nil {
+		return err
+	}
+
+	list, err := s.latest(r.Schedule)
+	if err != nil {
+		return err
+	}
+
+	for i := keepRuns; i < len(list); i++ {
+		if err := s.back.Delete(list[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
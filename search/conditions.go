@@ -9,6 +9,7 @@ import (
 
 var (
 	typeRegexp = regexp.MustCompile(`type:(\w+)`)
+	tagRegexp  = regexp.MustCompile(`tag:(\S+)`)
 )
 
 type condition func(path string) bool
@@ -45,6 +46,7 @@ func parseSearch(value string) *searchOptions {
 		CaseSensitive: strings.Contains(value, "case:sensitive"),
 		Conditions:    []condition{},
 		Terms:         []string{},
+		Tags:          []string{},
 	}
 
 	// removes the options from the value
@@ -75,6 +77,16 @@ func parseSearch(value string) *searchOptions {
 		value = typeRegexp.ReplaceAllString(value, "")
 	}
 
+	for _, t := range tagRegexp.FindAllStringSubmatch(value, -1) {
+		opts.Tags = append(opts.Tags, t[1])
+	}
+	value = tagRegexp.ReplaceAllString(value, "")
+
+	if strings.Contains(value, "is:starred") {
+		opts.Starred = true
+		value = strings.ReplaceAll(value, "is:starred", "")
+	}
+
 	// If it's case insensitive, put everything in lowercase.
 	if !opts.CaseSensitive {
 		value = strings.ToLower(value)
@@ -9,16 +9,26 @@ import (
 	"github.com/spf13/afero"
 
 	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/tags"
 )
 
 type searchOptions struct {
 	CaseSensitive bool
 	Conditions    []condition
 	Terms         []string
+	Tags          []string
+	Starred       bool
 }
 
-// Search searches for a query in a fs.
-func Search(fs afero.Fs, scope, query string, checker rules.Checker, found func(path string, f os.FileInfo) error) error {
+// Tagger gives what the user sees of the tags of the files, for the tag:
+// and is:starred terms. It returns nil for the files without tags.
+type Tagger interface {
+	Tags(path string) *tags.Summary
+}
+
+// Search searches for a query in a fs. The files must have all the tags
+// of the tag: terms, and be starred with the is:starred term.
+func Search(fs afero.Fs, scope, query string, checker rules.Checker, tagger Tagger, found func(path string, f os.FileInfo) error) error {
 	search := parseSearch(query)
 
 	scope = filepath.ToSlash(filepath.Clean(scope))
@@ -53,6 +63,12 @@ func Search(fs afero.Fs, scope, query string, checker rules.Checker, found func(
 			}
 		}
 
+		if len(search.Tags) > 0 || search.Starred {
+			if !matchTags(tagger, fPath, search) {
+				return nil
+			}
+		}
+
 		if len(search.Terms) > 0 {
 			for _, term := range search.Terms {
 				_, fileName := path.Split(fPath)
@@ -70,3 +86,22 @@ func Search(fs afero.Fs, scope, query string, checker rules.Checker, found func(
 		return found(relativePath, f)
 	})
 }
+
+func matchTags(tagger Tagger, fPath string, search *searchOptions) bool {
+	if tagger == nil {
+		return false
+	}
+
+	summary := tagger.Tags(fPath)
+	if summary == nil || (search.Starred && !summary.Starred) {
+		return false
+	}
+
+	for _, tag := range search.Tags {
+		if !summary.HasTag(tag) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,25 @@
+package settings
+
+import (
+	"log"
+	"time"
+)
+
+const DefaultCommandTimeout = "5m"
+
+// GetCommandTimeout returns how long the blocking commands of the hooks, the
+// scheduled commands and the commands of the command line can run before
+// being killed.
+func (s *Settings) GetCommandTimeout() time.Duration {
+	fallback, _ := time.ParseDuration(DefaultCommandTimeout)
+	if s.CommandTimeout == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(s.CommandTimeout)
+	if err != nil || duration <= 0 {
+		log.Printf("[WARN] Failed to parse command timeout: %q", s.CommandTimeout)
+		return fallback
+	}
+	return duration
+}
@@ -0,0 +1,13 @@
+package settings
+
+const DefaultExtractMaxSize = 10 * 1024 * 1024 * 1024 // 10GB
+const DefaultExtractMaxEntries = 100000
+const DefaultExtractMaxRatio = 100
+
+// Extract contains the limits applied when extracting archives, to
+// protect the server against archive bombs.
+type Extract struct {
+	MaxSize    uint64 `json:"maxSize"`    // total uncompressed size
+	MaxEntries uint64 `json:"maxEntries"` // number of files and directories
+	MaxRatio   uint64 `json:"maxRatio"`   // uncompressed size / archive size
+}
@@ -0,0 +1,12 @@
+package settings
+
+// Fetch contains the settings of the downloads of URLs to the server.
+//
+// The entries of the host lists are either host names, matching the host
+// and, when starting with "*.", its subdomains, or IP addresses and CIDR
+// ranges, matching the addresses the hosts resolve to. Internal addresses
+// can only be fetched from when an entry of AllowedHosts matches them.
+type Fetch struct {
+	AllowedHosts []string `json:"allowedHosts"` // when set, the only hosts that can be fetched from
+	DeniedHosts  []string `json:"deniedHosts"`
+}
@@ -0,0 +1,26 @@
+package settings
+
+const DefaultSandboxMaxOutput = 10 * 1024 * 1024 // 10MB
+
+// Sandbox contains the restrictions of the commands of the command line,
+// the hooks and the schedules, which run in the scope of the user.
+//
+// The resource limits only apply on Linux, to every process started by the
+// command. The wrapper runs the commands, such as bwrap or nsjail, and its
+// arguments can use the variables of the commands.
+type Sandbox struct {
+	CleanEnv  bool     `json:"cleanEnv"`  // only PATH, LANG and the variables of Env are kept, HOME is the scope
+	Env       []string `json:"env"`       // variables of the server kept in a clean environment
+	CPUTime   uint64   `json:"cpuTime"`   // seconds of CPU time
+	Memory    uint64   `json:"memory"`    // bytes of address space
+	MaxOutput uint64   `json:"maxOutput"` // bytes of output sent by the command line
+	Wrapper   []string `json:"wrapper"`
+}
+
+// GetMaxOutput returns the output limit of the command line.
+func (s Sandbox) GetMaxOutput() uint64 {
+	if s.MaxOutput == 0 {
+		return DefaultSandboxMaxOutput
+	}
+	return s.MaxOutput
+}
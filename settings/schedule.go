@@ -0,0 +1,12 @@
+package settings
+
+// Schedule is a command run at the times of a cron expression, as a user
+// and in a directory of its scope, with the FILE, SCOPE and USERNAME
+// variables of the hooks.
+type Schedule struct {
+	Name     string `json:"name"` // identifies the runs
+	Cron     string `json:"cron"`
+	Command  string `json:"command"`
+	Username string `json:"username"`
+	Path     string `json:"path"` // in the scope of the user, where the command runs
+}
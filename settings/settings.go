@@ -31,13 +31,21 @@ type Settings struct {
 	LogoutPage            string              `json:"logoutPage"`
 	Branding              Branding            `json:"branding"`
 	Tus                   Tus                 `json:"tus"`
-	Commands              map[string][]string `json:"commands"`
+	Extract               Extract             `json:"extract"`
+	Fetch                 Fetch               `json:"fetch"`
+	Commands              map[string][]string `json:"commands"`       // of the hooks by event, the before ones can rewrite the destination of copy and rename only
+	CommandTimeout        string              `json:"commandTimeout"` // of the blocking commands of the hooks and the command line
+	Sandbox               Sandbox             `json:"sandbox"`
+	Schedules             []Schedule          `json:"schedules"`
+	Webhooks              []Webhook           `json:"webhooks"`
 	Shell                 []string            `json:"shell"`
 	Rules                 []rules.Rule        `json:"rules"`
 	MinimumPasswordLength uint                `json:"minimumPasswordLength"`
 	FileMode              fs.FileMode         `json:"fileMode"`
 	DirMode               fs.FileMode         `json:"dirMode"`
 	HideDotfiles          bool                `json:"hideDotfiles"`
+	StripGPSOnShare       bool                `json:"stripGPSOnShare"` // images whose GPS data can't be removed aren't shared
+	UploadChecksum        string              `json:"uploadChecksum"`  // algorithm of the checksums stored for the uploads
 }
 
 // GetRules implements rules.Provider.
@@ -61,6 +69,7 @@ type Server struct {
 	TypeDetectionByHeader bool   `json:"typeDetectionByHeader"`
 	AuthHook              string `json:"authHook"`
 	TokenExpirationTime   string `json:"tokenExpirationTime"`
+	FFmpegPath            string `json:"ffmpegPath"`
 }
 
 // Clean cleans any variables that might need cleaning.
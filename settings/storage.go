@@ -47,6 +47,19 @@ func (s *Storage) Get() (*Settings, error) {
 		set.Tus = Tus{
 			ChunkSize:  DefaultTusChunkSize,
 			RetryCount: DefaultTusRetryCount,
+			Expiration: DefaultTusExpiration,
+		}
+	}
+
+	if set.Tus.Expiration == "" {
+		set.Tus.Expiration = DefaultTusExpiration
+	}
+
+	if set.Extract == (Extract{}) {
+		set.Extract = Extract{
+			MaxSize:    DefaultExtractMaxSize,
+			MaxEntries: DefaultExtractMaxEntries,
+			MaxRatio:   DefaultExtractMaxRatio,
 		}
 	}
 
@@ -67,6 +80,8 @@ var defaultEvents = []string{
 	"rename",
 	"upload",
 	"delete",
+	"chmod",
+	"archive",
 }
 
 // Save saves the settings for the current instance.
@@ -1,10 +1,32 @@
 package settings
 
+import (
+	"log"
+	"time"
+)
+
 const DefaultTusChunkSize = 10 * 1024 * 1024 // 10MB
 const DefaultTusRetryCount = 5
+const DefaultTusExpiration = "24h"
 
 // Tus contains the tus.io settings of the app.
 type Tus struct {
 	ChunkSize  uint64 `json:"chunkSize"`
 	RetryCount uint16 `json:"retryCount"`
+	Expiration string `json:"expiration"` // inactivity after which an incomplete upload is removed
+}
+
+// GetExpiration returns the expiration window of the uploads.
+func (t Tus) GetExpiration() time.Duration {
+	fallback, _ := time.ParseDuration(DefaultTusExpiration)
+	if t.Expiration == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(t.Expiration)
+	if err != nil || duration <= 0 {
+		log.Printf("[WARN] Failed to parse tus expiration: %q", t.Expiration)
+		return fallback
+	}
+	return duration
 }
@@ -0,0 +1,11 @@
+package settings
+
+// Webhook is a URL the events are posted to, as JSON payloads signed with
+// its secret.
+type Webhook struct {
+	Name   string   `json:"name"` // identifies the deliveries
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"` // all of them when empty
+	Path   string   `json:"path"`   // prefix of the paths of the events, from the root
+}
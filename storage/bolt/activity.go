@@ -0,0 +1,38 @@
+package bolt
+
+import (
+	"errors"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/filebrowser/filebrowser/v2/activity"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type activityBackend struct {
+	db *storm.DB
+}
+
+func (s activityBackend) Latest(skip, limit int) ([]*activity.Entry, error) {
+	var v []*activity.Entry
+	err := s.db.Select().OrderBy("ID").Reverse().Skip(skip).Limit(limit).Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s activityBackend) Save(e *activity.Entry) error {
+	return s.db.Save(e)
+}
+
+func (s activityBackend) DeleteBefore(t time.Time) error {
+	err := s.db.Select(q.Lt("Time", t)).Delete(&activity.Entry{})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
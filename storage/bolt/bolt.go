@@ -3,11 +3,21 @@ package bolt
 import (
 	"github.com/asdine/storm/v3"
 
+	"github.com/filebrowser/filebrowser/v2/activity"
 	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/checksums"
+	"github.com/filebrowser/filebrowser/v2/comments"
+	"github.com/filebrowser/filebrowser/v2/delta"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/locks"
+	"github.com/filebrowser/filebrowser/v2/schedules"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/share"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/tags"
+	"github.com/filebrowser/filebrowser/v2/uploads"
 	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
 )
 
 // NewStorage creates a storage.Storage based on Bolt DB.
@@ -16,6 +26,16 @@ func NewStorage(db *storm.DB) (*storage.Storage, error) {
 	shareStore := share.NewStorage(shareBackend{db: db})
 	settingsStore := settings.NewStorage(settingsBackend{db: db})
 	authStore := auth.NewStorage(authBackend{db: db}, userStore)
+	jobsStore := jobs.NewStorage(jobsBackend{db: db})
+	locksStore := locks.NewStorage(locksBackend{db: db})
+	tagsStore := tags.NewStorage(tagsBackend{db: db})
+	commentsStore := comments.NewStorage(commentsBackend{db: db})
+	activityStore := activity.NewStorage(activityBackend{db: db})
+	checksumsStore := checksums.NewStorage(checksumsBackend{db: db})
+	uploadsStore := uploads.NewStorage(uploadsBackend{db: db})
+	deltaStore := delta.NewStorage(deltaBackend{db: db})
+	schedulesStore := schedules.NewStorage(schedulesBackend{db: db})
+	webhooksStore := webhooks.NewStorage(webhooksBackend{db: db})
 
 	err := save(db, "version", 2)
 	if err != nil {
@@ -23,9 +43,19 @@ func NewStorage(db *storm.DB) (*storage.Storage, error) {
 	}
 
 	return &storage.Storage{
-		Auth:     authStore,
-		Users:    userStore,
-		Share:    shareStore,
-		Settings: settingsStore,
+		Auth:      authStore,
+		Users:     userStore,
+		Share:     shareStore,
+		Settings:  settingsStore,
+		Jobs:      jobsStore,
+		Locks:     locksStore,
+		Tags:      tagsStore,
+		Comments:  commentsStore,
+		Activity:  activityStore,
+		Checksums: checksumsStore,
+		Uploads:   uploadsStore,
+		Delta:     deltaStore,
+		Schedules: schedulesStore,
+		Webhooks:  webhooksStore,
 	}, nil
 }
@@ -0,0 +1,46 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	"github.com/filebrowser/filebrowser/v2/checksums"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type checksumsBackend struct {
+	db *storm.DB
+}
+
+func (s checksumsBackend) All() ([]*checksums.Record, error) {
+	var v []*checksums.Record
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s checksumsBackend) Get(path string) (*checksums.Record, error) {
+	var v checksums.Record
+	err := s.db.One("Path", path, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s checksumsBackend) Save(r *checksums.Record) error {
+	return s.db.Save(r)
+}
+
+func (s checksumsBackend) Delete(path string) error {
+	err := s.db.DeleteStruct(&checksums.Record{Path: path})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/filebrowser/filebrowser/v2/comments"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type commentsBackend struct {
+	db *storm.DB
+}
+
+func (s commentsBackend) All() ([]*comments.Comment, error) {
+	var v []*comments.Comment
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s commentsBackend) FindByPath(path string) ([]*comments.Comment, error) {
+	var v []*comments.Comment
+	err := s.db.Select(q.Eq("Path", path)).Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s commentsBackend) Get(id string) (*comments.Comment, error) {
+	var v comments.Comment
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s commentsBackend) Save(c *comments.Comment) error {
+	return s.db.Save(c)
+}
+
+func (s commentsBackend) Delete(id string) error {
+	err := s.db.DeleteStruct(&comments.Comment{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
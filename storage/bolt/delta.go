@@ -0,0 +1,47 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/filebrowser/filebrowser/v2/delta"
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type deltaBackend struct {
+	db *storm.DB
+}
+
+func (s deltaBackend) FindByUserID(id uint) ([]*delta.Snapshot, error) {
+	var v []*delta.Snapshot
+	err := s.db.Select(q.Eq("UserID", id)).Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s deltaBackend) Get(id string) (*delta.Snapshot, error) {
+	var v delta.Snapshot
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s deltaBackend) Save(snap *delta.Snapshot) error {
+	return s.db.Save(snap)
+}
+
+func (s deltaBackend) Delete(id string) error {
+	err := s.db.DeleteStruct(&delta.Snapshot{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
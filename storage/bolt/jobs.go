@@ -0,0 +1,57 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+)
+
+type jobsBackend struct {
+	db *storm.DB
+}
+
+func (s jobsBackend) All() ([]*jobs.Job, error) {
+	var v []*jobs.Job
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s jobsBackend) FindByUserID(id uint) ([]*jobs.Job, error) {
+	var v []*jobs.Job
+	err := s.db.Select(q.Eq("UserID", id)).Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s jobsBackend) Get(id string) (*jobs.Job, error) {
+	var v jobs.Job
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s jobsBackend) Save(j *jobs.Job) error {
+	return s.db.Save(j)
+}
+
+func (s jobsBackend) Delete(id string) error {
+	err := s.db.DeleteStruct(&jobs.Job{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/locks"
+)
+
+type locksBackend struct {
+	db *storm.DB
+}
+
+func (s locksBackend) All() ([]*locks.Lock, error) {
+	var v []*locks.Lock
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s locksBackend) Get(path string) (*locks.Lock, error) {
+	var v locks.Lock
+	err := s.db.One("Path", path, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s locksBackend) Save(l *locks.Lock) error {
+	return s.db.Save(l)
+}
+
+func (s locksBackend) Delete(path string) error {
+	err := s.db.DeleteStruct(&locks.Lock{Path: path})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
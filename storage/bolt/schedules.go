@@ -0,0 +1,32 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/schedules"
+)
+
+type schedulesBackend struct {
+	db *storm.DB
+}
+
+func (s schedulesBackend) FindBySchedule(name string) ([]*schedules.Run, error) {
+	var v []*schedules.Run
+	err := s.db.Find("Schedule", name, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s schedulesBackend) Save(r *schedules.Run) error {
+	return s.db.Save(r)
+}
+
+func (s schedulesBackend) Delete(id int) error {
+	return s.db.DeleteStruct(&schedules.Run{ID: id})
+}
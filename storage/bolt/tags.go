@@ -0,0 +1,57 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/tags"
+)
+
+type tagsBackend struct {
+	db *storm.DB
+}
+
+func (s tagsBackend) All() ([]*tags.Entry, error) {
+	var v []*tags.Entry
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s tagsBackend) FindByUserID(id uint) ([]*tags.Entry, error) {
+	var v []*tags.Entry
+	err := s.db.Select(q.Eq("UserID", id)).Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s tagsBackend) Get(id string) (*tags.Entry, error) {
+	var v tags.Entry
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s tagsBackend) Save(e *tags.Entry) error {
+	return s.db.Save(e)
+}
+
+func (s tagsBackend) Delete(id string) error {
+	err := s.db.DeleteStruct(&tags.Entry{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
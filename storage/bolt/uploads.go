@@ -0,0 +1,46 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/uploads"
+)
+
+type uploadsBackend struct {
+	db *storm.DB
+}
+
+func (s uploadsBackend) All() ([]*uploads.Upload, error) {
+	var v []*uploads.Upload
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s uploadsBackend) Get(path string) (*uploads.Upload, error) {
+	var v uploads.Upload
+	err := s.db.One("Path", path, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s uploadsBackend) Save(u *uploads.Upload) error {
+	return s.db.Save(u)
+}
+
+func (s uploadsBackend) Delete(path string) error {
+	err := s.db.DeleteStruct(&uploads.Upload{Path: path})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package bolt
+
+import (
+	"errors"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
+)
+
+type webhooksBackend struct {
+	db *storm.DB
+}
+
+func (s webhooksBackend) Pending() ([]*webhooks.Delivery, error) {
+	var v []*webhooks.Delivery
+	err := s.db.Select(q.Eq("Status", webhooks.Pending)).OrderBy("ID").Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s webhooksBackend) Latest(webhook string, limit int) ([]*webhooks.Delivery, error) {
+	var v []*webhooks.Delivery
+	err := s.db.Select(q.Eq("Webhook", webhook)).OrderBy("ID").Reverse().Limit(limit).Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s webhooksBackend) Save(d *webhooks.Delivery) error {
+	return s.db.Save(d)
+}
+
+func (s webhooksBackend) DeleteBefore(t time.Time) error {
+	err := s.db.Select(q.Lt("Created", t), q.Not(q.Eq("Status", webhooks.Pending))).Delete(&webhooks.Delivery{})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -1,17 +1,37 @@
 package storage
 
 import (
+	"github.com/filebrowser/filebrowser/v2/activity"
 	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/checksums"
+	"github.com/filebrowser/filebrowser/v2/comments"
+	"github.com/filebrowser/filebrowser/v2/delta"
+	"github.com/filebrowser/filebrowser/v2/jobs"
+	"github.com/filebrowser/filebrowser/v2/locks"
+	"github.com/filebrowser/filebrowser/v2/schedules"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/share"
+	"github.com/filebrowser/filebrowser/v2/tags"
+	"github.com/filebrowser/filebrowser/v2/uploads"
 	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
 )
 
 // Storage is a storage powered by a Backend which makes the necessary
 // verifications when fetching and saving data to ensure consistency.
 type Storage struct {
-	Users    users.Store
-	Share    *share.Storage
-	Auth     *auth.Storage
-	Settings *settings.Storage
+	Users     users.Store
+	Share     *share.Storage
+	Auth      *auth.Storage
+	Settings  *settings.Storage
+	Jobs      *jobs.Storage
+	Locks     *locks.Storage
+	Tags      *tags.Storage
+	Comments  *comments.Storage
+	Activity  *activity.Storage
+	Checksums *checksums.Storage
+	Uploads   *uploads.Storage
+	Delta     *delta.Storage
+	Schedules *schedules.Storage
+	Webhooks  *webhooks.Storage
 }
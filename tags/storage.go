@@ -0,0 +1,132 @@
+package tags
+
+import (
+	"errors"
+	"sync"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// StorageBackend is the interface to implement for a tags storage.
+type StorageBackend interface {
+	All() ([]*Entry, error)
+	FindByUserID(id uint) ([]*Entry, error)
+	Get(id string) (*Entry, error)
+	Save(e *Entry) error
+	Delete(id string) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+	mu   sync.Mutex
+}
+
+// NewStorage creates a tags storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get returns the entry of the user for the path, the shared one if the
+// user ID is zero. An empty entry is returned if there is none.
+func (s *Storage) Get(userID uint, path string) (*Entry, error) {
+	e, err := s.back.Get(entryID(userID, path))
+	if errors.Is(err, fberrors.ErrNotExist) {
+		e = &Entry{Path: path, UserID: userID}
+		e.normalize()
+		return e, nil
+	}
+
+	return e, err
+}
+
+// Save saves the entry, removing it if it's empty.
+func (s *Storage) Save(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.normalize()
+	if e.Empty() {
+		return s.back.Delete(e.ID)
+	}
+	return s.back.Save(e)
+}
+
+// Summaries returns what the user sees of the tagged files, by path.
+func (s *Storage) Summaries(userID uint) (map[string]*Summary, error) {
+	entries, err := s.back.FindByUserID(0)
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return nil, err
+	}
+
+	if userID != 0 {
+		own, err := s.back.FindByUserID(userID)
+		if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+			return nil, err
+		}
+		entries = append(entries, own...)
+	}
+
+	summaries := make(map[string]*Summary, len(entries))
+	for _, e := range entries {
+		summary, ok := summaries[e.Path]
+		if !ok {
+			summary = &Summary{}
+			summaries[e.Path] = summary
+		}
+		summary.add(e)
+	}
+
+	return summaries, nil
+}
+
+// Move moves the entries of the path and of everything in it to their new
+// path, as when it is renamed.
+func (s *Storage) Move(src, dst string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return err
+	}
+
+	for _, e := range entries {
+		if !within(e.Path, src) {
+			continue
+		}
+
+		if err := s.back.Delete(e.ID); err != nil {
+			return err
+		}
+		e.Path = dst + e.Path[len(src):]
+		e.normalize()
+		if err := s.back.Save(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteWithin removes the entries of the path and of everything in it, as
+// when it is deleted.
+func (s *Storage) DeleteWithin(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return err
+	}
+
+	for _, e := range entries {
+		if within(e.Path, path) {
+			if err := s.back.Delete(e.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
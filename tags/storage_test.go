@@ -0,0 +1,95 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type memoryBackend struct {
+	entries map[string]Entry
+}
+
+func (b *memoryBackend) All() ([]*Entry, error) {
+	list := []*Entry{}
+	for _, e := range b.entries {
+		e := e
+		list = append(list, &e)
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) FindByUserID(id uint) ([]*Entry, error) {
+	all, _ := b.All()
+	list := []*Entry{}
+	for _, e := range all {
+		if e.UserID == id {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Get(id string) (*Entry, error) {
+	e, ok := b.entries[id]
+	if !ok {
+		return nil, fberrors.ErrNotExist
+	}
+	return &e, nil
+}
+
+func (b *memoryBackend) Save(e *Entry) error {
+	b.entries[e.ID] = *e
+	return nil
+}
+
+func (b *memoryBackend) Delete(id string) error {
+	delete(b.entries, id)
+	return nil
+}
+
+func TestStorage(t *testing.T) {
+	back := &memoryBackend{entries: map[string]Entry{}}
+	s := NewStorage(back)
+
+	require.NoError(t, s.Save(&Entry{
+		Path:     "/srv/docs/a.txt",
+		UserID:   1,
+		Tags:     []string{" work ", "draft", "work", ""},
+		Starred:  true,
+		Metadata: map[string]string{"status": "mine"},
+	}))
+	require.NoError(t, s.Save(&Entry{
+		Path:     "/srv/docs/a.txt",
+		Tags:     []string{"team"},
+		Starred:  true,
+		Metadata: map[string]string{"status": "shared", "owner": "ops"},
+	}))
+	require.NoError(t, s.Save(&Entry{Path: "/srv/b.txt", UserID: 2, Starred: true}))
+
+	summaries, err := s.Summaries(1)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	summary := summaries["/srv/docs/a.txt"]
+	require.Equal(t, []string{"draft", "work"}, summary.Tags)
+	require.Equal(t, []string{"team"}, summary.SharedTags)
+	require.True(t, summary.Starred)
+	require.True(t, summary.HasTag("TEAM"))
+	require.Equal(t, map[string]string{"status": "mine", "owner": "ops"}, summary.Metadata)
+
+	shared, err := s.Get(0, "/srv/docs/a.txt")
+	require.NoError(t, err)
+	require.False(t, shared.Starred)
+
+	require.NoError(t, s.Move("/srv/docs", "/srv/papers"))
+	entry, err := s.Get(1, "/srv/papers/a.txt")
+	require.NoError(t, err)
+	require.True(t, entry.Starred)
+
+	// saving an empty entry removes it
+	require.NoError(t, s.Save(&Entry{Path: "/srv/b.txt", UserID: 2}))
+	require.NoError(t, s.DeleteWithin("/srv/papers"))
+	require.Empty(t, back.entries)
+}
@@ -0,0 +1,106 @@
+package tags
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Entry holds the tags, the star and the custom metadata of a file, either
+// for a user or shared by all of them.
+type Entry struct {
+	ID       string            `json:"-" storm:"id"`
+	Path     string            `json:"path" storm:"index"`   // full path on the server
+	UserID   uint              `json:"userID" storm:"index"` // zero for the shared entries
+	Tags     []string          `json:"tags"`
+	Starred  bool              `json:"starred"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Shared reports whether the entry is shared by all the users.
+func (e *Entry) Shared() bool {
+	return e.UserID == 0
+}
+
+// Empty reports whether the entry holds nothing.
+func (e *Entry) Empty() bool {
+	return len(e.Tags) == 0 && !e.Starred && len(e.Metadata) == 0
+}
+
+func entryID(userID uint, path string) string {
+	return fmt.Sprintf("%d:%s", userID, path)
+}
+
+// normalize trims the tags and the metadata keys, removing the empty and
+// duplicated ones. The shared entries can't be starred.
+func (e *Entry) normalize() {
+	tags := make([]string, 0, len(e.Tags))
+	for _, tag := range e.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	e.Tags = tags
+
+	metadata := make(map[string]string, len(e.Metadata))
+	for key, value := range e.Metadata {
+		if key = strings.TrimSpace(key); key != "" {
+			metadata[key] = value
+		}
+	}
+	e.Metadata = metadata
+
+	if e.Shared() {
+		e.Starred = false
+	}
+	e.ID = entryID(e.UserID, e.Path)
+}
+
+// Summary is what a user sees of a file: their entry merged with the
+// shared one.
+type Summary struct {
+	Tags       []string          `json:"tags,omitempty"`
+	SharedTags []string          `json:"sharedTags,omitempty"`
+	Starred    bool              `json:"starred,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"` // the values of the user override the shared ones
+}
+
+// HasTag reports whether the file has the tag, of the user or shared, the
+// case being ignored.
+func (s *Summary) HasTag(tag string) bool {
+	for _, t := range slices.Concat(s.Tags, s.SharedTags) {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Summary) add(e *Entry) {
+	if e.Shared() {
+		s.SharedTags = e.Tags
+	} else {
+		s.Tags = e.Tags
+		s.Starred = e.Starred
+	}
+
+	if len(e.Metadata) == 0 {
+		return
+	}
+	if s.Metadata == nil {
+		s.Metadata = map[string]string{}
+	}
+	for key, value := range e.Metadata {
+		if _, ok := s.Metadata[key]; !ok || !e.Shared() {
+			s.Metadata[key] = value
+		}
+	}
+}
+
+// within reports whether p is dir or is inside it.
+func within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
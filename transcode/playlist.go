@@ -0,0 +1,86 @@
+package transcode
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// SegmentDuration is the length in seconds of every HLS segment.
+const SegmentDuration = 6.0
+
+// Rendition is one step of the bitrate ladder.
+type Rendition struct {
+	Name         string `json:"name"`
+	Height       int    `json:"height"`
+	VideoBitrate int    `json:"videoBitrate"` // kbit/s
+	AudioBitrate int    `json:"audioBitrate"` // kbit/s
+}
+
+// Ladder lists the renditions offered to the clients, from the lowest to
+// the highest quality.
+var Ladder = []Rendition{
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 160},
+}
+
+// Renditions returns the renditions that make sense for a source of the
+// given height: there is no point in upscaling. The lowest rendition is
+// always included, and every rendition is returned if the height is unknown.
+func Renditions(height int) []Rendition {
+	if height <= 0 {
+		return Ladder
+	}
+
+	renditions := []Rendition{Ladder[0]}
+	for _, rendition := range Ladder[1:] {
+		if rendition.Height <= height {
+			renditions = append(renditions, rendition)
+		}
+	}
+	return renditions
+}
+
+// FindRendition returns the rendition of the ladder with the given name.
+func FindRendition(name string) (Rendition, bool) {
+	for _, rendition := range Ladder {
+		if rendition.Name == name {
+			return rendition, true
+		}
+	}
+	return Rendition{}, false
+}
+
+// Segments returns the number of segments of a media of the given duration.
+func Segments(duration float64) int {
+	return int(math.Ceil(duration / SegmentDuration))
+}
+
+// MasterPlaylist returns the multivariant playlist listing the renditions.
+// uri returns the location of the media playlist of a rendition.
+func MasterPlaylist(renditions []Rendition, uri func(Rendition) string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, rendition := range renditions {
+		bandwidth := (rendition.VideoBitrate + rendition.AudioBitrate) * 1000
+		fmt.Fprintf(&buf, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,NAME=%q\n",
+			bandwidth, rendition.Height*16/9, rendition.Height, rendition.Name)
+		buf.WriteString(uri(rendition) + "\n")
+	}
+	return buf.Bytes()
+}
+
+// MediaPlaylist returns the VOD playlist of a rendition of a media of the
+// given duration. uri returns the location of a segment.
+func MediaPlaylist(duration float64, uri func(index int) string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n", int(SegmentDuration))
+	buf.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+	for i := 0; i < Segments(duration); i++ {
+		length := math.Min(SegmentDuration, duration-float64(i)*SegmentDuration)
+		fmt.Fprintf(&buf, "#EXTINF:%.3f,\n%s\n", length, uri(i))
+	}
+	buf.WriteString("#EXT-X-ENDLIST\n")
+	return buf.Bytes()
+}
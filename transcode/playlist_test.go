@@ -0,0 +1,51 @@
+package transcode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenditions(t *testing.T) {
+	names := func(renditions []Rendition) []string {
+		var names []string
+		for _, rendition := range renditions {
+			names = append(names, rendition.Name)
+		}
+		return names
+	}
+
+	require.Equal(t, []string{"360p", "720p", "1080p"}, names(Renditions(0)))
+	require.Equal(t, []string{"360p", "720p", "1080p"}, names(Renditions(2160)))
+	require.Equal(t, []string{"360p", "720p"}, names(Renditions(720)))
+	require.Equal(t, []string{"360p"}, names(Renditions(240)))
+
+	// the ladder itself must not be modified
+	require.Equal(t, "720p", Ladder[1].Name)
+}
+
+func TestMediaPlaylist(t *testing.T) {
+	playlist := string(MediaPlaylist(14, func(index int) string {
+		return fmt.Sprintf("segment-%d.ts", index)
+	}))
+
+	require.Equal(t, 3, Segments(14))
+	require.True(t, strings.HasPrefix(playlist, "#EXTM3U\n"))
+	require.Contains(t, playlist, "#EXT-X-TARGETDURATION:6\n")
+	require.Contains(t, playlist, "#EXTINF:6.000,\nsegment-0.ts\n")
+	require.Contains(t, playlist, "#EXTINF:6.000,\nsegment-1.ts\n")
+	require.Contains(t, playlist, "#EXTINF:2.000,\nsegment-2.ts\n")
+	require.True(t, strings.HasSuffix(playlist, "#EXT-X-ENDLIST\n"))
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	playlist := string(MasterPlaylist(Renditions(720), func(rendition Rendition) string {
+		return rendition.Name + ".m3u8"
+	}))
+
+	require.Contains(t, playlist, "#EXT-X-STREAM-INF:BANDWIDTH=896000,RESOLUTION=640x360,NAME=\"360p\"\n360p.m3u8\n")
+	require.Contains(t, playlist, "#EXT-X-STREAM-INF:BANDWIDTH=2928000,RESOLUTION=1280x720,NAME=\"720p\"\n720p.m3u8\n")
+	require.NotContains(t, playlist, "1080p")
+}
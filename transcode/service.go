@@ -0,0 +1,152 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"github.com/marusama/semaphore/v2"
+)
+
+// ErrDisabled means no ffmpeg binary was configured.
+var ErrDisabled = errors.New("transcoding is disabled")
+
+// ErrUnknownDuration means the duration of the input couldn't be determined.
+var ErrUnknownDuration = errors.New("unknown media duration")
+
+var (
+	durationRe = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+(?:\.\d+)?)`)
+	videoRe    = regexp.MustCompile(`Stream #.*Video: .*?, (\d{2,5})x(\d{2,5})`)
+)
+
+// Service runs ffmpeg to transcode media into HLS segments.
+type Service struct {
+	ffmpeg string
+	sem    semaphore.Semaphore
+}
+
+// New creates a transcoding service using the given ffmpeg binary. At most
+// workers ffmpeg processes run at the same time. An empty path disables
+// transcoding.
+func New(ffmpeg string, workers int) *Service {
+	return &Service{
+		ffmpeg: ffmpeg,
+		sem:    semaphore.New(workers),
+	}
+}
+
+// Enabled reports whether ffmpeg is available.
+func (s *Service) Enabled() bool {
+	return s.ffmpeg != ""
+}
+
+// Probe returns the duration in seconds and the frame height of the media
+// at the given path, as reported by ffmpeg.
+func (s *Service) Probe(ctx context.Context, input string) (duration float64, height int, err error) {
+	if !s.Enabled() {
+		return 0, 0, ErrDisabled
+	}
+
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return 0, 0, err
+	}
+	defer s.sem.Release(1)
+
+	// ffmpeg exits with an error when no output is given, but it still
+	// prints the information about the input.
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.ffmpeg, "-hide_banner", "-nostdin", "-i", input)
+	cmd.Stderr = &stderr
+	_ = cmd.Run()
+
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	match := durationRe.FindSubmatch(stderr.Bytes())
+	if match == nil {
+		return 0, 0, ErrUnknownDuration
+	}
+	hours, _ := strconv.Atoi(string(match[1]))
+	minutes, _ := strconv.Atoi(string(match[2]))
+	seconds, _ := strconv.ParseFloat(string(match[3]), 64)
+	duration = float64(hours*3600+minutes*60) + seconds
+
+	if match := videoRe.FindSubmatch(stderr.Bytes()); match != nil {
+		height, _ = strconv.Atoi(string(match[2]))
+	}
+
+	return duration, height, nil
+}
+
+// Segment transcodes the segment number index of the input to the given
+// rendition and writes it to out as an MPEG-TS stream. ffmpeg writes to a
+// temporary file that is always removed, so cancelling the context (e.g.
+// because the client went away) kills the process and leaves nothing behind.
+func (s *Service) Segment(ctx context.Context, input string, rendition Rendition, index int, out io.Writer) error {
+	if !s.Enabled() {
+		return ErrDisabled
+	}
+
+	if err := s.sem.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.sem.Release(1)
+
+	tmp, err := os.CreateTemp("", "filebrowser-hls-*.ts")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.ffmpeg, segmentArgs(input, tmp.Name(), rendition, index)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	fd, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	_, err = io.Copy(out, fd)
+	return err
+}
+
+func segmentArgs(input, output string, rendition Rendition, index int) []string {
+	start := float64(index) * SegmentDuration
+	seconds := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+
+	return []string{
+		"-hide_banner", "-nostdin", "-loglevel", "error",
+		"-ss", seconds(start),
+		"-i", input,
+		"-t", seconds(SegmentDuration),
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:%d", rendition.Height),
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main", "-pix_fmt", "yuv420p",
+		"-b:v", fmt.Sprintf("%dk", rendition.VideoBitrate),
+		"-maxrate", fmt.Sprintf("%dk", rendition.VideoBitrate*3/2),
+		"-bufsize", fmt.Sprintf("%dk", rendition.VideoBitrate*2),
+		"-c:a", "aac", "-ac", "2",
+		"-b:a", fmt.Sprintf("%dk", rendition.AudioBitrate),
+		"-output_ts_offset", seconds(start),
+		"-muxdelay", "0",
+		"-f", "mpegts", "-y", output,
+	}
+}
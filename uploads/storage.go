@@ -0,0 +1,57 @@
+package uploads
+
+import (
+	"errors"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// StorageBackend is the interface to implement for an uploads storage.
+type StorageBackend interface {
+	All() ([]*Upload, error)
+	Get(path string) (*Upload, error)
+	Save(u *Upload) error
+	Delete(path string) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates an uploads storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(path string) (*Upload, error) {
+	return s.back.Get(path)
+}
+
+// Save wraps a StorageBackend.Save.
+func (s *Storage) Save(u *Upload) error {
+	return s.back.Save(u)
+}
+
+// Delete wraps a StorageBackend.Delete.
+func (s *Storage) Delete(path string) error {
+	return s.back.Delete(path)
+}
+
+// Expired returns the uploads that expired by now.
+func (s *Storage) Expired(now time.Time) ([]*Upload, error) {
+	all, err := s.back.All()
+	if err != nil && !errors.Is(err, fberrors.ErrNotExist) {
+		return nil, err
+	}
+
+	list := []*Upload{}
+	for _, u := range all {
+		if u.Expired(now) {
+			list = append(list, u)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,68 @@
+package uploads
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+type memoryBackend struct {
+	uploads map[string]Upload
+}
+
+func (b *memoryBackend) All() ([]*Upload, error) {
+	list := []*Upload{}
+	for _, u := range b.uploads {
+		u := u
+		list = append(list, &u)
+	}
+	return list, nil
+}
+
+func (b *memoryBackend) Get(path string) (*Upload, error) {
+	u, ok := b.uploads[path]
+	if !ok {
+		return nil, fberrors.ErrNotExist
+	}
+	return &u, nil
+}
+
+func (b *memoryBackend) Save(u *Upload) error {
+	b.uploads[u.Path] = *u
+	return nil
+}
+
+func (b *memoryBackend) Delete(path string) error {
+	delete(b.uploads, path)
+	return nil
+}
+
+func TestExpired(t *testing.T) {
+	s := NewStorage(&memoryBackend{uploads: map[string]Upload{}})
+	now := time.Now()
+
+	require.NoError(t, s.Save(&Upload{Path: "/srv/old.bin", Length: 10, Expires: now.Add(-time.Minute)}))
+	require.NoError(t, s.Save(&Upload{Path: "/srv/new.bin", Length: 10, Expires: now.Add(time.Minute)}))
+
+	list, err := s.Expired(now)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "/srv/old.bin", list[0].Path)
+
+	require.NoError(t, s.Delete("/srv/old.bin"))
+	_, err = s.Get("/srv/old.bin")
+	require.ErrorIs(t, err, fberrors.ErrNotExist)
+}
+
+func TestID(t *testing.T) {
+	id, err := NewID()
+	require.NoError(t, err)
+	require.True(t, ValidID(id))
+
+	require.False(t, ValidID(""))
+	require.False(t, ValidID("../../etc/passwd"))
+	require.False(t, ValidID(id+"00"))
+}
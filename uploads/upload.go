@@ -0,0 +1,39 @@
+package uploads
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+const idLength = 8
+
+// Upload is a tus upload that has been created but not completed yet.
+type Upload struct {
+	Path    string    `json:"path" storm:"id"` // full path on the server of the file being written
+	UserID  uint      `json:"userID"`
+	Length  int64     `json:"length"`
+	Partial bool      `json:"partial"` // a part of an upload to be concatenated
+	Sha256  string    `json:"sha256"`  // declared by the client, verified once complete
+	Expires time.Time `json:"expires"`
+}
+
+// Expired reports whether the upload was left inactive past its expiration.
+func (u *Upload) Expired(now time.Time) bool {
+	return !u.Expires.IsZero() && now.After(u.Expires)
+}
+
+// NewID returns a random identifier for a partial upload.
+func NewID() (string, error) {
+	b := make([]byte, idLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidID reports whether id may have been returned by NewID.
+func ValidID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == idLength
+}
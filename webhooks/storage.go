@@ -0,0 +1,84 @@
+package webhooks
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	fberrors "github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// Retention is how long the finished deliveries are kept.
+const Retention = 30 * 24 * time.Hour
+
+// pruneInterval is how often the old deliveries are removed.
+const pruneInterval = time.Hour
+
+// StorageBackend is the interface to implement for a deliveries storage.
+type StorageBackend interface {
+	Pending() ([]*Delivery, error)
+	// Latest returns the deliveries of the webhook from the newest.
+	Latest(webhook string, limit int) ([]*Delivery, error)
+	Save(d *Delivery) error
+	// DeleteBefore deletes the finished deliveries created before t.
+	DeleteBefore(t time.Time) error
+}
+
+// Storage is a storage.
+type Storage struct {
+	back StorageBackend
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// NewStorage creates a deliveries storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Pending returns the deliveries left to be sent.
+func (s *Storage) Pending() ([]*Delivery, error) {
+	list, err := s.back.Pending()
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return []*Delivery{}, nil
+	}
+	return list, err
+}
+
+// Latest wraps a StorageBackend.Latest.
+func (s *Storage) Latest(webhook string, limit int) ([]*Delivery, error) {
+	list, err := s.back.Latest(webhook, limit)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return []*Delivery{}, nil
+	}
+	return list, err
+}
+
+// Save saves the delivery, removing the deliveries older than the
+// retention from time to time.
+func (s *Storage) Save(d *Delivery) error {
+	if d.Created.IsZero() {
+		d.Created = time.Now()
+	}
+
+	if err := s.back.Save(d); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	prune := time.Since(s.lastPruned) >= pruneInterval
+	if prune {
+		s.lastPruned = time.Now()
+	}
+	s.mu.Unlock()
+
+	if prune {
+		if err := s.back.DeleteBefore(time.Now().Add(-Retention)); err != nil {
+			log.Printf("WARNING: couldn't remove the old webhook deliveries: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Statuses of the deliveries.
+const (
+	Pending   = "pending"
+	Delivered = "delivered"
+	Failed    = "failed"
+)
+
+// MaxAttempts is how many times a delivery is attempted before failing.
+const MaxAttempts = 8
+
+// Delivery is a payload sent, or to be sent, to a webhook.
+type Delivery struct {
+	ID           int             `json:"id" storm:"id,increment"`
+	Webhook      string          `json:"webhook" storm:"index"`
+	Event        string          `json:"event"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status" storm:"index"`
+	Attempts     int             `json:"attempts"`
+	NextAttempt  time.Time       `json:"nextAttempt"`
+	ResponseCode int             `json:"responseCode,omitempty"` // of the last attempt
+	Error        string          `json:"error,omitempty"`        // of the last attempt
+	Created      time.Time       `json:"created"`
+}
+
+// Backoff returns how long to wait before attempting again a delivery
+// which failed the given number of times.
+func Backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// Signature returns the signature of the payload sent in the
+// X-Filebrowser-Signature header, the hexadecimal HMAC-SHA256 of the body
+// keyed with the secret of the webhook, prefixed with "sha256=".
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}